@@ -0,0 +1,122 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+)
+
+const (
+	// VerifyPath is the path of the user service endpoint that verifies API keys
+	VerifyPath = "/api/v1/api-keys/verify"
+	// defaultCacheTTL is how long a verified key is trusted before it is verified again
+	defaultCacheTTL = 30 * time.Second
+	// verifyTimeout bounds verifying a key
+	verifyTimeout = 10 * time.Second
+)
+
+// verifyRequest is the request body of the verify endpoint
+type verifyRequest struct {
+	Key string `json:"key"`
+}
+
+// cachedClaims are the claims of a verified key together with when they stop being trusted
+type cachedClaims struct {
+	claims    *middleware.UserClaims
+	expiresAt time.Time
+}
+
+// Client verifies API keys with the user service and caches the claims of verified keys for a
+// short time, so that not every request makes a call to the user service. A revoked key is
+// accepted until its cached claims expire. It implements middleware.APIKeyVerifier.
+type Client struct {
+	client *commonhttp.Client
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cachedClaims
+}
+
+// NewClient creates a new client for the user service at baseURL that caches verified keys
+// for ttl. A zero ttl uses a default of 30 seconds.
+func NewClient(baseURL string, ttl time.Duration) *Client {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+
+	return &Client{
+		client: commonhttp.NewClient(baseURL, verifyTimeout),
+		ttl:    ttl,
+		cache:  make(map[[sha256.Size]byte]cachedClaims),
+	}
+}
+
+// VerifyAPIKey returns the claims of the user of an API key. It returns
+// middleware.ErrInvalidAPIKey when the user service rejects the key.
+func (c *Client) VerifyAPIKey(ctx context.Context, key string) (*middleware.UserClaims, error) {
+	// Only the hash of the key is kept in memory
+	id := sha256.Sum256([]byte(key))
+	now := time.Now()
+
+	c.mu.Lock()
+	cached, ok := c.cache[id]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.claims, nil
+	}
+
+	var claims middleware.UserClaims
+	if err := c.client.Post(ctx, VerifyPath, verifyRequest{Key: key}, &claims); err != nil {
+		var statusErr *commonhttp.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusUnauthorized {
+			return nil, middleware.ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("failed to verify API key: %w", err)
+	}
+
+	expiresAt := now.Add(c.ttl)
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Time.Before(expiresAt) {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	c.mu.Lock()
+	c.cache[id] = cachedClaims{claims: &claims, expiresAt: expiresAt}
+	c.mu.Unlock()
+
+	return &claims, nil
+}
+
+// RunCleanup drops the expired claims from the cache periodically until the context is
+// cancelled
+func (c *Client) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.prune(time.Now())
+		}
+	}
+}
+
+// prune removes the expired entries of the cache
+func (c *Client) prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, cached := range c.cache {
+		if !now.Before(cached.expiresAt) {
+			delete(c.cache, id)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionUserUpdate         = "user.update"
+	ActionUserDelete         = "user.delete"
+	ActionUserRoleAdd        = "user.role_add"
+	ActionUserRoleRemove     = "user.role_remove"
+	ActionUserPasswordChange = "user.password_change"
+	ActionUserPasswordReset  = "user.password_reset"
+	ActionUserUnlock         = "user.unlock"
+	ActionUserMFARequired    = "user.mfa_required"
+	ActionUserMFADisable     = "user.mfa_disable"
+	ActionRoleCreate         = "role.create"
+	ActionRolePermissions    = "role.permissions_change"
+	ActionAPIKeyCreate       = "api_key.create"
+	ActionAPIKeyRevoke       = "api_key.revoke"
+	ActionGroupMappingAdd    = "group_mapping.add"
+	ActionGroupMappingRemove = "group_mapping.remove"
+	ActionOrgCreate          = "organization.create"
+	ActionOrgRename          = "organization.rename"
+	ActionOrgMemberAdd       = "organization.member_add"
+	ActionOrgMemberUpdate    = "organization.member_update"
+	ActionOrgMemberRemove    = "organization.member_remove"
+	ActionSurveyCreate       = "survey.create"
+	ActionSurveyUpdate       = "survey.update"
+	ActionSurveyDelete       = "survey.delete"
+	ActionSurveyStatus       = "survey.status_change"
+	ActionSurveyTransfer     = "survey.transfer"
+	ActionCollaboratorInvite = "survey.collaborator_invite"
+	ActionCollaboratorRevoke = "survey.collaborator_revoke"
+	ActionResultsExport      = "results.export"
+	ActionExportDownload     = "results.export_download"
+)
+
+// Event records an action of an actor on a target resource
+type Event struct {
+	ID         string    `json:"id" db:"id"`
+	OccurredAt time.Time `json:"occurred_at" db:"occurred_at"`
+	// Service is the service that recorded the event
+	Service string `json:"service" db:"service"`
+	// ActorID is the user who acted, empty for anonymous requests
+	ActorID   string `json:"actor_id,omitempty" db:"actor_id"`
+	ActorName string `json:"actor_name,omitempty" db:"actor_name"`
+	// APIKeyID is the API key the actor used, if any
+	APIKeyID   string  `json:"api_key_id,omitempty" db:"api_key_id"`
+	Action     string  `json:"action" db:"action"`
+	TargetType string  `json:"target_type" db:"target_type"`
+	TargetID   string  `json:"target_id" db:"target_id"`
+	RequestID  string  `json:"request_id,omitempty" db:"request_id"`
+	IP         string  `json:"ip,omitempty" db:"ip"`
+	Changes    Changes `json:"changes,omitempty" db:"changes"`
+}
+
+// Change is the value of a field before and after an action
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Changes maps the fields an action changed to their change. It is stored as JSON.
+type Changes map[string]Change
+
+// Value implements driver.Valuer
+func (c Changes) Value() (driver.Value, error) {
+	if len(c) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// Scan implements sql.Scanner
+func (c *Changes) Scan(value interface{}) error {
+	if value == nil {
+		*c = nil
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Changes", value)
+	}
+
+	return json.Unmarshal(data, c)
+}
+
+// Diff returns the fields that differ between the JSON representations of two values, so that
+// fields hidden from JSON, such as password hashes, are never recorded. A nil before or after
+// value records the creation or deletion of a resource with all of its fields.
+func Diff(before, after interface{}) Changes {
+	beforeFields := fields(before)
+	afterFields := fields(after)
+
+	changes := Changes{}
+	for name, value := range beforeFields {
+		if other, ok := afterFields[name]; !ok || !reflect.DeepEqual(value, other) {
+			changes[name] = Change{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			changes[name] = Change{After: value}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+// fields returns the JSON fields of a value. A value that is not a JSON object is a single
+// field named value.
+func fields(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return map[string]interface{}{"value": fmt.Sprint(v)}
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err == nil {
+		return object
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil || value == nil {
+		return nil
+	}
+	return map[string]interface{}{"value": value}
+}
@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testUser struct {
+	ID           string   `json:"id"`
+	Email        string   `json:"email"`
+	PasswordHash string   `json:"-"`
+	Roles        []string `json:"roles"`
+	Active       bool     `json:"active"`
+}
+
+func TestDiff(t *testing.T) {
+	user := &testUser{ID: "user-1", Email: "ada@example.com", PasswordHash: "hash", Roles: []string{"user"}, Active: true}
+	var missing *testUser
+
+	tests := []struct {
+		name   string
+		before interface{}
+		after  interface{}
+		want   Changes
+	}{
+		{"unchanged", user, &testUser{ID: "user-1", Email: "ada@example.com", PasswordHash: "hash", Roles: []string{"user"}, Active: true}, nil},
+		{"hidden fields are not recorded", user, &testUser{ID: "user-1", Email: "ada@example.com", PasswordHash: "other", Roles: []string{"user"}, Active: true}, nil},
+		{"changed fields", user, &testUser{ID: "user-1", Email: "ada@example.org", Roles: []string{"user", "admin"}, Active: true}, Changes{
+			"email": {Before: "ada@example.com", After: "ada@example.org"},
+			"roles": {Before: []interface{}{"user"}, After: []interface{}{"user", "admin"}},
+		}},
+		{"creation", nil, &testUser{ID: "user-1", Active: true}, Changes{
+			"id":     {After: "user-1"},
+			"email":  {After: ""},
+			"roles":  {After: nil},
+			"active": {After: true},
+		}},
+		{"deletion through a nil pointer", &testUser{ID: "user-1"}, missing, Changes{
+			"id":     {Before: "user-1"},
+			"email":  {Before: ""},
+			"roles":  {Before: nil},
+			"active": {Before: false},
+		}},
+		{"maps", map[string]interface{}{"name": "Team", "size": 3}, map[string]interface{}{"name": "Team", "plan": "pro"}, Changes{
+			"size": {Before: float64(3)},
+			"plan": {After: "pro"},
+		}},
+		{"values that are not objects", []string{"survey:read"}, []string{"survey:read", "survey:edit"}, Changes{
+			"value": {Before: []interface{}{"survey:read"}, After: []interface{}{"survey:read", "survey:edit"}},
+		}},
+		{"scalars", "draft", "published", Changes{"value": {Before: "draft", After: "published"}}},
+		{"nothing", nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangesRoundTrip(t *testing.T) {
+	changes := Changes{"email": {Before: "ada@example.com", After: "ada@example.org"}}
+
+	value, err := changes.Value()
+	if err != nil {
+		t.Fatalf("Value returned error: %v", err)
+	}
+
+	var scanned Changes
+	if err := scanned.Scan(value); err != nil {
+		t.Fatalf("Scan returned error: %v", err)
+	}
+	if !reflect.DeepEqual(scanned, changes) {
+		t.Errorf("scanned %#v, want %#v", scanned, changes)
+	}
+
+	if value, err := (Changes{}).Value(); value != nil || err != nil {
+		t.Errorf("Value() of no changes = %v, %v, want NULL", value, err)
+	}
+	if err := scanned.Scan(nil); err != nil || scanned != nil {
+		t.Errorf("Scan(nil) = %v, %#v, want no changes", err, scanned)
+	}
+	if err := scanned.Scan(42); err == nil {
+		t.Error("Scan accepted an integer")
+	}
+}
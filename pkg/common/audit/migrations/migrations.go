@@ -0,0 +1,9 @@
+// Package migrations embeds the migrations of the audit database
+package migrations
+
+import "embed"
+
+// FS holds the migration files
+//
+//go:embed *.sql
+var FS embed.FS
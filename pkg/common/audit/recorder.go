@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	chimw "github.com/go-chi/chi/v5/middleware"
+)
+
+// appendTimeout bounds the write of an event, which must not hold up a request for long
+const appendTimeout = 5 * time.Second
+
+// Recorder records the actions of the requests to a service
+type Recorder struct {
+	store   *Store
+	service string
+	onError func(error)
+}
+
+// NewRecorder creates a new recorder for a service. Recording is best effort: an event that
+// cannot be written is passed to onError instead of failing the action it records.
+func NewRecorder(store *Store, service string, onError func(error)) *Recorder {
+	if onError == nil {
+		onError = func(error) {}
+	}
+	return &Recorder{
+		store:   store,
+		service: service,
+		onError: onError,
+	}
+}
+
+// Record records an action on a target with the fields that changed between before and after.
+// The actor, request ID and IP address are taken from the request, which must have passed the
+// RequestID and RealIP middleware to carry them.
+func (r *Recorder) Record(req *http.Request, action, targetType, targetID string, before, after interface{}) {
+	event := &Event{
+		Service:    r.service,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		RequestID:  chimw.GetReqID(req.Context()),
+		IP:         commonhttp.ClientIP(req),
+		Changes:    Diff(before, after),
+	}
+	if user, err := middleware.GetUserFromContext(req.Context()); err == nil {
+		event.ActorID = user.UserID
+		event.ActorName = user.Username
+		event.APIKeyID = user.APIKeyID
+	}
+
+	// The event is written even if the client went away after the action was done
+	ctx, cancel := context.WithTimeout(context.Background(), appendTimeout)
+	defer cancel()
+
+	if err := r.store.Append(ctx, event); err != nil {
+		r.onError(err)
+	}
+}
@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit/migrations"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/database"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// DefaultLimit is the number of events in a page if the filter does not set a limit
+	DefaultLimit = 50
+	// MaxLimit is the largest number of events in a page
+	MaxLimit = 500
+)
+
+// ErrInvalidCursor is returned for a cursor that was not returned by Query
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// eventColumns are the columns selected for an event
+const eventColumns = `
+	id, occurred_at, service, actor_id, actor_name, api_key_id, action, target_type, target_id, request_id, ip, changes
+`
+
+// Filter selects the events returned by Query. Empty fields match every event.
+type Filter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	Service    string
+	RequestID  string
+	From       time.Time
+	To         time.Time
+	// Limit is the number of events in a page, DefaultLimit if zero
+	Limit int
+	// Cursor continues after the last event of the previous page
+	Cursor string
+}
+
+// Page is a page of events, newest first
+type Page struct {
+	Events []*Event `json:"events"`
+	// NextCursor continues with the next page, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Store appends events to the audit log in Postgres and queries them. Events are never
+// changed or deleted, which the database enforces as well.
+type Store struct {
+	db *sqlx.DB
+}
+
+// NewStore creates a new store for the audit database
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Migrate applies the pending migrations of the audit database
+func (s *Store) Migrate(ctx context.Context) error {
+	migrator, err := database.NewMigrator(s.db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	_, err = migrator.Up(ctx)
+	return err
+}
+
+// Append records an event, setting its ID and time if they are not set
+func (s *Store) Append(ctx context.Context, event *Event) error {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+
+	query := `
+		INSERT INTO audit_events (id, occurred_at, service, actor_id, actor_name, api_key_id, action, target_type, target_id, request_id, ip, changes)
+		VALUES (:id, :occurred_at, :service, :actor_id, :actor_name, :api_key_id, :action, :target_type, :target_id, :request_id, :ip, :changes)
+	`
+
+	if _, err := s.db.NamedExecContext(ctx, query, event); err != nil {
+		return fmt.Errorf("failed to append audit event: %w", err)
+	}
+
+	return nil
+}
+
+// Query returns a page of the events that match a filter, newest first
+func (s *Store) Query(ctx context.Context, filter Filter) (*Page, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if filter.ActorID != "" {
+		where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		where("target_id = ?", filter.TargetID)
+	}
+	if filter.Service != "" {
+		where("service = ?", filter.Service)
+	}
+	if filter.RequestID != "" {
+		where("request_id = ?", filter.RequestID)
+	}
+	if !filter.From.IsZero() {
+		where("occurred_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("occurred_at < ?", filter.To)
+	}
+	if filter.Cursor != "" {
+		occurredAt, id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, occurredAt, id)
+		conditions = append(conditions, fmt.Sprintf("(occurred_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	query := `SELECT ` + eventColumns + ` FROM audit_events`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	// Fetch one more event to know whether there is a next page
+	args = append(args, limit+1)
+	query += fmt.Sprintf(` ORDER BY occurred_at DESC, id DESC LIMIT $%d`, len(args))
+
+	events := []*Event{}
+	if err := s.db.SelectContext(ctx, &events, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+
+	page := &Page{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		last := page.Events[limit-1]
+		page.NextCursor = encodeCursor(last.OccurredAt, last.ID)
+	}
+
+	return page, nil
+}
+
+// encodeCursor encodes the position of an event in the order of Query
+func encodeCursor(occurredAt time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(occurredAt.UTC().Format(time.RFC3339Nano) + "|" + id))
+}
+
+// decodeCursor decodes a cursor returned by encodeCursor
+func decodeCursor(cursor string) (time.Time, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(data), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	occurredAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(parts[1]); err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	return occurredAt, parts[1], nil
+}
@@ -0,0 +1,221 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	// Cursors keep the full precision of the timestamp, so that events of the same second are
+	// not skipped, and are in UTC whatever the zone of the event
+	occurredAt := time.Date(2024, 5, 1, 12, 30, 45, 123456789, time.FixedZone("CEST", 2*60*60))
+	id := "5f0c6a57-3d43-4b55-9d0c-7ab1e4d2c6f1"
+
+	gotTime, gotID, err := decodeCursor(encodeCursor(occurredAt, id))
+	if err != nil {
+		t.Fatalf("decodeCursor returned error: %v", err)
+	}
+	if !gotTime.Equal(occurredAt) || gotTime.Location() != time.UTC {
+		t.Errorf("occurred at = %v, want %v in UTC", gotTime, occurredAt)
+	}
+	if gotID != id {
+		t.Errorf("id = %q, want %q", gotID, id)
+	}
+}
+
+func TestDecodeCursorRejectsInvalidCursors(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not a cursor!"},
+		{"no separator", encode("2024-05-01T10:30:45Z")},
+		{"invalid time", encode("yesterday|5f0c6a57-3d43-4b55-9d0c-7ab1e4d2c6f1")},
+		{"invalid id", encode("2024-05-01T10:30:45Z|42; DROP TABLE audit_events")},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+// recordingConnector is a database/sql connector whose connections record the last query and
+// answer it with fixed rows of events
+type recordingConnector struct {
+	query string
+	args  []interface{}
+	rows  [][]driver.Value
+}
+
+func (c *recordingConnector) Connect(context.Context) (driver.Conn, error) {
+	return recordingConn{c}, nil
+}
+func (c *recordingConnector) Driver() driver.Driver { return nil }
+
+type recordingConn struct{ connector *recordingConnector }
+
+func (c recordingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c recordingConn) Close() error                        { return nil }
+func (c recordingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c recordingConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.connector.query = query
+	c.connector.args = nil
+	for _, arg := range args {
+		c.connector.args = append(c.connector.args, arg.Value)
+	}
+	return &eventRows{rows: c.connector.rows}, nil
+}
+
+type eventRows struct{ rows [][]driver.Value }
+
+func (r *eventRows) Columns() []string {
+	columns := strings.Split(eventColumns, ",")
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+	return columns
+}
+
+func (r *eventRows) Close() error { return nil }
+
+func (r *eventRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// newRecordingStore returns a store whose database answers every query with the events
+func newRecordingStore(events ...*Event) (*Store, *recordingConnector) {
+	connector := &recordingConnector{}
+	for _, e := range events {
+		connector.rows = append(connector.rows, []driver.Value{
+			e.ID, e.OccurredAt, e.Service, e.ActorID, e.ActorName, e.APIKeyID, e.Action, e.TargetType, e.TargetID, e.RequestID, e.IP, nil,
+		})
+	}
+	return NewStore(sqlx.NewDb(sql.OpenDB(connector), "postgres")), connector
+}
+
+func testEvents(n int) []*Event {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	events := make([]*Event, n)
+	for i := range events {
+		events[i] = &Event{ID: uuid.New().String(), OccurredAt: start.Add(-time.Duration(i) * time.Second), Action: ActionSurveyUpdate}
+	}
+	return events
+}
+
+func TestQueryPages(t *testing.T) {
+	events := testEvents(3)
+
+	tests := []struct {
+		name       string
+		limit      int
+		returned   []*Event
+		wantEvents int
+		wantNext   *Event
+	}{
+		{"more events than the limit", 2, events, 2, events[1]},
+		{"last page", 3, events, 3, nil},
+		{"empty page", 2, nil, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, connector := newRecordingStore(tt.returned...)
+
+			page, err := store.Query(context.Background(), Filter{Limit: tt.limit})
+			if err != nil {
+				t.Fatalf("Query returned error: %v", err)
+			}
+
+			// One more event than the limit is fetched to tell whether there is a next page
+			if got := connector.args[len(connector.args)-1]; got != int64(tt.limit+1) {
+				t.Errorf("LIMIT = %v, want %d", got, tt.limit+1)
+			}
+			if len(page.Events) != tt.wantEvents {
+				t.Errorf("page has %d events, want %d", len(page.Events), tt.wantEvents)
+			}
+			wantNext := ""
+			if tt.wantNext != nil {
+				wantNext = encodeCursor(tt.wantNext.OccurredAt, tt.wantNext.ID)
+			}
+			if page.NextCursor != wantNext {
+				t.Errorf("NextCursor = %q, want %q", page.NextCursor, wantNext)
+			}
+		})
+	}
+}
+
+func TestQueryContinuesAfterCursor(t *testing.T) {
+	store, connector := newRecordingStore()
+	last := testEvents(1)[0]
+
+	_, err := store.Query(context.Background(), Filter{ActorID: "user-1", Action: ActionSurveyUpdate, Cursor: encodeCursor(last.OccurredAt, last.ID)})
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+
+	for _, condition := range []string{"actor_id = $1", "action = $2", "(occurred_at, id) < ($3, $4)", "ORDER BY occurred_at DESC, id DESC LIMIT $5"} {
+		if !strings.Contains(connector.query, condition) {
+			t.Errorf("query %q does not contain %q", connector.query, condition)
+		}
+	}
+	if len(connector.args) != 5 || connector.args[2] != last.OccurredAt || connector.args[3] != last.ID {
+		t.Errorf("args = %v, want the filter, the cursor and the limit", connector.args)
+	}
+}
+
+func TestQueryLimits(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  int64
+	}{
+		{0, DefaultLimit + 1},
+		{-5, DefaultLimit + 1},
+		{MaxLimit + 1, MaxLimit + 1},
+	}
+
+	for _, tt := range tests {
+		store, connector := newRecordingStore()
+		if _, err := store.Query(context.Background(), Filter{Limit: tt.limit}); err != nil {
+			t.Fatalf("Query returned error: %v", err)
+		}
+		if got := connector.args[len(connector.args)-1]; got != tt.want {
+			t.Errorf("limit %d: LIMIT = %v, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestQueryRejectsInvalidCursor(t *testing.T) {
+	store, connector := newRecordingStore()
+
+	if _, err := store.Query(context.Background(), Filter{Cursor: "not a cursor!"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Query error = %v, want ErrInvalidCursor", err)
+	}
+	if connector.query != "" {
+		t.Error("Query ran a query for an invalid cursor")
+	}
+}
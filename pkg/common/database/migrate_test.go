@@ -0,0 +1,469 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeDatabase is an in-memory stand-in for Postgres that understands the statements of the
+// migrator. Its advisory lock blocks like the real one, and it records statements that are run
+// by a connection that does not hold the lock.
+type fakeDatabase struct {
+	lock chan struct{}
+
+	mu      sync.Mutex
+	applied map[int64]appliedMigration
+	// scripts are the committed migration scripts in the order they ran
+	scripts []string
+	// unlocked are the statements run without holding the lock
+	unlocked []string
+	// failing is a script that fails to run
+	failing string
+}
+
+func newFakeDatabase() *fakeDatabase {
+	return &fakeDatabase{lock: make(chan struct{}, 1), applied: make(map[int64]appliedMigration)}
+}
+
+// open returns a sqlx handle whose connections use the fake database
+func (d *fakeDatabase) open() *sqlx.DB {
+	return sqlx.NewDb(sql.OpenDB(d), "postgres")
+}
+
+// Connect implements driver.Connector
+func (d *fakeDatabase) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: d}, nil }
+
+// Driver implements driver.Connector
+func (d *fakeDatabase) Driver() driver.Driver { return nil }
+
+type fakeConn struct {
+	db     *fakeDatabase
+	locked bool
+	tx     *fakeTx
+}
+
+// fakeTx collects the changes of a transaction until it is committed
+type fakeTx struct {
+	conn     *fakeConn
+	scripts  []string
+	inserted []appliedMigration
+	deleted  []int64
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.tx = &fakeTx{conn: c}
+	return c.tx, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	switch {
+	case strings.Contains(query, "pg_advisory_lock("):
+		if args[0].Value != int64(migrationLockKey) {
+			return nil, fmt.Errorf("unexpected lock key %v", args[0].Value)
+		}
+		select {
+		case c.db.lock <- struct{}{}:
+			c.locked = true
+			return driver.ResultNoRows, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+	case strings.Contains(query, "pg_advisory_unlock("):
+		if c.locked {
+			c.locked = false
+			<-c.db.lock
+		}
+		return driver.ResultNoRows, nil
+	}
+
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	if !c.locked {
+		c.db.unlocked = append(c.db.unlocked, query)
+	}
+
+	switch {
+	case strings.Contains(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		return driver.ResultNoRows, nil
+	case c.tx == nil:
+		return nil, fmt.Errorf("statement outside a transaction: %s", query)
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		c.tx.inserted = append(c.tx.inserted, appliedMigration{
+			Version:   args[0].Value.(int64),
+			Name:      args[1].Value.(string),
+			Checksum:  args[2].Value.(string),
+			AppliedAt: args[3].Value.(time.Time),
+		})
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		c.tx.deleted = append(c.tx.deleted, args[0].Value.(int64))
+	case query == c.db.failing:
+		return nil, errors.New("syntax error")
+	default:
+		c.tx.scripts = append(c.tx.scripts, query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if !strings.HasPrefix(query, "SELECT version, name, checksum, applied_at FROM schema_migrations") {
+		return nil, fmt.Errorf("unexpected query: %s", query)
+	}
+
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	if !c.locked {
+		c.db.unlocked = append(c.db.unlocked, query)
+	}
+
+	rows := &fakeRows{}
+	for _, row := range c.db.applied {
+		rows.rows = append(rows.rows, []driver.Value{row.Version, row.Name, row.Checksum, row.AppliedAt})
+	}
+	sort.Slice(rows.rows, func(i, j int) bool { return rows.rows[i][0].(int64) < rows.rows[j][0].(int64) })
+	return rows, nil
+}
+
+func (tx *fakeTx) Commit() error {
+	db := tx.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.scripts = append(db.scripts, tx.scripts...)
+	for _, row := range tx.inserted {
+		db.applied[row.Version] = row
+	}
+	for _, version := range tx.deleted {
+		delete(db.applied, version)
+	}
+	tx.conn.tx = nil
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.conn.tx = nil
+	return nil
+}
+
+type fakeRows struct{ rows [][]driver.Value }
+
+func (r *fakeRows) Columns() []string { return []string{"version", "name", "checksum", "applied_at"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// testMigrations returns three migrations; the last one has no down script
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"000001_create_users.up.sql":     {Data: []byte("CREATE TABLE users")},
+		"000001_create_users.down.sql":   {Data: []byte("DROP TABLE users")},
+		"000002_add_email.up.sql":        {Data: []byte("ALTER TABLE users ADD email")},
+		"000002_add_email.down.sql":      {Data: []byte("ALTER TABLE users DROP email")},
+		"000003_backfill_emails.up.sql":  {Data: []byte("UPDATE users SET email = ''")},
+		"subdirectory/000004_ignored.up": {Data: []byte("ignored")},
+	}
+}
+
+func newTestMigrator(t *testing.T, db *fakeDatabase, fsys fstest.MapFS) *Migrator {
+	t.Helper()
+
+	migrator, err := NewMigrator(db.open(), fsys)
+	if err != nil {
+		t.Fatalf("NewMigrator returned error: %v", err)
+	}
+	return migrator
+}
+
+// versions returns the versions of migrations
+func versions(migrations []Migration) []int64 {
+	var versions []int64
+	for _, migration := range migrations {
+		versions = append(versions, migration.Version)
+	}
+	return versions
+}
+
+func equalVersions(a, b []int64) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(testMigrations())
+	if err != nil {
+		t.Fatalf("LoadMigrations returned error: %v", err)
+	}
+
+	if got := versions(migrations); !equalVersions(got, []int64{1, 2, 3}) {
+		t.Fatalf("versions = %v, want [1 2 3]", got)
+	}
+	first := migrations[0]
+	if first.Name != "create_users" || first.Up != "CREATE TABLE users" || first.Down != "DROP TABLE users" {
+		t.Errorf("first migration = %+v", first)
+	}
+	sum := sha256.Sum256([]byte("CREATE TABLE users"))
+	if first.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("checksum = %s, want the SHA-256 of the up script", first.Checksum)
+	}
+	if migrations[2].Down != "" {
+		t.Errorf("down script of the last migration = %q, want none", migrations[2].Down)
+	}
+}
+
+func TestLoadMigrationsRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"other file", fstest.MapFS{"000001_init.up.sql": {}, "notes.txt": {}}},
+		{"missing version", fstest.MapFS{"init.up.sql": {}}},
+		{"no direction", fstest.MapFS{"000001_init.sql": {}}},
+		{"no up script", fstest.MapFS{"000001_init.down.sql": {Data: []byte("DROP TABLE users")}}},
+		{"empty up script", fstest.MapFS{"000001_init.up.sql": {}}},
+		{"conflicting names", fstest.MapFS{"000001_init.up.sql": {Data: []byte("CREATE TABLE users")}, "000001_other.down.sql": {}}},
+		{"version overflow", fstest.MapFS{"99999999999999999999_init.up.sql": {Data: []byte("CREATE TABLE users")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadMigrations(tt.fsys); err == nil {
+				t.Error("LoadMigrations accepted invalid migrations")
+			}
+		})
+	}
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDatabase()
+	migrator := newTestMigrator(t, db, testMigrations())
+
+	migrated, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up returned error: %v", err)
+	}
+	if got := versions(migrated); !equalVersions(got, []int64{1, 2, 3}) {
+		t.Errorf("Up applied %v, want [1 2 3]", got)
+	}
+
+	migrated, err = migrator.Up(ctx)
+	if err != nil || len(migrated) != 0 {
+		t.Errorf("second Up = %v, %v, want nothing applied", versions(migrated), err)
+	}
+
+	// The last migration has no down script
+	if migrated, err := migrator.Down(ctx, 1); err == nil || len(migrated) != 0 {
+		t.Errorf("Down = %v, %v, want an error for a migration without a down script", versions(migrated), err)
+	}
+
+	// Down scripts are not part of the checksum, so they can be added later
+	fsys := testMigrations()
+	fsys["000003_backfill_emails.down.sql"] = &fstest.MapFile{Data: []byte("SELECT 1")}
+	migrated, err = newTestMigrator(t, db, fsys).Down(ctx, 5)
+	if err != nil {
+		t.Fatalf("Down returned error: %v", err)
+	}
+	if got := versions(migrated); !equalVersions(got, []int64{3, 2, 1}) {
+		t.Errorf("Down reverted %v, want [3 2 1]", got)
+	}
+
+	want := []string{
+		"CREATE TABLE users", "ALTER TABLE users ADD email", "UPDATE users SET email = ''",
+		"SELECT 1", "ALTER TABLE users DROP email", "DROP TABLE users",
+	}
+	if fmt.Sprint(db.scripts) != fmt.Sprint(want) {
+		t.Errorf("scripts = %q, want %q", db.scripts, want)
+	}
+	if len(db.applied) != 0 {
+		t.Errorf("applied = %v, want none", db.applied)
+	}
+	if len(db.unlocked) != 0 {
+		t.Errorf("statements run without the migration lock: %q", db.unlocked)
+	}
+}
+
+func TestMigratorRejectsChangedHistory(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(fsys fstest.MapFS)
+		want   error
+	}{
+		{"changed migration", func(fsys fstest.MapFS) {
+			fsys["000002_add_email.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE users ADD mail")}
+		}, ErrChecksumMismatch},
+		{"removed migration", func(fsys fstest.MapFS) {
+			delete(fsys, "000002_add_email.up.sql")
+			delete(fsys, "000002_add_email.down.sql")
+		}, ErrMissingMigration},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newFakeDatabase()
+			if _, err := newTestMigrator(t, db, testMigrations()).Up(ctx); err != nil {
+				t.Fatalf("Up returned error: %v", err)
+			}
+
+			fsys := testMigrations()
+			tt.change(fsys)
+			migrator := newTestMigrator(t, db, fsys)
+
+			if _, err := migrator.Up(ctx); !errors.Is(err, tt.want) {
+				t.Errorf("Up error = %v, want %v", err, tt.want)
+			}
+			if _, err := migrator.Down(ctx, 1); !errors.Is(err, tt.want) {
+				t.Errorf("Down error = %v, want %v", err, tt.want)
+			}
+			if _, err := migrator.Status(ctx); !errors.Is(err, tt.want) {
+				t.Errorf("Status error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigratorStopsAtFailedMigration(t *testing.T) {
+	db := newFakeDatabase()
+	db.failing = "ALTER TABLE users ADD email"
+	migrator := newTestMigrator(t, db, testMigrations())
+
+	migrated, err := migrator.Up(context.Background())
+	if err == nil {
+		t.Fatal("Up returned no error for a failed migration")
+	}
+	if got := versions(migrated); !equalVersions(got, []int64{1}) {
+		t.Errorf("Up applied %v, want [1]", got)
+	}
+	if _, ok := db.applied[2]; ok {
+		t.Error("failed migration was recorded as applied")
+	}
+	if _, ok := db.applied[3]; ok {
+		t.Error("migration after the failed one was applied")
+	}
+}
+
+func TestMigratorSerializesConcurrentRuns(t *testing.T) {
+	db := newFakeDatabase()
+
+	var wg sync.WaitGroup
+	applied := make([][]Migration, 5)
+	errs := make([]error, len(applied))
+	for i := range applied {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			applied[i], errs[i] = newTestMigrator(t, db, testMigrations()).Up(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Up returned error: %v", err)
+		}
+		total += len(applied[i])
+	}
+	if total != 3 || len(db.scripts) != 3 {
+		t.Errorf("concurrent runs applied %d migrations with %d scripts, want each of 3 once", total, len(db.scripts))
+	}
+	if len(db.unlocked) != 0 {
+		t.Errorf("statements run without the migration lock: %q", db.unlocked)
+	}
+}
+
+func TestMigratorWaitsForLock(t *testing.T) {
+	db := newFakeDatabase()
+	db.lock <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := newTestMigrator(t, db, testMigrations()).Up(ctx); err == nil {
+		t.Fatal("Up ran while another process held the migration lock")
+	}
+	if len(db.scripts) != 0 {
+		t.Errorf("scripts = %q, want none", db.scripts)
+	}
+}
+
+func TestRunMigrateCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied int
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{"up by default", 0, nil, []string{"Applied 1_create_users", "Applied 2_add_email", "Applied 3_backfill_emails"}, false},
+		{"up", 1, []string{"up"}, []string{"Applied 2_add_email", "Applied 3_backfill_emails"}, false},
+		{"nothing to apply", 3, []string{"up"}, []string{"No pending migrations"}, false},
+		{"up with steps", 0, []string{"up", "1"}, nil, true},
+		{"down one step", 2, []string{"down"}, []string{"Reverted 2_add_email"}, false},
+		{"down steps", 2, []string{"down", "2"}, []string{"Reverted 2_add_email", "Reverted 1_create_users"}, false},
+		{"nothing to revert", 0, []string{"down"}, []string{"No applied migrations"}, false},
+		{"zero steps", 2, []string{"down", "0"}, nil, true},
+		{"invalid steps", 2, []string{"down", "all"}, nil, true},
+		{"too many arguments", 2, []string{"down", "1", "2"}, nil, true},
+		{"status", 1, []string{"status"}, []string{
+			"VERSION  NAME             APPLIED AT",
+			"1        create_users     2024-05-01T12:00:00Z",
+			"2        add_email        pending",
+			"3        backfill_emails  pending",
+		}, false},
+		{"unknown command", 0, []string{"redo"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDatabase()
+			migrator := newTestMigrator(t, db, testMigrations())
+			for _, migration := range migrator.migrations[:tt.applied] {
+				db.applied[migration.Version] = appliedMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					Checksum:  migration.Checksum,
+					AppliedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+				}
+			}
+
+			var out bytes.Buffer
+			err := RunMigrateCommand(context.Background(), migrator, tt.args, &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunMigrateCommand error = %v, want error %v", err, tt.wantErr)
+			}
+
+			want := ""
+			if len(tt.want) > 0 {
+				want = strings.Join(tt.want, "\n") + "\n"
+			}
+			if out.String() != want {
+				t.Errorf("output = %q, want %q", out.String(), want)
+			}
+		})
+	}
+}
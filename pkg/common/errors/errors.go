@@ -27,6 +27,21 @@ var (
 	// ErrBadRequest indicates an invalid request
 	ErrBadRequest = errors.New("bad request")
 
+	// ErrConflict indicates a request that conflicts with the current state of a resource
+	ErrConflict = errors.New("conflict")
+
+	// ErrTooManyRequests indicates a client exceeded a rate limit
+	ErrTooManyRequests = errors.New("too many requests")
+
+	// ErrBadGateway indicates that an upstream service failed to handle a request
+	ErrBadGateway = errors.New("bad gateway")
+
+	// ErrServiceUnavailable indicates that no instance of a service can take a request
+	ErrServiceUnavailable = errors.New("service unavailable")
+
+	// ErrGatewayTimeout indicates that an upstream service did not respond in time
+	ErrGatewayTimeout = errors.New("gateway timeout")
+
 	// ErrInternalServer indicates an internal server error
 	ErrInternalServer = errors.New("internal server error")
 )
@@ -55,6 +70,16 @@ func HandleError(w http.ResponseWriter, err error, details string) {
 		WriteError(w, err, http.StatusForbidden, details)
 	case errors.Is(err, ErrBadRequest):
 		WriteError(w, err, http.StatusBadRequest, details)
+	case errors.Is(err, ErrConflict):
+		WriteError(w, err, http.StatusConflict, details)
+	case errors.Is(err, ErrTooManyRequests):
+		WriteError(w, err, http.StatusTooManyRequests, details)
+	case errors.Is(err, ErrBadGateway):
+		WriteError(w, err, http.StatusBadGateway, details)
+	case errors.Is(err, ErrServiceUnavailable):
+		WriteError(w, err, http.StatusServiceUnavailable, details)
+	case errors.Is(err, ErrGatewayTimeout):
+		WriteError(w, err, http.StatusGatewayTimeout, details)
 	default:
 		// Log the original error but don't expose it to the client
 		fmt.Printf("Internal error: %v\n", err)
@@ -10,6 +10,27 @@ import (
 	"time"
 )
 
+// StatusError is returned when the remote service responds with an error status code
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request failed with status code %d: %s", e.StatusCode, e.Body)
+}
+
+// authorizationKey is the context key of the Authorization header to forward
+type authorizationKey struct{}
+
+// WithAuthorization returns a context whose requests send the given Authorization header, for
+// example the header of an incoming request so that the remote service acts on behalf of the
+// same user. It takes precedence over SetAuthToken.
+func WithAuthorization(ctx context.Context, header string) context.Context {
+	return context.WithValue(ctx, authorizationKey{}, header)
+}
+
 // Client is a wrapper around the standard http.Client with additional features
 type Client struct {
 	baseURL    string
@@ -65,6 +86,9 @@ func (c *Client) Request(ctx context.Context, method, path string, payload inter
 	for key, value := range c.headers {
 		req.Header.Set(key, value)
 	}
+	if header, ok := ctx.Value(authorizationKey{}).(string); ok && header != "" {
+		req.Header.Set("Authorization", header)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -80,7 +104,7 @@ func (c *Client) Request(ctx context.Context, method, path string, payload inter
 
 	// Check for error status codes
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("request failed with status code %d: %s", resp.StatusCode, string(respBody))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	// Unmarshal the response if a result container was provided
@@ -111,4 +135,4 @@ func (c *Client) Put(ctx context.Context, path string, payload interface{}, resu
 // Delete performs a DELETE request
 func (c *Client) Delete(ctx context.Context, path string, result interface{}) error {
 	return c.Request(ctx, http.MethodDelete, path, nil, result)
-} 
\ No newline at end of file
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the IP address of the client of a request without the port
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// TrustedProxies are the networks of the proxies in front of a server, such as a load
+// balancer, whose X-Forwarded-For header is trusted
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses a list of IP addresses and CIDR ranges of trusted proxies
+func ParseTrustedProxies(values []string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", value)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// trusts reports whether an IP address is one of a trusted proxy
+func (p TrustedProxies) trusts(ip net.IP) bool {
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP sets the remote address of requests to the IP address of the client. Addresses in
+// the X-Forwarded-For header are only followed from right to left while they were added by a
+// trusted proxy, so clients cannot choose their address by sending the header themselves.
+// Without trusted proxies the address of the connection is kept.
+func RealIP(trusted TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := forwardedIP(r, trusted); ip != "" {
+				setClientIP(r, ip)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedIP returns the address of the client as forwarded by trusted proxies, or an empty
+// string if the request did not come from a trusted proxy
+func forwardedIP(r *http.Request, trusted TrustedProxies) string {
+	peer := net.ParseIP(ClientIP(r))
+	if peer == nil || !trusted.trusts(peer) {
+		return ""
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	client := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		client = ip.String()
+		if !trusted.trusts(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// setClientIP replaces the IP address of the remote address of a request. The port of the
+// connection is kept, as the remote address is expected to have one.
+func setClientIP(r *http.Request, ip string) {
+	_, port, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		port = "0"
+	}
+	r.RemoteAddr = net.JoinHostPort(ip, port)
+}
+
+// HeaderClientIP is the header the API gateway sets to the IP address of the client, replacing
+// any the client sent
+const HeaderClientIP = "X-Real-IP"
+
+// forwardedHeaders are the headers that carry the address of a client through proxies
+var forwardedHeaders = []string{"X-Forwarded-For", HeaderClientIP, "True-Client-IP"}
+
+// StripForwarded removes the headers that carry the address of the client from requests, so that
+// nothing after RealIP uses an address the client sent
+func StripForwarded(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range forwardedHeaders {
+			r.Header.Del(name)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GatewayIP sets the remote address of requests to the IP address of the client that the API
+// gateway sets in the X-Real-IP header. The header is only taken from trusted proxies, so
+// clients reaching a service directly cannot choose their address, and other forwarding headers
+// are ignored, so services behind the gateway only use the address the gateway resolved.
+func GatewayIP(trusted TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if peer := net.ParseIP(ClientIP(r)); peer != nil && trusted.trusts(peer) {
+				if ip := net.ParseIP(strings.TrimSpace(r.Header.Get(HeaderClientIP))); ip != nil {
+					setClientIP(r, ip.String())
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGatewayIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.5", "192.168.0.0/24"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		trusted    TrustedProxies
+		remoteAddr string
+		header     string
+		want       string
+	}{
+		{"trusted gateway", trusted, "10.0.0.5:4000", "203.0.113.7", "203.0.113.7:4000"},
+		{"trusted network", trusted, "192.168.0.20:4000", "203.0.113.7", "203.0.113.7:4000"},
+		{"untrusted caller", trusted, "198.51.100.1:4000", "203.0.113.7", "198.51.100.1:4000"},
+		{"no trusted proxies", nil, "10.0.0.5:4000", "203.0.113.7", "10.0.0.5:4000"},
+		{"missing header", trusted, "10.0.0.5:4000", "", "10.0.0.5:4000"},
+		{"invalid header", trusted, "10.0.0.5:4000", "not-an-ip", "10.0.0.5:4000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				r.Header.Set(HeaderClientIP, tt.header)
+			}
+
+			var got string
+			GatewayIP(tt.trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			})).ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
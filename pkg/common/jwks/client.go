@@ -0,0 +1,167 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultCacheTTL is how long fetched keys are used before the key set is fetched again
+	defaultCacheTTL = 5 * time.Minute
+	// minRefreshInterval limits how often an unknown key ID triggers fetching the key set
+	minRefreshInterval = 10 * time.Second
+	// fetchTimeout bounds fetching the key set
+	fetchTimeout = 10 * time.Second
+)
+
+// Client fetches the JSON Web Key Set of a token issuer and caches its public keys. A token
+// signed with a key ID the cache does not know yet makes the client fetch the key set again,
+// so keys can be rotated without restarting the services that verify tokens.
+type Client struct {
+	url        string
+	ttl        time.Duration
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	inflight    *fetchCall
+}
+
+// fetchCall is a fetch of the key set that requests needing it wait for
+type fetchCall struct {
+	done chan struct{}
+	err  error
+}
+
+// NewClient creates a new client for the key set at url that caches keys for ttl. A zero ttl
+// uses a default of five minutes.
+func NewClient(url string, ttl time.Duration) *Client {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+
+	return &Client{
+		url:        url,
+		ttl:        ttl,
+		httpClient: &http.Client{Timeout: fetchTimeout},
+	}
+}
+
+// PublicKey returns the public key with the given ID. Cached keys are still used while the
+// key set is fetched again or cannot be fetched, so only requests signed with an unknown key
+// wait for the key set.
+func (c *Client) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	key, ok := c.keys[kid]
+	if ok {
+		if time.Since(c.fetchedAt) >= c.ttl {
+			c.refresh()
+		}
+		c.mu.Unlock()
+		return key, nil
+	}
+	call := c.refresh()
+	c.mu.Unlock()
+
+	if call != nil {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err != nil {
+			return nil, call.err
+		}
+
+		c.mu.Lock()
+		key, ok = c.keys[kid]
+		c.mu.Unlock()
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+
+	return key, nil
+}
+
+// refresh fetches the key set in the background, unless a fetch is already running, and
+// returns the running fetch. It returns nil if the key set was fetched too recently. The fetch
+// is not bound to any request, so a cancelled request does not fail it for the others. The
+// caller must hold the lock.
+func (c *Client) refresh() *fetchCall {
+	if c.inflight != nil {
+		return c.inflight
+	}
+	if time.Since(c.lastAttempt) < minRefreshInterval {
+		return nil
+	}
+
+	c.lastAttempt = time.Now()
+	call := &fetchCall{done: make(chan struct{})}
+	c.inflight = call
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		defer cancel()
+
+		keys, err := c.fetch(ctx)
+
+		c.mu.Lock()
+		if err == nil {
+			c.keys = keys
+			c.fetchedAt = time.Now()
+		}
+		call.err = err
+		c.inflight = nil
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	return call
+}
+
+// fetch fetches and decodes the key set
+func (c *Client) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key set: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch key set: status %d", resp.StatusCode)
+	}
+
+	var doc Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode key set: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,193 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Path is where a service publishes its JSON Web Key Set
+const Path = "/.well-known/jwks.json"
+
+// Key is a private key that signs tokens
+type Key struct {
+	ID     string
+	signer crypto.Signer
+	method jwt.SigningMethod
+}
+
+// NewKey creates a signing key from an RSA or Ed25519 private key
+func NewKey(id string, private crypto.Signer) (*Key, error) {
+	key := &Key{ID: id, signer: private}
+	switch private.(type) {
+	case *rsa.PrivateKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T for key %s", private, id)
+	}
+
+	return key, nil
+}
+
+// GenerateKey creates a new Ed25519 signing key
+func GenerateKey(id string) (*Key, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	return NewKey(id, private)
+}
+
+// KeySet holds the keys of a token issuer. Tokens are signed with the current key, while
+// the public keys of all keys are published so that tokens signed with a previous key stay
+// valid until they expire.
+type KeySet struct {
+	current *Key
+	keys    map[string]*Key
+}
+
+// NewKeySet creates a key set that signs with the key with the current ID
+func NewKeySet(keys []*Key, currentID string) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for _, key := range keys {
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %s", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+
+	current, ok := set.keys[currentID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %s not found", currentID)
+	}
+	set.current = current
+
+	return set, nil
+}
+
+// LoadKeySet loads the PEM encoded private keys in dir, each named after its key ID, such
+// as 2024-01.pem. It signs with the key with the current ID or, if that is empty, with the
+// key whose ID sorts last.
+func LoadKeySet(dir, currentID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no signing keys found in %s", dir)
+	}
+	sort.Strings(paths)
+
+	keys := make([]*Key, 0, len(paths))
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := loadKey(path, id)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if currentID == "" {
+		currentID = keys[len(keys)-1].ID
+	}
+
+	return NewKeySet(keys, currentID)
+}
+
+// loadKey reads a PKCS #8 or PKCS #1 encoded private key
+func loadKey(path, id string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key %s: %w", id, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s is not PEM encoded", id)
+	}
+
+	var private interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("signing key %s has unsupported PEM type %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", id, err)
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T for key %s", private, id)
+	}
+
+	return NewKey(id, signer)
+}
+
+// Sign signs claims with the current key and sets the key ID in the token header
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.current.method, claims)
+	token.Header["kid"] = s.current.ID
+
+	signed, err := token.SignedString(s.current.signer)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return signed, nil
+}
+
+// PublicKey returns the public key with the given ID. It lets the issuer verify its own tokens
+// without fetching its key set.
+func (s *KeySet) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+
+	return key.signer.Public(), nil
+}
+
+// Document returns the public keys as a JSON Web Key Set
+func (s *KeySet) Document() Document {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	doc := Document{Keys: make([]JWK, 0, len(ids))}
+	for _, id := range ids {
+		key := s.keys[id]
+		doc.Keys = append(doc.Keys, newJWK(key.ID, key.method.Alg(), key.signer.Public()))
+	}
+
+	return doc
+}
+
+// ServeHTTP publishes the JSON Web Key Set
+func (s *KeySet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(s.Document())
+}
@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/google/uuid"
+)
+
+// LogMailer logs emails instead of sending them, for local development
+type LogMailer struct {
+	logger *logging.Logger
+}
+
+// NewLogMailer creates a new mailer that logs emails
+func NewLogMailer(logger *logging.Logger) *LogMailer {
+	return &LogMailer{logger: logger}
+}
+
+// Send logs a message
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	m.logger.Info("Email", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// FileMailer writes emails to files instead of sending them, for local development and tests
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer creates a new mailer that writes each email to a .eml file in dir
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+// Send writes a message to a new file
+func (m *FileMailer) Send(ctx context.Context, msg *Message) error {
+	data, err := format(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), uuid.New().String())
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+
+	return nil
+}
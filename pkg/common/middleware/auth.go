@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"crypto"
 	"errors"
 	"fmt"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // UserClaims defines custom claims for the JWT
@@ -17,62 +19,243 @@ type UserClaims struct {
 	Username string   `json:"username"`
 	Email    string   `json:"email"`
 	Roles    []string `json:"roles"`
+	// Permissions are the permissions granted by the roles when the token was issued
+	Permissions []string `json:"permissions"`
+	// EmailVerified is false until the user confirms their email, so that services can
+	// restrict unverified accounts
+	EmailVerified bool `json:"email_verified"`
+	// APIKeyID is set when the request was authenticated with an API key instead of a token
+	APIKeyID string `json:"api_key_id,omitempty"`
+	// Scopes are the permissions the API key is limited to. Permissions only holds those the
+	// user still has, while Scopes also limits the permissions granted on single surveys.
+	Scopes []string `json:"scopes,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// Auth middleware for validating JWT tokens
-func Auth(jwtSecret string) func(http.Handler) http.Handler {
+// InScope reports whether the claims may use a permission. Only claims of API keys are
+// limited to their scopes.
+func (c *UserClaims) InScope(permission string) bool {
+	if c.APIKeyID == "" {
+		return true
+	}
+
+	for _, scope := range c.Scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyScheme is the Authorization scheme of API keys, as in "ApiKey {key}"
+const APIKeyScheme = "ApiKey"
+
+// ErrInvalidAPIKey is returned by an APIKeyVerifier for keys that do not exist, expired or
+// were revoked
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// KeySource looks up the public keys that verify tokens by their key ID
+type KeySource interface {
+	PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// RevocationChecker reports whether a token was revoked before it expired, for example
+// because its user logged out
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, claims *UserClaims) (bool, error)
+}
+
+// APIKeyVerifier resolves an API key to the claims of its user
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (*UserClaims, error)
+}
+
+// AuthOption configures the Auth and OptionalAuth middleware
+type AuthOption func(*authOptions)
+
+// authOptions holds the configuration of the Auth and OptionalAuth middleware
+type authOptions struct {
+	revocations   RevocationChecker
+	apiKeys       APIKeyVerifier
+	gatewaySecret []byte
+}
+
+// WithRevocationChecker rejects tokens that the checker reports as revoked
+func WithRevocationChecker(checker RevocationChecker) AuthOption {
+	return func(o *authOptions) {
+		o.revocations = checker
+	}
+}
+
+// WithAPIKeyVerifier also accepts API keys in the Authorization header, which the verifier
+// resolves to the claims of their user
+func WithAPIKeyVerifier(verifier APIKeyVerifier) AuthOption {
+	return func(o *authOptions) {
+		o.apiKeys = verifier
+	}
+}
+
+// WithGatewaySecret also accepts the identity headers of the API gateway if they are signed with
+// the secret. An empty secret accepts none.
+func WithGatewaySecret(secret []byte) AuthOption {
+	return func(o *authOptions) {
+		if len(secret) > 0 {
+			o.gatewaySecret = secret
+		}
+	}
+}
+
+// newAuthOptions applies the options to the default configuration
+func newAuthOptions(opts []AuthOption) *authOptions {
+	options := &authOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// Auth middleware for validating JWT tokens signed with one of the keys of the key source, and
+// API keys if configured
+func Auth(keys KeySource, opts ...AuthOption) func(http.Handler) http.Handler {
+	options := newAuthOptions(opts)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+			claims, status, message := authenticate(r, keys, options)
+			if claims == nil {
+				http.Error(w, message, status)
 				return
 			}
 
-			// Bearer token format check
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				http.Error(w, "Authorization header format must be Bearer {token}", http.StatusUnauthorized)
-				return
-			}
+			next.ServeHTTP(w, r.WithContext(ContextWithUser(r.Context(), claims)))
+		})
+	}
+}
+
+// OptionalAuth middleware validates a JWT token or API key if the request has one and lets
+// anonymous requests through without user claims
+func OptionalAuth(keys KeySource, opts ...AuthOption) func(http.Handler) http.Handler {
+	options := newAuthOptions(opts)
 
-			tokenString := parts[1]
-			claims := &UserClaims{}
-
-			// Parse the JWT token
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				// Validate the algorithm
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return []byte(jwtSecret), nil
-			})
-
-			if err != nil {
-				if errors.Is(err, jwt.ErrTokenExpired) {
-					http.Error(w, "Token expired", http.StatusUnauthorized)
-					return
-				}
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" && !options.fromGateway(r) {
+				next.ServeHTTP(w, r)
 				return
 			}
 
-			if !token.Valid {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
+			claims, status, message := authenticate(r, keys, options)
+			if claims == nil {
+				http.Error(w, message, status)
 				return
 			}
 
-			// Create a context with user claims
-			ctx := context.WithValue(r.Context(), "user", claims)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r.WithContext(ContextWithUser(r.Context(), claims)))
 		})
 	}
 }
 
-// GenerateJWT creates a new JWT token for a user
-func GenerateJWT(userID, username, email string, roles []string, jwtSecret string, expirationTime time.Duration) (string, error) {
-	claims := UserClaims{
+// Authenticate validates the bearer token or API key of a request like Auth, for callers that
+// handle the result themselves such as the API gateway. If the request is not authenticated it
+// returns nil claims with the status and message to respond with.
+func Authenticate(r *http.Request, keys KeySource, opts ...AuthOption) (*UserClaims, int, string) {
+	return authenticate(r, keys, newAuthOptions(opts))
+}
+
+// fromGateway reports whether a request carries identity headers that may have been set by the
+// API gateway
+func (o *authOptions) fromGateway(r *http.Request) bool {
+	return o.gatewaySecret != nil && r.Header.Get(HeaderIdentitySignature) != ""
+}
+
+// authenticate validates the gateway identity, bearer token or API key of a request. If it is
+// missing or invalid it returns nil claims with the status and message to respond with.
+func authenticate(r *http.Request, keys KeySource, options *authOptions) (*UserClaims, int, string) {
+	// The gateway already verified the token or API key of the request
+	if options.fromGateway(r) {
+		claims, err := VerifyIdentity(r, options.gatewaySecret)
+		if err != nil {
+			return nil, http.StatusUnauthorized, "Invalid gateway identity"
+		}
+		if claims.APIKeyID == "" {
+			return checkRevocation(r, claims, options)
+		}
+		return claims, http.StatusOK, ""
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, http.StatusUnauthorized, "Authorization header is required"
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) == 2 && parts[0] == APIKeyScheme && options.apiKeys != nil {
+		return authenticateAPIKey(r, parts[1], options.apiKeys)
+	}
+
+	// Bearer token format check
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, http.StatusUnauthorized, "Authorization header format must be Bearer {token}"
+	}
+
+	tokenString := parts[1]
+	claims := &UserClaims{}
+
+	// Parse the JWT token
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("token has no key ID")
+		}
+		return keys.PublicKey(r.Context(), kid)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
+
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, http.StatusUnauthorized, "Token expired"
+		}
+		return nil, http.StatusUnauthorized, "Invalid token"
+	}
+
+	if !token.Valid {
+		return nil, http.StatusUnauthorized, "Invalid token"
+	}
+
+	return checkRevocation(r, claims, options)
+}
+
+// checkRevocation rejects the claims of a token that was revoked, if a revocation checker is
+// configured
+func checkRevocation(r *http.Request, claims *UserClaims, options *authOptions) (*UserClaims, int, string) {
+	if options.revocations != nil {
+		revoked, err := options.revocations.IsRevoked(r.Context(), claims)
+		if err != nil {
+			return nil, http.StatusInternalServerError, "Failed to verify token"
+		}
+		if revoked {
+			return nil, http.StatusUnauthorized, "Token revoked"
+		}
+	}
+
+	return claims, http.StatusOK, ""
+}
+
+// authenticateAPIKey resolves an API key to the claims of its user
+func authenticateAPIKey(r *http.Request, key string, verifier APIKeyVerifier) (*UserClaims, int, string) {
+	claims, err := verifier.VerifyAPIKey(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, ErrInvalidAPIKey) {
+			return nil, http.StatusUnauthorized, "Invalid API key"
+		}
+		return nil, http.StatusInternalServerError, "Failed to verify API key"
+	}
+
+	return claims, http.StatusOK, ""
+}
+
+// NewUserClaims creates the claims of a token for a user with a new token ID
+func NewUserClaims(userID, username, email string, roles []string, expirationTime time.Duration) *UserClaims {
+	return &UserClaims{
 		UserID:   userID,
 		Username: username,
 		Email:    email,
@@ -81,11 +264,14 @@ func GenerateJWT(userID, username, email string, roles []string, jwtSecret strin
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expirationTime)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "pollpulse",
+			ID:        uuid.New().String(),
 		},
 	}
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtSecret))
+// ContextWithUser returns a context that carries the claims of a user, as Auth sets them
+func ContextWithUser(ctx context.Context, claims *UserClaims) context.Context {
+	return context.WithValue(ctx, "user", claims)
 }
 
 // GetUserFromContext extracts the user information from the context
@@ -97,6 +283,42 @@ func GetUserFromContext(ctx context.Context) (*UserClaims, error) {
 	return user, nil
 }
 
+// RequireVerifiedEmail middleware rejects users who have not verified their email. It must run
+// after Auth.
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := GetUserFromContext(r.Context())
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !user.EmailVerified {
+			http.Error(w, "Email address is not verified", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAccessToken middleware rejects requests authenticated with an API key, for routes
+// that manage the account itself. It must run after Auth.
+func RequireAccessToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := GetUserFromContext(r.Context())
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if user.APIKeyID != "" {
+			http.Error(w, "API keys cannot be used for this request", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // CheckRole verifies if the user has the required role
 func CheckRole(ctx context.Context, requiredRole string) bool {
 	user, err := GetUserFromContext(ctx)
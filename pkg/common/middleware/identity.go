@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Identity headers the API gateway sets for the user it authenticated. Services accept them
+// only with a valid signature, so that clients cannot claim an identity by sending them.
+const (
+	HeaderUserID            = "X-User-ID"
+	HeaderUserRoles         = "X-User-Roles"
+	HeaderUserClaims        = "X-User-Claims"
+	HeaderIdentityTimestamp = "X-Identity-Timestamp"
+	HeaderIdentitySignature = "X-Identity-Signature"
+)
+
+// identityHeaders lists every identity header
+var identityHeaders = []string{
+	HeaderUserID,
+	HeaderUserRoles,
+	HeaderUserClaims,
+	HeaderIdentityTimestamp,
+	HeaderIdentitySignature,
+}
+
+// identityMaxAge bounds how long a signed identity is accepted, which limits replays of the
+// headers of a captured request
+const identityMaxAge = time.Minute
+
+// ErrInvalidIdentity is returned for identity headers without a valid signature
+var ErrInvalidIdentity = errors.New("invalid gateway identity")
+
+// StripIdentity removes the identity headers from a request
+func StripIdentity(header http.Header) {
+	for _, name := range identityHeaders {
+		header.Del(name)
+	}
+}
+
+// SignIdentity sets the identity headers of a user on a request to a service, replacing any
+// the request already had. The signature covers the method and path of the request.
+func SignIdentity(r *http.Request, claims *UserClaims, secret []byte) error {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return fmt.Errorf("failed to encode claims: %w", err)
+	}
+
+	StripIdentity(r.Header)
+	r.Header.Set(HeaderUserID, claims.UserID)
+	r.Header.Set(HeaderUserRoles, strings.Join(claims.Roles, ","))
+	r.Header.Set(HeaderUserClaims, base64.RawURLEncoding.EncodeToString(data))
+	r.Header.Set(HeaderIdentityTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+	r.Header.Set(HeaderIdentitySignature, hex.EncodeToString(identityMAC(r, secret)))
+	return nil
+}
+
+// VerifyIdentity returns the claims of the identity headers of a request, which must have been
+// signed with the secret within the last minute
+func VerifyIdentity(r *http.Request, secret []byte) (*UserClaims, error) {
+	signature, err := hex.DecodeString(r.Header.Get(HeaderIdentitySignature))
+	if err != nil || !hmac.Equal(signature, identityMAC(r, secret)) {
+		return nil, ErrInvalidIdentity
+	}
+
+	timestamp, err := strconv.ParseInt(r.Header.Get(HeaderIdentityTimestamp), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidIdentity
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > identityMaxAge || age < -identityMaxAge {
+		return nil, fmt.Errorf("%w: signature expired", ErrInvalidIdentity)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(r.Header.Get(HeaderUserClaims))
+	if err != nil {
+		return nil, ErrInvalidIdentity
+	}
+	claims := &UserClaims{}
+	if err := json.Unmarshal(data, claims); err != nil {
+		return nil, ErrInvalidIdentity
+	}
+
+	return claims, nil
+}
+
+// identityMAC computes the HMAC of the identity headers of a request with its method and path
+func identityMAC(r *http.Request, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	for _, part := range []string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.Header.Get(HeaderUserID),
+		r.Header.Get(HeaderUserRoles),
+		r.Header.Get(HeaderUserClaims),
+		r.Header.Get(HeaderIdentityTimestamp),
+	} {
+		mac.Write([]byte(part))
+		mac.Write([]byte{'\n'})
+	}
+	return mac.Sum(nil)
+}
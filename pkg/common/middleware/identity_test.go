@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("gateway-secret")
+
+// signedRequest returns a request whose identity headers are signed with the test secret
+func signedRequest(t *testing.T, method, path string) *http.Request {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, nil)
+	claims := &UserClaims{UserID: "user-1", Email: "user@example.com", Roles: []string{"user"}, Permissions: []string{"survey:create"}}
+	claims.ID = "token-1"
+	if err := SignIdentity(r, claims, testSecret); err != nil {
+		t.Fatalf("SignIdentity returned error: %v", err)
+	}
+	return r
+}
+
+func TestVerifyIdentityReturnsSignedClaims(t *testing.T) {
+	r := signedRequest(t, http.MethodGet, "/api/v1/surveys")
+
+	claims, err := VerifyIdentity(r, testSecret)
+	if err != nil {
+		t.Fatalf("VerifyIdentity returned error: %v", err)
+	}
+	if claims.UserID != "user-1" || claims.Email != "user@example.com" || claims.ID != "token-1" {
+		t.Errorf("claims = %+v, want the signed claims", claims)
+	}
+	if len(claims.Permissions) != 1 || claims.Permissions[0] != "survey:create" {
+		t.Errorf("permissions = %v, want [survey:create]", claims.Permissions)
+	}
+}
+
+func TestVerifyIdentityRejectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(r *http.Request)
+	}{
+		{"other user", func(r *http.Request) { r.Header.Set(HeaderUserID, "user-2") }},
+		{"other roles", func(r *http.Request) { r.Header.Set(HeaderUserRoles, "admin") }},
+		{"other claims", func(r *http.Request) { r.Header.Set(HeaderUserClaims, "e30") }},
+		{"other method", func(r *http.Request) { r.Method = http.MethodDelete }},
+		{"other path", func(r *http.Request) { r.URL.Path = "/api/v1/users" }},
+		{"missing signature", func(r *http.Request) { r.Header.Del(HeaderIdentitySignature) }},
+		{"malformed signature", func(r *http.Request) { r.Header.Set(HeaderIdentitySignature, "not hex") }},
+		{"other timestamp", func(r *http.Request) {
+			timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderIdentityTimestamp), 10, 64)
+			r.Header.Set(HeaderIdentityTimestamp, strconv.FormatInt(timestamp+1, 10))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := signedRequest(t, http.MethodGet, "/api/v1/surveys")
+			tt.tamper(r)
+
+			if _, err := VerifyIdentity(r, testSecret); !errors.Is(err, ErrInvalidIdentity) {
+				t.Errorf("VerifyIdentity error = %v, want ErrInvalidIdentity", err)
+			}
+		})
+	}
+}
+
+func TestVerifyIdentityRejectsOtherSecret(t *testing.T) {
+	r := signedRequest(t, http.MethodGet, "/api/v1/surveys")
+
+	if _, err := VerifyIdentity(r, []byte("other-secret")); !errors.Is(err, ErrInvalidIdentity) {
+		t.Errorf("VerifyIdentity error = %v, want ErrInvalidIdentity", err)
+	}
+}
+
+func TestVerifyIdentityRejectsExpiredSignature(t *testing.T) {
+	for _, age := range []time.Duration{identityMaxAge + time.Minute, -identityMaxAge - time.Minute} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/surveys", nil)
+		r.Header.Set(HeaderUserID, "user-1")
+		r.Header.Set(HeaderUserClaims, "e30")
+		r.Header.Set(HeaderIdentityTimestamp, strconv.FormatInt(time.Now().Add(-age).Unix(), 10))
+		// Sign with the stale timestamp in place
+		r.Header.Set(HeaderIdentitySignature, hex.EncodeToString(identityMAC(r, testSecret)))
+
+		if _, err := VerifyIdentity(r, testSecret); !errors.Is(err, ErrInvalidIdentity) {
+			t.Errorf("age %v: VerifyIdentity error = %v, want ErrInvalidIdentity", age, err)
+		}
+	}
+}
+
+func TestSignIdentityReplacesClientHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/surveys", nil)
+	r.Header.Add(HeaderUserID, "attacker")
+	r.Header.Add(HeaderUserRoles, "admin")
+
+	if err := SignIdentity(r, &UserClaims{UserID: "user-1"}, testSecret); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Header.Values(HeaderUserID); len(got) != 1 || got[0] != "user-1" {
+		t.Errorf("%s = %v, want [user-1]", HeaderUserID, got)
+	}
+	if got := r.Header.Get(HeaderUserRoles); got != "" {
+		t.Errorf("%s = %q, want empty", HeaderUserRoles, got)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+// HasPermission reports whether the authenticated user has a permission
+func HasPermission(ctx context.Context, permission string) bool {
+	user, err := GetUserFromContext(ctx)
+	if err != nil {
+		return false
+	}
+
+	for _, p := range user.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission middleware rejects users who lack any of the given permissions. It must
+// run after Auth.
+func RequirePermission(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := GetUserFromContext(r.Context()); err != nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			for _, permission := range permissions {
+				if !HasPermission(r.Context(), permission) {
+					http.Error(w, "Forbidden", http.StatusForbidden)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
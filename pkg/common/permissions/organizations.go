@@ -0,0 +1,73 @@
+package permissions
+
+// Permissions on a single survey. The owner of a personal survey has them, and members of an
+// organization get them on the surveys of the organization through their organization role.
+const (
+	// SurveyView allows viewing a survey while it is a draft and its status history
+	SurveyView = "survey:view"
+	// SurveyEdit allows changing the details and questions of a survey
+	SurveyEdit = "survey:edit"
+	// SurveyManage allows deleting a survey and transferring it to another owner
+	SurveyManage = "survey:manage"
+)
+
+// Organization roles, from the most to the least privileged
+const (
+	// OrgRoleOwner can do everything, including managing other owners
+	OrgRoleOwner = "owner"
+	// OrgRoleAdmin manages the organization, its members and all of its surveys
+	OrgRoleAdmin = "admin"
+	// OrgRoleEditor creates and edits surveys and reads their results
+	OrgRoleEditor = "editor"
+	// OrgRoleViewer reads surveys and their results
+	OrgRoleViewer = "viewer"
+)
+
+// OrgRoles lists every organization role, from the most to the least privileged
+var OrgRoles = []string{OrgRoleOwner, OrgRoleAdmin, OrgRoleEditor, OrgRoleViewer}
+
+// orgRolePermissions lists the permissions each organization role grants on the surveys of
+// the organization
+var orgRolePermissions = map[string][]string{
+	OrgRoleOwner:  {SurveyView, SurveyEdit, SurveyManage, SurveyCreate, SurveyPublish, ResultsRead, ResultsExport},
+	OrgRoleAdmin:  {SurveyView, SurveyEdit, SurveyManage, SurveyCreate, SurveyPublish, ResultsRead, ResultsExport},
+	OrgRoleEditor: {SurveyView, SurveyEdit, SurveyCreate, SurveyPublish, ResultsRead, ResultsExport},
+	OrgRoleViewer: {SurveyView, ResultsRead},
+}
+
+// ValidOrgRole reports whether an organization role exists
+func ValidOrgRole(role string) bool {
+	_, ok := orgRolePermissions[role]
+	return ok
+}
+
+// OrgRolePermissions returns the permissions an organization role grants on the surveys of the
+// organization
+func OrgRolePermissions(role string) []string {
+	return append([]string(nil), orgRolePermissions[role]...)
+}
+
+// OrgRoleAllows reports whether an organization role grants a permission
+func OrgRoleAllows(role, permission string) bool {
+	for _, p := range orgRolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// OrgRoleAtLeast reports whether an organization role is at least as privileged as another
+func OrgRoleAtLeast(role, minimum string) bool {
+	return ValidOrgRole(role) && orgRoleRank(role) <= orgRoleRank(minimum)
+}
+
+// orgRoleRank returns the position of a role in OrgRoles, where lower is more privileged
+func orgRoleRank(role string) int {
+	for i, r := range OrgRoles {
+		if r == role {
+			return i
+		}
+	}
+	return len(OrgRoles)
+}
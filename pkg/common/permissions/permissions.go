@@ -0,0 +1,62 @@
+package permissions
+
+// Permissions granted to roles. The user service stores which roles have which permissions
+// and embeds the permissions of a user in their access token.
+const (
+	// SurveyCreate allows creating surveys
+	SurveyCreate = "survey:create"
+	// SurveyPublish allows publishing surveys the user can manage
+	SurveyPublish = "survey:publish"
+	// SurveyManageAny allows managing the surveys of all users
+	SurveyManageAny = "survey:manage_any"
+	// ResultsRead allows reading the results and analytics of surveys
+	ResultsRead = "results:read"
+	// ResultsExport allows exporting the results of surveys
+	ResultsExport = "results:export"
+	// UserManage allows managing other users, their roles and their lockouts
+	UserManage = "user:manage"
+	// RoleManage allows managing roles and the permissions they grant
+	RoleManage = "role:manage"
+	// AuditRead allows reading the audit log
+	AuditRead = "audit:read"
+	// GatewayRead allows reading the routing table of the API gateway and the state of its
+	// upstreams
+	GatewayRead = "gateway:read"
+)
+
+// All lists every permission
+var All = []string{
+	SurveyCreate,
+	SurveyPublish,
+	SurveyManageAny,
+	ResultsRead,
+	ResultsExport,
+	UserManage,
+	RoleManage,
+	AuditRead,
+	GatewayRead,
+}
+
+// Valid reports whether a permission exists
+func Valid(permission string) bool {
+	for _, p := range All {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Scopes lists every permission an API key can be limited to, the permissions of roles and the
+// permissions on single surveys
+var Scopes = append(append([]string{}, All...), SurveyView, SurveyEdit, SurveyManage)
+
+// ValidScope reports whether an API key can be limited to a permission
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+)
+
+const (
+	// CheckPath is the path of the user service endpoint that reports whether a token was revoked
+	CheckPath = "/api/v1/tokens/revoked"
+	// defaultCacheTTL is how long a token is trusted before it is checked again
+	defaultCacheTTL = 10 * time.Second
+	// checkTimeout bounds checking a token
+	checkTimeout = 10 * time.Second
+)
+
+// checkRequest is the request body of the check endpoint
+type checkRequest struct {
+	TokenID  string `json:"token_id"`
+	UserID   string `json:"user_id"`
+	IssuedAt int64  `json:"issued_at"`
+}
+
+// checkResponse is the response body of the check endpoint
+type checkResponse struct {
+	Revoked bool `json:"revoked"`
+}
+
+// cachedResult is whether a token was revoked together with when the result stops being trusted
+type cachedResult struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+// Client checks with the user service whether access tokens were revoked, so that services
+// reject tokens after logout. Tokens that were not revoked are trusted for a short time, so
+// that not every request makes a call to the user service, while revoked tokens stay rejected
+// until they expire. It implements middleware.RevocationChecker.
+type Client struct {
+	client *commonhttp.Client
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedResult
+}
+
+// NewClient creates a new client for the user service at baseURL that trusts tokens that were
+// not revoked for ttl. A zero ttl uses a default of 10 seconds.
+func NewClient(baseURL string, ttl time.Duration) *Client {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+
+	return &Client{
+		client: commonhttp.NewClient(baseURL, checkTimeout),
+		ttl:    ttl,
+		cache:  make(map[string]cachedResult),
+	}
+}
+
+// IsRevoked reports whether the token of the claims was revoked
+func (c *Client) IsRevoked(ctx context.Context, claims *middleware.UserClaims) (bool, error) {
+	var issuedAt int64
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Unix()
+	}
+	id := fmt.Sprintf("%s:%s:%d", claims.UserID, claims.ID, issuedAt)
+	now := time.Now()
+
+	c.mu.Lock()
+	cached, ok := c.cache[id]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.revoked, nil
+	}
+
+	var resp checkResponse
+	req := checkRequest{TokenID: claims.ID, UserID: claims.UserID, IssuedAt: issuedAt}
+	if err := c.client.Post(ctx, CheckPath, req, &resp); err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	// A revoked token stays revoked, so it is not checked again before it expires
+	expiresAt := now.Add(c.ttl)
+	if claims.ExpiresAt != nil && (resp.Revoked || claims.ExpiresAt.Time.Before(expiresAt)) {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	c.mu.Lock()
+	c.cache[id] = cachedResult{revoked: resp.Revoked, expiresAt: expiresAt}
+	c.mu.Unlock()
+
+	return resp.Revoked, nil
+}
+
+// RunCleanup drops the expired results from the cache periodically until the context is
+// cancelled
+func (c *Client) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.prune(time.Now())
+		}
+	}
+}
+
+// prune removes the expired entries of the cache
+func (c *Client) prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, cached := range c.cache {
+		if !now.Before(cached.expiresAt) {
+			delete(c.cache, id)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+)
+
+// PublicRoute is a route of a service that requires auth which anonymous users may call.
+// Segments of the pattern in braces, such as {id}, match any single path segment.
+type PublicRoute struct {
+	Method  string `yaml:"method" json:"method"`
+	Pattern string `yaml:"pattern" json:"pattern"`
+}
+
+// Matches reports whether a request is for the route
+func (p PublicRoute) Matches(r *http.Request) bool {
+	if r.Method != p.Method {
+		return false
+	}
+
+	patternSegments := strings.Split(strings.Trim(p.Pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(patternSegments) != len(pathSegments) {
+		return false
+	}
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isPublic reports whether anonymous users may call the route of a request
+func (c RouteConfig) isPublic(r *http.Request) bool {
+	for _, public := range c.PublicRoutes {
+		if public.Matches(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator verifies the token or API key of requests before they are proxied, so that
+// services receive the identity of the user from the gateway
+type Authenticator struct {
+	keys    middleware.KeySource
+	options []middleware.AuthOption
+	logger  *logging.Logger
+}
+
+// NewAuthenticator creates a new authenticator that verifies tokens with the keys of the key
+// source, rejects tokens the revocation checker reports as revoked and verifies API keys with
+// the verifier
+func NewAuthenticator(keys middleware.KeySource, apiKeys middleware.APIKeyVerifier, revocations middleware.RevocationChecker, logger *logging.Logger) *Authenticator {
+	return &Authenticator{
+		keys: keys,
+		options: []middleware.AuthOption{
+			middleware.WithAPIKeyVerifier(apiKeys),
+			middleware.WithRevocationChecker(revocations),
+		},
+		logger: logger,
+	}
+}
+
+// Middleware authenticates the requests to a route. Identity headers sent by the client are
+// always removed. Routes that require auth only get authenticated requests, except for their
+// public routes, while routes with optional auth get anonymous requests and requests with
+// invalid credentials as well and their service decides.
+func (a *Authenticator) Middleware(route RouteConfig) func(http.Handler) http.Handler {
+	required := route.Auth == AuthRequired
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			middleware.StripIdentity(r.Header)
+
+			if r.Header.Get("Authorization") == "" {
+				if required && !route.isPublic(r) {
+					errors.HandleError(w, errors.ErrUnauthorized, "Authorization header is required")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			claims, status, message := middleware.Authenticate(r, a.keys, a.options...)
+			if claims == nil {
+				if required {
+					if status != http.StatusUnauthorized {
+						a.logger.Error("Failed to authenticate request", "error", message)
+						errors.WriteError(w, errors.ErrInternalServer, status, message)
+						return
+					}
+					errors.HandleError(w, errors.ErrUnauthorized, message)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(middleware.ContextWithUser(r.Context(), claims)))
+		})
+	}
+}
+
+// signIdentity sets the identity headers of the authenticated user of a proxied request. It
+// runs after the request was rewritten for the service, whose path the signature covers.
+func signIdentity(req *http.Request, secret []byte, logger *logging.Logger) {
+	middleware.StripIdentity(req.Header)
+	if len(secret) == 0 {
+		return
+	}
+
+	claims, err := middleware.GetUserFromContext(req.Context())
+	if err != nil {
+		return
+	}
+	if err := middleware.SignIdentity(req, claims, secret); err != nil {
+		logger.Error("Failed to sign identity", "user_id", claims.UserID, "error", err)
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/config"
+	"github.com/VitaliySynytskyi/pollpulse/services/api-gateway/ratelimit"
+	"github.com/VitaliySynytskyi/pollpulse/services/api-gateway/upstream"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults of the settings routes leave out
+const (
+	defaultTimeout            = 60 * time.Second
+	defaultHealthCheckPath    = "/health"
+	defaultHealthCheckEvery   = 10 * time.Second
+	defaultHealthCheckTimeout = 2 * time.Second
+	defaultFailureThreshold   = 5
+	defaultOpenTimeout        = 30 * time.Second
+)
+
+// AuthPolicy selects which requests of a route the gateway lets through
+type AuthPolicy string
+
+const (
+	// AuthRequired only lets authenticated requests through, except to the public routes
+	AuthRequired AuthPolicy = "required"
+	// AuthOptional lets every request through and forwards the identity of authenticated ones,
+	// for services that authenticate requests themselves
+	AuthOptional AuthPolicy = "optional"
+)
+
+// Config is the routing configuration of the gateway
+type Config struct {
+	Routes []RouteConfig `yaml:"routes" json:"routes"`
+}
+
+// RouteConfig routes the requests under a path prefix to the upstreams of a service
+type RouteConfig struct {
+	Name   string `yaml:"name" json:"name"`
+	Prefix string `yaml:"prefix" json:"prefix"`
+	// Upstreams are the base URLs of the replicas of the service
+	Upstreams []string `yaml:"upstreams" json:"upstreams"`
+	// Balance selects the upstream of each request, round_robin if empty
+	Balance     upstream.Strategy `yaml:"balance" json:"balance"`
+	StripPrefix bool              `yaml:"strip_prefix" json:"strip_prefix"`
+	Auth        AuthPolicy        `yaml:"auth" json:"auth"`
+	// PublicRoutes are the routes anonymous users may call if the route requires auth
+	PublicRoutes []PublicRoute `yaml:"public_routes" json:"public_routes,omitempty"`
+	// Timeout bounds each request, 60s if zero
+	Timeout        Duration             `yaml:"timeout" json:"timeout"`
+	RateLimits     []RateLimitConfig    `yaml:"rate_limits" json:"rate_limits,omitempty"`
+	HealthCheck    HealthCheckConfig    `yaml:"health_check" json:"health_check"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker" json:"circuit_breaker"`
+}
+
+// HealthCheckConfig configures the active health checks of the upstreams of a route
+type HealthCheckConfig struct {
+	// Path is requested relative to the base URL of each upstream, /health if empty
+	Path string `yaml:"path" json:"path"`
+	// Interval is the time between checks, 10s if zero
+	Interval Duration `yaml:"interval" json:"interval"`
+	// Timeout bounds each check, 2s if zero
+	Timeout Duration `yaml:"timeout" json:"timeout"`
+}
+
+// CircuitBreakerConfig configures the circuit breaker of each upstream of a route
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that open the breaker, 5 if zero
+	FailureThreshold int `yaml:"failure_threshold" json:"failure_threshold"`
+	// OpenTimeout is how long the breaker fails fast before probing the upstream, 30s if zero
+	OpenTimeout Duration `yaml:"open_timeout" json:"open_timeout"`
+}
+
+// RateLimitConfig limits the requests to the paths under a prefix of a route
+type RateLimitConfig struct {
+	// Prefix is the prefix of the route if empty
+	Prefix string          `yaml:"prefix" json:"prefix"`
+	Key    ratelimit.KeyBy `yaml:"key" json:"key"`
+	// Rate is the number of requests per s, m or h, such as 10/m
+	Rate  string `yaml:"rate" json:"rate"`
+	Burst int    `yaml:"burst" json:"burst"`
+}
+
+// Duration is a time.Duration written as a string such as 30s in the configuration
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	return d.parse(node.Value)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as 30s")
+	}
+	return d.parse(s)
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// parse parses a duration such as 30s
+func (d *Duration) parse(s string) error {
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// LoadConfig reads and validates a routing configuration file. Files ending in .json are JSON,
+// others YAML. References to environment variables such as ${USER_SERVICE_URL} are expanded.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routing configuration: %w", err)
+	}
+	data = []byte(os.ExpandEnv(string(data)))
+
+	var cfg Config
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&cfg)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode routing configuration: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks the configuration and fills in defaults. It reports every problem at once.
+func (c *Config) Validate() error {
+	var problems []error
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if len(c.Routes) == 0 {
+		fail("no routes configured")
+	}
+
+	names := make(map[string]bool)
+	prefixes := make(map[string]bool)
+	for i := range c.Routes {
+		route := &c.Routes[i]
+		if route.Name == "" {
+			fail("route %d has no name", i+1)
+			continue
+		}
+		if names[route.Name] {
+			fail("route %s: duplicate name", route.Name)
+		}
+		names[route.Name] = true
+
+		route.Prefix = strings.TrimSuffix(route.Prefix, "/")
+		if !strings.HasPrefix(route.Prefix, "/") {
+			fail("route %s: prefix must start with / and not be /", route.Name)
+		} else if prefixes[route.Prefix] {
+			fail("route %s: prefix %s is already routed", route.Name, route.Prefix)
+		}
+		prefixes[route.Prefix] = true
+
+		if len(route.Upstreams) == 0 {
+			fail("route %s: no upstreams", route.Name)
+		}
+		for _, target := range route.Upstreams {
+			u, err := url.Parse(target)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				fail("route %s: upstream %q must be an http or https URL", route.Name, target)
+			}
+		}
+
+		if route.Balance == "" {
+			route.Balance = upstream.RoundRobin
+		} else if err := route.Balance.Validate(); err != nil {
+			fail("route %s: %v", route.Name, err)
+		}
+
+		switch route.Auth {
+		case AuthRequired, AuthOptional:
+		case "":
+			route.Auth = AuthRequired
+		default:
+			fail("route %s: unknown auth policy %q, expected required or optional", route.Name, route.Auth)
+		}
+
+		for _, public := range route.PublicRoutes {
+			if !validMethod(public.Method) {
+				fail("route %s: public route %s %s has an invalid method", route.Name, public.Method, public.Pattern)
+			}
+			if !underPrefix(public.Pattern, route.Prefix) {
+				fail("route %s: public route %s is not under the prefix of the route", route.Name, public.Pattern)
+			}
+		}
+
+		if route.Timeout < 0 {
+			fail("route %s: timeout must not be negative", route.Name)
+		} else if route.Timeout == 0 {
+			route.Timeout = Duration(defaultTimeout)
+		}
+
+		check := &route.HealthCheck
+		if check.Path == "" {
+			check.Path = defaultHealthCheckPath
+		} else if !strings.HasPrefix(check.Path, "/") {
+			fail("route %s: health check path must start with /", route.Name)
+		}
+		if check.Interval < 0 || check.Timeout < 0 {
+			fail("route %s: health check interval and timeout must not be negative", route.Name)
+		}
+		if check.Interval == 0 {
+			check.Interval = Duration(defaultHealthCheckEvery)
+		}
+		if check.Timeout == 0 {
+			check.Timeout = Duration(defaultHealthCheckTimeout)
+		}
+
+		breaker := &route.CircuitBreaker
+		if breaker.FailureThreshold < 0 || breaker.OpenTimeout < 0 {
+			fail("route %s: circuit breaker threshold and open timeout must not be negative", route.Name)
+		}
+		if breaker.FailureThreshold == 0 {
+			breaker.FailureThreshold = defaultFailureThreshold
+		}
+		if breaker.OpenTimeout == 0 {
+			breaker.OpenTimeout = Duration(defaultOpenTimeout)
+		}
+
+		for j := range route.RateLimits {
+			limit := &route.RateLimits[j]
+			if limit.Prefix == "" {
+				limit.Prefix = route.Prefix
+			}
+			if !underPrefix(limit.Prefix, route.Prefix) {
+				fail("route %s: rate limit prefix %s is not under the prefix of the route", route.Name, limit.Prefix)
+			}
+			if _, err := limit.rule(); err != nil {
+				fail("route %s: rate limit of %s: %v", route.Name, limit.Prefix, err)
+			}
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// RateLimitRules returns the rate limit rules of all routes
+func (c *Config) RateLimitRules() []ratelimit.Rule {
+	var rules []ratelimit.Rule
+	for _, route := range c.Routes {
+		for _, limit := range route.RateLimits {
+			if rule, err := limit.rule(); err == nil {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return rules
+}
+
+// rule converts the rate limit to a rule of the limiter
+func (l RateLimitConfig) rule() (ratelimit.Rule, error) {
+	if err := l.Key.Validate(); err != nil {
+		return ratelimit.Rule{}, err
+	}
+	rate, err := ratelimit.ParseRate(l.Rate)
+	if err != nil {
+		return ratelimit.Rule{}, err
+	}
+	if l.Burst < 1 {
+		return ratelimit.Rule{}, fmt.Errorf("burst must be a positive integer")
+	}
+
+	return ratelimit.Rule{
+		Prefix: l.Prefix,
+		KeyBy:  l.Key,
+		Limit:  ratelimit.Limit{Rate: rate, Burst: l.Burst},
+	}, nil
+}
+
+// defaultConfig is the routing configuration without a configuration file. The upstreams are
+// taken from the environment and the rate limits from RATE_LIMITS if set.
+func defaultConfig() (*Config, error) {
+	cfg := &Config{
+		Routes: []RouteConfig{
+			{
+				Name:      "user-service",
+				Prefix:    "/api/v1",
+				Upstreams: []string{config.GetEnv("USER_SERVICE_URL", "http://localhost:8081")},
+				Auth:      AuthOptional, // Some endpoints require auth, handled by the service
+			},
+			{
+				Name:      "survey-service",
+				Prefix:    "/api/v1/surveys",
+				Upstreams: []string{config.GetEnv("SURVEY_SERVICE_URL", "http://localhost:8082")},
+				Auth:      AuthRequired, // Most endpoints require auth
+				PublicRoutes: []PublicRoute{
+					// Published surveys are taken anonymously, the service hides drafts
+					{Method: http.MethodGet, Pattern: "/api/v1/surveys/{id}"},
+				},
+			},
+			{
+				Name:      "result-service",
+				Prefix:    "/api/v1/results",
+				Upstreams: []string{config.GetEnv("RESULT_SERVICE_URL", "http://localhost:8083")},
+				Auth:      AuthRequired, // All endpoints require auth except responding to surveys
+				PublicRoutes: []PublicRoute{
+					{Method: http.MethodPost, Pattern: "/api/v1/results/responses"},
+					{Method: http.MethodPost, Pattern: "/api/v1/results/sessions"},
+					{Method: http.MethodPost, Pattern: "/api/v1/results/sessions/{id}/answers"},
+					{Method: http.MethodPost, Pattern: "/api/v1/results/sessions/{id}/complete"},
+				},
+			},
+		},
+	}
+
+	for _, spec := range config.GetEnvSlice("RATE_LIMITS", ",", defaultRateLimits) {
+		rules, err := ratelimit.ParseRules([]string{spec})
+		if err != nil {
+			return nil, err
+		}
+		rule := rules[0]
+
+		// Each rule belongs to the route that serves its prefix
+		route := cfg.route(rule.Prefix)
+		if route == nil {
+			return nil, fmt.Errorf("rate limit prefix %s is not routed", rule.Prefix)
+		}
+		route.RateLimits = append(route.RateLimits, RateLimitConfig{
+			Prefix: rule.Prefix,
+			Key:    rule.KeyBy,
+			Rate:   strings.Fields(spec)[2],
+			Burst:  rule.Limit.Burst,
+		})
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// route returns the route with the longest prefix of a path
+func (c *Config) route(path string) *RouteConfig {
+	var match *RouteConfig
+	for i := range c.Routes {
+		route := &c.Routes[i]
+		if underPrefix(path, route.Prefix) && (match == nil || len(route.Prefix) > len(match.Prefix)) {
+			match = route
+		}
+	}
+	return match
+}
+
+// underPrefix reports whether a path is a prefix or below it
+func underPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// validMethod reports whether a method is a standard HTTP method
+func validMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
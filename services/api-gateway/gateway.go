@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/services/api-gateway/ratelimit"
+	"github.com/VitaliySynytskyi/pollpulse/services/api-gateway/upstream"
+	"github.com/go-chi/chi/v5"
+)
+
+// Gateway proxies requests to the services by its routing table. Reloading the routing
+// configuration swaps in a new table, while requests in flight finish on the old one.
+type Gateway struct {
+	// path is the routing configuration file, empty to route by the environment
+	path           string
+	authenticator  *Authenticator
+	limits         ratelimit.Store
+	identitySecret []byte
+	logger         *logging.Logger
+
+	table  atomic.Pointer[routingTable]
+	reload sync.Mutex
+}
+
+// routingTable is a loaded routing configuration with the router that serves it
+type routingTable struct {
+	config   *Config
+	loadedAt time.Time
+	router   http.Handler
+	// pools holds the upstreams of each route by its name
+	pools map[string]*upstream.Pool
+	// stop ends the health checks of the upstreams
+	stop context.CancelFunc
+}
+
+// NewGateway creates a new gateway that routes by the configuration file at path, or by the
+// environment if path is empty. Rate limits are only enforced with a store.
+func NewGateway(path string, authenticator *Authenticator, limits ratelimit.Store, identitySecret []byte, logger *logging.Logger) (*Gateway, error) {
+	g := &Gateway{
+		path:           path,
+		authenticator:  authenticator,
+		limits:         limits,
+		identitySecret: identitySecret,
+		logger:         logger,
+	}
+	if err := g.Reload(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Reload loads the routing configuration and swaps in its routing table. The current table is
+// kept if the configuration is invalid.
+func (g *Gateway) Reload() error {
+	g.reload.Lock()
+	defer g.reload.Unlock()
+
+	var cfg *Config
+	var err error
+	if g.path == "" {
+		cfg, err = defaultConfig()
+	} else {
+		cfg, err = LoadConfig(g.path)
+	}
+	if err != nil {
+		return err
+	}
+
+	if previous := g.table.Swap(g.newTable(cfg)); previous != nil {
+		previous.stop()
+	}
+	g.logger.Info("Loaded routing configuration", "source", g.source(), "routes", len(cfg.Routes))
+	return nil
+}
+
+// Close stops the health checks of the upstreams
+func (g *Gateway) Close() {
+	g.table.Load().stop()
+}
+
+// Watch reloads the routing configuration on SIGHUP and when the content of the configuration
+// file changes, which it checks every interval, until the context is done
+func (g *Gateway) Watch(ctx context.Context, interval time.Duration) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var ticks <-chan time.Time
+	checksum, _ := g.checksum()
+	if g.path != "" && interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			g.logger.Info("Reloading routing configuration on SIGHUP")
+			checksum, _ = g.checksum()
+		case <-ticks:
+			current, err := g.checksum()
+			if err != nil {
+				g.logger.Error("Failed to read routing configuration", "path", g.path, "error", err)
+				continue
+			}
+			// A broken file is only reported once, not on every check
+			if current == checksum {
+				continue
+			}
+			checksum = current
+			g.logger.Info("Reloading changed routing configuration", "path", g.path)
+		}
+
+		if err := g.Reload(); err != nil {
+			g.logger.Error("Invalid routing configuration, keeping the current routes", "source", g.source(), "error", err)
+		}
+	}
+}
+
+// checksum hashes the content of the configuration file
+func (g *Gateway) checksum() ([sha256.Size]byte, error) {
+	if g.path == "" {
+		return [sha256.Size]byte{}, nil
+	}
+	data, err := os.ReadFile(g.path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// source describes where the routing configuration is loaded from
+func (g *Gateway) source() string {
+	if g.path == "" {
+		return "environment"
+	}
+	return g.path
+}
+
+// ServeHTTP proxies a request by the current routing table
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.table.Load().router.ServeHTTP(w, r)
+}
+
+// ServeRoutes responds with the active routing table
+func (g *Gateway) ServeRoutes(w http.ResponseWriter, r *http.Request) {
+	table := g.table.Load()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"source":    g.source(),
+		"loaded_at": table.loadedAt,
+		"routes":    table.config.Routes,
+	})
+}
+
+// ServeUpstreams responds with the health and circuit breaker state of the upstreams of each
+// route
+func (g *Gateway) ServeUpstreams(w http.ResponseWriter, r *http.Request) {
+	table := g.table.Load()
+
+	routes := make([]map[string]interface{}, 0, len(table.config.Routes))
+	for _, route := range table.config.Routes {
+		routes = append(routes, map[string]interface{}{
+			"name":      route.Name,
+			"balance":   route.Balance,
+			"upstreams": table.pools[route.Name].Status(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"routes": routes,
+	})
+}
+
+// newTable creates the routing table of a routing configuration and starts the health checks
+// of its upstreams
+func (g *Gateway) newTable(cfg *Config) *routingTable {
+	ctx, stop := context.WithCancel(context.Background())
+	table := &routingTable{
+		config:   cfg,
+		loadedAt: time.Now(),
+		pools:    make(map[string]*upstream.Pool),
+		stop:     stop,
+	}
+
+	for _, route := range cfg.Routes {
+		targets := make([]*url.URL, len(route.Upstreams))
+		for i, target := range route.Upstreams {
+			// The upstreams were validated when the configuration was loaded
+			targets[i], _ = url.Parse(target)
+		}
+		pool := upstream.NewPool(targets, route.Balance, upstream.BreakerSettings{
+			FailureThreshold: route.CircuitBreaker.FailureThreshold,
+			OpenTimeout:      time.Duration(route.CircuitBreaker.OpenTimeout),
+		})
+		table.pools[route.Name] = pool
+
+		name := route.Name
+		go pool.RunHealthChecks(ctx, upstream.HealthCheck{
+			Path:     route.HealthCheck.Path,
+			Interval: time.Duration(route.HealthCheck.Interval),
+			Timeout:  time.Duration(route.HealthCheck.Timeout),
+		}, func(u *upstream.Upstream, healthy bool, err error) {
+			if healthy {
+				g.logger.Info("Upstream is healthy", "service", name, "upstream", u.URL.String())
+			} else {
+				g.logger.Warn("Upstream is unhealthy", "service", name, "upstream", u.URL.String(), "error", err)
+			}
+		})
+	}
+
+	table.router = g.newRouter(cfg, table.pools)
+	return table
+}
+
+// newRouter creates the router of a routing configuration
+func (g *Gateway) newRouter(cfg *Config, pools map[string]*upstream.Pool) http.Handler {
+	r := chi.NewRouter()
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		errors.HandleError(w, errors.ErrNotFound, "No route matches the path")
+	})
+
+	var limiter *ratelimit.Limiter
+	if g.limits != nil {
+		limiter = ratelimit.NewLimiter(cfg.RateLimitRules(), g.limits, g.logger)
+	}
+
+	for _, route := range cfg.Routes {
+		// Limits by client IP apply before authentication, so that they also hold for requests
+		// with invalid credentials, and limits by user or API key once the request is
+		// authenticated
+		var routeMiddleware []func(http.Handler) http.Handler
+		if limiter != nil {
+			routeMiddleware = append(routeMiddleware, limiter.IPMiddleware)
+		}
+		routeMiddleware = append(routeMiddleware,
+			g.authenticator.Middleware(route),
+			withTimeout(time.Duration(route.Timeout)),
+		)
+		if limiter != nil {
+			routeMiddleware = append(routeMiddleware, limiter.Middleware)
+		}
+		r.With(routeMiddleware...).Handle(route.Prefix+"/*", g.newProxy(route, pools[route.Name]))
+	}
+
+	return r
+}
+
+// newProxy creates the reverse proxy of a route, which sends each request to an upstream of the
+// pool and reports its outcome to the circuit breaker of the upstream
+func (g *Gateway) newProxy(route RouteConfig, pool *upstream.Pool) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			// Strip the path prefix if needed
+			if route.StripPrefix {
+				pr.Out.URL.Path = ensureLeadingSlash(strings.TrimPrefix(pr.Out.URL.Path, route.Prefix))
+				if pr.Out.URL.RawPath != "" {
+					pr.Out.URL.RawPath = ensureLeadingSlash(strings.TrimPrefix(pr.Out.URL.RawPath, route.Prefix))
+				}
+			}
+
+			pr.SetURL(attemptFromContext(pr.In.Context()).upstream.URL)
+			pr.SetXForwarded()
+			// Services take the address of the client from the gateway, never from the client
+			pr.Out.Header.Set(commonhttp.HeaderClientIP, commonhttp.ClientIP(pr.In))
+			pr.Out.Header.Set("X-Gateway", "PollPulse-API-Gateway")
+
+			signIdentity(pr.Out, g.identitySecret, g.logger)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// The upstream is up but cannot handle requests, such as while it shuts down
+			switch resp.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				attemptFromContext(resp.Request.Context()).failed = true
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			// Requests the client cancelled say nothing about the upstream
+			attempt := attemptFromContext(r.Context())
+			if stderrors.Is(r.Context().Err(), context.Canceled) {
+				attempt.cancelled = true
+				return
+			}
+
+			attempt.failed = true
+			g.logger.Error("Proxy error",
+				"service", route.Name,
+				"upstream", attempt.upstream.URL.String(),
+				"error", err,
+				"path", r.URL.Path,
+			)
+			if stderrors.Is(err, context.DeadlineExceeded) {
+				errors.HandleError(w, errors.ErrGatewayTimeout, fmt.Sprintf("Service %s did not respond in time", route.Name))
+				return
+			}
+			errors.HandleError(w, errors.ErrBadGateway, fmt.Sprintf("Service %s is unavailable", route.Name))
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target, err := pool.Pick()
+		if err != nil {
+			// Fail fast while every upstream is down
+			errors.HandleError(w, errors.ErrServiceUnavailable, fmt.Sprintf("Service %s is unavailable", route.Name))
+			return
+		}
+
+		// Log the request
+		g.logger.Info("Proxying request",
+			"service", route.Name,
+			"upstream", target.URL.String(),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote_addr", r.RemoteAddr,
+		)
+
+		attempt := &proxyAttempt{upstream: target}
+		proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), proxyAttemptKey{}, attempt)))
+		if attempt.cancelled {
+			target.Release()
+		} else {
+			target.Done(attempt.failed)
+		}
+	})
+}
+
+// proxyAttempt is the upstream a request is proxied to and its outcome
+type proxyAttempt struct {
+	upstream  *upstream.Upstream
+	failed    bool
+	cancelled bool
+}
+
+// proxyAttemptKey is the context key of the proxy attempt of a request
+type proxyAttemptKey struct{}
+
+// attemptFromContext returns the proxy attempt of a request
+func attemptFromContext(ctx context.Context) *proxyAttempt {
+	return ctx.Value(proxyAttemptKey{}).(*proxyAttempt)
+}
+
+// withTimeout bounds the time a request may take. The proxy responds with 504 Gateway Timeout
+// when the deadline passes.
+func withTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ensureLeadingSlash makes a path absolute
+func ensureLeadingSlash(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return "/" + path
+	}
+	return path
+}
@@ -4,28 +4,36 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/apikeys"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/config"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/database"
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/jwks"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	authmw "github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/permissions"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/revocation"
+	"github.com/VitaliySynytskyi/pollpulse/services/api-gateway/migrations"
+	"github.com/VitaliySynytskyi/pollpulse/services/api-gateway/ratelimit"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
-	"github.com/pollpulse/pkg/common/config"
-	"github.com/pollpulse/pkg/common/logging"
 )
 
-// ServiceConfig represents the configuration for a service
-type ServiceConfig struct {
-	Name            string
-	URL             string
-	AuthRequired    bool
-	PathPrefix      string
-	StripPathPrefix bool
+// defaultRateLimits protect the routes bots flood and limit each user or API key overall when
+// routing by the environment. The format is that of RATE_LIMITS, which replaces them.
+var defaultRateLimits = []string{
+	"/api/v1/login ip 10/m 10",
+	"/api/v1/register ip 5/m 5",
+	"/api/v1/password/forgot ip 5/m 5",
+	"/api/v1/results/responses ip 30/m 30",
+	"/api/v1/results/sessions ip 60/m 60",
+	"/api/v1 api_key 20/s 40",
 }
 
 func main() {
@@ -37,29 +45,51 @@ func main() {
 	})
 	logger.Info("Starting API gateway")
 
-	// Service configurations
-	services := []ServiceConfig{
-		{
-			Name:            "user-service",
-			URL:             config.GetEnv("USER_SERVICE_URL", "http://localhost:8081"),
-			AuthRequired:    false, // Some endpoints require auth, handled by the service
-			PathPrefix:      "/api/v1",
-			StripPathPrefix: false,
-		},
-		{
-			Name:            "survey-service",
-			URL:             config.GetEnv("SURVEY_SERVICE_URL", "http://localhost:8082"),
-			AuthRequired:    true, // Most endpoints require auth
-			PathPrefix:      "/api/v1/surveys",
-			StripPathPrefix: false,
-		},
-		{
-			Name:            "result-service",
-			URL:             config.GetEnv("RESULT_SERVICE_URL", "http://localhost:8083"),
-			AuthRequired:    true, // All endpoints require auth
-			PathPrefix:      "/api/v1/results",
-			StripPathPrefix: false,
-		},
+	// Verify tokens with the public keys of the user service and API keys and the revocation of
+	// tokens with the user service
+	userServiceURL := config.GetEnv("USER_SERVICE_URL", "http://localhost:8081")
+	keys := jwks.NewClient(
+		config.GetEnv("JWKS_URL", userServiceURL+jwks.Path),
+		config.GetEnvDuration("JWKS_CACHE_TTL", 5*time.Minute),
+	)
+	apiKeys := apikeys.NewClient(userServiceURL, config.GetEnvDuration("API_KEY_CACHE_TTL", 30*time.Second))
+	revocations := revocation.NewClient(userServiceURL, config.GetEnvDuration("REVOCATION_CACHE_TTL", 10*time.Second))
+	authenticator := NewAuthenticator(keys, apiKeys, revocations, logger)
+
+	// Services trust the identity of users the gateway signs with the shared secret
+	identitySecret := []byte(config.GetEnv("GATEWAY_IDENTITY_SECRET", ""))
+	if len(identitySecret) == 0 {
+		logger.Warn("GATEWAY_IDENTITY_SECRET is not set, services verify credentials themselves")
+	}
+
+	// Rate limit requests per route prefix. The buckets outlive reloads of the routes.
+	var limits ratelimit.Store
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	if config.GetEnvBool("RATE_LIMIT_ENABLED", true) {
+		store, closeStore := newRateLimitStore(backgroundCtx, logger)
+		defer closeStore()
+		limits = store
+	}
+
+	// Drop expired entries from the caches of the credential clients
+	go apiKeys.RunCleanup(backgroundCtx, time.Minute)
+	go revocations.RunCleanup(backgroundCtx, time.Minute)
+
+	// Route by the configuration file if set, otherwise by the environment
+	configPath := config.GetEnv("GATEWAY_CONFIG", "")
+	gateway, err := NewGateway(configPath, authenticator, limits, identitySecret, logger)
+	if err != nil {
+		logger.Fatal("Invalid routing configuration", "source", configPath, "error", err)
+	}
+	defer gateway.Close()
+	go gateway.Watch(backgroundCtx, config.GetEnvDuration("GATEWAY_CONFIG_WATCH_INTERVAL", 5*time.Second))
+
+	// Only trust the X-Forwarded-For header of the proxies in front of the gateway, so that
+	// clients cannot choose the address their requests are limited and audited by
+	trustedProxies, err := commonhttp.ParseTrustedProxies(config.GetEnvSlice("TRUSTED_PROXIES", ",", nil))
+	if err != nil {
+		logger.Fatal("Invalid trusted proxies", "error", err)
 	}
 
 	// Initialize router
@@ -67,71 +97,31 @@ func main() {
 
 	// Middleware
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(commonhttp.RealIP(trustedProxies))
+	r.Use(commonhttp.StripForwarded)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
 
 	// CORS configuration
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	// Set up proxy routes for each service
-	for _, service := range services {
-		targetURL, err := url.Parse(service.URL)
-		if err != nil {
-			logger.Fatal("Invalid service URL", "service", service.Name, "url", service.URL, "error", err)
-		}
-
-		// Create a proxy for the service
-		proxy := httputil.NewSingleHostReverseProxy(targetURL)
-
-		// Set up the proxy director
-		originalDirector := proxy.Director
-		proxy.Director = func(req *http.Request) {
-			originalDirector(req)
-			req.Header.Add("X-Gateway", "PollPulse-API-Gateway")
-			req.URL.Host = targetURL.Host
-			req.URL.Scheme = targetURL.Scheme
-			req.Host = targetURL.Host
-
-			// Strip the path prefix if needed
-			if service.StripPathPrefix {
-				req.URL.Path = strings.TrimPrefix(req.URL.Path, service.PathPrefix)
-				if !strings.HasPrefix(req.URL.Path, "/") {
-					req.URL.Path = "/" + req.URL.Path
-				}
-			}
-		}
-
-		// Set up error handler
-		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-			logger.Error("Proxy error", "service", service.Name, "error", err, "path", r.URL.Path)
-			w.WriteHeader(http.StatusBadGateway)
-			w.Write([]byte(fmt.Sprintf("Service %s is unavailable", service.Name)))
-		}
-
-		// Define the handler
-		handler := func(w http.ResponseWriter, r *http.Request) {
-			// Log the request
-			logger.Info("Proxying request",
-				"service", service.Name,
-				"method", r.Method,
-				"path", r.URL.Path,
-				"remote_addr", r.RemoteAddr,
-			)
-			proxy.ServeHTTP(w, r)
-		}
+	// Proxy the routes of the services
+	r.Handle("/*", gateway)
 
-		// Register the route
-		r.Handle(service.PathPrefix+"/*", http.HandlerFunc(handler))
-	}
+	// Active routing table and the health and circuit breakers of the upstreams
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(authmw.Auth(keys, authmw.WithAPIKeyVerifier(apiKeys)))
+		r.Use(authmw.RequirePermission(permissions.GatewayRead))
+		r.Get("/routes", gateway.ServeRoutes)
+		r.Get("/upstreams", gateway.ServeUpstreams)
+	})
 
 	// Health check endpoint
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -189,3 +179,52 @@ func main() {
 
 	logger.Info("Server stopped")
 }
+
+// newRateLimitStore creates the store of the rate limit buckets selected by RATE_LIMIT_BACKEND
+// and starts dropping full buckets in the background. Replicas of the gateway share the
+// buckets of the postgres backend, while each has its own with the memory backend.
+func newRateLimitStore(ctx context.Context, logger *logging.Logger) (ratelimit.Store, func()) {
+	cleanupInterval := config.GetEnvDuration("RATE_LIMIT_CLEANUP_INTERVAL", time.Minute)
+
+	switch backend := config.GetEnv("RATE_LIMIT_BACKEND", "memory"); backend {
+	case "memory":
+		store := ratelimit.NewMemoryStore()
+		go store.RunCleanup(ctx, cleanupInterval)
+		return store, func() {}
+
+	case "postgres":
+		db, err := database.Connect(&database.Config{
+			Host:     config.GetEnv("DB_HOST", "localhost"),
+			Port:     config.GetEnvInt("DB_PORT", 5432),
+			User:     config.GetEnv("DB_USER", "postgres"),
+			Password: config.GetEnv("DB_PASSWORD", "postgres"),
+			DBName:   config.GetEnv("DB_NAME", "pollpulse_gateway"),
+			SSLMode:  config.GetEnv("DB_SSLMODE", "disable"),
+		})
+		if err != nil {
+			logger.Fatal("Failed to connect to database", "error", err)
+		}
+
+		if config.GetEnvBool("MIGRATE_ON_START", true) {
+			migrator, err := database.NewMigrator(db, migrations.FS)
+			if err != nil {
+				logger.Fatal("Failed to load migrations", "error", err)
+			}
+			migrated, err := migrator.Up(context.Background())
+			if err != nil {
+				logger.Fatal("Failed to apply migrations", "error", err)
+			}
+			for _, migration := range migrated {
+				logger.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+			}
+		}
+
+		store := ratelimit.NewPostgresStore(db, logger)
+		go store.RunCleanup(ctx, cleanupInterval)
+		return store, func() { database.Close(db) }
+
+	default:
+		logger.Fatal("Unknown RATE_LIMIT_BACKEND, expected memory or postgres", "backend", backend)
+		return nil, nil
+	}
+}
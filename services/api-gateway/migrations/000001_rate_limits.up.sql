@@ -0,0 +1,11 @@
+-- Create rate_limit_buckets table to share the token buckets of rate limits between the
+-- replicas of the gateway
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key VARCHAR(512) PRIMARY KEY,  -- The route prefix and the client, user or API key
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    full_at TIMESTAMP WITH TIME ZONE NOT NULL  -- The bucket can be deleted once it is full
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_full_at ON rate_limit_buckets(full_at);
@@ -0,0 +1,193 @@
+// Package ratelimit limits the requests of clients to the API gateway with token buckets
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+)
+
+// Limit is a token bucket. Each request takes a token, and Rate tokens per second are added
+// back up to Burst tokens.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// KeyBy selects whose requests share a bucket
+type KeyBy string
+
+const (
+	// KeyIP limits each client IP address
+	KeyIP KeyBy = "ip"
+	// KeyUser limits each user, and anonymous requests by IP address
+	KeyUser KeyBy = "user"
+	// KeyAPIKey limits each API key, and other requests like KeyUser
+	KeyAPIKey KeyBy = "api_key"
+)
+
+// Rule limits the requests to the paths under a prefix
+type Rule struct {
+	Prefix string
+	KeyBy  KeyBy
+	Limit  Limit
+}
+
+// Result is the state of a bucket after a request tried to take a token
+type Result struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left
+	Remaining int
+	// RetryAfter is the time until the next token is added, if the request was not allowed
+	RetryAfter time.Duration
+	// ResetAfter is the time until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// Store keeps the buckets of the limiter
+type Store interface {
+	// Take takes a token from the bucket of a key, which starts full
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Limiter rate limits requests by the rule of the longest matching route prefix
+type Limiter struct {
+	rules  []Rule
+	store  Store
+	logger *logging.Logger
+}
+
+// NewLimiter creates a new limiter with rules whose buckets are kept in the store
+func NewLimiter(rules []Rule, store Store, logger *logging.Logger) *Limiter {
+	sorted := append([]Rule(nil), rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+
+	return &Limiter{
+		rules:  sorted,
+		store:  store,
+		logger: logger,
+	}
+}
+
+// IPMiddleware rejects requests over their limit with 429 Too Many Requests if their rule
+// limits each client IP address. It runs before the gateway authenticates requests, so that
+// requests with invalid credentials count against the limit of their client as well.
+func (l *Limiter) IPMiddleware(next http.Handler) http.Handler {
+	return l.limit(next, func(by KeyBy) bool { return by == KeyIP })
+}
+
+// Middleware rejects requests over their limit with 429 Too Many Requests if their rule
+// limits each user or API key. It must run after the gateway authenticated the request.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return l.limit(next, func(by KeyBy) bool { return by != KeyIP })
+}
+
+// limit rejects requests over the limit of their rule if the rule applies to the keys the
+// middleware limits. Requests are let through if the store fails, so that an outage of the
+// store does not take down the API.
+func (l *Limiter) limit(next http.Handler, applies func(KeyBy) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := l.match(r.URL.Path)
+		if !ok || !applies(rule.KeyBy) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := l.store.Take(r.Context(), rule.Prefix+" "+key(r, rule.KeyBy), rule.Limit)
+		if err != nil {
+			l.logger.Error("Failed to check rate limit", "prefix", rule.Prefix, "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rule.Limit.Burst))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(seconds(result.ResetAfter)))
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+			errors.HandleError(w, errors.ErrTooManyRequests, "Too many requests")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// match returns the rule of the longest prefix of a path
+func (l *Limiter) match(path string) (Rule, bool) {
+	for _, rule := range l.rules {
+		if path == rule.Prefix || strings.HasPrefix(path, strings.TrimSuffix(rule.Prefix, "/")+"/") {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// key returns whose bucket a request takes from
+func key(r *http.Request, by KeyBy) string {
+	if by != KeyIP {
+		if user, err := middleware.GetUserFromContext(r.Context()); err == nil {
+			if by == KeyAPIKey && user.APIKeyID != "" {
+				return "api_key:" + user.APIKeyID
+			}
+			return "user:" + user.UserID
+		}
+	}
+
+	return "ip:" + commonhttp.ClientIP(r)
+}
+
+// seconds rounds a duration up to whole seconds for the rate limit headers
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// bucket is a token bucket as kept by the stores
+type bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// newBucket returns a full bucket
+func newBucket(now time.Time, limit Limit) bucket {
+	return bucket{Tokens: float64(limit.Burst), UpdatedAt: now}
+}
+
+// take adds the tokens since the last update and takes one if there is one
+func (b *bucket) take(now time.Time, limit Limit) Result {
+	if elapsed := now.Sub(b.UpdatedAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(limit.Burst), b.Tokens+elapsed*limit.Rate)
+	}
+	b.UpdatedAt = now
+
+	result := Result{Allowed: b.Tokens >= 1}
+	if result.Allowed {
+		b.Tokens--
+	} else {
+		result.RetryAfter = secondsDuration((1 - b.Tokens) / limit.Rate)
+	}
+	result.Remaining = int(b.Tokens)
+	result.ResetAfter = b.fullAfter(limit)
+	return result
+}
+
+// fullAfter returns the time until the bucket is full
+func (b *bucket) fullAfter(limit Limit) time.Duration {
+	return secondsDuration((float64(limit.Burst) - b.Tokens) / limit.Rate)
+}
+
+// secondsDuration converts seconds to a duration
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
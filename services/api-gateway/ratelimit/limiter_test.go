@@ -0,0 +1,194 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+)
+
+func TestBucketTakesUpToBurst(t *testing.T) {
+	limit := Limit{Rate: 1, Burst: 3}
+	now := time.Unix(1000, 0)
+	b := newBucket(now, limit)
+
+	for i := 2; i >= 0; i-- {
+		result := b.take(now, limit)
+		if !result.Allowed {
+			t.Fatalf("request %d was rejected", 3-i)
+		}
+		if result.Remaining != i {
+			t.Errorf("Remaining = %d, want %d", result.Remaining, i)
+		}
+	}
+
+	result := b.take(now, limit)
+	if result.Allowed {
+		t.Fatal("request over the burst was allowed")
+	}
+	if result.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %v, want 1s", result.RetryAfter)
+	}
+	if result.ResetAfter != 3*time.Second {
+		t.Errorf("ResetAfter = %v, want 3s", result.ResetAfter)
+	}
+}
+
+func TestBucketRefillsAtRate(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 4}
+	now := time.Unix(1000, 0)
+	b := bucket{Tokens: 0, UpdatedAt: now}
+
+	// Half a token is not enough, and the wait is for the other half
+	result := b.take(now.Add(250*time.Millisecond), limit)
+	if result.Allowed {
+		t.Fatal("request with half a token was allowed")
+	}
+	if result.RetryAfter != 250*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 250ms", result.RetryAfter)
+	}
+
+	// Another 1.25 seconds adds 2.5 tokens, for 3 in total
+	result = b.take(now.Add(1500*time.Millisecond), limit)
+	if !result.Allowed {
+		t.Fatal("request after refill was rejected")
+	}
+	if result.Remaining != 2 {
+		t.Errorf("Remaining = %d, want 2", result.Remaining)
+	}
+	if result.ResetAfter != 1*time.Second {
+		t.Errorf("ResetAfter = %v, want 1s", result.ResetAfter)
+	}
+}
+
+func TestBucketDoesNotOverfill(t *testing.T) {
+	limit := Limit{Rate: 10, Burst: 5}
+	now := time.Unix(1000, 0)
+	b := bucket{Tokens: 1, UpdatedAt: now}
+
+	result := b.take(now.Add(time.Hour), limit)
+	if !result.Allowed {
+		t.Fatal("request to a full bucket was rejected")
+	}
+	if b.Tokens != 4 {
+		t.Errorf("Tokens = %v, want 4", b.Tokens)
+	}
+}
+
+func TestBucketIgnoresClockGoingBack(t *testing.T) {
+	limit := Limit{Rate: 1, Burst: 5}
+	now := time.Unix(1000, 0)
+	b := bucket{Tokens: 2, UpdatedAt: now}
+
+	b.take(now.Add(-time.Minute), limit)
+	if b.Tokens != 1 {
+		t.Errorf("Tokens = %v, want 1", b.Tokens)
+	}
+}
+
+func TestKey(t *testing.T) {
+	user := &middleware.UserClaims{UserID: "user-1"}
+	apiKeyUser := &middleware.UserClaims{UserID: "user-1", APIKeyID: "key-1"}
+
+	tests := []struct {
+		name   string
+		by     KeyBy
+		claims *middleware.UserClaims
+		want   string
+	}{
+		{"ip ignores the user", KeyIP, user, "ip:192.0.2.1"},
+		{"user", KeyUser, user, "user:user-1"},
+		{"user of an API key", KeyUser, apiKeyUser, "user:user-1"},
+		{"api key", KeyAPIKey, apiKeyUser, "api_key:key-1"},
+		{"api key without one", KeyAPIKey, user, "user:user-1"},
+		{"anonymous", KeyUser, nil, "ip:192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			if tt.claims != nil {
+				r = r.WithContext(middleware.ContextWithUser(r.Context(), tt.claims))
+			}
+			if got := key(r, tt.by); got != tt.want {
+				t.Errorf("key() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRejectsOverLimit(t *testing.T) {
+	limiter := NewLimiter([]Rule{
+		{Prefix: "/api", KeyBy: KeyIP, Limit: Limit{Rate: 100, Burst: 100}},
+		{Prefix: "/api/login", KeyBy: KeyIP, Limit: Limit{Rate: 1, Burst: 1}},
+	}, NewMemoryStore(), nil)
+	handler := limiter.IPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request("/api/login"); w.Code != http.StatusOK {
+		t.Fatalf("first request: status %d, want 200", w.Code)
+	}
+
+	w := request("/api/login")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Limit"); got != "1" {
+		t.Errorf("X-RateLimit-Limit = %q, want 1", got)
+	}
+
+	// The longest prefix matched, so other routes have their own bucket
+	if w := request("/api/surveys"); w.Code != http.StatusOK {
+		t.Errorf("other route: status %d, want 200", w.Code)
+	}
+}
+
+func TestMiddlewaresApplyTheirRules(t *testing.T) {
+	limiter := NewLimiter([]Rule{
+		{Prefix: "/api/login", KeyBy: KeyIP, Limit: Limit{Rate: 1, Burst: 1}},
+		{Prefix: "/api/surveys", KeyBy: KeyUser, Limit: Limit{Rate: 1, Burst: 1}},
+	}, NewMemoryStore(), nil)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name       string
+		middleware func(http.Handler) http.Handler
+		path       string
+		limited    bool
+	}{
+		{"ip middleware limits ip rules", limiter.IPMiddleware, "/api/login", true},
+		{"ip middleware skips user rules", limiter.IPMiddleware, "/api/surveys", false},
+		{"middleware limits user rules", limiter.Middleware, "/api/surveys", true},
+		{"middleware skips ip rules", limiter.Middleware, "/api/login", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.middleware(ok)
+			var last int
+			for i := 0; i < 2; i++ {
+				r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+				r.RemoteAddr = "192.0.2.1:1234"
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				last = w.Code
+			}
+			if limited := last == http.StatusTooManyRequests; limited != tt.limited {
+				t.Errorf("second request: status %d, limited = %v, want %v", last, limited, tt.limited)
+			}
+		})
+	}
+}
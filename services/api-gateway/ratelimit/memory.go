@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryBucket is a bucket with the time it is full again, after which it can be dropped
+type memoryBucket struct {
+	bucket
+	fullAt time.Time
+}
+
+// MemoryStore keeps buckets in memory. Each replica of the gateway limits on its own.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+// NewMemoryStore creates a new in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*memoryBucket),
+	}
+}
+
+// Take takes a token from the bucket of a key
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: newBucket(now, limit)}
+		s.buckets[key] = b
+	}
+
+	result := b.take(now, limit)
+	b.fullAt = now.Add(result.ResetAfter)
+	return result, nil
+}
+
+// RunCleanup drops full buckets periodically until the context is cancelled. A full bucket is
+// the same as no bucket.
+func (s *MemoryStore) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.deleteFull(time.Now())
+		}
+	}
+}
+
+// deleteFull drops the buckets that are full at a time
+func (s *MemoryStore) deleteFull(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, b := range s.buckets {
+		if !b.fullAt.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
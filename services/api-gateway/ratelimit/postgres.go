@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStore keeps buckets in Postgres, so that all replicas of the gateway share them
+type PostgresStore struct {
+	db     *sqlx.DB
+	logger *logging.Logger
+}
+
+// NewPostgresStore creates a new Postgres store
+func NewPostgresStore(db *sqlx.DB, logger *logging.Logger) *PostgresStore {
+	return &PostgresStore{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Take takes a token from the bucket of a key. The bucket row is locked while it is updated,
+// so concurrent requests of the replicas take tokens one after another.
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (result Result, err error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Create a full bucket unless there is one, so that the row can be locked. A concurrent
+	// insert of another replica waits for this transaction.
+	now := time.Now().UTC()
+	full := newBucket(now, limit)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO rate_limit_buckets (key, tokens, updated_at, full_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (key) DO NOTHING
+	`, key, full.Tokens, full.UpdatedAt)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create rate limit bucket: %w", err)
+	}
+
+	var b bucket
+	err = tx.QueryRowxContext(ctx, `
+		SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE
+	`, key).Scan(&b.Tokens, &b.UpdatedAt)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to get rate limit bucket: %w", err)
+	}
+
+	result = b.take(now, limit)
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3, full_at = $4 WHERE key = $1
+	`, key, b.Tokens, b.UpdatedAt, now.Add(result.ResetAfter))
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to update rate limit bucket: %w", err)
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return Result{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// RunCleanup deletes full buckets periodically until the context is cancelled. A full bucket
+// is the same as no bucket.
+func (s *PostgresStore) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := s.db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE full_at <= $1", time.Now().UTC())
+			if err != nil && ctx.Err() == nil {
+				s.logger.Error("Failed to delete full rate limit buckets", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseRules parses rules in the format "{prefix} {key} {requests}/{s|m|h} {burst}", for
+// example "/api/v1/login ip 10/m 5"
+func ParseRules(specs []string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(specs))
+	for _, spec := range specs {
+		rule, err := parseRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit %q: %w", spec, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseRule parses a single rule
+func parseRule(spec string) (Rule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 4 {
+		return Rule{}, fmt.Errorf("expected prefix, key, rate and burst")
+	}
+
+	rule := Rule{Prefix: fields[0], KeyBy: KeyBy(fields[1])}
+	if !strings.HasPrefix(rule.Prefix, "/") {
+		return Rule{}, fmt.Errorf("prefix must start with /")
+	}
+	if err := rule.KeyBy.Validate(); err != nil {
+		return Rule{}, err
+	}
+
+	rate, err := ParseRate(fields[2])
+	if err != nil {
+		return Rule{}, err
+	}
+	burst, err := strconv.Atoi(fields[3])
+	if err != nil || burst < 1 {
+		return Rule{}, fmt.Errorf("burst must be a positive integer")
+	}
+	rule.Limit = Limit{Rate: rate, Burst: burst}
+
+	return rule, nil
+}
+
+// Validate checks that requests can be keyed by k
+func (k KeyBy) Validate() error {
+	switch k {
+	case KeyIP, KeyUser, KeyAPIKey:
+		return nil
+	default:
+		return fmt.Errorf("unknown key %q, expected ip, user or api_key", k)
+	}
+}
+
+// ParseRate parses a rate such as 10/m into tokens per second
+func ParseRate(s string) (float64, error) {
+	count, unit, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, fmt.Errorf("rate must be requests per s, m or h, such as 10/m")
+	}
+
+	requests, err := strconv.ParseFloat(count, 64)
+	if err != nil || requests <= 0 {
+		return 0, fmt.Errorf("rate must be a positive number of requests")
+	}
+
+	var period time.Duration
+	switch unit {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return 0, fmt.Errorf("unknown rate unit %q, expected s, m or h", unit)
+	}
+
+	return requests / period.Seconds(), nil
+}
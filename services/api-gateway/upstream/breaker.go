@@ -0,0 +1,125 @@
+package upstream
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker
+type BreakerState string
+
+const (
+	// BreakerClosed lets requests through while the upstream handles them
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects requests after consecutive failures until the open timeout passed
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single request through to probe whether the upstream recovered
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// BreakerSettings configures a circuit breaker
+type BreakerSettings struct {
+	// FailureThreshold is the number of consecutive failures that open the breaker
+	FailureThreshold int
+	// OpenTimeout is how long the breaker rejects requests before it probes the upstream
+	OpenTimeout time.Duration
+}
+
+// Breaker is a circuit breaker that fails fast while an upstream is down instead of letting
+// every request wait for it
+type Breaker struct {
+	settings BreakerSettings
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// BreakerStatus is a snapshot of a circuit breaker
+type BreakerStatus struct {
+	State    BreakerState `json:"state"`
+	Failures int          `json:"failures"`
+	OpenedAt *time.Time   `json:"opened_at,omitempty"`
+}
+
+// NewBreaker creates a new closed circuit breaker
+func NewBreaker(settings BreakerSettings) *Breaker {
+	return &Breaker{
+		settings: settings,
+		state:    BreakerClosed,
+	}
+}
+
+// Allow reports whether a request may be sent to the upstream. Once the open timeout passed,
+// an open breaker lets a single probe through, whose outcome must be reported with Success
+// or Failure.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.settings.OpenTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success reports a request the upstream handled, which closes the breaker
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure reports a request the upstream failed. A failed probe or too many consecutive
+// failures open the breaker.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.settings.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Release reports a request without an outcome, which lets another probe through if it was
+// the probe
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// Status returns a snapshot of the breaker
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{State: b.state, Failures: b.failures}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
@@ -0,0 +1,128 @@
+package upstream
+
+import (
+	"testing"
+	"time"
+)
+
+// openTimeout is long enough that the breakers of the tests do not probe unless they are told to
+const openTimeout = time.Hour
+
+func newTestBreaker() *Breaker {
+	return NewBreaker(BreakerSettings{FailureThreshold: 3, OpenTimeout: openTimeout})
+}
+
+// expire makes the open timeout of the breaker pass
+func expire(b *Breaker) {
+	b.mu.Lock()
+	b.openedAt = b.openedAt.Add(-openTimeout)
+	b.mu.Unlock()
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	b := newTestBreaker()
+
+	for i := 0; i < 2; i++ {
+		if !b.Allow() {
+			t.Fatalf("closed breaker rejected request %d", i+1)
+		}
+		b.Failure()
+	}
+	if state := b.Status().State; state != BreakerClosed {
+		t.Fatalf("state after 2 failures = %s, want closed", state)
+	}
+
+	b.Failure()
+	status := b.Status()
+	if status.State != BreakerOpen {
+		t.Fatalf("state after 3 failures = %s, want open", status.State)
+	}
+	if status.OpenedAt == nil {
+		t.Error("open breaker has no opened_at")
+	}
+	if b.Allow() {
+		t.Error("open breaker allowed a request before the timeout")
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b := newTestBreaker()
+
+	b.Failure()
+	b.Failure()
+	b.Success()
+	b.Failure()
+	b.Failure()
+
+	if state := b.Status().State; state != BreakerClosed {
+		t.Errorf("state = %s, want closed", state)
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		report func(b *Breaker)
+		want   BreakerState
+	}{
+		{"success closes", (*Breaker).Success, BreakerClosed},
+		{"failure opens again", (*Breaker).Failure, BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreaker()
+			for i := 0; i < 3; i++ {
+				b.Failure()
+			}
+			expire(b)
+
+			if !b.Allow() {
+				t.Fatal("breaker did not let the probe through after the timeout")
+			}
+			if state := b.Status().State; state != BreakerHalfOpen {
+				t.Fatalf("state = %s, want half_open", state)
+			}
+			if b.Allow() {
+				t.Fatal("half-open breaker let a second request through during the probe")
+			}
+
+			tt.report(b)
+			if state := b.Status().State; state != tt.want {
+				t.Errorf("state = %s, want %s", state, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerFailedProbeRestartsTimeout(t *testing.T) {
+	b := newTestBreaker()
+	for i := 0; i < 3; i++ {
+		b.Failure()
+	}
+	expire(b)
+
+	b.Allow()
+	b.Failure()
+	if b.Allow() {
+		t.Error("breaker allowed a request right after the probe failed")
+	}
+}
+
+func TestBreakerReleasedProbeLetsAnotherThrough(t *testing.T) {
+	b := newTestBreaker()
+	for i := 0; i < 3; i++ {
+		b.Failure()
+	}
+	expire(b)
+
+	b.Allow()
+	b.Release()
+
+	if state := b.Status().State; state != BreakerHalfOpen {
+		t.Fatalf("state = %s, want half_open", state)
+	}
+	if !b.Allow() {
+		t.Error("breaker did not let another probe through after the first was released")
+	}
+}
@@ -0,0 +1,178 @@
+// Package upstream balances the requests of the API gateway over the instances of a service,
+// skipping instances that fail their health checks or whose circuit breaker is open
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Strategy selects the upstream of a request
+type Strategy string
+
+const (
+	// RoundRobin sends requests to the upstreams in turn
+	RoundRobin Strategy = "round_robin"
+	// LeastConnections sends requests to the upstream with the fewest requests in flight
+	LeastConnections Strategy = "least_connections"
+)
+
+// Validate checks that upstreams can be selected by s
+func (s Strategy) Validate() error {
+	switch s {
+	case RoundRobin, LeastConnections:
+		return nil
+	default:
+		return fmt.Errorf("unknown balance strategy %q, expected round_robin or least_connections", s)
+	}
+}
+
+// ErrNoUpstream is returned when every upstream is unhealthy or its breaker is open
+var ErrNoUpstream = errors.New("no upstream available")
+
+// Upstream is an instance of a service
+type Upstream struct {
+	URL *url.URL
+
+	breaker *Breaker
+	active  atomic.Int64
+	healthy atomic.Bool
+}
+
+// Done reports the outcome of a request picked for the upstream
+func (u *Upstream) Done(failed bool) {
+	u.active.Add(-1)
+	if failed {
+		u.breaker.Failure()
+	} else {
+		u.breaker.Success()
+	}
+}
+
+// Release gives back a request picked for the upstream without an outcome, such as one the
+// client cancelled
+func (u *Upstream) Release() {
+	u.active.Add(-1)
+	u.breaker.Release()
+}
+
+// Status is a snapshot of an upstream
+type Status struct {
+	URL     string        `json:"url"`
+	Healthy bool          `json:"healthy"`
+	Active  int64         `json:"active_requests"`
+	Breaker BreakerStatus `json:"circuit_breaker"`
+}
+
+// Pool balances requests over the upstreams of a service
+type Pool struct {
+	upstreams []*Upstream
+	strategy  Strategy
+	next      atomic.Uint64
+}
+
+// NewPool creates a new pool of upstreams, each with its own circuit breaker. Upstreams are
+// healthy until a health check fails.
+func NewPool(urls []*url.URL, strategy Strategy, breaker BreakerSettings) *Pool {
+	p := &Pool{strategy: strategy}
+	for _, u := range urls {
+		upstream := &Upstream{URL: u, breaker: NewBreaker(breaker)}
+		upstream.healthy.Store(true)
+		p.upstreams = append(p.upstreams, upstream)
+	}
+	return p
+}
+
+// Pick selects the upstream of a request by the strategy of the pool. Its Done or Release method
+// must be called when the request is done.
+func (p *Pool) Pick() (*Upstream, error) {
+	// Rotate the upstreams so that ties go to each in turn
+	start := int(p.next.Add(1)-1) % len(p.upstreams)
+	candidates := append(append([]*Upstream{}, p.upstreams[start:]...), p.upstreams[:start]...)
+	if p.strategy == LeastConnections {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].active.Load() < candidates[j].active.Load()
+		})
+	}
+
+	for _, upstream := range candidates {
+		if upstream.healthy.Load() && upstream.breaker.Allow() {
+			upstream.active.Add(1)
+			return upstream, nil
+		}
+	}
+	return nil, ErrNoUpstream
+}
+
+// Status returns a snapshot of the upstreams
+func (p *Pool) Status() []Status {
+	statuses := make([]Status, len(p.upstreams))
+	for i, upstream := range p.upstreams {
+		statuses[i] = Status{
+			URL:     upstream.URL.String(),
+			Healthy: upstream.healthy.Load(),
+			Active:  upstream.active.Load(),
+			Breaker: upstream.breaker.Status(),
+		}
+	}
+	return statuses
+}
+
+// HealthCheck configures the active health checks of a pool
+type HealthCheck struct {
+	// Path is requested on each upstream, which is healthy if it responds with 2xx
+	Path     string
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// RunHealthChecks checks the health of the upstreams right away and then every interval until
+// the context is cancelled. onChange is called when an upstream becomes healthy or unhealthy.
+func (p *Pool) RunHealthChecks(ctx context.Context, check HealthCheck, onChange func(upstream *Upstream, healthy bool, err error)) {
+	client := &http.Client{Timeout: check.Timeout}
+	ticker := time.NewTicker(check.Interval)
+	defer ticker.Stop()
+
+	for {
+		for _, upstream := range p.upstreams {
+			err := upstream.checkHealth(ctx, client, check.Path)
+			if ctx.Err() != nil {
+				return
+			}
+			if healthy := err == nil; upstream.healthy.Swap(healthy) != healthy {
+				onChange(upstream, healthy, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkHealth requests the health check path of the upstream
+func (u *Upstream) checkHealth(ctx context.Context, client *http.Client, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.URL.JoinPath(path).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package aggregator
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/client"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/repository"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/stats"
+)
+
+// Options controls how a survey result is built
+type Options struct {
+	// TextPage is the one-based page of text answers returned per text question
+	TextPage int
+	// TextPerPage is the number of text answers returned per text question
+	TextPerPage int
+	// OmitTextAnswers leaves out the text answers entirely
+	OmitTextAnswers bool
+}
+
+// Aggregator builds aggregated survey results from the stored responses
+type Aggregator struct {
+	repo    *repository.ResultRepository
+	surveys *client.SurveyClient
+}
+
+// NewAggregator creates a new aggregator
+func NewAggregator(repo *repository.ResultRepository, surveys *client.SurveyClient) *Aggregator {
+	return &Aggregator{
+		repo:    repo,
+		surveys: surveys,
+	}
+}
+
+// SurveyResult builds the aggregated results of a survey
+func (a *Aggregator) SurveyResult(ctx context.Context, surveyID string, opts Options) (*models.SurveyResult, error) {
+	survey, err := a.surveys.GetSurvey(ctx, surveyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Build(ctx, survey, opts)
+}
+
+// Build builds the aggregated results of an already fetched survey definition
+func (a *Aggregator) Build(ctx context.Context, survey *models.Survey, opts Options) (*models.SurveyResult, error) {
+	sessions, err := a.repo.GetSessionCounts(ctx, survey.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	responseCounts, err := a.repo.GetQuestionResponseCounts(ctx, survey.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	optionCounts, err := a.repo.GetOptionCounts(ctx, survey.ID)
+	if err != nil {
+		return nil, err
+	}
+	optionCountsByQuestion := make(map[string]map[string]int)
+	for _, count := range optionCounts {
+		if optionCountsByQuestion[count.QuestionID] == nil {
+			optionCountsByQuestion[count.QuestionID] = make(map[string]int)
+		}
+		optionCountsByQuestion[count.QuestionID][count.OptionID] = count.Count
+	}
+
+	var ratingQuestionIDs []string
+	for _, question := range survey.Questions {
+		if question.Type == models.QuestionTypeRating {
+			ratingQuestionIDs = append(ratingQuestionIDs, question.ID)
+		}
+	}
+	ratingCounts, err := a.repo.GetValueCounts(ctx, survey.ID, ratingQuestionIDs)
+	if err != nil {
+		return nil, err
+	}
+	distributions := make(map[string]map[float64]int)
+	for _, count := range ratingCounts {
+		value, err := strconv.ParseFloat(count.Value, 64)
+		if err != nil {
+			continue
+		}
+		if distributions[count.QuestionID] == nil {
+			distributions[count.QuestionID] = make(map[float64]int)
+		}
+		distributions[count.QuestionID][value] += count.Count
+	}
+
+	page, perPage := opts.TextPage, opts.TextPerPage
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 20
+	}
+
+	result := &models.SurveyResult{
+		SurveyID:       survey.ID,
+		ResponseCount:  sessions.Total,
+		CompletionRate: stats.Percentage(sessions.Completed, sessions.Total),
+		Questions:      make([]models.QuestionResult, 0, len(survey.Questions)),
+	}
+
+	for _, question := range survey.Questions {
+		questionResult := models.QuestionResult{
+			QuestionID:    question.ID,
+			QuestionText:  question.Text,
+			QuestionType:  question.Type,
+			ResponseCount: responseCounts[question.ID],
+		}
+
+		switch {
+		case question.IsChoice():
+			questionResult.Options = optionResults(question, optionCountsByQuestion[question.ID], questionResult.ResponseCount)
+
+		case question.Type == models.QuestionTypeRating:
+			questionResult.Statistics = ratingStatistics(distributions[question.ID])
+
+		case opts.OmitTextAnswers:
+			// Only the response count is reported
+
+		default:
+			answers, err := a.repo.GetTextAnswers(ctx, survey.ID, question.ID, perPage, (page-1)*perPage)
+			if err != nil {
+				return nil, err
+			}
+			questionResult.TextAnswers = answers
+		}
+
+		result.Questions = append(result.Questions, questionResult)
+	}
+
+	return result, nil
+}
+
+// optionResults builds the option results of a choice question in the order of its options.
+// Percentages are relative to the number of responses that answered the question, so for
+// multiple choice questions they may add up to more than 100.
+func optionResults(question models.Question, counts map[string]int, responseCount int) []models.OptionResult {
+	results := make([]models.OptionResult, len(question.Options))
+	for i, option := range question.Options {
+		results[i] = models.OptionResult{
+			OptionID:   option.ID,
+			OptionText: option.Text,
+			Count:      counts[option.ID],
+			Percentage: stats.Percentage(counts[option.ID], responseCount),
+		}
+	}
+	return results
+}
+
+// ratingStatistics builds the statistics of a rating question from its value distribution
+func ratingStatistics(distribution map[float64]int) map[string]interface{} {
+	summary := stats.Summarize(distribution)
+
+	counts := make(map[string]int, len(distribution))
+	for value, count := range distribution {
+		counts[strconv.FormatFloat(value, 'f', -1, 64)] = count
+	}
+
+	return map[string]interface{}{
+		"count":        summary.Count,
+		"mean":         stats.Round(summary.Mean, 2),
+		"median":       summary.Median,
+		"std_dev":      stats.Round(summary.StdDev, 2),
+		"min":          summary.Min,
+		"max":          summary.Max,
+		"distribution": counts,
+	}
+}
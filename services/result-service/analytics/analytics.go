@@ -0,0 +1,261 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/client"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/repository"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/stats"
+)
+
+// defaultTopAnswers is the number of most common answers reported per question
+const defaultTopAnswers = 5
+
+// Engine computes survey analytics and caches them in the response_analytics table
+type Engine struct {
+	repo       *repository.AnalyticsRepository
+	surveys    *client.SurveyClient
+	topAnswers int
+}
+
+// NewEngine creates a new analytics engine
+func NewEngine(repo *repository.AnalyticsRepository, surveys *client.SurveyClient) *Engine {
+	return &Engine{
+		repo:       repo,
+		surveys:    surveys,
+		topAnswers: defaultTopAnswers,
+	}
+}
+
+// Analytics returns the analytics for the request, computing and caching them on a cache miss
+func (e *Engine) Analytics(ctx context.Context, req models.AnalyticsRequest) (*models.Analytics, error) {
+	start, end := normalizeWindow(req.StartDate, req.EndDate)
+
+	cached, err := e.repo.GetCachedAnalytics(ctx, req.SurveyID, req.Period, start, end)
+	if err == nil {
+		return cached, nil
+	}
+	if err != repository.ErrNotFound {
+		return nil, err
+	}
+
+	survey, err := e.surveys.GetSurvey(ctx, req.SurveyID)
+	if err != nil {
+		return nil, err
+	}
+
+	computedAt := time.Now().UTC()
+	analytics, err := e.Compute(ctx, survey, req.Period, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.repo.SaveAnalytics(ctx, analytics, req.Period, start, end, computedAt); err != nil {
+		return nil, err
+	}
+
+	return analytics, nil
+}
+
+// Invalidate drops the cached analytics of a survey, typically because new responses arrived
+func (e *Engine) Invalidate(ctx context.Context, surveyID string) error {
+	return e.repo.InvalidateAnalytics(ctx, surveyID)
+}
+
+// Compute computes the analytics of a survey for response sessions started within the time range
+func (e *Engine) Compute(ctx context.Context, survey *models.Survey, period models.TimePeriod, start, end *time.Time) (*models.Analytics, error) {
+	sessions, err := e.repo.GetSessionStats(ctx, survey.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	trends, err := e.trends(ctx, survey.ID, period, start, end, sessions.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	insights, err := e.questionInsights(ctx, survey, start, end, sessions.Completed)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.correlations(ctx, survey, start, end, insights); err != nil {
+		return nil, err
+	}
+
+	analytics := &models.Analytics{
+		SurveyID:         survey.ID,
+		TotalResponses:   sessions.Total,
+		CompletionRate:   stats.Percentage(sessions.Completed, sessions.Total),
+		ResponseTrends:   trends,
+		QuestionInsights: insights,
+	}
+	if sessions.AverageCompletionTime.Valid {
+		analytics.AverageTimeToComplete = stats.Round(sessions.AverageCompletionTime.Float64, 2)
+	}
+
+	return analytics, nil
+}
+
+// trends counts the response sessions per period and the change relative to the previous period
+func (e *Engine) trends(ctx context.Context, surveyID string, period models.TimePeriod, start, end *time.Time, total int) ([]models.ResponseTrend, error) {
+	if period == models.TimePeriodAll {
+		return []models.ResponseTrend{{Period: string(models.TimePeriodAll), Count: total}}, nil
+	}
+
+	buckets, err := e.repo.GetResponseTrend(ctx, surveyID, period, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	trends := make([]models.ResponseTrend, 0, len(buckets))
+	for i, bucket := range fillGaps(buckets, period) {
+		trend := models.ResponseTrend{
+			Period: formatPeriod(bucket.Bucket, period),
+			Count:  bucket.Count,
+		}
+		if i > 0 {
+			trend.Change = percentChange(trends[i-1].Count, bucket.Count)
+		}
+		trends = append(trends, trend)
+	}
+
+	return trends, nil
+}
+
+// questionInsights computes skip rates and the most common answers per question. The skip
+// rate is the share of completed response sessions that did not answer the question.
+func (e *Engine) questionInsights(ctx context.Context, survey *models.Survey, start, end *time.Time, completed int) ([]models.QuestionInsight, error) {
+	completedCounts, err := e.repo.GetCompletedAnswerCounts(ctx, survey.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	answerCounts, err := e.repo.GetAnswerCounts(ctx, survey.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	topOptions, err := e.repo.GetTopOptions(ctx, survey.ID, start, end, e.topAnswers)
+	if err != nil {
+		return nil, err
+	}
+
+	topValues, err := e.repo.GetTopValues(ctx, survey.ID, start, end, e.topAnswers)
+	if err != nil {
+		return nil, err
+	}
+
+	topAnswers := make(map[string][]interface{})
+	for _, count := range topOptions {
+		question := survey.Question(count.QuestionID)
+		if question == nil {
+			continue
+		}
+		answer := models.TopAnswer{
+			OptionID:   count.OptionID,
+			Count:      count.Count,
+			Percentage: stats.Percentage(count.Count, answerCounts[count.QuestionID]),
+		}
+		if option := question.Option(count.OptionID); option != nil {
+			answer.Answer = option.Text
+		}
+		topAnswers[count.QuestionID] = append(topAnswers[count.QuestionID], answer)
+	}
+	for _, count := range topValues {
+		topAnswers[count.QuestionID] = append(topAnswers[count.QuestionID], models.TopAnswer{
+			Answer:     count.Value,
+			Count:      count.Count,
+			Percentage: stats.Percentage(count.Count, answerCounts[count.QuestionID]),
+		})
+	}
+
+	insights := make([]models.QuestionInsight, len(survey.Questions))
+	for i, question := range survey.Questions {
+		insights[i] = models.QuestionInsight{
+			QuestionID:   question.ID,
+			QuestionText: question.Text,
+			SkipRate:     stats.Percentage(completed-completedCounts[question.ID], completed),
+			TopAnswers:   topAnswers[question.ID],
+		}
+	}
+
+	return insights, nil
+}
+
+// normalizeWindow converts the bounds of a time range to UTC
+func normalizeWindow(start, end *time.Time) (*time.Time, *time.Time) {
+	if start != nil {
+		utc := start.UTC()
+		start = &utc
+	}
+	if end != nil {
+		utc := end.UTC()
+		end = &utc
+	}
+	return start, end
+}
+
+// fillGaps inserts empty buckets for periods without responses between the first and last bucket
+func fillGaps(buckets []repository.TrendBucket, period models.TimePeriod) []repository.TrendBucket {
+	if len(buckets) == 0 {
+		return buckets
+	}
+
+	filled := make([]repository.TrendBucket, 0, len(buckets))
+	for i, bucket := range buckets {
+		bucket.Bucket = bucket.Bucket.UTC()
+		if i > 0 {
+			for next := nextPeriod(filled[len(filled)-1].Bucket, period); next.Before(bucket.Bucket); next = nextPeriod(next, period) {
+				filled = append(filled, repository.TrendBucket{Bucket: next})
+			}
+		}
+		filled = append(filled, bucket)
+	}
+
+	return filled
+}
+
+// nextPeriod returns the start of the period following the one starting at t
+func nextPeriod(t time.Time, period models.TimePeriod) time.Time {
+	switch period {
+	case models.TimePeriodDay:
+		return t.AddDate(0, 0, 1)
+	case models.TimePeriodWeek:
+		return t.AddDate(0, 0, 7)
+	case models.TimePeriodMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(1, 0, 0)
+	}
+}
+
+// formatPeriod formats the start of a period as its label
+func formatPeriod(t time.Time, period models.TimePeriod) string {
+	switch period {
+	case models.TimePeriodDay:
+		return t.Format("2006-01-02")
+	case models.TimePeriodWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case models.TimePeriodMonth:
+		return t.Format("2006-01")
+	default:
+		return t.Format("2006")
+	}
+}
+
+// percentChange returns the change from previous to current in percent. Growth from zero
+// is reported as 100 percent.
+func percentChange(previous, current int) float64 {
+	if previous == 0 {
+		if current == 0 {
+			return 0
+		}
+		return 100
+	}
+	return stats.Round(float64(current-previous)/float64(previous)*100, 2)
+}
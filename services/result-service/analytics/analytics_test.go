@@ -0,0 +1,91 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/repository"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestFillGaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		period  models.TimePeriod
+		buckets []repository.TrendBucket
+		want    []repository.TrendBucket
+	}{
+		{"no buckets", models.TimePeriodDay, nil, nil},
+		{"days", models.TimePeriodDay,
+			[]repository.TrendBucket{{Bucket: date(2024, 2, 28), Count: 3}, {Bucket: date(2024, 3, 2), Count: 1}},
+			[]repository.TrendBucket{{Bucket: date(2024, 2, 28), Count: 3}, {Bucket: date(2024, 2, 29)}, {Bucket: date(2024, 3, 1)}, {Bucket: date(2024, 3, 2), Count: 1}}},
+		{"consecutive weeks", models.TimePeriodWeek,
+			[]repository.TrendBucket{{Bucket: date(2024, 1, 1), Count: 2}, {Bucket: date(2024, 1, 8), Count: 5}},
+			[]repository.TrendBucket{{Bucket: date(2024, 1, 1), Count: 2}, {Bucket: date(2024, 1, 8), Count: 5}}},
+		{"months", models.TimePeriodMonth,
+			[]repository.TrendBucket{{Bucket: date(2023, 11, 1), Count: 1}, {Bucket: date(2024, 2, 1), Count: 4}},
+			[]repository.TrendBucket{{Bucket: date(2023, 11, 1), Count: 1}, {Bucket: date(2023, 12, 1)}, {Bucket: date(2024, 1, 1)}, {Bucket: date(2024, 2, 1), Count: 4}}},
+		{"buckets in another zone", models.TimePeriodYear,
+			[]repository.TrendBucket{{Bucket: date(2022, 1, 1).In(time.FixedZone("UTC+2", 7200)), Count: 1}, {Bucket: date(2024, 1, 1), Count: 1}},
+			[]repository.TrendBucket{{Bucket: date(2022, 1, 1), Count: 1}, {Bucket: date(2023, 1, 1)}, {Bucket: date(2024, 1, 1), Count: 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fillGaps(tt.buckets, tt.period)
+			if len(got) != len(tt.want) {
+				t.Fatalf("fillGaps() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Bucket.Equal(tt.want[i].Bucket) || got[i].Bucket.Location() != time.UTC || got[i].Count != tt.want[i].Count {
+					t.Errorf("bucket %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFormatPeriod(t *testing.T) {
+	tests := []struct {
+		period models.TimePeriod
+		start  time.Time
+		want   string
+	}{
+		{models.TimePeriodDay, date(2024, 3, 5), "2024-03-05"},
+		{models.TimePeriodWeek, date(2024, 3, 4), "2024-W10"},
+		// The first days of January can belong to the last week of the previous year
+		{models.TimePeriodWeek, date(2020, 12, 28), "2020-W53"},
+		{models.TimePeriodMonth, date(2024, 3, 1), "2024-03"},
+		{models.TimePeriodYear, date(2024, 1, 1), "2024"},
+	}
+
+	for _, tt := range tests {
+		if got := formatPeriod(tt.start, tt.period); got != tt.want {
+			t.Errorf("formatPeriod(%s, %s) = %q, want %q", tt.start.Format(time.DateOnly), tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestPercentChange(t *testing.T) {
+	tests := []struct {
+		previous, current int
+		want              float64
+	}{
+		{0, 0, 0},
+		{0, 7, 100},
+		{4, 5, 25},
+		{4, 2, -50},
+		{3, 4, 33.33},
+		{5, 0, -100},
+	}
+
+	for _, tt := range tests {
+		if got := percentChange(tt.previous, tt.current); got != tt.want {
+			t.Errorf("percentChange(%d, %d) = %v, want %v", tt.previous, tt.current, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package analytics
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/repository"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/stats"
+)
+
+// significanceLevel is the p-value below which an association is reported as significant
+const significanceLevel = 0.05
+
+// Correlation correlates the answers to two questions of a survey
+func (e *Engine) Correlation(ctx context.Context, req models.CorrelationRequest) (*models.CorrelationResult, error) {
+	survey, err := e.surveys.GetSurvey(ctx, req.SurveyID)
+	if err != nil {
+		return nil, err
+	}
+
+	questionA := survey.Question(req.QuestionAID)
+	if questionA == nil {
+		return nil, errors.NewError(errors.ErrBadRequest, "question %s does not belong to survey %s", req.QuestionAID, survey.ID)
+	}
+	questionB := survey.Question(req.QuestionBID)
+	if questionB == nil {
+		return nil, errors.NewError(errors.ErrBadRequest, "question %s does not belong to survey %s", req.QuestionBID, survey.ID)
+	}
+	if !correlatable(questionA) || !correlatable(questionB) {
+		return nil, errors.NewError(errors.ErrBadRequest, "only choice and rating questions can be correlated")
+	}
+
+	start, end := normalizeWindow(req.StartDate, req.EndDate)
+	return e.correlate(ctx, survey.ID, questionA, questionB, start, end)
+}
+
+// correlations computes the association between every pair of correlatable questions and
+// attaches a summary of each association to the insights of both questions. The answers are
+// loaded once and paired in memory.
+func (e *Engine) correlations(ctx context.Context, survey *models.Survey, start, end *time.Time, insights []models.QuestionInsight) error {
+	var questionIDs []string
+	for i := range survey.Questions {
+		if correlatable(&survey.Questions[i]) {
+			questionIDs = append(questionIDs, survey.Questions[i].ID)
+		}
+	}
+	if len(questionIDs) < 2 {
+		return nil
+	}
+
+	values, err := e.repo.GetAnswerValues(ctx, survey.ID, questionIDs, start, end)
+	if err != nil {
+		return err
+	}
+	answers := groupAnswers(values)
+
+	for i := range survey.Questions {
+		questionA := &survey.Questions[i]
+		if !correlatable(questionA) {
+			continue
+		}
+
+		for j := i + 1; j < len(survey.Questions); j++ {
+			questionB := &survey.Questions[j]
+			if !correlatable(questionB) {
+				continue
+			}
+
+			pairs, sampleSize := answers.pairs(questionA.ID, questionB.ID)
+			result := correlatePairs(survey.ID, questionA, questionB, pairs, sampleSize)
+			if result.Method == "" {
+				continue
+			}
+
+			insights[i].Correlations = append(insights[i].Correlations, correlationSummary(result, questionB))
+			insights[j].Correlations = append(insights[j].Correlations, correlationSummary(result, questionA))
+		}
+	}
+
+	return nil
+}
+
+// correlate computes the association between two correlatable questions. The method of the
+// result is empty when the answers do not vary enough to measure an association.
+func (e *Engine) correlate(ctx context.Context, surveyID string, questionA, questionB *models.Question, start, end *time.Time) (*models.CorrelationResult, error) {
+	pairs, err := e.repo.GetAnswerPairs(ctx, surveyID, questionA.ID, questionB.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	sampleSize, err := e.repo.CountJointResponses(ctx, surveyID, questionA.ID, questionB.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return correlatePairs(surveyID, questionA, questionB, pairs, sampleSize), nil
+}
+
+// correlatePairs computes the association between two correlatable questions from the pairs
+// of their answers and the number of response sessions that answered both
+func correlatePairs(surveyID string, questionA, questionB *models.Question, pairs []repository.AnswerPair, sampleSize int) *models.CorrelationResult {
+	result := &models.CorrelationResult{
+		SurveyID:    surveyID,
+		QuestionAID: questionA.ID,
+		QuestionBID: questionB.ID,
+		SampleSize:  sampleSize,
+	}
+
+	switch {
+	case questionA.IsChoice() && questionB.IsChoice():
+		correlateChoices(result, questionA, questionB, pairs)
+
+	case questionA.Type == models.QuestionTypeRating && questionB.Type == models.QuestionTypeRating:
+		correlateRatings(result, pairs)
+
+	case questionA.IsChoice():
+		correlateChoiceWithRating(result, questionA, pairs, false)
+
+	default:
+		correlateChoiceWithRating(result, questionB, pairs, true)
+	}
+
+	return result
+}
+
+// sessionAnswers holds the answer values of each response session by question
+type sessionAnswers map[string]map[string][]string
+
+// groupAnswers groups answer values by response session and question
+func groupAnswers(values []repository.AnswerValue) sessionAnswers {
+	answers := make(sessionAnswers)
+	for _, value := range values {
+		session, ok := answers[value.ResponseID]
+		if !ok {
+			session = make(map[string][]string)
+			answers[value.ResponseID] = session
+		}
+		session[value.QuestionID] = append(session[value.QuestionID], value.Value)
+	}
+	return answers
+}
+
+// pairs counts the combinations of answers to two questions given within the same response
+// session, like GetAnswerPairs, and the number of sessions that answered both questions
+func (a sessionAnswers) pairs(questionAID, questionBID string) ([]repository.AnswerPair, int) {
+	type key struct{ valueA, valueB string }
+	counts := make(map[key]int)
+	sampleSize := 0
+
+	for _, session := range a {
+		valuesA, valuesB := session[questionAID], session[questionBID]
+		if len(valuesA) == 0 || len(valuesB) == 0 {
+			continue
+		}
+		sampleSize++
+		for _, valueA := range valuesA {
+			for _, valueB := range valuesB {
+				counts[key{valueA, valueB}]++
+			}
+		}
+	}
+
+	pairs := make([]repository.AnswerPair, 0, len(counts))
+	for k, count := range counts {
+		pairs = append(pairs, repository.AnswerPair{ValueA: k.valueA, ValueB: k.valueB, Count: count})
+	}
+	return pairs, sampleSize
+}
+
+// correlateChoices measures the association between two choice questions with a
+// chi-square test and Cramér's V
+func correlateChoices(result *models.CorrelationResult, questionA, questionB *models.Question, pairs []repository.AnswerPair) {
+	result.Crosstab = buildCrosstab(optionHeaders(questionA), optionHeaders(questionB), pairs)
+
+	test, ok := stats.ChiSquare(result.Crosstab.Counts)
+	if !ok {
+		return
+	}
+
+	cramersV := stats.Round(test.CramersV(), 4)
+	result.ChiSquare = chiSquareResult(test)
+	result.CramersV = &cramersV
+	setPrimary(result, models.CorrelationMethodCramersV, cramersV, test.PValue)
+}
+
+// correlateRatings measures the association between two rating questions with Pearson's
+// and Spearman's correlation coefficients
+func correlateRatings(result *models.CorrelationResult, pairs []repository.AnswerPair) {
+	var weighted []stats.WeightedPair
+	for _, pair := range pairs {
+		x, errX := strconv.ParseFloat(pair.ValueA, 64)
+		y, errY := strconv.ParseFloat(pair.ValueB, 64)
+		if errX != nil || errY != nil {
+			continue
+		}
+		weighted = append(weighted, stats.WeightedPair{X: x, Y: y, Weight: pair.Count})
+	}
+
+	result.Crosstab = buildCrosstab(ratingHeaders(pairs, false), ratingHeaders(pairs, true), pairs)
+
+	pearson, ok := stats.Pearson(weighted)
+	if !ok {
+		return
+	}
+	pearson = stats.Round(pearson, 4)
+	result.Pearson = &pearson
+
+	if spearman, ok := stats.Spearman(weighted); ok {
+		spearman = stats.Round(spearman, 4)
+		result.Spearman = &spearman
+	}
+
+	setPrimary(result, models.CorrelationMethodPearson, pearson, stats.CorrelationPValue(pearson, result.Crosstab.Total))
+}
+
+// correlateChoiceWithRating measures the association between a choice question and a
+// rating question with the correlation ratio and the mean rating per option. Swapped
+// reports that the rating question is question A of the result.
+func correlateChoiceWithRating(result *models.CorrelationResult, choice *models.Question, pairs []repository.AnswerPair, swapped bool) {
+	if swapped {
+		result.Crosstab = buildCrosstab(ratingHeaders(pairs, false), optionHeaders(choice), pairs)
+	} else {
+		result.Crosstab = buildCrosstab(optionHeaders(choice), ratingHeaders(pairs, true), pairs)
+	}
+
+	groups := make(map[string]int, len(choice.Options))
+	for i, option := range choice.Options {
+		groups[option.ID] = i
+	}
+
+	var weighted []stats.WeightedPair
+	distributions := make([]map[float64]int, len(choice.Options))
+	for _, pair := range pairs {
+		optionID, value := pair.ValueA, pair.ValueB
+		if swapped {
+			optionID, value = pair.ValueB, pair.ValueA
+		}
+		group, ok := groups[optionID]
+		rating, err := strconv.ParseFloat(value, 64)
+		if !ok || err != nil {
+			continue
+		}
+		weighted = append(weighted, stats.WeightedPair{X: float64(group), Y: rating, Weight: pair.Count})
+		if distributions[group] == nil {
+			distributions[group] = make(map[float64]int)
+		}
+		distributions[group][rating] += pair.Count
+	}
+
+	for i, option := range choice.Options {
+		summary := stats.Summarize(distributions[i])
+		if summary.Count == 0 {
+			continue
+		}
+		result.GroupMeans = append(result.GroupMeans, models.GroupMean{
+			OptionID:   option.ID,
+			OptionText: option.Text,
+			Count:      summary.Count,
+			Mean:       stats.Round(summary.Mean, 2),
+		})
+	}
+
+	if test, ok := stats.ChiSquare(result.Crosstab.Counts); ok {
+		cramersV := stats.Round(test.CramersV(), 4)
+		result.ChiSquare = chiSquareResult(test)
+		result.CramersV = &cramersV
+	}
+
+	eta, pValue, ok := stats.CorrelationRatio(weighted)
+	if !ok {
+		return
+	}
+	eta = stats.Round(eta, 4)
+	result.Eta = &eta
+	setPrimary(result, models.CorrelationMethodEta, eta, pValue)
+}
+
+// buildCrosstab counts the answer pairs into a table with the given rows and columns
+func buildCrosstab(rows, columns []models.CrosstabHeader, pairs []repository.AnswerPair) *models.Crosstab {
+	rowIndex := headerIndex(rows)
+	columnIndex := headerIndex(columns)
+
+	crosstab := &models.Crosstab{
+		Rows:    rows,
+		Columns: columns,
+		Counts:  make([][]int, len(rows)),
+	}
+	for i := range crosstab.Counts {
+		crosstab.Counts[i] = make([]int, len(columns))
+	}
+
+	for _, pair := range pairs {
+		i, okRow := rowIndex[pair.ValueA]
+		j, okColumn := columnIndex[pair.ValueB]
+		if !okRow || !okColumn {
+			continue
+		}
+		crosstab.Counts[i][j] += pair.Count
+		crosstab.Total += pair.Count
+	}
+
+	return crosstab
+}
+
+// optionHeaders labels crosstab rows or columns with the options of a choice question
+func optionHeaders(question *models.Question) []models.CrosstabHeader {
+	headers := make([]models.CrosstabHeader, len(question.Options))
+	for i, option := range question.Options {
+		headers[i] = models.CrosstabHeader{Value: option.ID, Label: option.Text}
+	}
+	return headers
+}
+
+// ratingHeaders labels crosstab rows or columns with the observed rating values in
+// ascending order, taken from the second value of each pair if second is set
+func ratingHeaders(pairs []repository.AnswerPair, second bool) []models.CrosstabHeader {
+	seen := make(map[float64]string)
+	for _, pair := range pairs {
+		value := pair.ValueA
+		if second {
+			value = pair.ValueB
+		}
+		if rating, err := strconv.ParseFloat(value, 64); err == nil {
+			seen[rating] = value
+		}
+	}
+
+	ratings := make([]float64, 0, len(seen))
+	for rating := range seen {
+		ratings = append(ratings, rating)
+	}
+	sort.Float64s(ratings)
+
+	headers := make([]models.CrosstabHeader, len(ratings))
+	for i, rating := range ratings {
+		headers[i] = models.CrosstabHeader{Value: seen[rating], Label: seen[rating]}
+	}
+	return headers
+}
+
+// headerIndex maps the values of crosstab headers to their position
+func headerIndex(headers []models.CrosstabHeader) map[string]int {
+	index := make(map[string]int, len(headers))
+	for i, header := range headers {
+		index[header.Value] = i
+	}
+	return index
+}
+
+// chiSquareResult converts a chi-square test to its model
+func chiSquareResult(test stats.ChiSquareTest) *models.ChiSquareResult {
+	return &models.ChiSquareResult{
+		Statistic:        stats.Round(test.Statistic, 4),
+		DegreesOfFreedom: test.DegreesOfFreedom,
+		PValue:           stats.Round(test.PValue, 6),
+	}
+}
+
+// setPrimary sets the primary measure of association of a result
+func setPrimary(result *models.CorrelationResult, method string, coefficient, pValue float64) {
+	result.Method = method
+	result.Coefficient = coefficient
+	result.PValue = stats.Round(pValue, 6)
+	result.Significant = pValue < significanceLevel
+}
+
+// correlationSummary summarizes a correlation for the insights of the question it is not about
+func correlationSummary(result *models.CorrelationResult, other *models.Question) map[string]interface{} {
+	return map[string]interface{}{
+		"question_id":   other.ID,
+		"question_text": other.Text,
+		"method":        result.Method,
+		"coefficient":   result.Coefficient,
+		"p_value":       result.PValue,
+		"significant":   result.Significant,
+		"sample_size":   result.SampleSize,
+	}
+}
+
+// correlatable reports whether the answers to a question can be correlated
+func correlatable(question *models.Question) bool {
+	return question.IsChoice() || question.Type == models.QuestionTypeRating
+}
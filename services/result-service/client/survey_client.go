@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+)
+
+// SurveyClient fetches survey definitions from the survey service
+type SurveyClient struct {
+	client *commonhttp.Client
+}
+
+// NewSurveyClient creates a new survey service client
+func NewSurveyClient(baseURL string, timeout time.Duration) *SurveyClient {
+	return &SurveyClient{
+		client: commonhttp.NewClient(baseURL, timeout),
+	}
+}
+
+// GetSurvey retrieves the definition of a survey including its questions and options. Drafts
+// are only found when the context carries the Authorization header of a user with access, see
+// commonhttp.WithAuthorization.
+func (c *SurveyClient) GetSurvey(ctx context.Context, surveyID string) (*models.Survey, error) {
+	var survey models.Survey
+	if err := c.get(ctx, "/api/v1/surveys/"+url.PathEscape(surveyID), surveyID, &survey); err != nil {
+		return nil, err
+	}
+
+	return &survey, nil
+}
+
+// GetAccess retrieves the permissions on a survey of the user whose Authorization header is in
+// the context
+func (c *SurveyClient) GetAccess(ctx context.Context, surveyID string) (*models.SurveyAccess, error) {
+	var access models.SurveyAccess
+	if err := c.get(ctx, "/api/v1/surveys/"+url.PathEscape(surveyID)+"/access", surveyID, &access); err != nil {
+		return nil, err
+	}
+
+	return &access, nil
+}
+
+// get performs a GET request for a survey and maps error responses to common errors
+func (c *SurveyClient) get(ctx context.Context, path, surveyID string, result interface{}) error {
+	err := c.client.Get(ctx, path, result)
+	if err != nil {
+		var statusErr *commonhttp.StatusError
+		if stderrors.As(err, &statusErr) {
+			switch statusErr.StatusCode {
+			case http.StatusNotFound:
+				return errors.NewError(errors.ErrNotFound, "survey %s", surveyID)
+			case http.StatusBadRequest:
+				return errors.NewError(errors.ErrBadRequest, "invalid survey ID %s", surveyID)
+			case http.StatusUnauthorized:
+				return errors.ErrUnauthorized
+			case http.StatusForbidden:
+				return errors.ErrForbidden
+			}
+		}
+		return fmt.Errorf("failed to get survey %s: %w", surveyID, err)
+	}
+
+	return nil
+}
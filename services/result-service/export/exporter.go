@@ -0,0 +1,229 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/aggregator"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/client"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/repository"
+	"github.com/google/uuid"
+)
+
+// ProgressFunc is called while an export runs with the number of response sessions written so
+// far and the number of response sessions the survey had when the export started. Returning an
+// error aborts the export.
+type ProgressFunc func(processed, total int) error
+
+// Exporter writes survey results to files and keeps track of them in the exported_results table
+type Exporter struct {
+	repo       *repository.ExportRepository
+	aggregator *aggregator.Aggregator
+	surveys    *client.SurveyClient
+	dir        string
+	ttl        time.Duration
+}
+
+// NewExporter creates a new exporter that stores files in dir and keeps them for ttl. Every
+// replica of the service must use the same shared dir, since the records of the files are
+// shared through the database.
+func NewExporter(repo *repository.ExportRepository, aggregator *aggregator.Aggregator, surveys *client.SurveyClient, dir string, ttl time.Duration) *Exporter {
+	return &Exporter{
+		repo:       repo,
+		aggregator: aggregator,
+		surveys:    surveys,
+		dir:        dir,
+		ttl:        ttl,
+	}
+}
+
+// Export writes the results of a survey to a new file and records it. Progress may be nil.
+func (e *Exporter) Export(ctx context.Context, req models.ExportRequest, progress ProgressFunc) (*models.ExportedResult, error) {
+	survey, err := e.surveys.GetSurvey(ctx, req.SurveyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(e.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	now := time.Now().UTC()
+	export := &models.ExportedResult{
+		ID:        uuid.New().String(),
+		SurveyID:  survey.ID,
+		Format:    req.Format,
+		CreatedAt: now,
+		ExpiresAt: now.Add(e.ttl),
+	}
+	export.FilePath = filepath.Join(e.dir, export.ID+"."+string(req.Format))
+
+	export.Size, err = e.writeFile(ctx, export.FilePath, survey, req, progress)
+	if err != nil {
+		os.Remove(export.FilePath)
+		return nil, err
+	}
+
+	if err := e.repo.CreateExport(ctx, export); err != nil {
+		os.Remove(export.FilePath)
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Open returns an export that has not expired together with its file, which the caller must close
+func (e *Exporter) Open(ctx context.Context, id string) (*models.ExportedResult, *os.File, error) {
+	export, err := e.repo.GetExport(ctx, id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, nil, errors.NewError(errors.ErrNotFound, "export %s", id)
+		}
+		return nil, nil, err
+	}
+	if !time.Now().Before(export.ExpiresAt) {
+		return nil, nil, errors.NewError(errors.ErrNotFound, "export %s has expired", id)
+	}
+
+	file, err := os.Open(export.FilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, errors.NewError(errors.ErrNotFound, "export %s", id)
+		}
+		return nil, nil, fmt.Errorf("failed to open export: %w", err)
+	}
+
+	return export, file, nil
+}
+
+// Sweep deletes the files and records of expired exports and returns how many were deleted
+func (e *Exporter) Sweep(ctx context.Context) (int, error) {
+	exports, err := e.repo.ListExpiredExports(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, export := range exports {
+		if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+			return deleted, fmt.Errorf("failed to delete export file: %w", err)
+		}
+		if err := e.repo.DeleteExport(ctx, export.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// RunSweeper deletes expired exports every interval until the context is canceled
+func (e *Exporter) RunSweeper(ctx context.Context, interval time.Duration, logger *logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := e.Sweep(ctx)
+			if err != nil {
+				logger.Error("Failed to delete expired exports", "error", err)
+			}
+			if deleted > 0 {
+				logger.Info("Deleted expired exports", "count", deleted)
+			}
+		}
+	}
+}
+
+// writeFile writes the export of a survey to path and returns the size of the file
+func (e *Exporter) writeFile(ctx context.Context, path string, survey *models.Survey, req models.ExportRequest, progress ProgressFunc) (int64, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+
+	buffered := bufio.NewWriter(file)
+	table, err := newTableWriter(req.Format, buffered, survey)
+	if err != nil {
+		return 0, err
+	}
+
+	err = e.writeTable(ctx, table, survey, req, progress)
+	if closeErr := table.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat export file: %w", err)
+	}
+
+	return info.Size(), nil
+}
+
+// writeTable streams the response sessions of a survey to a table writer, followed by the
+// aggregated results if requested
+func (e *Exporter) writeTable(ctx context.Context, table tableWriter, survey *models.Survey, req models.ExportRequest, progress ProgressFunc) error {
+	if progress == nil {
+		progress = func(processed, total int) error { return nil }
+	}
+
+	total, err := e.repo.CountResponses(ctx, survey.ID)
+	if err != nil {
+		return err
+	}
+	if err := progress(0, total); err != nil {
+		return err
+	}
+
+	if err := table.WriteHeader(columns(survey, req.IncludeRaw)); err != nil {
+		return fmt.Errorf("failed to write export header: %w", err)
+	}
+
+	processed := 0
+	err = e.repo.StreamResponses(ctx, survey.ID, func(response *models.Response) error {
+		if err := table.WriteRow(row(survey, response, req.IncludeRaw)); err != nil {
+			return fmt.Errorf("failed to write export row: %w", err)
+		}
+		processed++
+		return progress(processed, total)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !req.IncludeSummary {
+		return nil
+	}
+
+	result, err := e.aggregator.Build(ctx, survey, aggregator.Options{OmitTextAnswers: true})
+	if err != nil {
+		return err
+	}
+	if err := table.WriteSummary(result); err != nil {
+		return fmt.Errorf("failed to write export summary: %w", err)
+	}
+
+	return nil
+}
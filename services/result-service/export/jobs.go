@@ -0,0 +1,298 @@
+package export
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/repository"
+)
+
+const (
+	// pollInterval is how often idle workers look for pending jobs they were not woken up for
+	pollInterval = 5 * time.Second
+	// heartbeatInterval is how often a running job is marked as alive
+	heartbeatInterval = 15 * time.Second
+	// staleAfter is how long a running job may go without a heartbeat before it is requeued
+	staleAfter = 2 * time.Minute
+	// finishTimeout bounds recording the outcome of a job after the service started shutting down
+	finishTimeout = 5 * time.Second
+)
+
+// errJobCanceled aborts an export whose job was canceled
+var errJobCanceled = stderrors.New("export job canceled")
+
+// jobStore keeps export jobs, as repository.ExportJobRepository does
+type jobStore interface {
+	CreateJob(ctx context.Context, job *models.ExportJob) error
+	GetJob(ctx context.Context, id string) (*models.ExportJob, error)
+	ClaimJob(ctx context.Context) (*models.ExportJob, error)
+	UpdateProgress(ctx context.Context, id string, processed, total int) error
+	Heartbeat(ctx context.Context, id string) error
+	CompleteJob(ctx context.Context, id, exportID string) error
+	FailJob(ctx context.Context, id, message string) error
+	RequeueJob(ctx context.Context, id string) error
+	RequeueStaleJobs(ctx context.Context, before time.Time) (int64, error)
+	CancelJob(ctx context.Context, id string) (*models.ExportJob, error)
+}
+
+// resultExporter writes the results of a survey to a file, as Exporter does
+type resultExporter interface {
+	Export(ctx context.Context, req models.ExportRequest, progress ProgressFunc) (*models.ExportedResult, error)
+}
+
+// JobRunner queues export jobs in the export_jobs table and runs them with a fixed number of
+// workers. Jobs left running by a stopped service are picked up again once they go stale.
+type JobRunner struct {
+	jobs     jobStore
+	exporter resultExporter
+	workers  int
+	logger   *logging.Logger
+	wake     chan struct{}
+	// heartbeatInterval is how often running jobs are marked as alive
+	heartbeatInterval time.Duration
+}
+
+// NewJobRunner creates a new job runner with the given number of workers
+func NewJobRunner(jobs *repository.ExportJobRepository, exporter *Exporter, workers int, logger *logging.Logger) *JobRunner {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &JobRunner{
+		jobs:     jobs,
+		exporter: exporter,
+		workers:  workers,
+		logger:   logger,
+		wake:     make(chan struct{}, workers),
+
+		heartbeatInterval: heartbeatInterval,
+	}
+}
+
+// Submit queues an export job for the request
+func (r *JobRunner) Submit(ctx context.Context, req models.ExportRequest, requestedBy *string) (*models.ExportJob, error) {
+	job := &models.ExportJob{
+		SurveyID:       req.SurveyID,
+		Format:         req.Format,
+		IncludeRaw:     req.IncludeRaw,
+		IncludeSummary: req.IncludeSummary,
+		RequestedBy:    requestedBy,
+	}
+	if err := r.jobs.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	// Wake up an idle worker without blocking if all of them are busy
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+// Job returns an export job by ID
+func (r *JobRunner) Job(ctx context.Context, id string) (*models.ExportJob, error) {
+	job, err := r.jobs.GetJob(ctx, id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, errors.NewError(errors.ErrNotFound, "export job %s", id)
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Cancel cancels a pending or running export job. A running job stops at its next progress
+// update or heartbeat.
+func (r *JobRunner) Cancel(ctx context.Context, id string) (*models.ExportJob, error) {
+	job, err := r.jobs.CancelJob(ctx, id)
+	if err == nil {
+		return job, nil
+	}
+	if err != repository.ErrNotFound {
+		return nil, err
+	}
+
+	// Tell a missing job apart from a finished one
+	job, err = r.Job(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return nil, errors.NewError(errors.ErrBadRequest, "export job %s is already %s", id, job.Status)
+}
+
+// Run runs queued export jobs until the context is canceled. Jobs that are still running
+// at that point are put back into the queue.
+func (r *JobRunner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.requeueStaleJobs(ctx)
+	}()
+
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// work claims and runs jobs until none is pending, then waits to be woken up
+func (r *JobRunner) work(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil && r.runNext(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext claims the oldest pending job and runs it. It reports whether a job was run.
+func (r *JobRunner) runNext(ctx context.Context) bool {
+	job, err := r.jobs.ClaimJob(ctx)
+	if err != nil {
+		if err != repository.ErrNotFound && ctx.Err() == nil {
+			r.logger.Error("Failed to claim export job", "error", err)
+		}
+		return false
+	}
+
+	r.run(ctx, job)
+	return true
+}
+
+// run runs a claimed job and records its outcome
+func (r *JobRunner) run(ctx context.Context, job *models.ExportJob) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go r.heartbeat(jobCtx, cancel, job.ID)
+
+	// Only record progress when the percentage changes
+	lastProgress := -1
+	progress := func(processed, total int) error {
+		percent := 0
+		if total > 0 {
+			percent = processed * 100 / total
+		}
+		if percent == lastProgress {
+			return nil
+		}
+		lastProgress = percent
+
+		err := r.jobs.UpdateProgress(jobCtx, job.ID, processed, total)
+		if err == repository.ErrNotFound {
+			return errJobCanceled
+		}
+		return err
+	}
+
+	export, err := r.exporter.Export(jobCtx, job.Request(), progress)
+
+	// Record the outcome even if the service is shutting down
+	finishCtx, finishCancel := context.WithTimeout(context.Background(), finishTimeout)
+	defer finishCancel()
+
+	switch {
+	case err == nil:
+		if err := r.jobs.CompleteJob(finishCtx, job.ID, export.ID); err != nil && err != repository.ErrNotFound {
+			r.logger.Error("Failed to complete export job", "job_id", job.ID, "error", err)
+		}
+
+	case ctx.Err() != nil:
+		// The service is shutting down, so let another worker pick the job up
+		if err := r.jobs.RequeueJob(finishCtx, job.ID); err != nil && err != repository.ErrNotFound {
+			r.logger.Error("Failed to requeue export job", "job_id", job.ID, "error", err)
+		}
+
+	case stderrors.Is(err, errJobCanceled) || jobCtx.Err() != nil:
+		r.logger.Info("Export job canceled", "job_id", job.ID)
+
+	default:
+		r.logger.Error("Export job failed", "job_id", job.ID, "survey_id", job.SurveyID, "error", err)
+		if err := r.jobs.FailJob(finishCtx, job.ID, failureMessage(err)); err != nil && err != repository.ErrNotFound {
+			r.logger.Error("Failed to record export job failure", "job_id", job.ID, "error", err)
+		}
+	}
+}
+
+// heartbeat marks a running job as alive until its context is done and cancels the job
+// once it is no longer running
+func (r *JobRunner) heartbeat(ctx context.Context, cancel context.CancelFunc, jobID string) {
+	ticker := time.NewTicker(r.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := r.jobs.Heartbeat(ctx, jobID)
+			if err == repository.ErrNotFound {
+				cancel()
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				r.logger.Error("Failed to update export job heartbeat", "job_id", jobID, "error", err)
+			}
+		}
+	}
+}
+
+// requeueStaleJobs periodically puts jobs abandoned by stopped workers back into the queue
+func (r *JobRunner) requeueStaleJobs(ctx context.Context) {
+	ticker := time.NewTicker(staleAfter / 2)
+	defer ticker.Stop()
+
+	for {
+		requeued, err := r.jobs.RequeueStaleJobs(ctx, time.Now().UTC().Add(-staleAfter))
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("Failed to requeue stale export jobs", "error", err)
+		}
+		if requeued > 0 {
+			r.logger.Info("Requeued stale export jobs", "count", requeued)
+			for i := int64(0); i < requeued && i < int64(r.workers); i++ {
+				select {
+				case r.wake <- struct{}{}:
+				default:
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// failureMessage returns the error message recorded for a failed job. Details of unexpected
+// errors are only logged.
+func failureMessage(err error) string {
+	if stderrors.Is(err, errors.ErrNotFound) || stderrors.Is(err, errors.ErrBadRequest) {
+		return err.Error()
+	}
+	return "export failed"
+}
@@ -0,0 +1,271 @@
+package export
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/repository"
+)
+
+// fakeJobs records the calls the runner makes for the outcome of jobs
+type fakeJobs struct {
+	mu sync.Mutex
+	// heartbeatErr is returned by Heartbeat, progressErr by UpdateProgress
+	heartbeatErr error
+	progressErr  error
+	// requeued is the number of jobs RequeueStaleJobs reports
+	requeued int64
+
+	heartbeats  int
+	completed   map[string]string
+	failed      map[string]string
+	requeuedIDs []string
+	staleBefore []time.Time
+}
+
+func newFakeJobs() *fakeJobs {
+	return &fakeJobs{completed: make(map[string]string), failed: make(map[string]string)}
+}
+
+func (f *fakeJobs) CreateJob(ctx context.Context, job *models.ExportJob) error { return nil }
+
+func (f *fakeJobs) GetJob(ctx context.Context, id string) (*models.ExportJob, error) {
+	return nil, repository.ErrNotFound
+}
+
+func (f *fakeJobs) ClaimJob(ctx context.Context) (*models.ExportJob, error) {
+	return nil, repository.ErrNotFound
+}
+
+func (f *fakeJobs) UpdateProgress(ctx context.Context, id string, processed, total int) error {
+	return f.progressErr
+}
+
+func (f *fakeJobs) Heartbeat(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.heartbeats++
+	return f.heartbeatErr
+}
+
+func (f *fakeJobs) CompleteJob(ctx context.Context, id, exportID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.completed[id] = exportID
+	return nil
+}
+
+func (f *fakeJobs) FailJob(ctx context.Context, id, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failed[id] = message
+	return nil
+}
+
+func (f *fakeJobs) RequeueJob(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requeuedIDs = append(f.requeuedIDs, id)
+	return nil
+}
+
+func (f *fakeJobs) RequeueStaleJobs(ctx context.Context, before time.Time) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.staleBefore = append(f.staleBefore, before)
+	return f.requeued, nil
+}
+
+func (f *fakeJobs) CancelJob(ctx context.Context, id string) (*models.ExportJob, error) {
+	return nil, repository.ErrNotFound
+}
+
+// exportFunc runs an export in place of an Exporter
+type exportFunc func(ctx context.Context, progress ProgressFunc) (*models.ExportedResult, error)
+
+func (f exportFunc) Export(ctx context.Context, req models.ExportRequest, progress ProgressFunc) (*models.ExportedResult, error) {
+	return f(ctx, progress)
+}
+
+// untilDone blocks an export until its context is done
+func untilDone(ctx context.Context, progress ProgressFunc) (*models.ExportedResult, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// newTestRunner returns a runner whose running jobs send heartbeats every few milliseconds
+func newTestRunner(jobs *fakeJobs, export exportFunc, workers int) *JobRunner {
+	return &JobRunner{
+		jobs:     jobs,
+		exporter: export,
+		workers:  workers,
+		logger:   logging.NewLogger(&logging.Config{Level: "fatal", ServiceName: "result-service"}),
+		wake:     make(chan struct{}, workers),
+
+		heartbeatInterval: 5 * time.Millisecond,
+	}
+}
+
+func TestRunRecordsOutcome(t *testing.T) {
+	tests := []struct {
+		name        string
+		export      exportFunc
+		progressErr error
+		completed   string
+		failed      string
+	}{
+		{"completed", func(ctx context.Context, progress ProgressFunc) (*models.ExportedResult, error) {
+			return &models.ExportedResult{ID: "export-1"}, nil
+		}, nil, "export-1", ""},
+		{"expected error", func(ctx context.Context, progress ProgressFunc) (*models.ExportedResult, error) {
+			return nil, errors.NewError(errors.ErrNotFound, "survey survey-1")
+		}, nil, "", "resource not found: survey survey-1"},
+		{"unexpected error is not shown", func(ctx context.Context, progress ProgressFunc) (*models.ExportedResult, error) {
+			return nil, stderrors.New("disk full at /var/exports")
+		}, nil, "", "export failed"},
+		{"canceled while reporting progress", func(ctx context.Context, progress ProgressFunc) (*models.ExportedResult, error) {
+			return nil, progress(1, 10)
+		}, repository.ErrNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := newFakeJobs()
+			jobs.progressErr = tt.progressErr
+
+			newTestRunner(jobs, tt.export, 1).run(context.Background(), &models.ExportJob{ID: "job-1"})
+
+			if got := jobs.completed["job-1"]; got != tt.completed {
+				t.Errorf("completed with export %q, want %q", got, tt.completed)
+			}
+			if got := jobs.failed["job-1"]; got != tt.failed {
+				t.Errorf("failed with %q, want %q", got, tt.failed)
+			}
+			if len(jobs.requeuedIDs) != 0 {
+				t.Errorf("requeued %v, want none", jobs.requeuedIDs)
+			}
+		})
+	}
+}
+
+func TestRunRequeuesJobOnShutdown(t *testing.T) {
+	jobs := newFakeJobs()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	started := make(chan struct{})
+	runner := newTestRunner(jobs, func(ctx context.Context, progress ProgressFunc) (*models.ExportedResult, error) {
+		close(started)
+		return untilDone(ctx, progress)
+	}, 1)
+
+	done := make(chan struct{})
+	go func() {
+		runner.run(ctx, &models.ExportJob{ID: "job-1"})
+		close(done)
+	}()
+
+	<-started
+	cancel()
+	<-done
+
+	if len(jobs.requeuedIDs) != 1 || jobs.requeuedIDs[0] != "job-1" {
+		t.Errorf("requeued %v, want [job-1]", jobs.requeuedIDs)
+	}
+	if len(jobs.failed) != 0 || len(jobs.completed) != 0 {
+		t.Errorf("job was also failed %v or completed %v", jobs.failed, jobs.completed)
+	}
+}
+
+func TestHeartbeatKeepsJobAlive(t *testing.T) {
+	jobs := newFakeJobs()
+
+	runner := newTestRunner(jobs, func(ctx context.Context, progress ProgressFunc) (*models.ExportedResult, error) {
+		// Run until a few heartbeats were sent
+		for {
+			jobs.mu.Lock()
+			heartbeats := jobs.heartbeats
+			jobs.mu.Unlock()
+			if heartbeats >= 3 {
+				return &models.ExportedResult{ID: "export-1"}, nil
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}, 1)
+
+	runner.run(context.Background(), &models.ExportJob{ID: "job-1"})
+
+	if jobs.completed["job-1"] != "export-1" {
+		t.Errorf("job was not completed, failed with %q", jobs.failed["job-1"])
+	}
+}
+
+func TestHeartbeatStopsJobThatIsNoLongerRunning(t *testing.T) {
+	jobs := newFakeJobs()
+	// The job was canceled, or requeued after going stale and claimed by another worker
+	jobs.heartbeatErr = repository.ErrNotFound
+
+	done := make(chan struct{})
+	go func() {
+		newTestRunner(jobs, untilDone, 1).run(context.Background(), &models.ExportJob{ID: "job-1"})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("export kept running after its job was gone")
+	}
+
+	if len(jobs.completed) != 0 || len(jobs.failed) != 0 || len(jobs.requeuedIDs) != 0 {
+		t.Errorf("outcome recorded for a job that is gone: completed %v, failed %v, requeued %v", jobs.completed, jobs.failed, jobs.requeuedIDs)
+	}
+}
+
+func TestRequeueStaleJobs(t *testing.T) {
+	tests := []struct {
+		name     string
+		requeued int64
+		workers  int
+		wakeUps  int
+	}{
+		{"none", 0, 3, 0},
+		{"fewer than the workers", 2, 3, 2},
+		{"more than the workers", 5, 3, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := newFakeJobs()
+			jobs.requeued = tt.requeued
+			runner := newTestRunner(jobs, untilDone, tt.workers)
+
+			// Stale jobs are requeued right away, then every half of staleAfter
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			start := time.Now()
+			runner.requeueStaleJobs(ctx)
+			end := time.Now()
+
+			if len(jobs.staleBefore) != 1 {
+				t.Fatalf("RequeueStaleJobs called %d times, want once", len(jobs.staleBefore))
+			}
+			if before := jobs.staleBefore[0]; before.Before(start.Add(-staleAfter)) || before.After(end.Add(-staleAfter)) {
+				t.Errorf("requeued jobs without a heartbeat since %v, want %v ago", before, staleAfter)
+			}
+			if len(runner.wake) != tt.wakeUps {
+				t.Errorf("woke up %d workers, want %d", len(runner.wake), tt.wakeUps)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package export
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/stats"
+)
+
+// multipleAnswerSeparator joins the answers given to the same question in one cell
+const multipleAnswerSeparator = "; "
+
+// summaryHeader labels the columns of the aggregated summary
+var summaryHeader = []string{"Question ID", "Question", "Type", "Responses", "Answer", "Count", "Percentage", "Value"}
+
+// ratingStatistics are the statistics of a rating question included in the summary, in order
+var ratingStatistics = []string{"mean", "median", "std_dev", "min", "max"}
+
+// column is a column of the exported responses
+type column struct {
+	// Key identifies the column in JSON exports
+	Key string
+	// Label is the column header in CSV and XLSX exports
+	Label string
+}
+
+// columns returns the columns of the exported responses: the session fields followed by
+// one column per question
+func columns(survey *models.Survey, includeRaw bool) []column {
+	cols := []column{
+		{Key: "response_id", Label: "Response ID"},
+		{Key: "respondent_id", Label: "Respondent ID"},
+		{Key: "started_at", Label: "Started At"},
+		{Key: "completed_at", Label: "Completed At"},
+	}
+	if includeRaw {
+		cols = append(cols,
+			column{Key: "ip_address", Label: "IP Address"},
+			column{Key: "user_agent", Label: "User Agent"},
+		)
+	}
+	for _, question := range survey.Questions {
+		cols = append(cols, column{Key: question.ID, Label: question.Text})
+	}
+	return cols
+}
+
+// row returns the values of a response session in the order of its columns. Options are
+// exported as their text and unanswered questions as empty values.
+func row(survey *models.Survey, response *models.Response, includeRaw bool) []string {
+	values := []string{response.ID, "", response.StartedAt.UTC().Format(time.RFC3339), ""}
+	if response.RespondentID != nil {
+		values[1] = *response.RespondentID
+	}
+	if response.CompletedAt != nil {
+		values[3] = response.CompletedAt.UTC().Format(time.RFC3339)
+	}
+	if includeRaw {
+		values = append(values, response.IPAddress, response.UserAgent)
+	}
+
+	answers := make(map[string][]string)
+	for _, answer := range response.Answers {
+		answers[answer.QuestionID] = append(answers[answer.QuestionID], answerValue(survey, answer))
+	}
+	for _, question := range survey.Questions {
+		values = append(values, strings.Join(answers[question.ID], multipleAnswerSeparator))
+	}
+
+	return values
+}
+
+// answerValue returns the exported value of an answer
+func answerValue(survey *models.Survey, answer models.Answer) string {
+	if answer.OptionID != nil {
+		if question := survey.Question(answer.QuestionID); question != nil {
+			if option := question.Option(*answer.OptionID); option != nil {
+				return option.Text
+			}
+		}
+		return *answer.OptionID
+	}
+	if answer.TextAnswer != nil {
+		return *answer.TextAnswer
+	}
+	return ""
+}
+
+// summaryRows flattens aggregated survey results into rows matching summaryHeader. Choice
+// questions get one row per option, rating questions one row per rating value followed by
+// their statistics, and other questions a single row with their response count.
+func summaryRows(result *models.SurveyResult) [][]string {
+	var rows [][]string
+	for _, question := range result.Questions {
+		switch {
+		case len(question.Options) > 0:
+			for _, option := range question.Options {
+				rows = append(rows, summaryRow(question, option.OptionText, strconv.Itoa(option.Count), formatFloat(option.Percentage), ""))
+			}
+
+		case question.Statistics != nil:
+			distribution, _ := question.Statistics["distribution"].(map[string]int)
+			for _, value := range sortedRatings(distribution) {
+				count := distribution[value]
+				rows = append(rows, summaryRow(question, value, strconv.Itoa(count), formatFloat(stats.Percentage(count, question.ResponseCount)), ""))
+			}
+			for _, name := range ratingStatistics {
+				rows = append(rows, summaryRow(question, name, "", "", formatStatistic(question.Statistics[name])))
+			}
+
+		default:
+			rows = append(rows, summaryRow(question, "", "", "", ""))
+		}
+	}
+	return rows
+}
+
+// summaryRow returns a row of the aggregated summary for a question
+func summaryRow(question models.QuestionResult, answer, count, percentage, value string) []string {
+	return []string{
+		question.QuestionID,
+		question.QuestionText,
+		question.QuestionType,
+		strconv.Itoa(question.ResponseCount),
+		answer,
+		count,
+		percentage,
+		value,
+	}
+}
+
+// sortedRatings returns the rating values of a distribution in ascending numeric order
+func sortedRatings(distribution map[string]int) []string {
+	values := make([]string, 0, len(distribution))
+	for value := range distribution {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, _ := strconv.ParseFloat(values[i], 64)
+		b, _ := strconv.ParseFloat(values[j], 64)
+		return a < b
+	})
+	return values
+}
+
+// formatStatistic formats a rating statistic
+func formatStatistic(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return formatFloat(v)
+	case int:
+		return strconv.Itoa(v)
+	default:
+		return ""
+	}
+}
+
+// formatFloat formats a number without trailing zeros
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
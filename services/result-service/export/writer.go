@@ -0,0 +1,305 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	// responsesSheet is the XLSX sheet holding one row per response session
+	responsesSheet = "Responses"
+	// summarySheet is the XLSX sheet holding the aggregated results
+	summarySheet = "Summary"
+)
+
+// tableWriter writes exported responses in a file format. The header is written first,
+// followed by the rows and optionally the summary.
+type tableWriter interface {
+	WriteHeader(columns []column) error
+	WriteRow(values []string) error
+	WriteSummary(result *models.SurveyResult) error
+	Close() error
+}
+
+// newTableWriter creates a table writer for the format that writes to w
+func newTableWriter(format models.ExportFormat, w io.Writer, survey *models.Survey) (tableWriter, error) {
+	switch format {
+	case models.ExportFormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case models.ExportFormatJSON:
+		return &jsonWriter{w: w, survey: survey}, nil
+	case models.ExportFormatXLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// csvWriter writes responses as CSV. The summary is appended after an empty line.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// WriteHeader writes the column labels
+func (c *csvWriter) WriteHeader(columns []column) error {
+	labels := make([]string, len(columns))
+	for i, col := range columns {
+		labels[i] = col.Label
+	}
+	return c.w.Write(escapeFormulas(labels))
+}
+
+// WriteRow writes the values of a response session
+func (c *csvWriter) WriteRow(values []string) error {
+	return c.w.Write(escapeFormulas(values))
+}
+
+// WriteSummary appends the aggregated results
+func (c *csvWriter) WriteSummary(result *models.SurveyResult) error {
+	if err := c.w.Write(nil); err != nil {
+		return err
+	}
+	if err := c.w.Write(summaryHeader); err != nil {
+		return err
+	}
+	for _, row := range summaryRows(result) {
+		if err := c.w.Write(escapeFormulas(row)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes the buffered rows
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeFormulas prefixes the values that spreadsheet applications would run as formulas with
+// an apostrophe, so that answers cannot inject formulas into the exported file
+func escapeFormulas(values []string) []string {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+			value = "'" + value
+		}
+		escaped[i] = value
+	}
+	return escaped
+}
+
+// jsonWriter writes responses as a JSON document. Each response session is an object keyed
+// by column, with question columns keyed by question ID and unanswered questions as null.
+type jsonWriter struct {
+	w       io.Writer
+	survey  *models.Survey
+	columns []column
+	rows    int
+	summary *models.SurveyResult
+}
+
+// WriteHeader opens the document and its list of responses
+func (j *jsonWriter) WriteHeader(columns []column) error {
+	j.columns = columns
+
+	type jsonColumn struct {
+		Key   string `json:"key"`
+		Label string `json:"label"`
+	}
+	header := make([]jsonColumn, len(columns))
+	for i, col := range columns {
+		header[i] = jsonColumn{Key: col.Key, Label: col.Label}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"survey_id":`)
+	writeJSON(&buf, j.survey.ID)
+	buf.WriteString(`,"title":`)
+	writeJSON(&buf, j.survey.Title)
+	buf.WriteString(`,"exported_at":`)
+	writeJSON(&buf, time.Now().UTC())
+	buf.WriteString(`,"columns":`)
+	writeJSON(&buf, header)
+	buf.WriteString(`,"responses":[`)
+
+	_, err := j.w.Write(buf.Bytes())
+	return err
+}
+
+// WriteRow writes the values of a response session as an object
+func (j *jsonWriter) WriteRow(values []string) error {
+	var buf bytes.Buffer
+	if j.rows > 0 {
+		buf.WriteByte(',')
+	}
+	buf.WriteByte('{')
+	for i, col := range j.columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSON(&buf, col.Key)
+		buf.WriteByte(':')
+		if i < len(values) && values[i] != "" {
+			writeJSON(&buf, values[i])
+		} else {
+			buf.WriteString("null")
+		}
+	}
+	buf.WriteByte('}')
+	j.rows++
+
+	_, err := j.w.Write(buf.Bytes())
+	return err
+}
+
+// WriteSummary keeps the aggregated results to write them after the responses
+func (j *jsonWriter) WriteSummary(result *models.SurveyResult) error {
+	j.summary = result
+	return nil
+}
+
+// Close closes the list of responses, writes the summary and closes the document
+func (j *jsonWriter) Close() error {
+	var buf bytes.Buffer
+	buf.WriteByte(']')
+	if j.summary != nil {
+		buf.WriteString(`,"summary":`)
+		writeJSON(&buf, j.summary)
+	}
+	buf.WriteString("}\n")
+
+	_, err := j.w.Write(buf.Bytes())
+	return err
+}
+
+// writeJSON appends the JSON encoding of a value that is known to be encodable
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	data, _ := json.Marshal(v)
+	buf.Write(data)
+}
+
+// xlsxWriter writes responses and the summary to separate sheets of a workbook. Rows are
+// written with a stream writer, which spills to temporary files instead of keeping every
+// row in memory.
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+// newXLSXWriter creates a workbook with the responses sheet
+func newXLSXWriter(out io.Writer) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName("Sheet1", responsesSheet); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to create sheet: %w", err)
+	}
+
+	stream, err := file.NewStreamWriter(responsesSheet)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to create sheet writer: %w", err)
+	}
+
+	return &xlsxWriter{out: out, file: file, stream: stream}, nil
+}
+
+// WriteHeader writes the column labels
+func (x *xlsxWriter) WriteHeader(columns []column) error {
+	labels := make([]string, len(columns))
+	for i, col := range columns {
+		labels[i] = col.Label
+	}
+	return x.writeRow(labels)
+}
+
+// WriteRow writes the values of a response session
+func (x *xlsxWriter) WriteRow(values []string) error {
+	return x.writeRow(values)
+}
+
+// WriteSummary writes the aggregated results to the summary sheet
+func (x *xlsxWriter) WriteSummary(result *models.SurveyResult) error {
+	if err := x.stream.Flush(); err != nil {
+		return fmt.Errorf("failed to write sheet: %w", err)
+	}
+
+	if _, err := x.file.NewSheet(summarySheet); err != nil {
+		return fmt.Errorf("failed to create sheet: %w", err)
+	}
+	stream, err := x.file.NewStreamWriter(summarySheet)
+	if err != nil {
+		return fmt.Errorf("failed to create sheet writer: %w", err)
+	}
+	x.stream = stream
+	x.row = 0
+
+	if err := x.writeRow(summaryHeader); err != nil {
+		return err
+	}
+	for _, values := range summaryRows(result) {
+		if err := x.writeRow(values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close writes the workbook and removes its temporary files
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+
+	if err := x.stream.Flush(); err != nil {
+		return fmt.Errorf("failed to write sheet: %w", err)
+	}
+	if err := x.file.Write(x.out); err != nil {
+		return fmt.Errorf("failed to write workbook: %w", err)
+	}
+
+	return nil
+}
+
+// writeRow writes values to the next row of the current sheet
+func (x *xlsxWriter) writeRow(values []string) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+
+	cells := make([]interface{}, len(values))
+	for i, value := range values {
+		cells[i] = value
+	}
+
+	if err := x.stream.SetRow(cell, cells); err != nil {
+		return fmt.Errorf("failed to write row: %w", err)
+	}
+
+	return nil
+}
+
+// ContentType returns the media type of an export format
+func ContentType(format models.ExportFormat) string {
+	switch format {
+	case models.ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	case models.ExportFormatJSON:
+		return "application/json"
+	case models.ExportFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/octet-stream"
+	}
+}
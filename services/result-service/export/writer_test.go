@@ -0,0 +1,63 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+)
+
+func TestCSVWriterEscapesFormulas(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"plain text", "hello", "hello"},
+		{"empty", "", ""},
+		{"number", "42", "42"},
+		{"equals", "=SUM(A1:A2)", "'=SUM(A1:A2)"},
+		{"plus", "+1+2", "'+1+2"},
+		{"minus", "-1", "'-1"},
+		{"at", "@cmd", "'@cmd"},
+		{"tab", "\t=1", "'\t=1"},
+		{"carriage return", "\r=1", "'\r=1"},
+		{"formula later", "a=1", "a=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := &csvWriter{w: csv.NewWriter(&buf)}
+			if err := w.WriteHeader([]column{{Key: "q1", Label: tt.value}, {Key: "q2", Label: "plain"}}); err != nil {
+				t.Fatalf("WriteHeader() error = %v", err)
+			}
+			if err := w.WriteRow([]string{tt.value, "plain"}); err != nil {
+				t.Fatalf("WriteRow() error = %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatalf("reading written CSV: %v", err)
+			}
+			want := [][]string{{tt.want, "plain"}, {tt.want, "plain"}}
+			if !reflect.DeepEqual(records, want) {
+				t.Errorf("records = %q, want %q", records, want)
+			}
+		})
+	}
+}
+
+func TestCSVWriterKeepsRowValues(t *testing.T) {
+	values := []string{"=1", "ok"}
+	w := &csvWriter{w: csv.NewWriter(&bytes.Buffer{})}
+	if err := w.WriteRow(values); err != nil {
+		t.Fatalf("WriteRow() error = %v", err)
+	}
+	if values[0] != "=1" {
+		t.Errorf("WriteRow() changed the values of the caller to %q", values)
+	}
+}
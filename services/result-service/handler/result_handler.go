@@ -0,0 +1,746 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/permissions"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/aggregator"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/analytics"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/client"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/export"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/repository"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/validation"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// BasePath is the path the result handler routes are mounted under
+const BasePath = "/api/v1/results"
+
+// ResultHandler handles HTTP requests for survey responses and results
+type ResultHandler struct {
+	repo       *repository.ResponseRepository
+	aggregator *aggregator.Aggregator
+	analytics  *analytics.Engine
+	exporter   *export.Exporter
+	exportJobs *export.JobRunner
+	surveys    *client.SurveyClient
+	recorder   *audit.Recorder
+	validate   *validator.Validate
+	logger     *logging.Logger
+	keys       middleware.KeySource
+	authOpts   []middleware.AuthOption
+}
+
+// NewResultHandler creates a new result handler. The auth options configure how the protected
+// routes authenticate requests besides tokens.
+func NewResultHandler(repo *repository.ResponseRepository, aggregator *aggregator.Aggregator, analytics *analytics.Engine, exporter *export.Exporter, exportJobs *export.JobRunner, surveys *client.SurveyClient, recorder *audit.Recorder, logger *logging.Logger, keys middleware.KeySource, authOpts ...middleware.AuthOption) *ResultHandler {
+	return &ResultHandler{
+		repo:       repo,
+		aggregator: aggregator,
+		analytics:  analytics,
+		exporter:   exporter,
+		exportJobs: exportJobs,
+		surveys:    surveys,
+		recorder:   recorder,
+		validate:   validator.New(),
+		logger:     logger,
+		keys:       keys,
+		authOpts:   authOpts,
+	}
+}
+
+// RegisterRoutes registers the routes for the result handler
+func (h *ResultHandler) RegisterRoutes(r chi.Router) {
+	// Respondents may answer surveys anonymously. Responses of signed-in users are attributed
+	// to them.
+	r.With(middleware.OptionalAuth(h.keys, h.authOpts...)).Post("/responses", h.SubmitResponse)
+	r.With(middleware.OptionalAuth(h.keys, h.authOpts...)).Post("/sessions", h.StartSession)
+	r.Post("/sessions/{id}/answers", h.AddAnswers)
+	r.Post("/sessions/{id}/complete", h.CompleteSession)
+
+	// Protected routes
+	r.Group(func(r chi.Router) {
+		// Access to the results of each survey is checked with the survey service, which
+		// resolves ownership and organization membership
+		r.Use(middleware.Auth(h.keys, h.authOpts...))
+		r.Get("/responses/{id}", h.GetResponse)
+		r.Get("/surveys/{surveyId}", h.GetSurveyResults)
+		r.Get("/surveys/{surveyId}/responses", h.ListResponses)
+		r.Get("/surveys/{surveyId}/analytics", h.GetAnalytics)
+		r.Get("/surveys/{surveyId}/correlations", h.GetCorrelation)
+		r.Post("/surveys/{surveyId}/exports", h.ExportResults)
+		r.Get("/export-jobs/{id}", h.GetExportJob)
+		r.Post("/export-jobs/{id}/cancel", h.CancelExportJob)
+		r.Get("/exports/{id}/download", h.DownloadExport)
+	})
+}
+
+// SubmitResponse validates and stores a complete response to a survey
+func (h *ResultHandler) SubmitResponse(w http.ResponseWriter, r *http.Request) {
+	var req models.SubmitResponseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	survey, ok := h.getOpenSurvey(w, r, req.SurveyID)
+	if !ok {
+		return
+	}
+
+	// Validate the answers against the survey definition
+	if err := validation.ValidateAnswers(survey, req.Answers); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	answered := make(map[string]bool, len(req.Answers))
+	for _, answer := range req.Answers {
+		answered[answer.QuestionID] = true
+	}
+	if err := validation.ValidateRequired(survey, answered); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	response := &models.Response{
+		SurveyID:     survey.ID,
+		RespondentID: respondentID(r, req.RespondentID),
+		StartedAt:    now,
+		CompletedAt:  &now,
+		IPAddress:    commonhttp.ClientIP(r),
+		UserAgent:    r.UserAgent(),
+		Answers:      toAnswers(req.Answers),
+	}
+
+	if err := h.repo.CreateResponse(r.Context(), response); err != nil {
+		h.logger.Error("Failed to create response", "survey_id", survey.ID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+	h.invalidateAnalytics(r, survey.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// StartSession starts a response session that answers can be added to incrementally
+func (h *ResultHandler) StartSession(w http.ResponseWriter, r *http.Request) {
+	var req models.StartResponseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	survey, ok := h.getOpenSurvey(w, r, req.SurveyID)
+	if !ok {
+		return
+	}
+
+	response := &models.Response{
+		SurveyID:     survey.ID,
+		RespondentID: respondentID(r, req.RespondentID),
+		IPAddress:    commonhttp.ClientIP(r),
+		UserAgent:    r.UserAgent(),
+	}
+
+	if err := h.repo.CreateResponse(r.Context(), response); err != nil {
+		h.logger.Error("Failed to start response session", "survey_id", survey.ID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+	h.invalidateAnalytics(r, survey.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// respondentID returns the respondent of a new response. Signed-in users always respond as
+// themselves, whatever the request claims.
+func respondentID(r *http.Request, requested *string) *string {
+	if user, err := middleware.GetUserFromContext(r.Context()); err == nil {
+		return &user.UserID
+	}
+	return requested
+}
+
+// AddAnswers adds answers to an open response session, replacing earlier answers to the same questions
+func (h *ResultHandler) AddAnswers(w http.ResponseWriter, r *http.Request) {
+	response, ok := h.getOpenSession(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AddAnswersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	survey, ok := h.getOpenSurvey(w, r, response.SurveyID)
+	if !ok {
+		return
+	}
+
+	// Validate the answers against the survey definition
+	if err := validation.ValidateAnswers(survey, req.Answers); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	if err := h.repo.ReplaceAnswers(r.Context(), response, toAnswers(req.Answers)); err != nil {
+		h.logger.Error("Failed to add answers", "response_id", response.ID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+	h.invalidateAnalytics(r, response.SurveyID)
+
+	updated, err := h.repo.GetResponse(r.Context(), response.ID)
+	if err != nil {
+		h.logger.Error("Failed to get response", "response_id", response.ID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// CompleteSession marks a response session as completed once all required questions are answered
+func (h *ResultHandler) CompleteSession(w http.ResponseWriter, r *http.Request) {
+	response, ok := h.getOpenSession(w, r)
+	if !ok {
+		return
+	}
+
+	survey, ok := h.getOpenSurvey(w, r, response.SurveyID)
+	if !ok {
+		return
+	}
+
+	answered := make(map[string]bool, len(response.Answers))
+	for _, answer := range response.Answers {
+		answered[answer.QuestionID] = true
+	}
+	if err := validation.ValidateRequired(survey, answered); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	if err := h.repo.CompleteResponse(r.Context(), response.ID, now); err != nil {
+		if err == repository.ErrNotFound {
+			errors.HandleError(w, errors.ErrBadRequest, "Response is already completed")
+			return
+		}
+		h.logger.Error("Failed to complete response", "response_id", response.ID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+	response.CompletedAt = &now
+	response.UpdatedAt = now
+	h.invalidateAnalytics(r, response.SurveyID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetResponse gets a response by ID
+func (h *ResultHandler) GetResponse(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid response ID")
+		return
+	}
+
+	response, err := h.repo.GetResponse(r.Context(), id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			errors.HandleError(w, errors.ErrNotFound, "Response not found")
+			return
+		}
+		h.logger.Error("Failed to get response", "response_id", id, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+	if !h.authorize(w, r, response.SurveyID, permissions.ResultsRead) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListResponses lists the responses to a survey with pagination
+func (h *ResultHandler) ListResponses(w http.ResponseWriter, r *http.Request) {
+	surveyID := chi.URLParam(r, "surveyId")
+	if !h.authorize(w, r, surveyID, permissions.ResultsRead) {
+		return
+	}
+
+	// Parse pagination parameters
+	page := 1
+	perPage := 20
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if pageVal, err := strconv.Atoi(pageStr); err == nil && pageVal > 0 {
+			page = pageVal
+		}
+	}
+
+	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
+		if perPageVal, err := strconv.Atoi(perPageStr); err == nil && perPageVal > 0 && perPageVal <= 100 {
+			perPage = perPageVal
+		}
+	}
+
+	total, err := h.repo.CountResponses(r.Context(), surveyID)
+	if err != nil {
+		h.logger.Error("Failed to count responses", "survey_id", surveyID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	responses, err := h.repo.ListResponses(r.Context(), surveyID, perPage, (page-1)*perPage)
+	if err != nil {
+		h.logger.Error("Failed to list responses", "survey_id", surveyID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	// Convert to summaries
+	list := models.ResponseList{
+		Data: make([]models.ResponseSummary, len(responses)),
+		Meta: models.PaginationMeta{
+			Total:   total,
+			Page:    page,
+			PerPage: perPage,
+		},
+	}
+	for i, response := range responses {
+		list.Data[i] = response.ToSummary()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetSurveyResults gets the aggregated results of a survey
+func (h *ResultHandler) GetSurveyResults(w http.ResponseWriter, r *http.Request) {
+	surveyID := chi.URLParam(r, "surveyId")
+	if !h.authorize(w, r, surveyID, permissions.ResultsRead) {
+		return
+	}
+
+	survey, ok := h.getSurvey(w, r, surveyID)
+	if !ok {
+		return
+	}
+
+	// Parse text answer pagination parameters
+	opts := aggregator.Options{
+		TextPage:    1,
+		TextPerPage: 20,
+	}
+
+	if pageStr := r.URL.Query().Get("text_page"); pageStr != "" {
+		if pageVal, err := strconv.Atoi(pageStr); err == nil && pageVal > 0 {
+			opts.TextPage = pageVal
+		}
+	}
+
+	if perPageStr := r.URL.Query().Get("text_per_page"); perPageStr != "" {
+		if perPageVal, err := strconv.Atoi(perPageStr); err == nil && perPageVal > 0 && perPageVal <= 100 {
+			opts.TextPerPage = perPageVal
+		}
+	}
+
+	result, err := h.aggregator.Build(r.Context(), survey, opts)
+	if err != nil {
+		h.logger.Error("Failed to aggregate survey results", "survey_id", surveyID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetAnalytics gets the analytics of a survey for a period and optional time range
+func (h *ResultHandler) GetAnalytics(w http.ResponseWriter, r *http.Request) {
+	req := models.AnalyticsRequest{
+		SurveyID: chi.URLParam(r, "surveyId"),
+		Period:   models.TimePeriod(r.URL.Query().Get("period")),
+	}
+	if req.Period == "" {
+		req.Period = models.TimePeriodAll
+	}
+
+	if !h.authorize(w, r, req.SurveyID, permissions.ResultsRead) {
+		return
+	}
+
+	// Parse the optional time range
+	var err error
+	if req.StartDate, err = parseTimeParam(r, "start_date"); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid start_date, expected RFC 3339 timestamp")
+		return
+	}
+	if req.EndDate, err = parseTimeParam(r, "end_date"); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid end_date, expected RFC 3339 timestamp")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	if req.StartDate != nil && req.EndDate != nil && !req.EndDate.After(*req.StartDate) {
+		errors.HandleError(w, errors.ErrBadRequest, "end_date must be after start_date")
+		return
+	}
+
+	result, err := h.analytics.Analytics(r.Context(), req)
+	if err != nil {
+		h.logger.Error("Failed to compute analytics", "survey_id", req.SurveyID, "error", err)
+		errors.HandleError(w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetCorrelation gets the association between the answers to two questions of a survey
+func (h *ResultHandler) GetCorrelation(w http.ResponseWriter, r *http.Request) {
+	req := models.CorrelationRequest{
+		SurveyID:    chi.URLParam(r, "surveyId"),
+		QuestionAID: r.URL.Query().Get("question_a"),
+		QuestionBID: r.URL.Query().Get("question_b"),
+	}
+
+	if !h.authorize(w, r, req.SurveyID, permissions.ResultsRead) {
+		return
+	}
+
+	// Parse the optional time range
+	var err error
+	if req.StartDate, err = parseTimeParam(r, "start_date"); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid start_date, expected RFC 3339 timestamp")
+		return
+	}
+	if req.EndDate, err = parseTimeParam(r, "end_date"); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid end_date, expected RFC 3339 timestamp")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	if req.StartDate != nil && req.EndDate != nil && !req.EndDate.After(*req.StartDate) {
+		errors.HandleError(w, errors.ErrBadRequest, "end_date must be after start_date")
+		return
+	}
+
+	result, err := h.analytics.Correlation(r.Context(), req)
+	if err != nil {
+		h.logger.Error("Failed to compute correlation", "survey_id", req.SurveyID, "error", err)
+		errors.HandleError(w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ExportResults queues an export of the responses of a survey. The export runs in the
+// background and its progress can be followed through the returned job.
+func (h *ResultHandler) ExportResults(w http.ResponseWriter, r *http.Request) {
+	var req models.ExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+	req.SurveyID = chi.URLParam(r, "surveyId")
+
+	if _, err := uuid.Parse(req.SurveyID); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid survey ID")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	// Unknown surveys fail here instead of queueing a job that cannot succeed
+	if !h.authorize(w, r, req.SurveyID, permissions.ResultsExport) {
+		return
+	}
+
+	var requestedBy *string
+	if user, err := middleware.GetUserFromContext(r.Context()); err == nil {
+		requestedBy = &user.UserID
+	}
+
+	job, err := h.exportJobs.Submit(r.Context(), req, requestedBy)
+	if err != nil {
+		h.logger.Error("Failed to queue export job", "survey_id", req.SurveyID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionResultsExport, "survey", req.SurveyID, nil, job)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", BasePath+"/export-jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetExportJob gets the status and progress of an export job
+func (h *ResultHandler) GetExportJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid export job ID")
+		return
+	}
+
+	job, err := h.exportJobs.Job(r.Context(), id)
+	if err != nil {
+		errors.HandleError(w, err, "")
+		return
+	}
+	if !h.authorize(w, r, job.SurveyID, permissions.ResultsExport) {
+		return
+	}
+	setDownloadURL(job)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// CancelExportJob cancels a pending or running export job
+func (h *ResultHandler) CancelExportJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid export job ID")
+		return
+	}
+
+	job, err := h.exportJobs.Job(r.Context(), id)
+	if err != nil {
+		errors.HandleError(w, err, "")
+		return
+	}
+	if !h.authorize(w, r, job.SurveyID, permissions.ResultsExport) {
+		return
+	}
+
+	job, err = h.exportJobs.Cancel(r.Context(), id)
+	if err != nil {
+		errors.HandleError(w, err, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// DownloadExport serves the file of an export
+func (h *ResultHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid export ID")
+		return
+	}
+
+	result, file, err := h.exporter.Open(r.Context(), id)
+	if err != nil {
+		errors.HandleError(w, err, "")
+		return
+	}
+	defer file.Close()
+	if !h.authorize(w, r, result.SurveyID, permissions.ResultsExport) {
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionExportDownload, "export", result.ID, nil, nil)
+
+	filename := fmt.Sprintf("survey-%s-%s.%s", result.SurveyID, result.CreatedAt.Format("20060102-150405"), result.Format)
+	w.Header().Set("Content-Type", export.ContentType(result.Format))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	http.ServeContent(w, r, filename, result.CreatedAt, file)
+}
+
+// setDownloadURL links a completed export job to the download of its file
+func setDownloadURL(job *models.ExportJob) {
+	if job.Status == models.ExportJobStatusCompleted && job.ExportID != nil {
+		job.DownloadURL = BasePath + "/exports/" + *job.ExportID + "/download"
+	}
+}
+
+// invalidateAnalytics drops the cached analytics of a survey after its responses changed
+func (h *ResultHandler) invalidateAnalytics(r *http.Request, surveyID string) {
+	if err := h.analytics.Invalidate(r.Context(), surveyID); err != nil {
+		h.logger.Error("Failed to invalidate analytics", "survey_id", surveyID, "error", err)
+	}
+}
+
+// getSurvey fetches a survey definition and writes an error response if that fails
+func (h *ResultHandler) getSurvey(w http.ResponseWriter, r *http.Request, surveyID string) (*models.Survey, bool) {
+	if _, err := uuid.Parse(surveyID); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid survey ID")
+		return nil, false
+	}
+
+	survey, err := h.surveys.GetSurvey(forwardAuthorization(r), surveyID)
+	if err != nil {
+		h.logger.Error("Failed to get survey", "survey_id", surveyID, "error", err)
+		errors.HandleError(w, err, "")
+		return nil, false
+	}
+
+	return survey, true
+}
+
+// authorize checks with the survey service that the current user has a permission on a
+// survey. If not it responds with an error and returns false.
+func (h *ResultHandler) authorize(w http.ResponseWriter, r *http.Request, surveyID, permission string) bool {
+	if _, err := uuid.Parse(surveyID); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid survey ID")
+		return false
+	}
+
+	access, err := h.surveys.GetAccess(forwardAuthorization(r), surveyID)
+	if err != nil {
+		h.logger.Error("Failed to get survey access", "survey_id", surveyID, "error", err)
+		errors.HandleError(w, err, "")
+		return false
+	}
+	if !access.Allows(permission) {
+		errors.HandleError(w, errors.ErrForbidden, "")
+		return false
+	}
+
+	return true
+}
+
+// forwardAuthorization returns the context of a request that makes calls to other services on
+// behalf of the user who sent it
+func forwardAuthorization(r *http.Request) context.Context {
+	return commonhttp.WithAuthorization(r.Context(), r.Header.Get("Authorization"))
+}
+
+// getOpenSurvey fetches a survey definition and checks that the survey accepts responses
+func (h *ResultHandler) getOpenSurvey(w http.ResponseWriter, r *http.Request, surveyID string) (*models.Survey, bool) {
+	survey, ok := h.getSurvey(w, r, surveyID)
+	if !ok {
+		return nil, false
+	}
+
+	if !survey.AcceptsResponses() {
+		errors.HandleError(w, errors.ErrConflict, "Survey is not accepting responses")
+		return nil, false
+	}
+
+	return survey, true
+}
+
+// getOpenSession loads the response session from the URL and checks that it is not completed yet
+func (h *ResultHandler) getOpenSession(w http.ResponseWriter, r *http.Request) (*models.Response, bool) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid response ID")
+		return nil, false
+	}
+
+	response, err := h.repo.GetResponse(r.Context(), id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			errors.HandleError(w, errors.ErrNotFound, "Response not found")
+			return nil, false
+		}
+		h.logger.Error("Failed to get response", "response_id", id, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return nil, false
+	}
+
+	if response.CompletedAt != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Response is already completed")
+		return nil, false
+	}
+
+	return response, true
+}
+
+// toAnswers converts submitted answers to answer models
+func toAnswers(requests []models.SubmitAnswerRequest) []models.Answer {
+	answers := make([]models.Answer, len(requests))
+	for i, req := range requests {
+		answers[i] = models.Answer{
+			QuestionID: req.QuestionID,
+			OptionID:   req.OptionID,
+		}
+		if req.TextAnswer != nil {
+			text := strings.TrimSpace(*req.TextAnswer)
+			answers[i].TextAnswer = &text
+		}
+	}
+	return answers
+}
+
+// parseTimeParam parses an optional RFC 3339 timestamp query parameter
+func parseTimeParam(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsed, nil
+}
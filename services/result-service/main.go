@@ -1,17 +1,240 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/apikeys"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/config"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/database"
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/jwks"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	authmw "github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/revocation"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/aggregator"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/analytics"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/client"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/export"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/handler"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/migrations"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
 )
 
 func main() {
-	fmt.Println("Result Service is running...")
+	// Initialize logger
+	logger := logging.NewLogger(&logging.Config{
+		Level:       config.GetEnv("LOG_LEVEL", "info"),
+		ServiceName: "result-service",
+		Environment: config.GetEnv("ENV", "development"),
+	})
+	logger.Info("Starting result service")
+
+	// Database configuration
+	dbConfig := &database.Config{
+		Host:     config.GetEnv("DB_HOST", "localhost"),
+		Port:     config.GetEnvInt("DB_PORT", 5432),
+		User:     config.GetEnv("DB_USER", "postgres"),
+		Password: config.GetEnv("DB_PASSWORD", "postgres"),
+		DBName:   config.GetEnv("DB_NAME", "pollpulse_results"),
+		SSLMode:  config.GetEnv("DB_SSLMODE", "disable"),
+	}
+
+	// Connect to the database
+	db, err := database.Connect(dbConfig)
+	if err != nil {
+		logger.Fatal("Failed to connect to database", "error", err)
+	}
+	defer database.Close(db)
+
+	// Run the migrate subcommand or apply pending migrations
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		logger.Fatal("Failed to load migrations", "error", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := database.RunMigrateCommand(context.Background(), migrator, os.Args[2:], os.Stdout); err != nil {
+			logger.Fatal("Failed to run migrations", "error", err)
+		}
+		return
+	}
+	if config.GetEnvBool("MIGRATE_ON_START", true) {
+		migrated, err := migrator.Up(context.Background())
+		if err != nil {
+			logger.Fatal("Failed to apply migrations", "error", err)
+		}
+		for _, migration := range migrated {
+			logger.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+		}
+	}
+
+	// Connect to the audit database, which the services share
+	auditDB, err := database.Connect(&database.Config{
+		Host:     config.GetEnv("AUDIT_DB_HOST", dbConfig.Host),
+		Port:     config.GetEnvInt("AUDIT_DB_PORT", dbConfig.Port),
+		User:     config.GetEnv("AUDIT_DB_USER", dbConfig.User),
+		Password: config.GetEnv("AUDIT_DB_PASSWORD", dbConfig.Password),
+		DBName:   config.GetEnv("AUDIT_DB_NAME", "pollpulse_audit"),
+		SSLMode:  config.GetEnv("AUDIT_DB_SSLMODE", dbConfig.SSLMode),
+	})
+	if err != nil {
+		logger.Fatal("Failed to connect to audit database", "error", err)
+	}
+	defer database.Close(auditDB)
+
+	auditStore := audit.NewStore(auditDB)
+	if config.GetEnvBool("MIGRATE_ON_START", true) {
+		if err := auditStore.Migrate(context.Background()); err != nil {
+			logger.Fatal("Failed to apply audit migrations", "error", err)
+		}
+	}
+	auditRecorder := audit.NewRecorder(auditStore, "result-service", func(err error) {
+		logger.Error("Failed to record audit event", "error", err)
+	})
+
+	// Create repository and clients
+	responseRepo := repository.NewResponseRepository(db)
+	resultRepo := repository.NewResultRepository(db)
+	surveyClient := client.NewSurveyClient(
+		config.GetEnv("SURVEY_SERVICE_URL", "http://localhost:8082"),
+		config.GetEnvDuration("SURVEY_SERVICE_TIMEOUT", 10*time.Second),
+	)
+	resultAggregator := aggregator.NewAggregator(resultRepo, surveyClient)
+	analyticsEngine := analytics.NewEngine(repository.NewAnalyticsRepository(db), surveyClient)
+	// Replicas must share EXPORT_DIR, since any of them may serve or sweep an export
+	exporter := export.NewExporter(
+		repository.NewExportRepository(db),
+		resultAggregator,
+		surveyClient,
+		config.GetEnv("EXPORT_DIR", filepath.Join(os.TempDir(), "pollpulse-exports")),
+		config.GetEnvDuration("EXPORT_TTL", 24*time.Hour),
+	)
+
+	exportJobs := export.NewJobRunner(
+		repository.NewExportJobRepository(db),
+		exporter,
+		config.GetEnvInt("EXPORT_WORKERS", 2),
+		logger,
+	)
+
+	// Run export jobs and delete expired exports in the background
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	backgroundDone := make(chan struct{})
+	go func() {
+		defer close(backgroundDone)
+		exportJobs.Run(backgroundCtx)
+	}()
+	go exporter.RunSweeper(backgroundCtx, config.GetEnvDuration("EXPORT_SWEEP_INTERVAL", time.Hour), logger)
+
+	// Only take the address of clients from the API gateway and the proxies it runs behind
+	trustedProxies, err := commonhttp.ParseTrustedProxies(config.GetEnvSlice("TRUSTED_PROXIES", ",", nil))
+	if err != nil {
+		logger.Fatal("Invalid trusted proxies", "error", err)
+	}
+
+	// Initialize router
+	r := chi.NewRouter()
 
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	// Middleware
+	r.Use(middleware.RequestID)
+	// Take the address of clients from the API gateway
+	r.Use(commonhttp.GatewayIP(trustedProxies))
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Timeout(60 * time.Second))
+
+	// CORS configuration
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   []string{"*"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	}))
+
+	// Create handler
+	keys := jwks.NewClient(
+		config.GetEnv("JWKS_URL", "http://localhost:8081"+jwks.Path),
+		config.GetEnvDuration("JWKS_CACHE_TTL", 5*time.Minute),
+	)
+	userServiceURL := config.GetEnv("USER_SERVICE_URL", "http://localhost:8081")
+	apiKeys := apikeys.NewClient(userServiceURL, config.GetEnvDuration("API_KEY_CACHE_TTL", 30*time.Second))
+	revocations := revocation.NewClient(userServiceURL, config.GetEnvDuration("REVOCATION_CACHE_TTL", 10*time.Second))
+	go apiKeys.RunCleanup(backgroundCtx, time.Minute)
+	go revocations.RunCleanup(backgroundCtx, time.Minute)
+	resultHandler := handler.NewResultHandler(responseRepo, resultAggregator, analyticsEngine, exporter, exportJobs, surveyClient, auditRecorder, logger, keys,
+		authmw.WithAPIKeyVerifier(apiKeys),
+		authmw.WithRevocationChecker(revocations),
+		authmw.WithGatewaySecret([]byte(config.GetEnv("GATEWAY_IDENTITY_SECRET", ""))),
+	)
+
+	// Register routes
+	r.Route(handler.BasePath, func(r chi.Router) {
+		resultHandler.RegisterRoutes(r)
+	})
+
+	// Health check endpoint
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
 
-	http.ListenAndServe(":8083", nil)
+	// Start server
+	port := config.GetEnvInt("PORT", 8083)
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: r,
+	}
+
+	// Create a channel to listen for errors from the server
+	serverErrors := make(chan error, 1)
+
+	// Start the server in a goroutine
+	go func() {
+		logger.Info("Starting server", "port", port)
+		serverErrors <- server.ListenAndServe()
+	}()
+
+	// Create a channel to listen for an interrupt or terminate signal
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+
+	// Block until an error or shutdown signal is received
+	select {
+	case err := <-serverErrors:
+		logger.Error("Server error", "error", err)
+
+	case <-shutdown:
+		logger.Info("Shutting down server")
+
+		// Create a context with a timeout to give outstanding requests a chance to complete
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// Ask the server to shutdown gracefully
+		if err := server.Shutdown(ctx); err != nil {
+			// If graceful shutdown fails, forcefully close
+			logger.Error("Server forced to shutdown", "error", err)
+			if err := server.Close(); err != nil {
+				logger.Error("Server close error", "error", err)
+			}
+		}
+	}
+
+	// Stop the background work and wait for running export jobs to be requeued
+	stopBackground()
+	<-backgroundDone
+
+	logger.Info("Server stopped")
 }
@@ -0,0 +1,15 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_exported_results_survey_id;
+DROP INDEX IF EXISTS idx_response_analytics_survey_id;
+DROP INDEX IF EXISTS idx_responses_option_id;
+DROP INDEX IF EXISTS idx_responses_question_id;
+DROP INDEX IF EXISTS idx_responses_survey_id;
+DROP INDEX IF EXISTS idx_responses_response_id;
+DROP INDEX IF EXISTS idx_response_sessions_respondent_id;
+DROP INDEX IF EXISTS idx_response_sessions_survey_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS exported_results;
+DROP TABLE IF EXISTS response_analytics;
+DROP TABLE IF EXISTS responses;
+DROP TABLE IF EXISTS response_sessions;
@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_export_jobs_status_created_at;
+DROP INDEX IF EXISTS idx_export_jobs_survey_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS export_jobs;
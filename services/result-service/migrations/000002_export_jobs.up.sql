@@ -0,0 +1,23 @@
+-- Create export_jobs table to track asynchronous exports of survey results
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id UUID PRIMARY KEY,
+    survey_id UUID NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    include_raw BOOLEAN NOT NULL DEFAULT FALSE,
+    include_summary BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL,  -- pending, running, completed, failed, canceled
+    progress INTEGER NOT NULL DEFAULT 0,  -- Percentage of processed responses
+    processed_responses INTEGER NOT NULL DEFAULT 0,
+    total_responses INTEGER NOT NULL DEFAULT 0,
+    export_id UUID REFERENCES exported_results(id) ON DELETE SET NULL,  -- Set once completed
+    error TEXT,
+    requested_by UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_export_jobs_survey_id ON export_jobs(survey_id);
+CREATE INDEX IF NOT EXISTS idx_export_jobs_status_created_at ON export_jobs(status, created_at);
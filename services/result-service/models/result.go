@@ -57,10 +57,24 @@ type OptionResult struct {
 	Percentage float64 `json:"percentage"`
 }
 
+// OptionCount represents how often an option of a question was selected
+type OptionCount struct {
+	QuestionID string `db:"question_id"`
+	OptionID   string `db:"option_id"`
+	Count      int    `db:"count"`
+}
+
+// ValueCount represents how often a value was given as the answer to a question
+type ValueCount struct {
+	QuestionID string `db:"question_id"`
+	Value      string `db:"value"`
+	Count      int    `db:"count"`
+}
+
 // SubmitResponseRequest represents the request to submit a response to a survey
 type SubmitResponseRequest struct {
 	SurveyID     string                `json:"survey_id" validate:"required"`
-	RespondentID *string               `json:"respondent_id,omitempty"`
+	RespondentID *string               `json:"respondent_id,omitempty" validate:"omitempty,uuid"`
 	Answers      []SubmitAnswerRequest `json:"answers" validate:"required,min=1,dive"`
 	IPAddress    string                `json:"ip_address,omitempty"`
 	UserAgent    string                `json:"user_agent,omitempty"`
@@ -73,6 +87,17 @@ type SubmitAnswerRequest struct {
 	TextAnswer *string `json:"text_answer,omitempty"`
 }
 
+// StartResponseRequest represents the request to start a response session
+type StartResponseRequest struct {
+	SurveyID     string  `json:"survey_id" validate:"required"`
+	RespondentID *string `json:"respondent_id,omitempty" validate:"omitempty,uuid"`
+}
+
+// AddAnswersRequest represents the request to add answers to a response session
+type AddAnswersRequest struct {
+	Answers []SubmitAnswerRequest `json:"answers" validate:"required,min=1,dive"`
+}
+
 // ResponseSummary represents a summary of a response
 type ResponseSummary struct {
 	ID           string     `json:"id"`
@@ -97,6 +122,19 @@ func (r *Response) ToSummary() ResponseSummary {
 	}
 }
 
+// PaginationMeta describes a page of a paginated list
+type PaginationMeta struct {
+	Total   int `json:"total"`
+	Page    int `json:"page"`
+	PerPage int `json:"per_page"`
+}
+
+// ResponseList represents a page of response summaries
+type ResponseList struct {
+	Data []ResponseSummary `json:"data"`
+	Meta PaginationMeta    `json:"meta"`
+}
+
 // ExportFormat represents the format for exporting survey results
 type ExportFormat string
 
@@ -109,9 +147,69 @@ const (
 
 // ExportRequest represents the request to export survey results
 type ExportRequest struct {
-	SurveyID   string       `json:"survey_id" validate:"required"`
-	Format     ExportFormat `json:"format" validate:"required,oneof=csv json xlsx"`
-	IncludeRaw bool         `json:"include_raw"` // Whether to include raw responses
+	SurveyID       string       `json:"survey_id" validate:"required"`
+	Format         ExportFormat `json:"format" validate:"required,oneof=csv json xlsx"`
+	IncludeRaw     bool         `json:"include_raw"`     // Whether to include raw session data such as IP address and user agent
+	IncludeSummary bool         `json:"include_summary"` // Whether to include the aggregated results
+}
+
+// ExportedResult represents an exported file of survey results
+type ExportedResult struct {
+	ID        string       `json:"id" db:"id"`
+	SurveyID  string       `json:"survey_id" db:"survey_id"`
+	FilePath  string       `json:"-" db:"file_path"`
+	Format    ExportFormat `json:"format" db:"format"`
+	Size      int64        `json:"size" db:"size"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at" db:"expires_at"`
+}
+
+// ExportJobStatus represents the state of an export job
+type ExportJobStatus string
+
+// Export job statuses
+const (
+	ExportJobStatusPending   ExportJobStatus = "pending"
+	ExportJobStatusRunning   ExportJobStatus = "running"
+	ExportJobStatusCompleted ExportJobStatus = "completed"
+	ExportJobStatusFailed    ExportJobStatus = "failed"
+	ExportJobStatusCanceled  ExportJobStatus = "canceled"
+)
+
+// IsFinished reports whether a job in this status will not change anymore
+func (s ExportJobStatus) IsFinished() bool {
+	return s == ExportJobStatusCompleted || s == ExportJobStatusFailed || s == ExportJobStatusCanceled
+}
+
+// ExportJob represents an asynchronous export of survey results
+type ExportJob struct {
+	ID                 string          `json:"id" db:"id"`
+	SurveyID           string          `json:"survey_id" db:"survey_id"`
+	Format             ExportFormat    `json:"format" db:"format"`
+	IncludeRaw         bool            `json:"include_raw" db:"include_raw"`
+	IncludeSummary     bool            `json:"include_summary" db:"include_summary"`
+	Status             ExportJobStatus `json:"status" db:"status"`
+	Progress           int             `json:"progress" db:"progress"` // Percentage of processed responses
+	ProcessedResponses int             `json:"processed_responses" db:"processed_responses"`
+	TotalResponses     int             `json:"total_responses" db:"total_responses"`
+	ExportID           *string         `json:"export_id,omitempty" db:"export_id"` // Set once completed
+	DownloadURL        string          `json:"download_url,omitempty" db:"-"`
+	Error              *string         `json:"error,omitempty" db:"error"`
+	RequestedBy        *string         `json:"requested_by,omitempty" db:"requested_by"`
+	CreatedAt          time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time       `json:"updated_at" db:"updated_at"`
+	StartedAt          *time.Time      `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt         *time.Time      `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+// Request returns the export request the job runs
+func (j *ExportJob) Request() ExportRequest {
+	return ExportRequest{
+		SurveyID:       j.SurveyID,
+		Format:         j.Format,
+		IncludeRaw:     j.IncludeRaw,
+		IncludeSummary: j.IncludeSummary,
+	}
 }
 
 // TimePeriod represents a time period for analytics
@@ -152,6 +250,14 @@ type Analytics struct {
 	Demographics          map[string]interface{} `json:"demographics,omitempty"` // If demographic data is available
 }
 
+// TopAnswer represents one of the most common answers to a question
+type TopAnswer struct {
+	Answer     string  `json:"answer"`
+	OptionID   string  `json:"option_id,omitempty"`
+	Count      int     `json:"count"`
+	Percentage float64 `json:"percentage"` // Percentage of the responses that answered the question
+}
+
 // QuestionInsight represents insights for a specific question
 type QuestionInsight struct {
 	QuestionID   string                   `json:"question_id"`
@@ -160,3 +266,67 @@ type QuestionInsight struct {
 	TopAnswers   []interface{}            `json:"top_answers,omitempty"`  // Most common answers
 	Correlations []map[string]interface{} `json:"correlations,omitempty"` // Correlations with other questions
 }
+
+// Correlation methods
+const (
+	CorrelationMethodCramersV = "cramers_v"
+	CorrelationMethodPearson  = "pearson"
+	CorrelationMethodEta      = "eta"
+)
+
+// CorrelationRequest represents the request to correlate the answers to two questions
+type CorrelationRequest struct {
+	SurveyID    string     `json:"survey_id" validate:"required"`
+	QuestionAID string     `json:"question_a" validate:"required"`
+	QuestionBID string     `json:"question_b" validate:"required,nefield=QuestionAID"`
+	StartDate   *time.Time `json:"start_date,omitempty"`
+	EndDate     *time.Time `json:"end_date,omitempty"`
+}
+
+// CrosstabHeader labels a row or column of a crosstab
+type CrosstabHeader struct {
+	Value string `json:"value"` // Option ID or rating value
+	Label string `json:"label"`
+}
+
+// Crosstab represents how often each combination of answers to two questions was given
+type Crosstab struct {
+	Rows    []CrosstabHeader `json:"rows"`    // Answers to question A
+	Columns []CrosstabHeader `json:"columns"` // Answers to question B
+	Counts  [][]int          `json:"counts"`  // Counts[row][column]
+	Total   int              `json:"total"`
+}
+
+// ChiSquareResult represents the result of a chi-square test of independence
+type ChiSquareResult struct {
+	Statistic        float64 `json:"statistic"`
+	DegreesOfFreedom int     `json:"degrees_of_freedom"`
+	PValue           float64 `json:"p_value"`
+}
+
+// GroupMean represents the mean rating given by respondents who selected an option
+type GroupMean struct {
+	OptionID   string  `json:"option_id"`
+	OptionText string  `json:"option_text"`
+	Count      int     `json:"count"`
+	Mean       float64 `json:"mean"`
+}
+
+// CorrelationResult represents the association between the answers to two questions
+type CorrelationResult struct {
+	SurveyID    string           `json:"survey_id"`
+	QuestionAID string           `json:"question_a"`
+	QuestionBID string           `json:"question_b"`
+	SampleSize  int              `json:"sample_size"` // Responses that answered both questions
+	Method      string           `json:"method"`      // Primary measure of association
+	Coefficient float64          `json:"coefficient"`
+	PValue      float64          `json:"p_value"`
+	Significant bool             `json:"significant"`
+	Crosstab    *Crosstab        `json:"crosstab,omitempty"`
+	ChiSquare   *ChiSquareResult `json:"chi_square,omitempty"`
+	CramersV    *float64         `json:"cramers_v,omitempty"`
+	Pearson     *float64         `json:"pearson,omitempty"`
+	Spearman    *float64         `json:"spearman,omitempty"`
+	Eta         *float64         `json:"eta,omitempty"`
+	GroupMeans  []GroupMean      `json:"group_means,omitempty"`
+}
@@ -0,0 +1,101 @@
+package models
+
+// Question types supported by the survey service
+const (
+	QuestionTypeMultipleChoice = "multiple_choice"
+	QuestionTypeSingleChoice   = "single_choice"
+	QuestionTypeText           = "text"
+	QuestionTypeRating         = "rating"
+	QuestionTypeDate           = "date"
+)
+
+// Survey statuses of the survey service
+const (
+	SurveyStatusDraft     = "draft"
+	SurveyStatusPublished = "published"
+	SurveyStatusClosed    = "closed"
+)
+
+// Answer constraints
+const (
+	MinRatingValue      = 1
+	MaxRatingValue      = 10
+	MaxTextAnswerLength = 5000
+	DateAnswerLayout    = "2006-01-02"
+)
+
+// Survey represents a survey definition as served by the survey service
+type Survey struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	CreatedBy   string     `json:"created_by"`
+	IsActive    bool       `json:"is_active"`
+	Status      string     `json:"status"`
+	Questions   []Question `json:"questions"`
+}
+
+// SurveyAccess lists the permissions of a user on a survey as served by the survey service
+type SurveyAccess struct {
+	SurveyID    string   `json:"survey_id"`
+	Permissions []string `json:"permissions"`
+}
+
+// Allows reports whether the user has a permission on the survey
+func (a *SurveyAccess) Allows(permission string) bool {
+	for _, p := range a.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Question represents a question of a survey definition
+type Question struct {
+	ID       string   `json:"id"`
+	SurveyID string   `json:"survey_id"`
+	Text     string   `json:"text"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Order    int      `json:"order"`
+	Options  []Option `json:"options,omitempty"`
+}
+
+// Option represents an option of a choice question
+type Option struct {
+	ID         string `json:"id"`
+	QuestionID string `json:"question_id"`
+	Text       string `json:"text"`
+	Order      int    `json:"order"`
+}
+
+// AcceptsResponses reports whether respondents may currently answer the survey
+func (s *Survey) AcceptsResponses() bool {
+	return s.Status == SurveyStatusPublished
+}
+
+// Question returns the question with the given ID or nil if the survey has no such question
+func (s *Survey) Question(id string) *Question {
+	for i := range s.Questions {
+		if s.Questions[i].ID == id {
+			return &s.Questions[i]
+		}
+	}
+	return nil
+}
+
+// Option returns the option with the given ID or nil if the question has no such option
+func (q *Question) Option(id string) *Option {
+	for i := range q.Options {
+		if q.Options[i].ID == id {
+			return &q.Options[i]
+		}
+	}
+	return nil
+}
+
+// IsChoice reports whether the question is answered by selecting options
+func (q *Question) IsChoice() bool {
+	return q.Type == QuestionTypeSingleChoice || q.Type == QuestionTypeMultipleChoice
+}
@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// windowCondition restricts response sessions aliased as s to the ones started
+// within the optional time range passed as the second and third query argument
+const windowCondition = `
+	s.survey_id = $1
+	AND ($2::timestamptz IS NULL OR s.started_at >= $2)
+	AND ($3::timestamptz IS NULL OR s.started_at < $3)
+`
+
+// AnalyticsRepository handles analytics queries and the analytics cache
+type AnalyticsRepository struct {
+	db *sqlx.DB
+}
+
+// NewAnalyticsRepository creates a new analytics repository
+func NewAnalyticsRepository(db *sqlx.DB) *AnalyticsRepository {
+	return &AnalyticsRepository{
+		db: db,
+	}
+}
+
+// TrendBucket holds the number of response sessions started within a period
+type TrendBucket struct {
+	Bucket time.Time `db:"bucket"`
+	Count  int       `db:"count"`
+}
+
+// SessionStats holds statistics about the response sessions within a time range
+type SessionStats struct {
+	Total                 int             `db:"total"`
+	Completed             int             `db:"completed"`
+	AverageCompletionTime sql.NullFloat64 `db:"average_completion_time"`
+}
+
+// GetSessionStats computes statistics about the response sessions of a survey within a time range
+func (r *AnalyticsRepository) GetSessionStats(ctx context.Context, surveyID string, start, end *time.Time) (*SessionStats, error) {
+	query := `
+		SELECT
+			COUNT(*) AS total,
+			COUNT(s.completed_at) AS completed,
+			AVG(EXTRACT(EPOCH FROM (s.completed_at - s.started_at))) FILTER (WHERE s.completed_at IS NOT NULL) AS average_completion_time
+		FROM response_sessions s
+		WHERE ` + windowCondition
+
+	var stats SessionStats
+	err := r.db.GetContext(ctx, &stats, query, surveyID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session statistics: %w", err)
+	}
+
+	return &stats, nil
+}
+
+// GetResponseTrend counts the response sessions of a survey per period. The period must be
+// a date_trunc field such as day, week, month or year. Periods without responses are omitted.
+func (r *AnalyticsRepository) GetResponseTrend(ctx context.Context, surveyID string, period models.TimePeriod, start, end *time.Time) ([]TrendBucket, error) {
+	query := `
+		SELECT date_trunc($4, s.started_at AT TIME ZONE 'UTC') AS bucket, COUNT(*) AS count
+		FROM response_sessions s
+		WHERE ` + windowCondition + `
+		GROUP BY bucket
+		ORDER BY bucket
+	`
+
+	var buckets []TrendBucket
+	err := r.db.SelectContext(ctx, &buckets, query, surveyID, start, end, string(period))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response trend: %w", err)
+	}
+
+	return buckets, nil
+}
+
+// GetCompletedAnswerCounts counts, per question, the completed response sessions that answered it
+func (r *AnalyticsRepository) GetCompletedAnswerCounts(ctx context.Context, surveyID string, start, end *time.Time) (map[string]int, error) {
+	query := `
+		SELECT a.question_id, COUNT(DISTINCT a.response_id) AS count
+		FROM responses a
+		JOIN response_sessions s ON s.id = a.response_id
+		WHERE s.completed_at IS NOT NULL AND ` + windowCondition + `
+		GROUP BY a.question_id
+	`
+
+	var rows []struct {
+		QuestionID string `db:"question_id"`
+		Count      int    `db:"count"`
+	}
+	err := r.db.SelectContext(ctx, &rows, query, surveyID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count answered questions: %w", err)
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.QuestionID] = row.Count
+	}
+
+	return counts, nil
+}
+
+// GetAnswerCounts counts, per question, the response sessions within a time range that answered it
+func (r *AnalyticsRepository) GetAnswerCounts(ctx context.Context, surveyID string, start, end *time.Time) (map[string]int, error) {
+	query := `
+		SELECT a.question_id, COUNT(DISTINCT a.response_id) AS count
+		FROM responses a
+		JOIN response_sessions s ON s.id = a.response_id
+		WHERE ` + windowCondition + `
+		GROUP BY a.question_id
+	`
+
+	var rows []struct {
+		QuestionID string `db:"question_id"`
+		Count      int    `db:"count"`
+	}
+	err := r.db.SelectContext(ctx, &rows, query, surveyID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count answers: %w", err)
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.QuestionID] = row.Count
+	}
+
+	return counts, nil
+}
+
+// GetTopOptions returns, per question, the most often selected options within a time range
+func (r *AnalyticsRepository) GetTopOptions(ctx context.Context, surveyID string, start, end *time.Time, limit int) ([]models.OptionCount, error) {
+	query := `
+		SELECT question_id, option_id, count
+		FROM (
+			SELECT a.question_id, a.option_id, COUNT(*) AS count,
+				ROW_NUMBER() OVER (PARTITION BY a.question_id ORDER BY COUNT(*) DESC, a.option_id) AS rank
+			FROM responses a
+			JOIN response_sessions s ON s.id = a.response_id
+			WHERE a.option_id IS NOT NULL AND ` + windowCondition + `
+			GROUP BY a.question_id, a.option_id
+		) ranked
+		WHERE rank <= $4
+		ORDER BY question_id, rank
+	`
+
+	var counts []models.OptionCount
+	err := r.db.SelectContext(ctx, &counts, query, surveyID, start, end, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top options: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetTopValues returns, per question, the most common text values within a time range.
+// Values are compared case-insensitively.
+func (r *AnalyticsRepository) GetTopValues(ctx context.Context, surveyID string, start, end *time.Time, limit int) ([]models.ValueCount, error) {
+	query := `
+		SELECT question_id, value, count
+		FROM (
+			SELECT a.question_id, LOWER(a.text_answer) AS value, COUNT(*) AS count,
+				ROW_NUMBER() OVER (PARTITION BY a.question_id ORDER BY COUNT(*) DESC, LOWER(a.text_answer)) AS rank
+			FROM responses a
+			JOIN response_sessions s ON s.id = a.response_id
+			WHERE a.text_answer IS NOT NULL AND ` + windowCondition + `
+			GROUP BY a.question_id, LOWER(a.text_answer)
+		) ranked
+		WHERE rank <= $4
+		ORDER BY question_id, rank
+	`
+
+	var counts []models.ValueCount
+	err := r.db.SelectContext(ctx, &counts, query, surveyID, start, end, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top values: %w", err)
+	}
+
+	return counts, nil
+}
+
+// AnswerPair holds how often two answers were given together within one response session
+type AnswerPair struct {
+	ValueA string `db:"value_a"`
+	ValueB string `db:"value_b"`
+	Count  int    `db:"count"`
+}
+
+// GetAnswerPairs counts the combinations of answers to two questions given within the same
+// response session. The value of an answer is its option ID or, lacking one, its text.
+func (r *AnalyticsRepository) GetAnswerPairs(ctx context.Context, surveyID, questionAID, questionBID string, start, end *time.Time) ([]AnswerPair, error) {
+	query := `
+		SELECT
+			COALESCE(a.option_id::text, a.text_answer) AS value_a,
+			COALESCE(b.option_id::text, b.text_answer) AS value_b,
+			COUNT(*) AS count
+		FROM responses a
+		JOIN responses b ON b.response_id = a.response_id
+		JOIN response_sessions s ON s.id = a.response_id
+		WHERE a.question_id = $4 AND b.question_id = $5 AND ` + windowCondition + `
+		GROUP BY value_a, value_b
+	`
+
+	var pairs []AnswerPair
+	err := r.db.SelectContext(ctx, &pairs, query, surveyID, start, end, questionAID, questionBID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get answer pairs: %w", err)
+	}
+
+	return pairs, nil
+}
+
+// CountJointResponses counts the response sessions that answered both questions
+func (r *AnalyticsRepository) CountJointResponses(ctx context.Context, surveyID, questionAID, questionBID string, start, end *time.Time) (int, error) {
+	query := `
+		SELECT COUNT(DISTINCT a.response_id)
+		FROM responses a
+		JOIN responses b ON b.response_id = a.response_id
+		JOIN response_sessions s ON s.id = a.response_id
+		WHERE a.question_id = $4 AND b.question_id = $5 AND ` + windowCondition
+
+	var count int
+	err := r.db.GetContext(ctx, &count, query, surveyID, start, end, questionAID, questionBID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count joint responses: %w", err)
+	}
+
+	return count, nil
+}
+
+// AnswerValue is the value of an answer given within a response session. The value of an
+// answer is its option ID or, lacking one, its text.
+type AnswerValue struct {
+	ResponseID string `db:"response_id"`
+	QuestionID string `db:"question_id"`
+	Value      string `db:"value"`
+}
+
+// GetAnswerValues loads the answers to the given questions of a survey within a time range, so
+// that the answer pairs of many questions can be counted without a query per pair
+func (r *AnalyticsRepository) GetAnswerValues(ctx context.Context, surveyID string, questionIDs []string, start, end *time.Time) ([]AnswerValue, error) {
+	query := `
+		SELECT
+			a.response_id,
+			a.question_id,
+			COALESCE(a.option_id::text, a.text_answer) AS value
+		FROM responses a
+		JOIN response_sessions s ON s.id = a.response_id
+		WHERE a.question_id = ANY($4::uuid[]) AND ` + windowCondition + `
+			AND COALESCE(a.option_id::text, a.text_answer) IS NOT NULL
+	`
+
+	var values []AnswerValue
+	err := r.db.SelectContext(ctx, &values, query, surveyID, start, end, pq.Array(questionIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get answer values: %w", err)
+	}
+
+	return values, nil
+}
+
+// GetCachedAnalytics retrieves previously computed analytics for a survey and time range
+func (r *AnalyticsRepository) GetCachedAnalytics(ctx context.Context, surveyID string, period models.TimePeriod, start, end *time.Time) (*models.Analytics, error) {
+	query := `
+		SELECT analytics_data
+		FROM response_analytics
+		WHERE survey_id = $1 AND period = $2
+			AND start_date IS NOT DISTINCT FROM $3
+			AND end_date IS NOT DISTINCT FROM $4
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`
+
+	var data []byte
+	err := r.db.GetContext(ctx, &data, query, surveyID, string(period), start, end)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get cached analytics: %w", err)
+	}
+
+	var analytics models.Analytics
+	if err := json.Unmarshal(data, &analytics); err != nil {
+		return nil, fmt.Errorf("failed to decode cached analytics: %w", err)
+	}
+
+	return &analytics, nil
+}
+
+// SaveAnalytics caches computed analytics for a survey and time range. The analytics are
+// not cached when a response session of the survey changed after computedAt, so a result
+// that raced with a new response never outlives the invalidation.
+func (r *AnalyticsRepository) SaveAnalytics(ctx context.Context, analytics *models.Analytics, period models.TimePeriod, start, end *time.Time, computedAt time.Time) error {
+	data, err := json.Marshal(analytics)
+	if err != nil {
+		return fmt.Errorf("failed to encode analytics: %w", err)
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`DELETE FROM response_analytics
+		WHERE survey_id = $1 AND period = $2
+			AND start_date IS NOT DISTINCT FROM $3
+			AND end_date IS NOT DISTINCT FROM $4`,
+		analytics.SurveyID,
+		string(period),
+		start,
+		end,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete cached analytics: %w", err)
+	}
+
+	now := time.Now().UTC()
+	query := `
+		INSERT INTO response_analytics (id, survey_id, analytics_data, period, start_date, end_date, created_at, updated_at)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $7
+		WHERE NOT EXISTS (
+			SELECT 1 FROM response_sessions WHERE survey_id = $2 AND updated_at > $8
+		)
+	`
+
+	_, err = tx.ExecContext(ctx, query, uuid.New().String(), analytics.SurveyID, data, string(period), start, end, now, computedAt)
+	if err != nil {
+		return fmt.Errorf("failed to cache analytics: %w", err)
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// InvalidateAnalytics removes all cached analytics of a survey
+func (r *AnalyticsRepository) InvalidateAnalytics(ctx context.Context, surveyID string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM response_analytics WHERE survey_id = $1", surveyID)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate analytics: %w", err)
+	}
+
+	return nil
+}
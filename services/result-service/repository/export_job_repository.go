@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// exportJobColumns are the columns selected for an export job
+const exportJobColumns = `
+	id, survey_id, format, include_raw, include_summary, status, progress, processed_responses,
+	total_responses, export_id, error, requested_by, created_at, updated_at, started_at, finished_at
+`
+
+// ExportJobRepository handles database operations for export jobs
+type ExportJobRepository struct {
+	db *sqlx.DB
+}
+
+// NewExportJobRepository creates a new export job repository
+func NewExportJobRepository(db *sqlx.DB) *ExportJobRepository {
+	return &ExportJobRepository{
+		db: db,
+	}
+}
+
+// CreateJob creates a pending export job
+func (r *ExportJobRepository) CreateJob(ctx context.Context, job *models.ExportJob) error {
+	now := time.Now().UTC()
+	job.ID = uuid.New().String()
+	job.Status = models.ExportJobStatusPending
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	query := `
+		INSERT INTO export_jobs (id, survey_id, format, include_raw, include_summary, status, requested_by, created_at, updated_at)
+		VALUES (:id, :survey_id, :format, :include_raw, :include_summary, :status, :requested_by, :created_at, :updated_at)
+	`
+
+	_, err := r.db.NamedExecContext(ctx, query, job)
+	if err != nil {
+		return fmt.Errorf("failed to create export job: %w", err)
+	}
+
+	return nil
+}
+
+// GetJob retrieves an export job by ID
+func (r *ExportJobRepository) GetJob(ctx context.Context, id string) (*models.ExportJob, error) {
+	query := `SELECT ` + exportJobColumns + ` FROM export_jobs WHERE id = $1`
+
+	var job models.ExportJob
+	err := r.db.GetContext(ctx, &job, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get export job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// ClaimJob marks the oldest pending export job as running and returns it. Concurrent callers
+// never claim the same job. It returns ErrNotFound when no job is pending.
+func (r *ExportJobRepository) ClaimJob(ctx context.Context) (*models.ExportJob, error) {
+	query := `
+		UPDATE export_jobs
+		SET status = $1, started_at = $2, updated_at = $2
+		WHERE id = (
+			SELECT id FROM export_jobs
+			WHERE status = $3
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + exportJobColumns
+
+	var job models.ExportJob
+	err := r.db.GetContext(ctx, &job, query, models.ExportJobStatusRunning, time.Now().UTC(), models.ExportJobStatusPending)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to claim export job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// UpdateProgress records how many responses a running export job has processed. It returns
+// ErrNotFound when the job is no longer running, for example because it was canceled.
+func (r *ExportJobRepository) UpdateProgress(ctx context.Context, id string, processed, total int) error {
+	query := `
+		UPDATE export_jobs
+		SET processed_responses = $1,
+			total_responses = $2,
+			progress = CASE WHEN $2 > 0 THEN LEAST(100, $1 * 100 / $2) ELSE 0 END,
+			updated_at = $3
+		WHERE id = $4 AND status = $5
+	`
+
+	return r.updateRunning(ctx, "update export job progress", query, processed, total, time.Now().UTC(), id, models.ExportJobStatusRunning)
+}
+
+// Heartbeat marks a running export job as alive. It returns ErrNotFound when the job is no
+// longer running.
+func (r *ExportJobRepository) Heartbeat(ctx context.Context, id string) error {
+	query := `UPDATE export_jobs SET updated_at = $1 WHERE id = $2 AND status = $3`
+
+	return r.updateRunning(ctx, "update export job heartbeat", query, time.Now().UTC(), id, models.ExportJobStatusRunning)
+}
+
+// CompleteJob marks a running export job as completed with the exported file. It returns
+// ErrNotFound when the job is no longer running.
+func (r *ExportJobRepository) CompleteJob(ctx context.Context, id, exportID string) error {
+	query := `
+		UPDATE export_jobs
+		SET status = $1, progress = 100, export_id = $2, finished_at = $3, updated_at = $3
+		WHERE id = $4 AND status = $5
+	`
+
+	return r.updateRunning(ctx, "complete export job", query, models.ExportJobStatusCompleted, exportID, time.Now().UTC(), id, models.ExportJobStatusRunning)
+}
+
+// FailJob marks a running export job as failed. It returns ErrNotFound when the job is no
+// longer running.
+func (r *ExportJobRepository) FailJob(ctx context.Context, id, message string) error {
+	query := `
+		UPDATE export_jobs
+		SET status = $1, error = $2, finished_at = $3, updated_at = $3
+		WHERE id = $4 AND status = $5
+	`
+
+	return r.updateRunning(ctx, "fail export job", query, models.ExportJobStatusFailed, message, time.Now().UTC(), id, models.ExportJobStatusRunning)
+}
+
+// RequeueJob puts a running export job back into the queue, for example because the service
+// is shutting down. It returns ErrNotFound when the job is no longer running.
+func (r *ExportJobRepository) RequeueJob(ctx context.Context, id string) error {
+	query := `
+		UPDATE export_jobs
+		SET status = $1, progress = 0, processed_responses = 0, started_at = NULL, updated_at = $2
+		WHERE id = $3 AND status = $4
+	`
+
+	return r.updateRunning(ctx, "requeue export job", query, models.ExportJobStatusPending, time.Now().UTC(), id, models.ExportJobStatusRunning)
+}
+
+// RequeueStaleJobs puts running export jobs that were not updated since the given time back
+// into the queue. Such jobs were left behind by a worker that stopped unexpectedly.
+func (r *ExportJobRepository) RequeueStaleJobs(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		UPDATE export_jobs
+		SET status = $1, progress = 0, processed_responses = 0, started_at = NULL, updated_at = $2
+		WHERE status = $3 AND updated_at < $4
+	`
+
+	result, err := r.db.ExecContext(ctx, query, models.ExportJobStatusPending, time.Now().UTC(), models.ExportJobStatusRunning, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue stale export jobs: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue stale export jobs: %w", err)
+	}
+
+	return rows, nil
+}
+
+// CancelJob cancels a pending or running export job and returns it. It returns ErrNotFound
+// when no such job exists or it already finished.
+func (r *ExportJobRepository) CancelJob(ctx context.Context, id string) (*models.ExportJob, error) {
+	query := `
+		UPDATE export_jobs
+		SET status = $1, finished_at = $2, updated_at = $2
+		WHERE id = $3 AND status IN ($4, $5)
+		RETURNING ` + exportJobColumns
+
+	var job models.ExportJob
+	err := r.db.GetContext(ctx, &job, query, models.ExportJobStatusCanceled, time.Now().UTC(), id,
+		models.ExportJobStatusPending, models.ExportJobStatusRunning)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to cancel export job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// updateRunning executes an update of a single running job and returns ErrNotFound when
+// no row was updated
+func (r *ExportJobRepository) updateRunning(ctx context.Context, action, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ExportRepository handles database operations for result exports
+type ExportRepository struct {
+	db *sqlx.DB
+}
+
+// NewExportRepository creates a new export repository
+func NewExportRepository(db *sqlx.DB) *ExportRepository {
+	return &ExportRepository{
+		db: db,
+	}
+}
+
+// exportRow is a response session joined with one of its answers
+type exportRow struct {
+	models.Response
+	QuestionID sql.NullString `db:"question_id"`
+	OptionID   sql.NullString `db:"option_id"`
+	TextAnswer sql.NullString `db:"text_answer"`
+}
+
+// StreamResponses calls fn for every response session of a survey, in the order the sessions
+// were started, with its answers loaded. Sessions are read from a single cursor so that only
+// one session is held in memory at a time.
+func (r *ExportRepository) StreamResponses(ctx context.Context, surveyID string, fn func(*models.Response) error) error {
+	query := `
+		SELECT s.id, s.survey_id, s.respondent_id, s.started_at, s.completed_at,
+			COALESCE(s.ip_address, '') AS ip_address, COALESCE(s.user_agent, '') AS user_agent,
+			s.created_at, s.updated_at, a.question_id, a.option_id, a.text_answer
+		FROM response_sessions s
+		LEFT JOIN responses a ON a.response_id = s.id
+		WHERE s.survey_id = $1
+		ORDER BY s.started_at, s.id, a.created_at, a.id
+	`
+
+	rows, err := r.db.QueryxContext(ctx, query, surveyID)
+	if err != nil {
+		return fmt.Errorf("failed to query responses: %w", err)
+	}
+	defer rows.Close()
+
+	var current *models.Response
+	for rows.Next() {
+		var row exportRow
+		if err := rows.StructScan(&row); err != nil {
+			return fmt.Errorf("failed to scan response: %w", err)
+		}
+
+		if current == nil || current.ID != row.ID {
+			if current != nil {
+				if err := fn(current); err != nil {
+					return err
+				}
+			}
+			response := row.Response
+			current = &response
+		}
+
+		if row.QuestionID.Valid {
+			answer := models.Answer{
+				ResponseID: row.ID,
+				SurveyID:   row.SurveyID,
+				QuestionID: row.QuestionID.String,
+			}
+			if row.OptionID.Valid {
+				answer.OptionID = &row.OptionID.String
+			}
+			if row.TextAnswer.Valid {
+				answer.TextAnswer = &row.TextAnswer.String
+			}
+			current.Answers = append(current.Answers, answer)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read responses: %w", err)
+	}
+
+	if current != nil {
+		return fn(current)
+	}
+
+	return nil
+}
+
+// CountResponses counts the response sessions of a survey
+func (r *ExportRepository) CountResponses(ctx context.Context, surveyID string) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM response_sessions WHERE survey_id = $1", surveyID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count responses: %w", err)
+	}
+
+	return count, nil
+}
+
+// CreateExport records an exported file
+func (r *ExportRepository) CreateExport(ctx context.Context, export *models.ExportedResult) error {
+	query := `
+		INSERT INTO exported_results (id, survey_id, file_path, format, size, created_at, expires_at)
+		VALUES (:id, :survey_id, :file_path, :format, :size, :created_at, :expires_at)
+	`
+
+	_, err := r.db.NamedExecContext(ctx, query, export)
+	if err != nil {
+		return fmt.Errorf("failed to create export: %w", err)
+	}
+
+	return nil
+}
+
+// GetExport retrieves an exported file by ID
+func (r *ExportRepository) GetExport(ctx context.Context, id string) (*models.ExportedResult, error) {
+	query := `
+		SELECT id, survey_id, file_path, format, size, created_at, expires_at
+		FROM exported_results
+		WHERE id = $1
+	`
+
+	var export models.ExportedResult
+	err := r.db.GetContext(ctx, &export, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get export: %w", err)
+	}
+
+	return &export, nil
+}
+
+// ListExpiredExports lists the exported files that expired before the given time
+func (r *ExportRepository) ListExpiredExports(ctx context.Context, before time.Time) ([]models.ExportedResult, error) {
+	query := `
+		SELECT id, survey_id, file_path, format, size, created_at, expires_at
+		FROM exported_results
+		WHERE expires_at <= $1
+		ORDER BY expires_at
+	`
+
+	var exports []models.ExportedResult
+	err := r.db.SelectContext(ctx, &exports, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired exports: %w", err)
+	}
+
+	return exports, nil
+}
+
+// DeleteExport deletes the record of an exported file
+func (r *ExportRepository) DeleteExport(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM exported_results WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete export: %w", err)
+	}
+
+	return nil
+}
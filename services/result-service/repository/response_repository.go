@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+)
+
+// ResponseRepository handles database operations for survey responses
+type ResponseRepository struct {
+	db *sqlx.DB
+}
+
+// NewResponseRepository creates a new response repository
+func NewResponseRepository(db *sqlx.DB) *ResponseRepository {
+	return &ResponseRepository{
+		db: db,
+	}
+}
+
+// CreateResponse creates a response session together with its answers
+func (r *ResponseRepository) CreateResponse(ctx context.Context, response *models.Response) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if response.ID == "" {
+		response.ID = uuid.New().String()
+	}
+
+	now := time.Now().UTC()
+	if response.StartedAt.IsZero() {
+		response.StartedAt = now
+	}
+	response.CreatedAt = now
+	response.UpdatedAt = now
+
+	query := `
+		INSERT INTO response_sessions (id, survey_id, respondent_id, started_at, completed_at, ip_address, user_agent, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err = tx.ExecContext(
+		ctx,
+		query,
+		response.ID,
+		response.SurveyID,
+		response.RespondentID,
+		response.StartedAt,
+		response.CompletedAt,
+		response.IPAddress,
+		response.UserAgent,
+		response.CreatedAt,
+		response.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create response session: %w", err)
+	}
+
+	for i := range response.Answers {
+		response.Answers[i].ResponseID = response.ID
+		response.Answers[i].SurveyID = response.SurveyID
+		if err = insertAnswer(ctx, tx, &response.Answers[i], now); err != nil {
+			return err
+		}
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetResponse retrieves a response session by ID together with its answers
+func (r *ResponseRepository) GetResponse(ctx context.Context, id string) (*models.Response, error) {
+	query := `
+		SELECT id, survey_id, respondent_id, started_at, completed_at,
+			COALESCE(ip_address, '') AS ip_address, COALESCE(user_agent, '') AS user_agent, created_at, updated_at
+		FROM response_sessions
+		WHERE id = $1
+	`
+
+	var response models.Response
+	err := r.db.GetContext(ctx, &response, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get response: %w", err)
+	}
+
+	answers, err := r.getAnswers(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	response.Answers = answers
+
+	return &response, nil
+}
+
+// ReplaceAnswers replaces the answers of a response session for every question
+// that is present in the given answers
+func (r *ResponseRepository) ReplaceAnswers(ctx context.Context, response *models.Response, answers []models.Answer) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	questionIDs := make([]string, 0, len(answers))
+	seen := make(map[string]bool, len(answers))
+	for _, answer := range answers {
+		if !seen[answer.QuestionID] {
+			seen[answer.QuestionID] = true
+			questionIDs = append(questionIDs, answer.QuestionID)
+		}
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"DELETE FROM responses WHERE response_id = $1 AND question_id = ANY($2::uuid[])",
+		response.ID,
+		pq.Array(questionIDs),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete existing answers: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for i := range answers {
+		answers[i].ResponseID = response.ID
+		answers[i].SurveyID = response.SurveyID
+		if err = insertAnswer(ctx, tx, &answers[i], now); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE response_sessions SET updated_at = $1 WHERE id = $2", now, response.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update response session: %w", err)
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// CompleteResponse marks a response session as completed
+func (r *ResponseRepository) CompleteResponse(ctx context.Context, id string, completedAt time.Time) error {
+	query := `
+		UPDATE response_sessions
+		SET completed_at = $1, updated_at = $1
+		WHERE id = $2 AND completed_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, completedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to complete response: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to complete response: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ListResponses lists the response sessions of a survey with pagination
+func (r *ResponseRepository) ListResponses(ctx context.Context, surveyID string, limit, offset int) ([]*models.Response, error) {
+	query := `
+		SELECT id, survey_id, respondent_id, started_at, completed_at,
+			COALESCE(ip_address, '') AS ip_address, COALESCE(user_agent, '') AS user_agent, created_at, updated_at
+		FROM response_sessions
+		WHERE survey_id = $1
+		ORDER BY started_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	var responses []*models.Response
+	err := r.db.SelectContext(ctx, &responses, query, surveyID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list responses: %w", err)
+	}
+
+	if len(responses) == 0 {
+		return responses, nil
+	}
+
+	ids := make([]string, len(responses))
+	byID := make(map[string]*models.Response, len(responses))
+	for i, response := range responses {
+		ids[i] = response.ID
+		byID[response.ID] = response
+	}
+
+	answers, err := r.getAnswers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, answer := range answers {
+		response := byID[answer.ResponseID]
+		response.Answers = append(response.Answers, answer)
+	}
+
+	return responses, nil
+}
+
+// CountResponses counts the response sessions of a survey
+func (r *ResponseRepository) CountResponses(ctx context.Context, surveyID string) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM response_sessions WHERE survey_id = $1", surveyID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count responses: %w", err)
+	}
+
+	return count, nil
+}
+
+// getAnswers retrieves the answers of the given response sessions
+func (r *ResponseRepository) getAnswers(ctx context.Context, responseIDs []string) ([]models.Answer, error) {
+	query := `
+		SELECT id, response_id, survey_id, question_id, option_id, text_answer, created_at, updated_at
+		FROM responses
+		WHERE response_id = ANY($1::uuid[])
+		ORDER BY created_at, id
+	`
+
+	var answers []models.Answer
+	err := r.db.SelectContext(ctx, &answers, query, pq.Array(responseIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get answers: %w", err)
+	}
+
+	return answers, nil
+}
+
+// insertAnswer inserts a single answer within a transaction
+func insertAnswer(ctx context.Context, tx *sqlx.Tx, answer *models.Answer, now time.Time) error {
+	if answer.ID == "" {
+		answer.ID = uuid.New().String()
+	}
+	answer.CreatedAt = now
+	answer.UpdatedAt = now
+
+	query := `
+		INSERT INTO responses (id, response_id, survey_id, question_id, option_id, text_answer, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := tx.ExecContext(
+		ctx,
+		query,
+		answer.ID,
+		answer.ResponseID,
+		answer.SurveyID,
+		answer.QuestionID,
+		answer.OptionID,
+		answer.TextAnswer,
+		answer.CreatedAt,
+		answer.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create answer: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ResultRepository handles aggregate queries over survey responses
+type ResultRepository struct {
+	db *sqlx.DB
+}
+
+// NewResultRepository creates a new result repository
+func NewResultRepository(db *sqlx.DB) *ResultRepository {
+	return &ResultRepository{
+		db: db,
+	}
+}
+
+// SessionCounts holds the number of started and completed response sessions of a survey
+type SessionCounts struct {
+	Total     int `db:"total"`
+	Completed int `db:"completed"`
+}
+
+// GetSessionCounts counts the started and completed response sessions of a survey
+func (r *ResultRepository) GetSessionCounts(ctx context.Context, surveyID string) (*SessionCounts, error) {
+	query := `
+		SELECT COUNT(*) AS total, COUNT(completed_at) AS completed
+		FROM response_sessions
+		WHERE survey_id = $1
+	`
+
+	var counts SessionCounts
+	err := r.db.GetContext(ctx, &counts, query, surveyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count response sessions: %w", err)
+	}
+
+	return &counts, nil
+}
+
+// GetQuestionResponseCounts counts, per question, the response sessions that answered it
+func (r *ResultRepository) GetQuestionResponseCounts(ctx context.Context, surveyID string) (map[string]int, error) {
+	query := `
+		SELECT question_id, COUNT(DISTINCT response_id) AS count
+		FROM responses
+		WHERE survey_id = $1
+		GROUP BY question_id
+	`
+
+	var rows []struct {
+		QuestionID string `db:"question_id"`
+		Count      int    `db:"count"`
+	}
+	err := r.db.SelectContext(ctx, &rows, query, surveyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count question responses: %w", err)
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.QuestionID] = row.Count
+	}
+
+	return counts, nil
+}
+
+// GetOptionCounts counts how often each option of the survey was selected
+func (r *ResultRepository) GetOptionCounts(ctx context.Context, surveyID string) ([]models.OptionCount, error) {
+	query := `
+		SELECT question_id, option_id, COUNT(*) AS count
+		FROM responses
+		WHERE survey_id = $1 AND option_id IS NOT NULL
+		GROUP BY question_id, option_id
+	`
+
+	var counts []models.OptionCount
+	err := r.db.SelectContext(ctx, &counts, query, surveyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count options: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetValueCounts counts how often each distinct text value was given to the given questions
+func (r *ResultRepository) GetValueCounts(ctx context.Context, surveyID string, questionIDs []string) ([]models.ValueCount, error) {
+	if len(questionIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT question_id, text_answer AS value, COUNT(*) AS count
+		FROM responses
+		WHERE survey_id = $1 AND question_id = ANY($2::uuid[]) AND text_answer IS NOT NULL
+		GROUP BY question_id, text_answer
+	`
+
+	var counts []models.ValueCount
+	err := r.db.SelectContext(ctx, &counts, query, surveyID, pq.Array(questionIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count answer values: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetTextAnswers retrieves a page of the text answers given to a question, newest first
+func (r *ResultRepository) GetTextAnswers(ctx context.Context, surveyID, questionID string, limit, offset int) ([]string, error) {
+	query := `
+		SELECT text_answer
+		FROM responses
+		WHERE survey_id = $1 AND question_id = $2 AND text_answer IS NOT NULL
+		ORDER BY created_at DESC, id
+		LIMIT $3 OFFSET $4
+	`
+
+	var answers []string
+	err := r.db.SelectContext(ctx, &answers, query, surveyID, questionID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get text answers: %w", err)
+	}
+
+	return answers, nil
+}
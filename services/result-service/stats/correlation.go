@@ -0,0 +1,359 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// maxIterations bounds the continued fraction and series expansions
+	maxIterations = 300
+	// epsilon is the relative accuracy of the expansions
+	epsilon = 3e-14
+	// tiny guards the continued fractions against division by zero
+	tiny = 1e-300
+)
+
+// WeightedPair is an observation of two variables that occurred Weight times
+type WeightedPair struct {
+	X      float64
+	Y      float64
+	Weight int
+}
+
+// ChiSquareTest is the result of Pearson's chi-square test of independence
+type ChiSquareTest struct {
+	Statistic        float64
+	DegreesOfFreedom int
+	PValue           float64
+	// Total, Rows and Columns describe the table after empty rows and columns were dropped
+	Total   int
+	Rows    int
+	Columns int
+}
+
+// CramersV returns Cramér's V, the strength of association measured by the test
+func (t ChiSquareTest) CramersV() float64 {
+	k := math.Min(float64(t.Rows-1), float64(t.Columns-1))
+	if k <= 0 || t.Total == 0 {
+		return 0
+	}
+	return math.Sqrt(t.Statistic / (float64(t.Total) * k))
+}
+
+// ChiSquare runs a chi-square test of independence on a contingency table. Rows and
+// columns without observations are ignored. It returns false when fewer than two
+// rows or columns remain.
+func ChiSquare(table [][]int) (ChiSquareTest, bool) {
+	var test ChiSquareTest
+
+	var rows []int
+	colTotals := make(map[int]int)
+	rowTotals := make(map[int]int)
+	for i, row := range table {
+		for j, count := range row {
+			rowTotals[i] += count
+			colTotals[j] += count
+			test.Total += count
+		}
+		if rowTotals[i] > 0 {
+			rows = append(rows, i)
+		}
+	}
+
+	var cols []int
+	for j, total := range colTotals {
+		if total > 0 {
+			cols = append(cols, j)
+		}
+	}
+
+	test.Rows = len(rows)
+	test.Columns = len(cols)
+	if test.Rows < 2 || test.Columns < 2 {
+		return test, false
+	}
+
+	for _, i := range rows {
+		for _, j := range cols {
+			expected := float64(rowTotals[i]) * float64(colTotals[j]) / float64(test.Total)
+			diff := float64(table[i][j]) - expected
+			test.Statistic += diff * diff / expected
+		}
+	}
+
+	test.DegreesOfFreedom = (test.Rows - 1) * (test.Columns - 1)
+	test.PValue = ChiSquarePValue(test.Statistic, test.DegreesOfFreedom)
+
+	return test, true
+}
+
+// ChiSquarePValue returns the probability of a chi-square statistic at least as large as
+// the given one under the null hypothesis
+func ChiSquarePValue(statistic float64, degreesOfFreedom int) float64 {
+	if degreesOfFreedom <= 0 {
+		return 1
+	}
+	return regularizedGammaQ(float64(degreesOfFreedom)/2, statistic/2)
+}
+
+// Pearson computes Pearson's correlation coefficient of weighted pairs. It returns false
+// when either variable is constant.
+func Pearson(pairs []WeightedPair) (float64, bool) {
+	var n, sumX, sumY float64
+	for _, pair := range pairs {
+		w := float64(pair.Weight)
+		n += w
+		sumX += w * pair.X
+		sumY += w * pair.Y
+	}
+	if n < 2 {
+		return 0, false
+	}
+
+	meanX, meanY := sumX/n, sumY/n
+	var sxy, sxx, syy float64
+	for _, pair := range pairs {
+		w := float64(pair.Weight)
+		dx, dy := pair.X-meanX, pair.Y-meanY
+		sxy += w * dx * dy
+		sxx += w * dx * dx
+		syy += w * dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, false
+	}
+
+	return math.Max(-1, math.Min(1, sxy/math.Sqrt(sxx*syy))), true
+}
+
+// Spearman computes Spearman's rank correlation coefficient of weighted pairs, assigning
+// tied values their average rank. It returns false when either variable is constant.
+func Spearman(pairs []WeightedPair) (float64, bool) {
+	ranksX := averageRanks(pairs, func(p WeightedPair) float64 { return p.X })
+	ranksY := averageRanks(pairs, func(p WeightedPair) float64 { return p.Y })
+
+	ranked := make([]WeightedPair, len(pairs))
+	for i, pair := range pairs {
+		ranked[i] = WeightedPair{X: ranksX[pair.X], Y: ranksY[pair.Y], Weight: pair.Weight}
+	}
+
+	return Pearson(ranked)
+}
+
+// CorrelationPValue returns the two-sided p-value of a correlation coefficient computed
+// from n observations, using Student's t distribution with n-2 degrees of freedom
+func CorrelationPValue(r float64, n int) float64 {
+	df := float64(n - 2)
+	if df <= 0 {
+		return 1
+	}
+	if math.Abs(r) >= 1 {
+		return 0
+	}
+	t := r * math.Sqrt(df/(1-r*r))
+	return regularizedBeta(df/(df+t*t), df/2, 0.5)
+}
+
+// CorrelationRatio computes the correlation ratio (eta) between a categorical variable X
+// and a numeric variable Y, together with the p-value of the one-way ANOVA F-test. It
+// returns false when there are fewer than two groups or Y is constant.
+func CorrelationRatio(pairs []WeightedPair) (float64, float64, bool) {
+	type group struct {
+		n   float64
+		sum float64
+	}
+	groups := make(map[float64]*group)
+	var n, sum float64
+	for _, pair := range pairs {
+		w := float64(pair.Weight)
+		g, ok := groups[pair.X]
+		if !ok {
+			g = &group{}
+			groups[pair.X] = g
+		}
+		g.n += w
+		g.sum += w * pair.Y
+		n += w
+		sum += w * pair.Y
+	}
+	if len(groups) < 2 || n <= float64(len(groups)) {
+		return 0, 1, false
+	}
+
+	mean := sum / n
+	var between, total float64
+	for _, g := range groups {
+		diff := g.sum/g.n - mean
+		between += g.n * diff * diff
+	}
+	for _, pair := range pairs {
+		diff := pair.Y - mean
+		total += float64(pair.Weight) * diff * diff
+	}
+	if total == 0 {
+		return 0, 1, false
+	}
+
+	eta := math.Sqrt(between / total)
+
+	within := total - between
+	d1 := float64(len(groups) - 1)
+	d2 := n - float64(len(groups))
+	if within <= 0 {
+		return eta, 0, true
+	}
+	f := (between / d1) / (within / d2)
+	pValue := regularizedBeta(d2/(d2+d1*f), d2/2, d1/2)
+
+	return eta, pValue, true
+}
+
+// averageRanks assigns every distinct value its average rank among the weighted observations
+func averageRanks(pairs []WeightedPair, value func(WeightedPair) float64) map[float64]float64 {
+	weights := make(map[float64]int)
+	for _, pair := range pairs {
+		weights[value(pair)] += pair.Weight
+	}
+
+	values := make([]float64, 0, len(weights))
+	for v := range weights {
+		values = append(values, v)
+	}
+	sort.Float64s(values)
+
+	ranks := make(map[float64]float64, len(values))
+	seen := 0
+	for _, v := range values {
+		count := weights[v]
+		ranks[v] = float64(seen) + float64(count+1)/2
+		seen += count
+	}
+
+	return ranks
+}
+
+// regularizedGammaQ computes the regularized upper incomplete gamma function Q(a, x)
+func regularizedGammaQ(a, x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+	if x < a+1 {
+		return 1 - gammaSeries(a, x)
+	}
+	return gammaContinuedFraction(a, x)
+}
+
+// gammaSeries computes the regularized lower incomplete gamma function P(a, x) by its series
+func gammaSeries(a, x float64) float64 {
+	lgamma, _ := math.Lgamma(a)
+	ap := a
+	sum := 1 / a
+	del := sum
+	for n := 0; n < maxIterations; n++ {
+		ap++
+		del *= x / ap
+		sum += del
+		if math.Abs(del) < math.Abs(sum)*epsilon {
+			break
+		}
+	}
+	return sum * math.Exp(-x+a*math.Log(x)-lgamma)
+}
+
+// gammaContinuedFraction computes Q(a, x) by its continued fraction
+func gammaContinuedFraction(a, x float64) float64 {
+	lgamma, _ := math.Lgamma(a)
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i <= maxIterations; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < epsilon {
+			break
+		}
+	}
+	return math.Exp(-x+a*math.Log(x)-lgamma) * h
+}
+
+// regularizedBeta computes the regularized incomplete beta function I_x(a, b)
+func regularizedBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	lgammaA, _ := math.Lgamma(a)
+	lgammaB, _ := math.Lgamma(b)
+	lgammaAB, _ := math.Lgamma(a + b)
+	front := math.Exp(lgammaAB - lgammaA - lgammaB + a*math.Log(x) + b*math.Log(1-x))
+
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*betaContinuedFraction(1-x, b, a)/b
+}
+
+// betaContinuedFraction evaluates the continued fraction of the incomplete beta function
+func betaContinuedFraction(x, a, b float64) float64 {
+	qab := a + b
+	qap := a + 1
+	qam := a - 1
+	c := 1.0
+	d := 1 - qab*x/qap
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		m2 := 2 * fm
+
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < epsilon {
+			break
+		}
+	}
+
+	return h
+}
@@ -0,0 +1,230 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+// near reports whether two values agree to within 1e-6
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+// pairsOf returns unweighted pairs of the values
+func pairsOf(xs, ys []float64) []WeightedPair {
+	pairs := make([]WeightedPair, len(xs))
+	for i := range xs {
+		pairs[i] = WeightedPair{X: xs[i], Y: ys[i], Weight: 1}
+	}
+	return pairs
+}
+
+func TestPearson(t *testing.T) {
+	tests := []struct {
+		name  string
+		pairs []WeightedPair
+		want  float64
+		ok    bool
+	}{
+		{"perfect", pairsOf([]float64{1, 2, 3}, []float64{2, 4, 6}), 1, true},
+		{"inverse", pairsOf([]float64{1, 2, 3}, []float64{3, 2, 1}), -1, true},
+		{"partial", pairsOf([]float64{1, 2, 3, 4, 5}, []float64{2, 4, 5, 4, 5}), math.Sqrt(0.6), true},
+		{"weights count as repeated pairs", []WeightedPair{{1, 2, 1}, {2, 4, 1}, {3, 5, 1}, {4, 4, 1}, {5, 5, 1}}, math.Sqrt(0.6), true},
+		{"weighted", []WeightedPair{{1, 1, 2}, {2, 1, 1}, {2, 2, 1}}, 1 / math.Sqrt(3), true},
+		{"constant x", pairsOf([]float64{2, 2, 2}, []float64{1, 2, 3}), 0, false},
+		{"constant y", pairsOf([]float64{1, 2, 3}, []float64{4, 4, 4}), 0, false},
+		{"single observation", []WeightedPair{{1, 2, 1}}, 0, false},
+		{"no observations", nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Pearson(tt.pairs)
+			if ok != tt.ok || !near(got, tt.want) {
+				t.Errorf("Pearson() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSpearman(t *testing.T) {
+	tests := []struct {
+		name  string
+		pairs []WeightedPair
+		want  float64
+		ok    bool
+	}{
+		{"monotonic", pairsOf([]float64{1, 2, 3, 4}, []float64{1, 4, 9, 16}), 1, true},
+		{"reversed", pairsOf([]float64{1, 2, 3, 4}, []float64{10, 5, 2, 1}), -1, true},
+		{"ties share their average rank", pairsOf([]float64{1, 2, 2, 3}, []float64{1, 2, 3, 4}), 4.5 / math.Sqrt(22.5), true},
+		{"weighted ties", []WeightedPair{{1, 1, 1}, {2, 2, 1}, {2, 3, 1}, {3, 4, 1}}, 4.5 / math.Sqrt(22.5), true},
+		{"constant", pairsOf([]float64{1, 2, 3}, []float64{5, 5, 5}), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Spearman(tt.pairs)
+			if ok != tt.ok || !near(got, tt.want) {
+				t.Errorf("Spearman() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestChiSquare(t *testing.T) {
+	// Expected counts are 12, 18, 28 and 42
+	statistic := 4.0/12 + 4.0/18 + 4.0/28 + 4.0/42
+
+	tests := []struct {
+		name  string
+		table [][]int
+		want  ChiSquareTest
+		ok    bool
+	}{
+		{"two by two", [][]int{{10, 20}, {30, 40}}, ChiSquareTest{Statistic: statistic, DegreesOfFreedom: 1, Total: 100, Rows: 2, Columns: 2}, true},
+		{"empty rows and columns are dropped", [][]int{{10, 0, 20}, {0, 0, 0}, {30, 0, 40}}, ChiSquareTest{Statistic: statistic, DegreesOfFreedom: 1, Total: 100, Rows: 2, Columns: 2}, true},
+		{"independent", [][]int{{10, 20, 30}, {20, 40, 60}}, ChiSquareTest{Statistic: 0, DegreesOfFreedom: 2, Total: 180, Rows: 2, Columns: 3}, true},
+		{"single row", [][]int{{10, 20}, {0, 0}}, ChiSquareTest{Total: 30, Rows: 1, Columns: 2}, false},
+		{"single column", [][]int{{10, 0}, {20, 0}}, ChiSquareTest{Total: 30, Rows: 2, Columns: 1}, false},
+		{"empty", nil, ChiSquareTest{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ChiSquare(tt.table)
+			if ok != tt.ok || !near(got.Statistic, tt.want.Statistic) || got.DegreesOfFreedom != tt.want.DegreesOfFreedom ||
+				got.Total != tt.want.Total || got.Rows != tt.want.Rows || got.Columns != tt.want.Columns {
+				t.Errorf("ChiSquare() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+			if ok && !near(got.PValue, ChiSquarePValue(got.Statistic, got.DegreesOfFreedom)) {
+				t.Errorf("PValue = %v, want the p-value of the statistic", got.PValue)
+			}
+		})
+	}
+}
+
+func TestChiSquarePValue(t *testing.T) {
+	tests := []struct {
+		statistic        float64
+		degreesOfFreedom int
+		want             float64
+	}{
+		// With one degree of freedom Q is erfc(sqrt(x/2)), with two it is exp(-x/2)
+		{0.5, 1, math.Erfc(math.Sqrt(0.25))},
+		{3.841459, 1, 0.05},
+		{40, 1, math.Erfc(math.Sqrt(20))},
+		{1, 2, math.Exp(-0.5)},
+		{5.991465, 2, 0.05},
+		// Critical values at the 5% and 1% level
+		{7.814728, 3, 0.05},
+		{18.307038, 10, 0.05},
+		{23.209251, 10, 0.01},
+		{0, 4, 1},
+		{3, 0, 1},
+	}
+
+	for _, tt := range tests {
+		if got := ChiSquarePValue(tt.statistic, tt.degreesOfFreedom); !near(got, tt.want) {
+			t.Errorf("ChiSquarePValue(%v, %d) = %v, want %v", tt.statistic, tt.degreesOfFreedom, got, tt.want)
+		}
+	}
+}
+
+func TestCramersV(t *testing.T) {
+	tests := []struct {
+		name  string
+		table [][]int
+		want  float64
+	}{
+		{"perfect association", [][]int{{10, 0}, {0, 10}}, 1},
+		{"no association", [][]int{{10, 10}, {10, 10}}, 0},
+		{"weak association", [][]int{{10, 20}, {30, 40}}, math.Sqrt((4.0/12 + 4.0/18 + 4.0/28 + 4.0/42) / 100)},
+		{"three by two uses the smaller side", [][]int{{10, 0}, {0, 10}, {5, 5}}, math.Sqrt(20.0 / 30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test, _ := ChiSquare(tt.table)
+			if got := test.CramersV(); !near(got, tt.want) {
+				t.Errorf("CramersV() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := (ChiSquareTest{}).CramersV(); got != 0 {
+		t.Errorf("CramersV() of an empty test = %v, want 0", got)
+	}
+}
+
+func TestCorrelationPValue(t *testing.T) {
+	tests := []struct {
+		name string
+		r    float64
+		n    int
+		want float64
+	}{
+		// With one degree of freedom the two-sided p-value is 1 - 2/pi atan(|t|), with two it
+		// is 1 - |t|/sqrt(t^2+2)
+		{"one degree of freedom", 0.5, 3, 2.0 / 3},
+		{"two degrees of freedom", 0.5, 4, 0.5},
+		{"negative coefficient", -0.5, 4, 0.5},
+		{"no correlation", 0, 30, 1},
+		{"perfect correlation", 1, 10, 0},
+		{"too few observations", 0.9, 2, 1},
+		// The critical value of t at the 5% level with 18 degrees of freedom is 2.100922
+		{"critical value", 2.100922 / math.Sqrt(2.100922*2.100922+18), 20, 0.05},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CorrelationPValue(tt.r, tt.n); !near(got, tt.want) {
+				t.Errorf("CorrelationPValue(%v, %d) = %v, want %v", tt.r, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCorrelationRatio(t *testing.T) {
+	// Groups 1 and 2 have the values 1, 2, 3 and 4, 5, 6, so that the sums of squares are
+	// 13.5 between the groups and 17.5 in total
+	twoGroups := pairsOf([]float64{1, 1, 1, 2, 2, 2}, []float64{1, 2, 3, 4, 5, 6})
+
+	eta, pValue, ok := CorrelationRatio(twoGroups)
+	if !ok || !near(eta, math.Sqrt(13.5/17.5)) {
+		t.Errorf("CorrelationRatio() = %v, %v, want %v, true", eta, ok, math.Sqrt(13.5/17.5))
+	}
+	// With two groups the F-test is the t-test of the point-biserial correlation
+	if want := CorrelationPValue(eta, 6); !near(pValue, want) {
+		t.Errorf("p-value = %v, want %v", pValue, want)
+	}
+
+	// Weights count as repeated pairs
+	weightedEta, weightedPValue, _ := CorrelationRatio([]WeightedPair{{1, 1, 1}, {1, 3, 2}, {2, 4, 2}, {2, 6, 1}})
+	repeatedEta, repeatedPValue, _ := CorrelationRatio(pairsOf([]float64{1, 1, 1, 2, 2, 2}, []float64{1, 3, 3, 4, 4, 6}))
+	if !near(weightedEta, repeatedEta) || !near(weightedPValue, repeatedPValue) {
+		t.Errorf("weighted CorrelationRatio() = %v, %v, want %v, %v", weightedEta, weightedPValue, repeatedEta, repeatedPValue)
+	}
+
+	tests := []struct {
+		name   string
+		pairs  []WeightedPair
+		eta    float64
+		pValue float64
+		ok     bool
+	}{
+		{"groups without spread", pairsOf([]float64{1, 1, 2, 2}, []float64{3, 3, 5, 5}), 1, 0, true},
+		{"same means", pairsOf([]float64{1, 1, 2, 2}, []float64{1, 3, 1, 3}), 0, 1, true},
+		{"single group", pairsOf([]float64{1, 1, 1}, []float64{1, 2, 3}), 0, 1, false},
+		{"one observation per group", pairsOf([]float64{1, 2}, []float64{1, 2}), 0, 1, false},
+		{"constant values", pairsOf([]float64{1, 1, 2, 2}, []float64{4, 4, 4, 4}), 0, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eta, pValue, ok := CorrelationRatio(tt.pairs)
+			if ok != tt.ok || !near(eta, tt.eta) || !near(pValue, tt.pValue) {
+				t.Errorf("CorrelationRatio() = %v, %v, %v, want %v, %v, %v", eta, pValue, ok, tt.eta, tt.pValue, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// Summary holds descriptive statistics of a numeric sample
+type Summary struct {
+	Count  int
+	Mean   float64
+	Median float64
+	StdDev float64
+	Min    float64
+	Max    float64
+}
+
+// Summarize computes descriptive statistics from a frequency distribution that maps
+// each observed value to the number of times it was observed. The standard deviation
+// is the sample standard deviation.
+func Summarize(distribution map[float64]int) Summary {
+	values := make([]float64, 0, len(distribution))
+	var summary Summary
+	var sum float64
+
+	for value, count := range distribution {
+		if count <= 0 {
+			continue
+		}
+		values = append(values, value)
+		summary.Count += count
+		sum += value * float64(count)
+	}
+
+	if summary.Count == 0 {
+		return summary
+	}
+
+	sort.Float64s(values)
+	summary.Min = values[0]
+	summary.Max = values[len(values)-1]
+	summary.Mean = sum / float64(summary.Count)
+
+	var squares float64
+	for _, value := range values {
+		diff := value - summary.Mean
+		squares += diff * diff * float64(distribution[value])
+	}
+	if summary.Count > 1 {
+		summary.StdDev = math.Sqrt(squares / float64(summary.Count-1))
+	}
+
+	summary.Median = median(values, distribution, summary.Count)
+
+	return summary
+}
+
+// median finds the median of a frequency distribution whose values are sorted
+func median(values []float64, distribution map[float64]int, total int) float64 {
+	lower := valueAt(values, distribution, (total-1)/2)
+	if total%2 == 1 {
+		return lower
+	}
+	upper := valueAt(values, distribution, total/2)
+	return (lower + upper) / 2
+}
+
+// valueAt returns the value at the given zero-based position of the expanded sample
+func valueAt(values []float64, distribution map[float64]int, position int) float64 {
+	seen := 0
+	for _, value := range values {
+		seen += distribution[value]
+		if position < seen {
+			return value
+		}
+	}
+	return values[len(values)-1]
+}
+
+// Percentage returns part as a percentage of total, rounded to two decimals
+func Percentage(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return Round(float64(part)/float64(total)*100, 2)
+}
+
+// Round rounds a value to the given number of decimals
+func Round(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
+}
@@ -0,0 +1,78 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+// approx reports whether two values agree to within 1e-9
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name         string
+		distribution map[float64]int
+		want         Summary
+	}{
+		{"empty", map[float64]int{}, Summary{}},
+		{"only empty counts", map[float64]int{3: 0, 4: -1}, Summary{}},
+		{"single value", map[float64]int{7: 1}, Summary{Count: 1, Mean: 7, Median: 7, Min: 7, Max: 7}},
+		{"odd count", map[float64]int{1: 1, 2: 1, 9: 1}, Summary{Count: 3, Mean: 4, Median: 2, StdDev: math.Sqrt(19), Min: 1, Max: 9}},
+		{"even count averages the middle values", map[float64]int{1: 1, 2: 1, 3: 1, 10: 1}, Summary{Count: 4, Mean: 4, Median: 2.5, StdDev: math.Sqrt(50.0 / 3), Min: 1, Max: 10}},
+		{"middle inside a repeated value", map[float64]int{1: 1, 5: 3, 10: 1}, Summary{Count: 5, Mean: 5.2, Median: 5, StdDev: math.Sqrt(10.2), Min: 1, Max: 10}},
+		{"middle between two values", map[float64]int{2: 2, 4: 2}, Summary{Count: 4, Mean: 3, Median: 3, StdDev: math.Sqrt(4.0 / 3), Min: 2, Max: 4}},
+		{"constant", map[float64]int{5: 4}, Summary{Count: 4, Mean: 5, Median: 5, Min: 5, Max: 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Summarize(tt.distribution)
+			if got.Count != tt.want.Count || !approx(got.Mean, tt.want.Mean) || !approx(got.Median, tt.want.Median) ||
+				!approx(got.StdDev, tt.want.StdDev) || got.Min != tt.want.Min || got.Max != tt.want.Max {
+				t.Errorf("Summarize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	tests := []struct {
+		part, total int
+		want        float64
+	}{
+		{0, 0, 0},
+		{3, 0, 0},
+		{1, 4, 25},
+		{1, 3, 33.33},
+		{2, 3, 66.67},
+		{3, 3, 100},
+	}
+
+	for _, tt := range tests {
+		if got := Percentage(tt.part, tt.total); got != tt.want {
+			t.Errorf("Percentage(%d, %d) = %v, want %v", tt.part, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		value    float64
+		decimals int
+		want     float64
+	}{
+		{1.005, 1, 1},
+		{1.25, 1, 1.3},
+		{-1.25, 1, -1.3},
+		{2.675, 0, 3},
+		{1234.5678, 2, 1234.57},
+	}
+
+	for _, tt := range tests {
+		if got := Round(tt.value, tt.decimals); got != tt.want {
+			t.Errorf("Round(%v, %d) = %v, want %v", tt.value, tt.decimals, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package validation
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+)
+
+// ValidateAnswers checks that the answers belong to the survey and have the shape
+// required by the type of the question they answer. Every question may be answered
+// at most once per call, multiple choice questions by several answers.
+func ValidateAnswers(survey *models.Survey, answers []models.SubmitAnswerRequest) error {
+	grouped := make(map[string][]models.SubmitAnswerRequest)
+	var order []string
+
+	for _, answer := range answers {
+		if survey.Question(answer.QuestionID) == nil {
+			return errors.NewError(errors.ErrBadRequest, "question %s does not belong to survey %s", answer.QuestionID, survey.ID)
+		}
+		if _, ok := grouped[answer.QuestionID]; !ok {
+			order = append(order, answer.QuestionID)
+		}
+		grouped[answer.QuestionID] = append(grouped[answer.QuestionID], answer)
+	}
+
+	for _, questionID := range order {
+		if err := validateQuestionAnswers(survey.Question(questionID), grouped[questionID]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateRequired checks that every required question of the survey has been answered
+func ValidateRequired(survey *models.Survey, answered map[string]bool) error {
+	for _, question := range survey.Questions {
+		if question.Required && !answered[question.ID] {
+			return errors.NewError(errors.ErrBadRequest, "question %s is required", question.ID)
+		}
+	}
+	return nil
+}
+
+// validateQuestionAnswers checks the answers given to a single question
+func validateQuestionAnswers(question *models.Question, answers []models.SubmitAnswerRequest) error {
+	switch question.Type {
+	case models.QuestionTypeSingleChoice:
+		if len(answers) != 1 {
+			return errors.NewError(errors.ErrBadRequest, "question %s accepts exactly one option", question.ID)
+		}
+		return validateOptionAnswer(question, answers[0])
+
+	case models.QuestionTypeMultipleChoice:
+		seen := make(map[string]bool, len(answers))
+		for _, answer := range answers {
+			if err := validateOptionAnswer(question, answer); err != nil {
+				return err
+			}
+			if seen[*answer.OptionID] {
+				return errors.NewError(errors.ErrBadRequest, "option %s is selected more than once for question %s", *answer.OptionID, question.ID)
+			}
+			seen[*answer.OptionID] = true
+		}
+		return nil
+
+	case models.QuestionTypeText:
+		text, err := singleTextAnswer(question, answers)
+		if err != nil {
+			return err
+		}
+		if utf8.RuneCountInString(text) > models.MaxTextAnswerLength {
+			return errors.NewError(errors.ErrBadRequest, "answer to question %s exceeds %d characters", question.ID, models.MaxTextAnswerLength)
+		}
+		return nil
+
+	case models.QuestionTypeRating:
+		text, err := singleTextAnswer(question, answers)
+		if err != nil {
+			return err
+		}
+		rating, err := strconv.Atoi(text)
+		if err != nil || rating < models.MinRatingValue || rating > models.MaxRatingValue {
+			return errors.NewError(errors.ErrBadRequest, "answer to question %s must be a rating between %d and %d", question.ID, models.MinRatingValue, models.MaxRatingValue)
+		}
+		return nil
+
+	case models.QuestionTypeDate:
+		text, err := singleTextAnswer(question, answers)
+		if err != nil {
+			return err
+		}
+		if _, err := time.Parse(models.DateAnswerLayout, text); err != nil {
+			return errors.NewError(errors.ErrBadRequest, "answer to question %s must be a date in YYYY-MM-DD format", question.ID)
+		}
+		return nil
+
+	default:
+		return errors.NewError(errors.ErrBadRequest, "question %s has unsupported type %s", question.ID, question.Type)
+	}
+}
+
+// validateOptionAnswer checks that a choice answer references an option of the question
+func validateOptionAnswer(question *models.Question, answer models.SubmitAnswerRequest) error {
+	if answer.OptionID == nil || *answer.OptionID == "" {
+		return errors.NewError(errors.ErrBadRequest, "answer to question %s must select an option", question.ID)
+	}
+	if answer.TextAnswer != nil {
+		return errors.NewError(errors.ErrBadRequest, "answer to question %s must not contain text", question.ID)
+	}
+	if question.Option(*answer.OptionID) == nil {
+		return errors.NewError(errors.ErrBadRequest, "option %s does not belong to question %s", *answer.OptionID, question.ID)
+	}
+	return nil
+}
+
+// singleTextAnswer checks that a question has exactly one non-empty text answer and returns it
+func singleTextAnswer(question *models.Question, answers []models.SubmitAnswerRequest) (string, error) {
+	if len(answers) != 1 {
+		return "", errors.NewError(errors.ErrBadRequest, "question %s accepts exactly one answer", question.ID)
+	}
+
+	answer := answers[0]
+	if answer.OptionID != nil {
+		return "", errors.NewError(errors.ErrBadRequest, "answer to question %s must not select an option", question.ID)
+	}
+	if answer.TextAnswer == nil || strings.TrimSpace(*answer.TextAnswer) == "" {
+		return "", errors.NewError(errors.ErrBadRequest, "answer to question %s must not be empty", question.ID)
+	}
+
+	return strings.TrimSpace(*answer.TextAnswer), nil
+}
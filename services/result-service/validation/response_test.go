@@ -0,0 +1,104 @@
+package validation
+
+import (
+	stderrors "errors"
+	"strings"
+	"testing"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/services/result-service/models"
+)
+
+// testSurvey has one question of every type
+var testSurvey = &models.Survey{
+	ID: "survey-1",
+	Questions: []models.Question{
+		{ID: "single", Type: models.QuestionTypeSingleChoice, Required: true, Options: []models.Option{{ID: "s1"}, {ID: "s2"}}},
+		{ID: "multiple", Type: models.QuestionTypeMultipleChoice, Options: []models.Option{{ID: "m1"}, {ID: "m2"}}},
+		{ID: "text", Type: models.QuestionTypeText},
+		{ID: "rating", Type: models.QuestionTypeRating},
+		{ID: "date", Type: models.QuestionTypeDate},
+		{ID: "unknown", Type: "matrix"},
+	},
+}
+
+func option(questionID, optionID string) models.SubmitAnswerRequest {
+	return models.SubmitAnswerRequest{QuestionID: questionID, OptionID: &optionID}
+}
+
+func text(questionID, answer string) models.SubmitAnswerRequest {
+	return models.SubmitAnswerRequest{QuestionID: questionID, TextAnswer: &answer}
+}
+
+func TestValidateAnswers(t *testing.T) {
+	textWithOption := text("text", "answer")
+	textWithOption.OptionID = option("text", "s1").OptionID
+	choiceWithText := option("single", "s1")
+	choiceWithText.TextAnswer = text("single", "answer").TextAnswer
+
+	tests := []struct {
+		name    string
+		answers []models.SubmitAnswerRequest
+		valid   bool
+	}{
+		{"no answers", nil, true},
+		{"one of every type", []models.SubmitAnswerRequest{
+			option("single", "s2"),
+			option("multiple", "m1"),
+			option("multiple", "m2"),
+			text("text", "  an answer  "),
+			text("rating", "10"),
+			text("date", "2024-02-29"),
+		}, true},
+		{"question of another survey", []models.SubmitAnswerRequest{option("other", "s1")}, false},
+		{"unsupported question type", []models.SubmitAnswerRequest{text("unknown", "answer")}, false},
+
+		{"single choice with two options", []models.SubmitAnswerRequest{option("single", "s1"), option("single", "s2")}, false},
+		{"single choice without option", []models.SubmitAnswerRequest{{QuestionID: "single"}}, false},
+		{"single choice with empty option", []models.SubmitAnswerRequest{option("single", "")}, false},
+		{"single choice with option of another question", []models.SubmitAnswerRequest{option("single", "m1")}, false},
+		{"single choice with text", []models.SubmitAnswerRequest{choiceWithText}, false},
+		{"multiple choice with repeated option", []models.SubmitAnswerRequest{option("multiple", "m1"), option("multiple", "m1")}, false},
+
+		{"text too long", []models.SubmitAnswerRequest{text("text", strings.Repeat("é", models.MaxTextAnswerLength+1))}, false},
+		{"text at the limit", []models.SubmitAnswerRequest{text("text", strings.Repeat("é", models.MaxTextAnswerLength))}, true},
+		{"blank text", []models.SubmitAnswerRequest{text("text", "   ")}, false},
+		{"text without answer", []models.SubmitAnswerRequest{{QuestionID: "text"}}, false},
+		{"text with option", []models.SubmitAnswerRequest{textWithOption}, false},
+		{"two texts", []models.SubmitAnswerRequest{text("text", "a"), text("text", "b")}, false},
+
+		{"lowest rating", []models.SubmitAnswerRequest{text("rating", "1")}, true},
+		{"rating too low", []models.SubmitAnswerRequest{text("rating", "0")}, false},
+		{"rating too high", []models.SubmitAnswerRequest{text("rating", "11")}, false},
+		{"rating not a number", []models.SubmitAnswerRequest{text("rating", "five")}, false},
+
+		{"date not in the calendar", []models.SubmitAnswerRequest{text("date", "2023-02-29")}, false},
+		{"date in another format", []models.SubmitAnswerRequest{text("date", "29.02.2024")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAnswers(testSurvey, tt.answers)
+			if tt.valid {
+				if err != nil {
+					t.Errorf("ValidateAnswers returned error: %v", err)
+				}
+				return
+			}
+			if !stderrors.Is(err, errors.ErrBadRequest) {
+				t.Errorf("ValidateAnswers error = %v, want ErrBadRequest", err)
+			}
+		})
+	}
+}
+
+func TestValidateRequired(t *testing.T) {
+	if err := ValidateRequired(testSurvey, map[string]bool{"single": true}); err != nil {
+		t.Errorf("ValidateRequired returned error: %v", err)
+	}
+
+	err := ValidateRequired(testSurvey, map[string]bool{"multiple": true, "text": true})
+	if !stderrors.Is(err, errors.ErrBadRequest) {
+		t.Errorf("ValidateRequired error = %v, want ErrBadRequest", err)
+	}
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/models"
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when the user service does not know a user or membership, or hides
+// it from the current user
+var ErrNotFound = stderrors.New("not found")
+
+// UserClient looks up users and organization memberships in the user service. Requests are
+// made on behalf of the user whose Authorization header is in the context, see
+// commonhttp.WithAuthorization.
+type UserClient struct {
+	client *commonhttp.Client
+}
+
+// NewUserClient creates a new user service client
+func NewUserClient(baseURL string, timeout time.Duration) *UserClient {
+	return &UserClient{
+		client: commonhttp.NewClient(baseURL, timeout),
+	}
+}
+
+// GetMember retrieves the membership of a user in an organization with the permissions it
+// grants. It returns ErrNotFound when the user is not a member or the current user does not
+// belong to the organization.
+func (c *UserClient) GetMember(ctx context.Context, orgID, userID uuid.UUID) (*models.Membership, error) {
+	var member models.Membership
+	path := "/api/v1/organizations/" + url.PathEscape(orgID.String()) + "/members/" + url.PathEscape(userID.String())
+	if err := c.get(ctx, path, &member); err != nil {
+		return nil, fmt.Errorf("failed to get membership: %w", err)
+	}
+
+	return &member, nil
+}
+
+// UserExists reports whether a user exists
+func (c *UserClient) UserExists(ctx context.Context, userID uuid.UUID) (bool, error) {
+	err := c.get(ctx, "/api/v1/users/"+url.PathEscape(userID.String())+"/profile", nil)
+	if err != nil {
+		if stderrors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return true, nil
+}
+
+// get performs a GET request and maps not found responses to ErrNotFound
+func (c *UserClient) get(ctx context.Context, path string, result interface{}) error {
+	err := c.client.Get(ctx, path, result)
+	if err != nil {
+		var statusErr *commonhttp.StatusError
+		if stderrors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit"
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/permissions"
+	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// InviteCollaborator handles sharing a survey with a user as an editor or viewer. Inviting an
+// existing collaborator changes their role.
+func (h *SurveyHandler) InviteCollaborator(w http.ResponseWriter, r *http.Request) {
+	var req models.InviteCollaboratorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.UserID == uuid.Nil {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+	if !req.Role.IsValid() {
+		http.Error(w, "Invalid collaborator role", http.StatusBadRequest)
+		return
+	}
+
+	survey, ok := h.getSurvey(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, survey, permissions.SurveyManage) {
+		return
+	}
+	if req.UserID == survey.CreatedBy {
+		http.Error(w, "The owner of a survey cannot be a collaborator", http.StatusBadRequest)
+		return
+	}
+
+	ctx := commonhttp.WithAuthorization(r.Context(), r.Header.Get("Authorization"))
+	exists, err := h.users.UserExists(ctx, req.UserID)
+	if err != nil {
+		http.Error(w, "Failed to check user", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "User does not exist", http.StatusBadRequest)
+		return
+	}
+
+	collaborator := &models.Collaborator{
+		SurveyID: survey.ID,
+		UserID:   req.UserID,
+		Role:     req.Role,
+	}
+	if userID, ok := currentUserID(r); ok {
+		collaborator.InvitedBy = &userID
+	}
+
+	if err := h.collaborators.SaveCollaborator(r.Context(), collaborator); err != nil {
+		http.Error(w, "Failed to save collaborator", http.StatusInternalServerError)
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionCollaboratorInvite, "survey", survey.ID.String(), nil, collaborator)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collaborator)
+}
+
+// ListCollaborators handles listing the collaborators of a survey
+func (h *SurveyHandler) ListCollaborators(w http.ResponseWriter, r *http.Request) {
+	survey, ok := h.getSurvey(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, survey, permissions.SurveyView) {
+		return
+	}
+
+	collaborators, err := h.collaborators.ListCollaborators(r.Context(), survey.ID)
+	if err != nil {
+		http.Error(w, "Failed to list collaborators", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collaborators)
+}
+
+// RevokeCollaborator handles stopping to share a survey with a user. Collaborators may also
+// revoke their own access.
+func (h *SurveyHandler) RevokeCollaborator(w http.ResponseWriter, r *http.Request) {
+	collaboratorID, err := uuid.Parse(chi.URLParam(r, "userId"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	survey, ok := h.getSurvey(w, r)
+	if !ok {
+		return
+	}
+	if userID, ok := currentUserID(r); !ok || userID != collaboratorID {
+		if !h.authorize(w, r, survey, permissions.SurveyManage) {
+			return
+		}
+	}
+
+	if err := h.collaborators.RemoveCollaborator(r.Context(), survey.ID, collaboratorID); err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "Collaborator not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to remove collaborator", http.StatusInternalServerError)
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionCollaboratorRevoke, "survey", survey.ID.String(), map[string]uuid.UUID{"user_id": collaboratorID}, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getSurvey loads the survey from the URL. If that fails it responds with an error and returns
+// false.
+func (h *SurveyHandler) getSurvey(w http.ResponseWriter, r *http.Request) (*models.Survey, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid survey ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	survey, err := h.repo.GetSurvey(r.Context(), id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "Survey not found", http.StatusNotFound)
+			return nil, false
+		}
+		http.Error(w, "Failed to get survey", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return survey, true
+}
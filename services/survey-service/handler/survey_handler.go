@@ -2,21 +2,49 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit"
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/permissions"
+	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/client"
 	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/models"
 	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/repository"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
+const (
+	// scopeMine lists the surveys of the current user
+	scopeMine = "mine"
+	// scopeAll lists the surveys of all users
+	scopeAll = "all"
+	// scopeShared lists the surveys shared with the current user as a collaborator
+	scopeShared = "shared"
+)
+
+var (
+	// creatorPermissions are the permissions the creator of a personal survey has on it
+	creatorPermissions = []string{permissions.SurveyView, permissions.SurveyEdit, permissions.SurveyManage}
+	// rolePermissions are the permissions the creator of a personal survey has on it if one of
+	// their roles grants them
+	rolePermissions = []string{permissions.SurveyPublish, permissions.ResultsRead, permissions.ResultsExport}
+)
+
 type SurveyHandler struct {
-	repo *repository.SurveyRepository
+	repo          *repository.SurveyRepository
+	collaborators *repository.CollaboratorRepository
+	users         *client.UserClient
+	recorder      *audit.Recorder
 }
 
-func NewSurveyHandler(repo *repository.SurveyRepository) *SurveyHandler {
-	return &SurveyHandler{repo: repo}
+func NewSurveyHandler(repo *repository.SurveyRepository, collaborators *repository.CollaboratorRepository, users *client.UserClient, recorder *audit.Recorder) *SurveyHandler {
+	return &SurveyHandler{repo: repo, collaborators: collaborators, users: users, recorder: recorder}
 }
 
 // CreateSurvey handles the creation of a new survey
@@ -27,19 +55,60 @@ func (h *SurveyHandler) CreateSurvey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user ID from context (assuming it's set by auth middleware)
-	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	// The creator is the authenticated user
+	userID, ok := currentUserID(r)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 	survey.CreatedBy = userID
 
+	// Surveys of an organization may be created by its members whose role allows it, personal
+	// surveys by users whose roles allow it
+	var canPublish bool
+	if survey.OrganizationID != nil {
+		granted, err := h.orgPermissions(r, *survey.OrganizationID, userID)
+		if err != nil {
+			http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		granted = inScope(r, granted)
+		if !hasPermission(granted, permissions.SurveyCreate) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		canPublish = hasPermission(granted, permissions.SurveyPublish)
+	} else {
+		if !middleware.HasPermission(r.Context(), permissions.SurveyCreate) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		canPublish = middleware.HasPermission(r.Context(), permissions.SurveyPublish)
+	}
+
+	// The scheduler publishes drafts once their start date passes, so only users who may
+	// publish a survey can schedule it
+	if (survey.StartDate != nil || survey.EndDate != nil) && !canPublish {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := survey.ValidateSchedule(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// New surveys start as drafts and only accept responses once published
+	survey.Status = models.SurveyStatusDraft
+	survey.IsActive = false
+
 	if err := h.repo.CreateSurvey(r.Context(), &survey); err != nil {
 		http.Error(w, "Failed to create survey", http.StatusInternalServerError)
 		return
 	}
 
+	h.recorder.Record(r, audit.ActionSurveyCreate, "survey", survey.ID.String(), nil, survey)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(survey)
@@ -56,12 +125,25 @@ func (h *SurveyHandler) GetSurvey(w http.ResponseWriter, r *http.Request) {
 
 	survey, err := h.repo.GetSurvey(r.Context(), id)
 	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "Survey not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Failed to get survey", http.StatusInternalServerError)
 		return
 	}
-	if survey == nil {
-		http.Error(w, "Survey not found", http.StatusNotFound)
-		return
+
+	// Drafts are only visible to the people who have access to them
+	if survey.Status == models.SurveyStatusDraft {
+		granted, err := h.surveyPermissions(r, survey)
+		if err != nil {
+			http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		if !hasPermission(granted, permissions.SurveyView) {
+			http.Error(w, "Survey not found", http.StatusNotFound)
+			return
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -84,18 +166,176 @@ func (h *SurveyHandler) UpdateSurvey(w http.ResponseWriter, r *http.Request) {
 	}
 	survey.ID = id
 
-	if err := h.repo.UpdateSurvey(r.Context(), &survey); err != nil {
+	if err := survey.ValidateSchedule(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.repo.GetSurvey(r.Context(), id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "Survey not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get survey", http.StatusInternalServerError)
+		return
+	}
+	if !h.authorize(w, r, existing, permissions.SurveyEdit) {
+		return
+	}
+	// The scheduler publishes drafts once their start date passes, so only users who may
+	// publish the survey can change the schedule of a draft
+	if existing.Status == models.SurveyStatusDraft && !sameSchedule(existing, &survey) &&
+		!h.authorize(w, r, existing, permissions.SurveyPublish) {
+		return
+	}
+	survey.CreatedBy = existing.CreatedBy
+	survey.OrganizationID = existing.OrganizationID
+
+	// Questions may only change while the survey is a draft, other details at any time
+	if existing.Status == models.SurveyStatusDraft {
+		err = h.repo.UpdateSurvey(r.Context(), &survey)
+	} else if survey.Questions == nil || sameQuestions(existing.Questions, survey.Questions) {
+		err = h.repo.UpdateSurveyDetails(r.Context(), &survey)
+	} else {
+		err = repository.ErrNotDraft
+	}
+
+	if err != nil {
 		if err == repository.ErrNotFound {
 			http.Error(w, "Survey not found", http.StatusNotFound)
 			return
 		}
+		if err == repository.ErrNotDraft {
+			http.Error(w, "Questions can only be changed while the survey is a draft", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to update survey", http.StatusInternalServerError)
 		return
 	}
 
+	// Record the stored survey, whose fields the request may leave out
+	if updated, err := h.repo.GetSurvey(r.Context(), id); err == nil {
+		h.recorder.Record(r, audit.ActionSurveyUpdate, "survey", id.String(), existing, updated)
+	} else {
+		h.recorder.Record(r, audit.ActionSurveyUpdate, "survey", id.String(), existing, survey)
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
+// UpdateSurveyStatus handles moving a survey through its lifecycle: a draft can be published,
+// a published survey can be closed and a closed survey can be reopened
+func (h *SurveyHandler) UpdateSurveyStatus(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid survey ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateSurveyStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !req.Status.IsValid() {
+		http.Error(w, "Invalid survey status", http.StatusBadRequest)
+		return
+	}
+
+	survey, err := h.repo.GetSurvey(r.Context(), id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "Survey not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get survey", http.StatusInternalServerError)
+		return
+	}
+	granted, err := h.surveyPermissions(r, survey)
+	if err != nil {
+		http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+		return
+	}
+	if !hasPermission(granted, permissions.SurveyEdit) || (req.Status == models.SurveyStatusPublished && !hasPermission(granted, permissions.SurveyPublish)) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if !survey.Status.CanTransitionTo(req.Status) {
+		http.Error(w, fmt.Sprintf("Cannot change survey status from %s to %s", survey.Status, req.Status), http.StatusConflict)
+		return
+	}
+	if req.Status == models.SurveyStatusPublished && len(survey.Questions) == 0 {
+		http.Error(w, "Cannot publish a survey without questions", http.StatusConflict)
+		return
+	}
+	if req.Status == models.SurveyStatusPublished && survey.HasEnded(time.Now()) {
+		http.Error(w, "Cannot publish a survey whose end date has passed", http.StatusConflict)
+		return
+	}
+
+	// Record who changed the status
+	var changedBy *uuid.UUID
+	if userID, ok := currentUserID(r); ok {
+		changedBy = &userID
+	}
+
+	if err := h.repo.UpdateSurveyStatus(r.Context(), id, survey.Status, req.Status, changedBy); err != nil {
+		if err == repository.ErrStatusChanged {
+			http.Error(w, "Survey status was changed concurrently", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to update survey status", http.StatusInternalServerError)
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionSurveyStatus, "survey", id.String(),
+		map[string]models.SurveyStatus{"status": survey.Status}, map[string]models.SurveyStatus{"status": req.Status})
+
+	survey.Status = req.Status
+	survey.IsActive = req.Status == models.SurveyStatusPublished
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(survey)
+}
+
+// GetSurveyStatusHistory handles listing the status transitions of a survey
+func (h *SurveyHandler) GetSurveyStatusHistory(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid survey ID", http.StatusBadRequest)
+		return
+	}
+
+	survey, err := h.repo.GetSurvey(r.Context(), id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "Survey not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get survey", http.StatusInternalServerError)
+		return
+	}
+	if !h.authorize(w, r, survey, permissions.SurveyView) {
+		return
+	}
+
+	transitions, err := h.repo.ListStatusTransitions(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Failed to get survey status history", http.StatusInternalServerError)
+		return
+	}
+	if transitions == nil {
+		transitions = []models.SurveyStatusTransition{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transitions)
+}
+
 // DeleteSurvey handles deleting a survey
 func (h *SurveyHandler) DeleteSurvey(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
@@ -105,6 +345,19 @@ func (h *SurveyHandler) DeleteSurvey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	survey, err := h.repo.GetSurvey(r.Context(), id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "Survey not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get survey", http.StatusInternalServerError)
+		return
+	}
+	if !h.authorize(w, r, survey, permissions.SurveyManage) {
+		return
+	}
+
 	if err := h.repo.DeleteSurvey(r.Context(), id); err != nil {
 		if err == repository.ErrNotFound {
 			http.Error(w, "Survey not found", http.StatusNotFound)
@@ -114,11 +367,57 @@ func (h *SurveyHandler) DeleteSurvey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.recorder.Record(r, audit.ActionSurveyDelete, "survey", id.String(), survey, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// ListSurveys handles retrieving a list of surveys with pagination
+// ListSurveys handles retrieving a list of the surveys of the current user with pagination.
+// The surveys shared with the user are listed with scope=shared. Members of an organization
+// may list its surveys with organization_id, and users who may manage all surveys may list the
+// surveys of all users with scope=all.
 func (h *SurveyHandler) ListSurveys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	scope := r.URL.Query().Get("scope")
+	if scope != "" && scope != scopeMine && scope != scopeAll && scope != scopeShared {
+		http.Error(w, "Invalid scope", http.StatusBadRequest)
+		return
+	}
+	if scope == scopeAll && !middleware.HasPermission(r.Context(), permissions.SurveyManageAny) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var orgID *uuid.UUID
+	if value := r.URL.Query().Get("organization_id"); value != "" {
+		if scope != "" {
+			http.Error(w, "scope cannot be combined with organization_id", http.StatusBadRequest)
+			return
+		}
+
+		id, err := uuid.Parse(value)
+		if err != nil {
+			http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+			return
+		}
+
+		granted, err := h.orgPermissions(r, id, userID)
+		if err != nil {
+			http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		if !hasPermission(granted, permissions.SurveyView) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		orgID = &id
+	}
+
 	pageStr := r.URL.Query().Get("page")
 	limitStr := r.URL.Query().Get("limit")
 
@@ -134,7 +433,16 @@ func (h *SurveyHandler) ListSurveys(w http.ResponseWriter, r *http.Request) {
 
 	offset := (page - 1) * limit
 
-	surveys, err := h.repo.ListSurveys(r.Context(), offset, limit)
+	var surveys []*models.Survey
+	if orgID != nil {
+		surveys, err = h.repo.GetSurveysByOrganization(r.Context(), *orgID, limit, offset)
+	} else if scope == scopeAll {
+		surveys, err = h.repo.ListSurveys(r.Context(), offset, limit)
+	} else if scope == scopeShared {
+		surveys, err = h.repo.GetSurveysSharedWith(r.Context(), userID, limit, offset)
+	} else {
+		surveys, err = h.repo.GetSurveysByUserID(r.Context(), userID, limit, offset)
+	}
 	if err != nil {
 		http.Error(w, "Failed to list surveys", http.StatusInternalServerError)
 		return
@@ -143,3 +451,293 @@ func (h *SurveyHandler) ListSurveys(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(surveys)
 }
+
+// GetSurveyAccess handles listing the permissions of the current user on a survey. Other
+// services use it to check access to the results of a survey.
+func (h *SurveyHandler) GetSurveyAccess(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid survey ID", http.StatusBadRequest)
+		return
+	}
+
+	survey, err := h.repo.GetSurvey(r.Context(), id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "Survey not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get survey", http.StatusInternalServerError)
+		return
+	}
+
+	granted, err := h.surveyPermissions(r, survey)
+	if err != nil {
+		http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+		return
+	}
+	if survey.Status == models.SurveyStatusDraft && !hasPermission(granted, permissions.SurveyView) {
+		http.Error(w, "Survey not found", http.StatusNotFound)
+		return
+	}
+	if granted == nil {
+		granted = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.SurveyAccess{
+		SurveyID:    survey.ID,
+		Permissions: granted,
+	})
+}
+
+// TransferSurvey handles handing a survey over to another owner, optionally moving it into or
+// out of an organization. Surveys only move into organizations where the current user may
+// create surveys, and only to members of the organization.
+func (h *SurveyHandler) TransferSurvey(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid survey ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.TransferSurveyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.OwnerID == uuid.Nil {
+		http.Error(w, "owner_id is required", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	survey, err := h.repo.GetSurvey(r.Context(), id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "Survey not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get survey", http.StatusInternalServerError)
+		return
+	}
+	if !h.authorize(w, r, survey, permissions.SurveyManage) {
+		return
+	}
+
+	ctx := commonhttp.WithAuthorization(r.Context(), r.Header.Get("Authorization"))
+	if req.OrganizationID != nil {
+		granted, err := h.orgPermissions(r, *req.OrganizationID, userID)
+		if err != nil {
+			http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		if !hasPermission(inScope(r, granted), permissions.SurveyCreate) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if _, err := h.users.GetMember(ctx, *req.OrganizationID, req.OwnerID); err != nil {
+			if errors.Is(err, client.ErrNotFound) {
+				http.Error(w, "New owner is not a member of the organization", http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Failed to check new owner", http.StatusInternalServerError)
+			return
+		}
+	} else if req.OwnerID != userID {
+		exists, err := h.users.UserExists(ctx, req.OwnerID)
+		if err != nil {
+			http.Error(w, "Failed to check new owner", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "New owner does not exist", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.repo.TransferSurvey(r.Context(), id, req.OwnerID, req.OrganizationID); err != nil {
+		if err == repository.ErrNotFound {
+			http.Error(w, "Survey not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to transfer survey", http.StatusInternalServerError)
+		return
+	}
+
+	before := models.TransferSurveyRequest{OwnerID: survey.CreatedBy, OrganizationID: survey.OrganizationID}
+	h.recorder.Record(r, audit.ActionSurveyTransfer, "survey", id.String(), before, req)
+
+	survey.CreatedBy = req.OwnerID
+	survey.OrganizationID = req.OrganizationID
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(survey)
+}
+
+// currentUserID returns the ID of the authenticated user
+func currentUserID(r *http.Request) (uuid.UUID, bool) {
+	claims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// surveyPermissions returns the permissions of the authenticated user on a survey. Users who
+// may manage all surveys have every permission. Members of the organization of a survey get
+// the permissions of their organization role, and the creator of a personal survey manages it
+// with the permissions of their roles. Collaborators additionally get the permissions of their
+// role on the survey. Requests made with an API key only get the permissions in its scopes.
+func (h *SurveyHandler) surveyPermissions(r *http.Request, survey *models.Survey) ([]string, error) {
+	if middleware.HasPermission(r.Context(), permissions.SurveyManageAny) {
+		return inScope(r, append(append([]string{}, creatorPermissions...), rolePermissions...)), nil
+	}
+
+	userID, ok := currentUserID(r)
+	if !ok {
+		return nil, nil
+	}
+
+	var granted []string
+	if survey.OrganizationID != nil {
+		orgPermissions, err := h.orgPermissions(r, *survey.OrganizationID, userID)
+		if err != nil {
+			return nil, err
+		}
+		granted = addPermissions(granted, orgPermissions...)
+	} else if survey.CreatedBy == userID {
+		granted = addPermissions(granted, creatorPermissions...)
+		for _, permission := range rolePermissions {
+			if middleware.HasPermission(r.Context(), permission) {
+				granted = addPermissions(granted, permission)
+			}
+		}
+	}
+
+	collaborator, err := h.collaborators.GetCollaborator(r.Context(), survey.ID, userID)
+	if err != nil && err != repository.ErrNotFound {
+		return nil, err
+	}
+	if collaborator != nil {
+		granted = addPermissions(granted, collaborator.Role.Permissions()...)
+	}
+
+	return inScope(r, granted), nil
+}
+
+// orgPermissions returns the permissions the role of a user in an organization grants on its
+// surveys, or none if the user is not a member. The user service is asked on behalf of the
+// authenticated user.
+func (h *SurveyHandler) orgPermissions(r *http.Request, orgID, userID uuid.UUID) ([]string, error) {
+	ctx := commonhttp.WithAuthorization(r.Context(), r.Header.Get("Authorization"))
+	member, err := h.users.GetMember(ctx, orgID, userID)
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return member.Permissions, nil
+}
+
+// authorize checks that the authenticated user has a permission on a survey. If not it
+// responds with an error and returns false.
+func (h *SurveyHandler) authorize(w http.ResponseWriter, r *http.Request, survey *models.Survey, permission string) bool {
+	granted, err := h.surveyPermissions(r, survey)
+	if err != nil {
+		http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+		return false
+	}
+	if !hasPermission(granted, permission) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// inScope returns the permissions the claims of the request may use, which are limited for
+// requests made with an API key
+func inScope(r *http.Request, granted []string) []string {
+	claims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		return nil
+	}
+
+	var allowed []string
+	for _, permission := range granted {
+		if claims.InScope(permission) {
+			allowed = append(allowed, permission)
+		}
+	}
+	return allowed
+}
+
+// addPermissions adds the permissions a list does not contain yet
+func addPermissions(granted []string, more ...string) []string {
+	for _, permission := range more {
+		if !hasPermission(granted, permission) {
+			granted = append(granted, permission)
+		}
+	}
+	return granted
+}
+
+// hasPermission reports whether a list of permissions contains one
+func hasPermission(granted []string, permission string) bool {
+	for _, p := range granted {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// sameQuestions reports whether two lists of questions have the same content in the same order
+func sameQuestions(a, b []models.Question) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID || a[i].Text != b[i].Text || a[i].Type != b[i].Type || a[i].Required != b[i].Required {
+			return false
+		}
+		if len(a[i].Options) != len(b[i].Options) {
+			return false
+		}
+		for j := range a[i].Options {
+			if a[i].Options[j].ID != b[i].Options[j].ID || a[i].Options[j].Text != b[i].Options[j].Text {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sameSchedule reports whether two surveys open and close at the same times
+func sameSchedule(a, b *models.Survey) bool {
+	return sameTime(a.StartDate, b.StartDate) && sameTime(a.EndDate, b.EndDate)
+}
+
+// sameTime reports whether two optional times are both unset or the same instant
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,12 +17,24 @@ import (
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/apikeys"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/config"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/database"
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/jwks"
+	authmw "github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/revocation"
+	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/client"
 	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/handler"
+	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/migrations"
 	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/repository"
+	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/scheduler"
 )
 
 const (
-	defaultPort = "8082"
+	defaultPort              = "8082"
+	defaultSchedulerInterval = time.Minute
 )
 
 func main() {
@@ -47,19 +60,92 @@ func main() {
 		logger.Fatal("Failed to ping database", zap.Error(err))
 	}
 
+	// Run the migrate subcommand or apply pending migrations
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		logger.Fatal("Failed to load migrations", zap.Error(err))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := database.RunMigrateCommand(context.Background(), migrator, os.Args[2:], os.Stdout); err != nil {
+			logger.Fatal("Failed to run migrations", zap.Error(err))
+		}
+		return
+	}
+	if os.Getenv("MIGRATE_ON_START") != "false" {
+		migrated, err := migrator.Up(context.Background())
+		if err != nil {
+			logger.Fatal("Failed to apply migrations", zap.Error(err))
+		}
+		for _, migration := range migrated {
+			logger.Info("Applied migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+		}
+	}
+
+	// Connect to the audit database, which the services share
+	auditDB, err := database.Connect(&database.Config{
+		Host:     config.GetEnv("AUDIT_DB_HOST", config.GetEnv("DB_HOST", "postgres")),
+		Port:     config.GetEnvInt("AUDIT_DB_PORT", config.GetEnvInt("DB_PORT", 5432)),
+		User:     config.GetEnv("AUDIT_DB_USER", config.GetEnv("DB_USER", "postgres")),
+		Password: config.GetEnv("AUDIT_DB_PASSWORD", config.GetEnv("DB_PASSWORD", "postgres")),
+		DBName:   config.GetEnv("AUDIT_DB_NAME", "pollpulse_audit"),
+		SSLMode:  config.GetEnv("AUDIT_DB_SSLMODE", config.GetEnv("DB_SSLMODE", "disable")),
+	})
+	if err != nil {
+		logger.Fatal("Failed to connect to audit database", zap.Error(err))
+	}
+	defer database.Close(auditDB)
+
+	auditStore := audit.NewStore(auditDB)
+	if os.Getenv("MIGRATE_ON_START") != "false" {
+		if err := auditStore.Migrate(context.Background()); err != nil {
+			logger.Fatal("Failed to apply audit migrations", zap.Error(err))
+		}
+	}
+	auditRecorder := audit.NewRecorder(auditStore, "survey-service", func(err error) {
+		logger.Error("Failed to record audit event", zap.Error(err))
+	})
+
 	// Initialize repository and handler
 	surveyRepo := repository.NewSurveyRepository(db)
-	surveyHandler := handler.NewSurveyHandler(surveyRepo)
+	userServiceURL := os.Getenv("USER_SERVICE_URL")
+	if userServiceURL == "" {
+		userServiceURL = "http://localhost:8081"
+	}
+	surveyHandler := handler.NewSurveyHandler(surveyRepo, repository.NewCollaboratorRepository(db), client.NewUserClient(userServiceURL, 10*time.Second), auditRecorder)
+
+	// Publish and close surveys on schedule
+	schedulerInterval := defaultSchedulerInterval
+	if value := os.Getenv("SCHEDULER_INTERVAL"); value != "" {
+		schedulerInterval, err = time.ParseDuration(value)
+		if err != nil || schedulerInterval <= 0 {
+			logger.Fatal("Invalid SCHEDULER_INTERVAL", zap.String("value", value))
+		}
+	}
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		scheduler.New(surveyRepo, schedulerInterval, logger).Run(schedulerCtx)
+	}()
+
+	// Only take the address of clients from the API gateway and the proxies it runs behind
+	trustedProxies, err := commonhttp.ParseTrustedProxies(strings.Split(os.Getenv("TRUSTED_PROXIES"), ","))
+	if err != nil {
+		logger.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
 
 	// Create router
 	r := chi.NewRouter()
 
 	// Middleware
+	r.Use(middleware.RequestID)
+	// Take the address of clients from the API gateway
+	r.Use(commonhttp.GatewayIP(trustedProxies))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
@@ -72,13 +158,50 @@ func main() {
 		w.Write([]byte("OK"))
 	})
 
+	// Verify tokens with the public keys of the user service
+	jwksURL := os.Getenv("JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = "http://localhost:8081" + jwks.Path
+	}
+	keys := jwks.NewClient(jwksURL, 0)
+
+	// Verify API keys with the user service
+	apiKeyClient := apikeys.NewClient(userServiceURL, 0)
+	apiKeys := authmw.WithAPIKeyVerifier(apiKeyClient)
+
+	// Reject tokens revoked by logging out
+	revocationClient := revocation.NewClient(userServiceURL, 0)
+	revocations := authmw.WithRevocationChecker(revocationClient)
+
+	// Drop expired entries from the caches of the credential clients
+	cleanupCtx, stopCleanup := context.WithCancel(context.Background())
+	defer stopCleanup()
+	go apiKeyClient.RunCleanup(cleanupCtx, time.Minute)
+	go revocationClient.RunCleanup(cleanupCtx, time.Minute)
+
+	// Trust the identity of users the API gateway signs with the shared secret
+	gateway := authmw.WithGatewaySecret([]byte(os.Getenv("GATEWAY_IDENTITY_SECRET")))
+
 	// Survey routes
 	r.Route("/api/v1/surveys", func(r chi.Router) {
-		r.Post("/", surveyHandler.CreateSurvey)
-		r.Get("/", surveyHandler.ListSurveys)
-		r.Get("/{id}", surveyHandler.GetSurvey)
-		r.Put("/{id}", surveyHandler.UpdateSurvey)
-		r.Delete("/{id}", surveyHandler.DeleteSurvey)
+		// Published and closed surveys are public, drafts only visible to the people with access
+		r.With(authmw.OptionalAuth(keys, apiKeys, revocations, gateway)).Get("/{id}", surveyHandler.GetSurvey)
+
+		// Protected routes
+		r.Group(func(r chi.Router) {
+			r.Use(authmw.Auth(keys, apiKeys, revocations, gateway))
+			r.Post("/", surveyHandler.CreateSurvey)
+			r.Get("/", surveyHandler.ListSurveys)
+			r.Put("/{id}", surveyHandler.UpdateSurvey)
+			r.Delete("/{id}", surveyHandler.DeleteSurvey)
+			r.Patch("/{id}/status", surveyHandler.UpdateSurveyStatus)
+			r.Get("/{id}/status-history", surveyHandler.GetSurveyStatusHistory)
+			r.Get("/{id}/access", surveyHandler.GetSurveyAccess)
+			r.Post("/{id}/transfer", surveyHandler.TransferSurvey)
+			r.Get("/{id}/collaborators", surveyHandler.ListCollaborators)
+			r.Post("/{id}/collaborators", surveyHandler.InviteCollaborator)
+			r.Delete("/{id}/collaborators/{userId}", surveyHandler.RevokeCollaborator)
+		})
 	})
 
 	// Get port from environment or use default
@@ -103,7 +226,8 @@ func main() {
 		<-sig
 
 		// Shutdown signal with grace period of 30 seconds
-		shutdownCtx, _ := context.WithTimeout(serverCtx, 30*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(serverCtx, 30*time.Second)
+		defer cancel()
 
 		go func() {
 			<-shutdownCtx.Done()
@@ -129,4 +253,8 @@ func main() {
 
 	// Wait for server context to be stopped
 	<-serverCtx.Done()
+
+	// Stop the scheduler
+	stopScheduler()
+	<-schedulerDone
 }
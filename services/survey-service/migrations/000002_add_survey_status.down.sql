@@ -0,0 +1,7 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_surveys_status;
+
+-- Drop the lifecycle status of surveys
+ALTER TABLE surveys ALTER COLUMN is_active SET DEFAULT true;
+ALTER TABLE surveys DROP CONSTRAINT IF EXISTS chk_surveys_status;
+ALTER TABLE surveys DROP COLUMN IF EXISTS status;
@@ -0,0 +1,21 @@
+-- Add the lifecycle status of surveys. Existing surveys keep accepting responses if they were
+-- active, and the backfill only runs when the column is new.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'surveys' AND column_name = 'status'
+    ) THEN
+        ALTER TABLE surveys ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'draft';
+        UPDATE surveys SET status = CASE WHEN is_active THEN 'published' ELSE 'closed' END;
+    END IF;
+END $$;
+
+ALTER TABLE surveys DROP CONSTRAINT IF EXISTS chk_surveys_status;
+ALTER TABLE surveys ADD CONSTRAINT chk_surveys_status CHECK (status IN ('draft', 'published', 'closed'));
+
+-- is_active is true while the survey is published
+ALTER TABLE surveys ALTER COLUMN is_active SET DEFAULT false;
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_surveys_status ON surveys(status);
@@ -0,0 +1,12 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_survey_status_transitions_survey_id;
+DROP INDEX IF EXISTS idx_surveys_end_date;
+DROP INDEX IF EXISTS idx_surveys_start_date;
+
+-- Drop tables
+DROP TABLE IF EXISTS survey_status_transitions;
+
+-- Drop the schedule of surveys
+ALTER TABLE surveys DROP CONSTRAINT IF EXISTS chk_surveys_schedule;
+ALTER TABLE surveys DROP COLUMN IF EXISTS end_date;
+ALTER TABLE surveys DROP COLUMN IF EXISTS start_date;
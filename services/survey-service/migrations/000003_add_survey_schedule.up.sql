@@ -0,0 +1,21 @@
+-- Add the schedule of surveys
+ALTER TABLE surveys ADD COLUMN IF NOT EXISTS start_date TIMESTAMP WITH TIME ZONE;
+ALTER TABLE surveys ADD COLUMN IF NOT EXISTS end_date TIMESTAMP WITH TIME ZONE;
+ALTER TABLE surveys DROP CONSTRAINT IF EXISTS chk_surveys_schedule;
+ALTER TABLE surveys ADD CONSTRAINT chk_surveys_schedule CHECK (start_date IS NULL OR end_date IS NULL OR end_date > start_date);
+
+-- Create survey_status_transitions table to record every status change
+CREATE TABLE IF NOT EXISTS survey_status_transitions (
+    id UUID PRIMARY KEY,
+    survey_id UUID NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    reason VARCHAR(20) NOT NULL,  -- manual, scheduled
+    changed_by UUID,  -- NULL for scheduled transitions
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_surveys_start_date ON surveys(start_date) WHERE status = 'draft';
+CREATE INDEX IF NOT EXISTS idx_surveys_end_date ON surveys(end_date) WHERE status = 'published';
+CREATE INDEX IF NOT EXISTS idx_survey_status_transitions_survey_id ON survey_status_transitions(survey_id);
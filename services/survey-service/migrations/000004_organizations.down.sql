@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_surveys_organization_id;
+
+-- Drop columns
+ALTER TABLE surveys DROP COLUMN IF EXISTS organization_id;
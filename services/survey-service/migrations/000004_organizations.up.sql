@@ -0,0 +1,5 @@
+-- Surveys may belong to an organization of the user service, whose members share them
+ALTER TABLE surveys ADD COLUMN IF NOT EXISTS organization_id UUID;
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_surveys_organization_id ON surveys(organization_id);
@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_survey_collaborators_user_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS survey_collaborators;
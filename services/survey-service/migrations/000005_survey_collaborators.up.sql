@@ -0,0 +1,14 @@
+-- Create survey_collaborators table to share single surveys with other users
+CREATE TABLE IF NOT EXISTS survey_collaborators (
+    survey_id UUID NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    role VARCHAR(20) NOT NULL,  -- editor, viewer
+    invited_by UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (survey_id, user_id),
+    CONSTRAINT chk_survey_collaborators_role CHECK (role IN ('editor', 'viewer'))
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_survey_collaborators_user_id ON survey_collaborators(user_id);
@@ -1,72 +0,0 @@
--- Create surveys table
-CREATE TABLE IF NOT EXISTS surveys (
-    id UUID PRIMARY KEY,
-    title VARCHAR(255) NOT NULL,
-    description TEXT NOT NULL,
-    user_id UUID NOT NULL,
-    status VARCHAR(50) NOT NULL,
-    start_date TIMESTAMP WITH TIME ZONE,
-    end_date TIMESTAMP WITH TIME ZONE,
-    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
-    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
-);
-
--- Create questions table
-CREATE TABLE IF NOT EXISTS questions (
-    id UUID PRIMARY KEY,
-    survey_id UUID NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
-    text TEXT NOT NULL,
-    type VARCHAR(50) NOT NULL,
-    required BOOLEAN NOT NULL DEFAULT FALSE,
-    question_order INT NOT NULL,
-    additional_settings TEXT,
-    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
-    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
-);
-
--- Create options table for multiple choice and single choice questions
-CREATE TABLE IF NOT EXISTS options (
-    id UUID PRIMARY KEY,
-    question_id UUID NOT NULL REFERENCES questions(id) ON DELETE CASCADE,
-    text TEXT NOT NULL,
-    option_order INT NOT NULL,
-    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
-    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
-);
-
--- Create responses table to store response sessions
-CREATE TABLE IF NOT EXISTS response_sessions (
-    id UUID PRIMARY KEY,
-    survey_id UUID NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
-    respondent_id UUID,  -- Can be NULL for anonymous responses
-    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
-    completed_at TIMESTAMP WITH TIME ZONE,
-    ip_address VARCHAR(45),
-    user_agent TEXT,
-    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
-    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
-);
-
--- Create responses table to store individual question responses
-CREATE TABLE IF NOT EXISTS responses (
-    id UUID PRIMARY KEY,
-    response_id UUID NOT NULL REFERENCES response_sessions(id) ON DELETE CASCADE,
-    survey_id UUID NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
-    question_id UUID NOT NULL REFERENCES questions(id) ON DELETE CASCADE,
-    option_id UUID REFERENCES options(id) ON DELETE CASCADE,  -- NULL for text answers
-    text_answer TEXT,  -- NULL for choice answers
-    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
-    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
-);
-
--- Create indexes
-CREATE INDEX IF NOT EXISTS idx_surveys_user_id ON surveys(user_id);
-CREATE INDEX IF NOT EXISTS idx_surveys_status ON surveys(status);
-CREATE INDEX IF NOT EXISTS idx_questions_survey_id ON questions(survey_id);
-CREATE INDEX IF NOT EXISTS idx_options_question_id ON options(question_id);
-CREATE INDEX IF NOT EXISTS idx_response_sessions_survey_id ON response_sessions(survey_id);
-CREATE INDEX IF NOT EXISTS idx_response_sessions_respondent_id ON response_sessions(respondent_id);
-CREATE INDEX IF NOT EXISTS idx_responses_response_id ON responses(response_id);
-CREATE INDEX IF NOT EXISTS idx_responses_survey_id ON responses(survey_id);
-CREATE INDEX IF NOT EXISTS idx_responses_question_id ON responses(question_id);
-CREATE INDEX IF NOT EXISTS idx_responses_option_id ON responses(option_id); 
\ No newline at end of file
@@ -0,0 +1,9 @@
+// Package migrations embeds the database migrations of the survey service
+package migrations
+
+import "embed"
+
+// FS holds the migration files
+//
+//go:embed *.sql
+var FS embed.FS
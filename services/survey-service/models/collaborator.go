@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/permissions"
+	"github.com/google/uuid"
+)
+
+// CollaboratorRole represents the access a collaborator has to a single survey
+type CollaboratorRole string
+
+// Collaborator roles
+const (
+	CollaboratorRoleEditor CollaboratorRole = "editor"
+	CollaboratorRoleViewer CollaboratorRole = "viewer"
+)
+
+// collaboratorPermissions lists the permissions each collaborator role grants on the survey
+var collaboratorPermissions = map[CollaboratorRole][]string{
+	CollaboratorRoleEditor: {permissions.SurveyView, permissions.SurveyEdit, permissions.SurveyPublish, permissions.ResultsRead, permissions.ResultsExport},
+	CollaboratorRoleViewer: {permissions.SurveyView, permissions.ResultsRead},
+}
+
+// IsValid reports whether the role is a known collaborator role
+func (r CollaboratorRole) IsValid() bool {
+	_, ok := collaboratorPermissions[r]
+	return ok
+}
+
+// Permissions returns the permissions the role grants on the survey
+func (r CollaboratorRole) Permissions() []string {
+	return append([]string(nil), collaboratorPermissions[r]...)
+}
+
+// Collaborator is a user a single survey is shared with
+type Collaborator struct {
+	SurveyID  uuid.UUID        `json:"survey_id" db:"survey_id"`
+	UserID    uuid.UUID        `json:"user_id" db:"user_id"`
+	Role      CollaboratorRole `json:"role" db:"role"`
+	InvitedBy *uuid.UUID       `json:"invited_by,omitempty" db:"invited_by"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// InviteCollaboratorRequest represents the request to share a survey with a user, or to
+// change the role of an existing collaborator
+type InviteCollaboratorRequest struct {
+	UserID uuid.UUID        `json:"user_id"`
+	Role   CollaboratorRole `json:"role"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +17,49 @@ const (
 	SurveyStatusClosed    SurveyStatus = "closed"
 )
 
+// surveyTransitions lists the statuses a survey may move to from each status
+var surveyTransitions = map[SurveyStatus][]SurveyStatus{
+	SurveyStatusDraft:     {SurveyStatusPublished},
+	SurveyStatusPublished: {SurveyStatusClosed},
+	SurveyStatusClosed:    {SurveyStatusPublished},
+}
+
+// IsValid reports whether the status is a known survey status
+func (s SurveyStatus) IsValid() bool {
+	_, ok := surveyTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a survey may move from this status to next
+func (s SurveyStatus) CanTransitionTo(next SurveyStatus) bool {
+	for _, allowed := range surveyTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionReason represents why the status of a survey changed
+type TransitionReason string
+
+// Transition reasons
+const (
+	TransitionReasonManual    TransitionReason = "manual"
+	TransitionReasonScheduled TransitionReason = "scheduled"
+)
+
+// SurveyStatusTransition records a change of the status of a survey
+type SurveyStatusTransition struct {
+	ID         uuid.UUID        `json:"id" db:"id"`
+	SurveyID   uuid.UUID        `json:"survey_id" db:"survey_id"`
+	FromStatus SurveyStatus     `json:"from_status" db:"from_status"`
+	ToStatus   SurveyStatus     `json:"to_status" db:"to_status"`
+	Reason     TransitionReason `json:"reason" db:"reason"`
+	ChangedBy  *uuid.UUID       `json:"changed_by,omitempty" db:"changed_by"` // NULL for scheduled transitions
+	CreatedAt  time.Time        `json:"created_at" db:"created_at"`
+}
+
 // QuestionType represents the type of a question
 type QuestionType string
 
@@ -30,14 +74,34 @@ const (
 
 // Survey represents a survey in the system
 type Survey struct {
-	ID          uuid.UUID  `json:"id" db:"id"`
-	Title       string     `json:"title" db:"title"`
-	Description string     `json:"description" db:"description"`
-	CreatedBy   uuid.UUID  `json:"created_by" db:"created_by"`
-	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
-	IsActive    bool       `json:"is_active" db:"is_active"`
-	Questions   []Question `json:"questions,omitempty" db:"-"`
+	ID          uuid.UUID    `json:"id" db:"id"`
+	Title       string       `json:"title" db:"title"`
+	Description string       `json:"description" db:"description"`
+	CreatedBy   uuid.UUID    `json:"created_by" db:"created_by"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
+	IsActive    bool         `json:"is_active" db:"is_active"`
+	Status      SurveyStatus `json:"status" db:"status"`
+	StartDate   *time.Time   `json:"start_date,omitempty" db:"start_date"` // Published automatically at this time
+	EndDate     *time.Time   `json:"end_date,omitempty" db:"end_date"`     // Closed automatically at this time
+	Questions   []Question   `json:"questions,omitempty" db:"-"`
+
+	// OrganizationID is the organization that shares the survey. Its members get access through
+	// their organization role; surveys without one are managed by their creator.
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty" db:"organization_id"`
+}
+
+// ValidateSchedule checks that the survey does not end before it starts
+func (s *Survey) ValidateSchedule() error {
+	if s.StartDate != nil && s.EndDate != nil && !s.EndDate.After(*s.StartDate) {
+		return errors.New("end_date must be after start_date")
+	}
+	return nil
+}
+
+// HasEnded reports whether the end date of the survey has passed
+func (s *Survey) HasEnded(now time.Time) bool {
+	return s.EndDate != nil && !s.EndDate.After(now)
 }
 
 // SurveyQuestion represents a question in a survey
@@ -90,6 +154,8 @@ type CreateSurveyRequest struct {
 	Title       string     `json:"title" validate:"required"`
 	Description string     `json:"description" validate:"required"`
 	Questions   []Question `json:"questions" validate:"required,min=1,dive"`
+	StartDate   *time.Time `json:"start_date,omitempty"`
+	EndDate     *time.Time `json:"end_date,omitempty"`
 }
 
 // UpdateSurveyRequest represents the request to update an existing survey
@@ -98,31 +164,65 @@ type UpdateSurveyRequest struct {
 	Description string     `json:"description" validate:"required"`
 	Questions   []Question `json:"questions" validate:"required,min=1,dive"`
 	IsActive    bool       `json:"is_active"`
+	StartDate   *time.Time `json:"start_date,omitempty"`
+	EndDate     *time.Time `json:"end_date,omitempty"`
+}
+
+// UpdateSurveyStatusRequest represents the request to change the status of a survey
+type UpdateSurveyStatusRequest struct {
+	Status SurveyStatus `json:"status"`
+}
+
+// TransferSurveyRequest represents the request to hand a survey over to another owner. The
+// survey moves to the organization, or becomes a personal survey of the owner without one.
+type TransferSurveyRequest struct {
+	OwnerID        uuid.UUID  `json:"owner_id"`
+	OrganizationID *uuid.UUID `json:"organization_id"`
+}
+
+// SurveyAccess lists the permissions of the current user on a survey
+type SurveyAccess struct {
+	SurveyID    uuid.UUID `json:"survey_id"`
+	Permissions []string  `json:"permissions"`
+}
+
+// Membership is the membership of a user in an organization as served by the user service
+type Membership struct {
+	OrganizationID uuid.UUID `json:"organization_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Role           string    `json:"role"`
+	Permissions    []string  `json:"permissions"`
 }
 
 // SurveyResponse represents the response to a survey request
 type SurveyResponse struct {
-	ID          string     `json:"id"`
-	Title       string     `json:"title"`
-	Description string     `json:"description"`
-	CreatedBy   string     `json:"created_by"`
-	IsActive    bool       `json:"is_active"`
-	Questions   []Question `json:"questions"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID          string       `json:"id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	CreatedBy   string       `json:"created_by"`
+	IsActive    bool         `json:"is_active"`
+	Status      SurveyStatus `json:"status"`
+	StartDate   *time.Time   `json:"start_date,omitempty"`
+	EndDate     *time.Time   `json:"end_date,omitempty"`
+	Questions   []Question   `json:"questions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
 }
 
 // SurveySummary represents a summary of a survey
 type SurveySummary struct {
-	ID            string    `json:"id"`
-	Title         string    `json:"title"`
-	Description   string    `json:"description"`
-	CreatedBy     string    `json:"created_by"`
-	IsActive      bool      `json:"is_active"`
-	QuestionCount int       `json:"question_count"`
-	ResponseCount int       `json:"response_count"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID            string       `json:"id"`
+	Title         string       `json:"title"`
+	Description   string       `json:"description"`
+	CreatedBy     string       `json:"created_by"`
+	IsActive      bool         `json:"is_active"`
+	Status        SurveyStatus `json:"status"`
+	StartDate     *time.Time   `json:"start_date,omitempty"`
+	EndDate       *time.Time   `json:"end_date,omitempty"`
+	QuestionCount int          `json:"question_count"`
+	ResponseCount int          `json:"response_count"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
 }
 
 // ToResponse converts a Survey to a SurveyResponse
@@ -133,6 +233,9 @@ func (s *Survey) ToResponse() SurveyResponse {
 		Description: s.Description,
 		CreatedBy:   s.CreatedBy.String(),
 		IsActive:    s.IsActive,
+		Status:      s.Status,
+		StartDate:   s.StartDate,
+		EndDate:     s.EndDate,
 		Questions:   s.Questions,
 		CreatedAt:   s.CreatedAt,
 		UpdatedAt:   s.UpdatedAt,
@@ -147,6 +250,9 @@ func (s *Survey) ToSummary(responseCount int) SurveySummary {
 		Description:   s.Description,
 		CreatedBy:     s.CreatedBy.String(),
 		IsActive:      s.IsActive,
+		Status:        s.Status,
+		StartDate:     s.StartDate,
+		EndDate:       s.EndDate,
 		QuestionCount: len(s.Questions),
 		ResponseCount: responseCount,
 		CreatedAt:     s.CreatedAt,
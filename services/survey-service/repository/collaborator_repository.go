@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// CollaboratorRepository handles database operations for survey collaborators
+type CollaboratorRepository struct {
+	db *sqlx.DB
+}
+
+// NewCollaboratorRepository creates a new collaborator repository
+func NewCollaboratorRepository(db *sqlx.DB) *CollaboratorRepository {
+	return &CollaboratorRepository{
+		db: db,
+	}
+}
+
+// SaveCollaborator shares a survey with a user, or changes the role of an existing
+// collaborator. The collaborator is updated with the stored row.
+func (r *CollaboratorRepository) SaveCollaborator(ctx context.Context, collaborator *models.Collaborator) error {
+	query := `
+		INSERT INTO survey_collaborators (survey_id, user_id, role, invited_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (survey_id, user_id) DO UPDATE
+		SET role = EXCLUDED.role, updated_at = EXCLUDED.updated_at
+		RETURNING survey_id, user_id, role, invited_by, created_at, updated_at
+	`
+
+	err := r.db.GetContext(ctx, collaborator, query, collaborator.SurveyID, collaborator.UserID, collaborator.Role, collaborator.InvitedBy, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save collaborator: %w", err)
+	}
+
+	return nil
+}
+
+// GetCollaborator retrieves the collaborator of a survey with the given user ID
+func (r *CollaboratorRepository) GetCollaborator(ctx context.Context, surveyID, userID uuid.UUID) (*models.Collaborator, error) {
+	query := `
+		SELECT survey_id, user_id, role, invited_by, created_at, updated_at
+		FROM survey_collaborators
+		WHERE survey_id = $1 AND user_id = $2
+	`
+
+	var collaborator models.Collaborator
+	err := r.db.GetContext(ctx, &collaborator, query, surveyID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get collaborator: %w", err)
+	}
+
+	return &collaborator, nil
+}
+
+// ListCollaborators lists the collaborators of a survey, oldest first
+func (r *CollaboratorRepository) ListCollaborators(ctx context.Context, surveyID uuid.UUID) ([]models.Collaborator, error) {
+	query := `
+		SELECT survey_id, user_id, role, invited_by, created_at, updated_at
+		FROM survey_collaborators
+		WHERE survey_id = $1
+		ORDER BY created_at, user_id
+	`
+
+	collaborators := []models.Collaborator{}
+	err := r.db.SelectContext(ctx, &collaborators, query, surveyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collaborators: %w", err)
+	}
+
+	return collaborators, nil
+}
+
+// RemoveCollaborator stops sharing a survey with a user
+func (r *CollaboratorRepository) RemoveCollaborator(ctx context.Context, surveyID, userID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM survey_collaborators WHERE survey_id = $1 AND user_id = $2", surveyID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove collaborator: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to remove collaborator: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
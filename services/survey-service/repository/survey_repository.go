@@ -14,8 +14,23 @@ import (
 
 var (
 	ErrNotFound = errors.New("not found")
+	// ErrNotDraft is returned when a change requires the survey to be a draft
+	ErrNotDraft = errors.New("survey is not a draft")
+	// ErrStatusChanged is returned when the status of a survey changed concurrently
+	ErrStatusChanged = errors.New("survey status changed")
 )
 
+// scheduleLockKey identifies the advisory lock that serializes applying survey schedules
+const scheduleLockKey = 7251946301
+
+// ScheduleResult describes the surveys changed by applying the schedule
+type ScheduleResult struct {
+	// Locked reports whether the schedule lock was acquired
+	Locked    bool
+	Published []uuid.UUID
+	Closed    []uuid.UUID
+}
+
 // SurveyRepository handles database operations for surveys
 type SurveyRepository struct {
 	db *sqlx.DB
@@ -54,8 +69,8 @@ func (r *SurveyRepository) CreateSurvey(ctx context.Context, survey *models.Surv
 
 	// Insert survey
 	query := `
-		INSERT INTO surveys (id, title, description, created_by, created_at, updated_at, is_active)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO surveys (id, title, description, created_by, created_at, updated_at, is_active, status, start_date, end_date, organization_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
 
 	_, err = tx.ExecContext(
@@ -68,6 +83,10 @@ func (r *SurveyRepository) CreateSurvey(ctx context.Context, survey *models.Surv
 		survey.CreatedAt,
 		survey.UpdatedAt,
 		survey.IsActive,
+		survey.Status,
+		survey.StartDate,
+		survey.EndDate,
+		survey.OrganizationID,
 	)
 
 	if err != nil {
@@ -157,7 +176,7 @@ func (r *SurveyRepository) CreateSurvey(ctx context.Context, survey *models.Surv
 func (r *SurveyRepository) GetSurvey(ctx context.Context, id uuid.UUID) (*models.Survey, error) {
 	// Get the survey
 	query := `
-		SELECT id, title, description, created_by, created_at, updated_at, is_active
+		SELECT id, title, description, created_by, created_at, updated_at, is_active, status, start_date, end_date, organization_id
 		FROM surveys
 		WHERE id = $1
 	`
@@ -210,12 +229,83 @@ func (r *SurveyRepository) GetSurvey(ctx context.Context, id uuid.UUID) (*models
 	return &survey, nil
 }
 
+// GetSurveysByOrganization retrieves the surveys of an organization
+func (r *SurveyRepository) GetSurveysByOrganization(ctx context.Context, orgID uuid.UUID, limit, offset int) ([]*models.Survey, error) {
+	query := `
+		SELECT id, title, description, created_by, created_at, updated_at, is_active, status, start_date, end_date, organization_id
+		FROM surveys
+		WHERE organization_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	var surveys []*models.Survey
+	err := r.db.SelectContext(ctx, &surveys, query, orgID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get surveys: %w", err)
+	}
+
+	// For each survey, get the number of questions
+	for i, survey := range surveys {
+		countQuery := `
+			SELECT COUNT(*) FROM survey_questions WHERE survey_id = $1
+		`
+
+		var count int
+		err = r.db.GetContext(ctx, &count, countQuery, survey.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get question count: %w", err)
+		}
+
+		// Create a slice with the capacity for the questions (they'll be loaded on demand)
+		surveys[i].Questions = make([]models.Question, 0, count)
+	}
+
+	return surveys, nil
+}
+
+// GetSurveysSharedWith retrieves the surveys shared with a user as a collaborator
+func (r *SurveyRepository) GetSurveysSharedWith(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.Survey, error) {
+	query := `
+		SELECT s.id, s.title, s.description, s.created_by, s.created_at, s.updated_at, s.is_active, s.status, s.start_date, s.end_date, s.organization_id
+		FROM surveys s
+		JOIN survey_collaborators c ON c.survey_id = s.id
+		WHERE c.user_id = $1
+		ORDER BY s.created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	var surveys []*models.Survey
+	err := r.db.SelectContext(ctx, &surveys, query, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get surveys: %w", err)
+	}
+
+	// For each survey, get the number of questions
+	for i, survey := range surveys {
+		countQuery := `
+			SELECT COUNT(*) FROM survey_questions WHERE survey_id = $1
+		`
+
+		var count int
+		err = r.db.GetContext(ctx, &count, countQuery, survey.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get question count: %w", err)
+		}
+
+		// Create a slice with the capacity for the questions (they'll be loaded on demand)
+		surveys[i].Questions = make([]models.Question, 0, count)
+	}
+
+	return surveys, nil
+}
+
 // GetSurveysByUserID retrieves all surveys created by a user
-func (r *SurveyRepository) GetSurveysByUserID(ctx context.Context, userID string, limit, offset int) ([]*models.Survey, error) {
+func (r *SurveyRepository) GetSurveysByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.Survey, error) {
 	query := `
-		SELECT id, title, description, user_id, status, start_date, end_date, created_at, updated_at
+		SELECT id, title, description, created_by, created_at, updated_at, is_active, status, start_date, end_date, organization_id
 		FROM surveys
-		WHERE user_id = $1
+		WHERE created_by = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
@@ -229,7 +319,7 @@ func (r *SurveyRepository) GetSurveysByUserID(ctx context.Context, userID string
 	// For each survey, get the number of questions
 	for i, survey := range surveys {
 		countQuery := `
-			SELECT COUNT(*) FROM questions WHERE survey_id = $1
+			SELECT COUNT(*) FROM survey_questions WHERE survey_id = $1
 		`
 
 		var count int
@@ -245,7 +335,8 @@ func (r *SurveyRepository) GetSurveysByUserID(ctx context.Context, userID string
 	return surveys, nil
 }
 
-// UpdateSurvey updates a survey
+// UpdateSurvey updates a draft survey including its questions. It returns ErrNotDraft when
+// the survey is no longer a draft.
 func (r *SurveyRepository) UpdateSurvey(ctx context.Context, survey *models.Survey) error {
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
@@ -265,24 +356,36 @@ func (r *SurveyRepository) UpdateSurvey(ctx context.Context, survey *models.Surv
 	// Update survey
 	query := `
 		UPDATE surveys
-		SET title = $1, description = $2, is_active = $3, updated_at = $4
-		WHERE id = $5
+		SET title = $1, description = $2, start_date = $3, end_date = $4, updated_at = $5
+		WHERE id = $6 AND status = $7
 	`
 
-	_, err = tx.ExecContext(
+	result, err := tx.ExecContext(
 		ctx,
 		query,
 		survey.Title,
 		survey.Description,
-		survey.IsActive,
+		survey.StartDate,
+		survey.EndDate,
 		survey.UpdatedAt,
 		survey.ID,
+		models.SurveyStatusDraft,
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to update survey: %w", err)
 	}
 
+	// Questions may only change while the survey is a draft
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update survey: %w", err)
+	}
+	if rows == 0 {
+		err = r.missingOrNotDraft(ctx, survey.ID)
+		return err
+	}
+
 	// Delete existing questions and options
 	_, err = tx.ExecContext(ctx, "DELETE FROM survey_questions WHERE survey_id = $1", survey.ID)
 	if err != nil {
@@ -395,26 +498,287 @@ func (r *SurveyRepository) DeleteSurvey(ctx context.Context, id uuid.UUID) error
 	return nil
 }
 
-// UpdateSurveyStatus updates a survey's status
-func (r *SurveyRepository) UpdateSurveyStatus(ctx context.Context, id string, status models.SurveyStatus) error {
+// UpdateSurveyDetails updates the title, description and schedule of a survey in any status
+func (r *SurveyRepository) UpdateSurveyDetails(ctx context.Context, survey *models.Survey) error {
+	survey.UpdatedAt = time.Now().UTC()
+
+	query := `
+		UPDATE surveys
+		SET title = $1, description = $2, start_date = $3, end_date = $4, updated_at = $5
+		WHERE id = $6
+	`
+
+	result, err := r.db.ExecContext(ctx, query, survey.Title, survey.Description, survey.StartDate, survey.EndDate, survey.UpdatedAt, survey.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update survey: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update survey: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// TransferSurvey hands a survey over to another owner and organization. A nil organization
+// makes it a personal survey of the owner. A new owner who collaborated on the survey stops
+// being a collaborator.
+func (r *SurveyRepository) TransferSurvey(ctx context.Context, id, ownerID uuid.UUID, orgID *uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE surveys
+		SET created_by = $1, organization_id = $2, updated_at = $3
+		WHERE id = $4
+	`
+
+	result, err := tx.ExecContext(ctx, query, ownerID, orgID, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to transfer survey: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to transfer survey: %w", err)
+	}
+	if rows == 0 {
+		err = ErrNotFound
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM survey_collaborators WHERE survey_id = $1 AND user_id = $2", id, ownerID)
+	if err != nil {
+		return fmt.Errorf("failed to remove collaborator: %w", err)
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateSurveyStatus moves a survey from one status to another and records the transition.
+// The survey is active while it is published. It returns ErrStatusChanged when the survey is
+// no longer in the from status.
+func (r *SurveyRepository) UpdateSurveyStatus(ctx context.Context, id uuid.UUID, from, to models.SurveyStatus, changedBy *uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now().UTC()
 	query := `
 		UPDATE surveys
-		SET status = $1, updated_at = $2
-		WHERE id = $3
+		SET status = $1, is_active = $2, updated_at = $3
+		WHERE id = $4 AND status = $5
 	`
 
-	_, err := r.db.ExecContext(ctx, query, status, time.Now().UTC(), id)
+	result, err := tx.ExecContext(ctx, query, to, to == models.SurveyStatusPublished, now, id, from)
+	if err != nil {
+		return fmt.Errorf("failed to update survey status: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to update survey status: %w", err)
 	}
+	if rows == 0 {
+		err = ErrStatusChanged
+		return err
+	}
+
+	err = insertTransition(ctx, tx, &models.SurveyStatusTransition{
+		SurveyID:   id,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     models.TransitionReasonManual,
+		ChangedBy:  changedBy,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ListStatusTransitions lists the status transitions of a survey, oldest first
+func (r *SurveyRepository) ListStatusTransitions(ctx context.Context, surveyID uuid.UUID) ([]models.SurveyStatusTransition, error) {
+	query := `
+		SELECT id, survey_id, from_status, to_status, reason, changed_by, created_at
+		FROM survey_status_transitions
+		WHERE survey_id = $1
+		ORDER BY created_at, id
+	`
+
+	var transitions []models.SurveyStatusTransition
+	err := r.db.SelectContext(ctx, &transitions, query, surveyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list status transitions: %w", err)
+	}
+
+	return transitions, nil
+}
+
+// ApplySchedule publishes draft surveys whose start date has come and closes published surveys
+// whose end date has passed, recording each transition. Drafts without questions or with an
+// end date that already passed are not published. Concurrent callers are serialized with an
+// advisory lock; a caller that does not get the lock returns without doing anything.
+func (r *SurveyRepository) ApplySchedule(ctx context.Context, now time.Time) (*ScheduleResult, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result := &ScheduleResult{}
+	err = tx.GetContext(ctx, &result.Locked, "SELECT pg_try_advisory_xact_lock($1)", scheduleLockKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire schedule lock: %w", err)
+	}
+	if !result.Locked {
+		err = tx.Rollback()
+		return result, err
+	}
+
+	publishQuery := `
+		UPDATE surveys s
+		SET status = $1, is_active = true, updated_at = $2
+		WHERE s.status = $3
+			AND s.start_date <= $2
+			AND (s.end_date IS NULL OR s.end_date > $2)
+			AND EXISTS (SELECT 1 FROM survey_questions q WHERE q.survey_id = s.id)
+		RETURNING s.id
+	`
+	err = tx.SelectContext(ctx, &result.Published, publishQuery, models.SurveyStatusPublished, now, models.SurveyStatusDraft)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish scheduled surveys: %w", err)
+	}
+
+	closeQuery := `
+		UPDATE surveys
+		SET status = $1, is_active = false, updated_at = $2
+		WHERE status = $3 AND end_date <= $2
+		RETURNING id
+	`
+	err = tx.SelectContext(ctx, &result.Closed, closeQuery, models.SurveyStatusClosed, now, models.SurveyStatusPublished)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close scheduled surveys: %w", err)
+	}
+
+	for _, id := range result.Published {
+		err = insertTransition(ctx, tx, &models.SurveyStatusTransition{
+			SurveyID:   id,
+			FromStatus: models.SurveyStatusDraft,
+			ToStatus:   models.SurveyStatusPublished,
+			Reason:     models.TransitionReasonScheduled,
+			CreatedAt:  now,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range result.Closed {
+		err = insertTransition(ctx, tx, &models.SurveyStatusTransition{
+			SurveyID:   id,
+			FromStatus: models.SurveyStatusPublished,
+			ToStatus:   models.SurveyStatusClosed,
+			Reason:     models.TransitionReasonScheduled,
+			CreatedAt:  now,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// insertTransition records a status transition within a transaction
+func insertTransition(ctx context.Context, tx *sqlx.Tx, transition *models.SurveyStatusTransition) error {
+	if transition.ID == uuid.Nil {
+		transition.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO survey_status_transitions (id, survey_id, from_status, to_status, reason, changed_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := tx.ExecContext(
+		ctx,
+		query,
+		transition.ID,
+		transition.SurveyID,
+		transition.FromStatus,
+		transition.ToStatus,
+		transition.Reason,
+		transition.ChangedBy,
+		transition.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record status transition: %w", err)
+	}
 
 	return nil
 }
 
+// missingOrNotDraft explains why a survey that was expected to be a draft was not updated
+func (r *SurveyRepository) missingOrNotDraft(ctx context.Context, id uuid.UUID) error {
+	var exists bool
+	err := r.db.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM surveys WHERE id = $1)", id)
+	if err != nil {
+		return fmt.Errorf("failed to check survey: %w", err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrNotDraft
+}
+
 // ListSurveys lists all surveys with pagination
 func (r *SurveyRepository) ListSurveys(ctx context.Context, offset, limit int) ([]*models.Survey, error) {
 	query := `
-		SELECT id, title, description, created_by, created_at, updated_at, is_active
+		SELECT id, title, description, created_by, created_at, updated_at, is_active, status, start_date, end_date, organization_id
 		FROM surveys
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
@@ -448,11 +812,11 @@ func (r *SurveyRepository) ListSurveys(ctx context.Context, offset, limit int) (
 // GetPublicSurveys gets all published surveys
 func (r *SurveyRepository) GetPublicSurveys(ctx context.Context, limit, offset int) ([]*models.Survey, error) {
 	query := `
-		SELECT id, title, description, user_id, status, start_date, end_date, created_at, updated_at
+		SELECT id, title, description, created_by, created_at, updated_at, is_active, status, start_date, end_date, organization_id
 		FROM surveys
 		WHERE status = $1
 		AND (start_date IS NULL OR start_date <= NOW())
-		AND (end_date IS NULL OR end_date >= NOW())
+		AND (end_date IS NULL OR end_date > NOW())
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
@@ -466,7 +830,7 @@ func (r *SurveyRepository) GetPublicSurveys(ctx context.Context, limit, offset i
 	// For each survey, get the number of questions
 	for i, survey := range surveys {
 		countQuery := `
-			SELECT COUNT(*) FROM questions WHERE survey_id = $1
+			SELECT COUNT(*) FROM survey_questions WHERE survey_id = $1
 		`
 
 		var count int
@@ -481,18 +845,3 @@ func (r *SurveyRepository) GetPublicSurveys(ctx context.Context, limit, offset i
 
 	return surveys, nil
 }
-
-// CountSurveyResponses counts the number of responses to a survey
-func (r *SurveyRepository) CountSurveyResponses(ctx context.Context, surveyID string) (int, error) {
-	query := `
-		SELECT COUNT(DISTINCT response_id) FROM responses WHERE survey_id = $1
-	`
-
-	var count int
-	err := r.db.GetContext(ctx, &count, query, surveyID)
-	if err != nil {
-		return 0, fmt.Errorf("failed to count survey responses: %w", err)
-	}
-
-	return count, nil
-}
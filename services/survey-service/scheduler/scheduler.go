@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/survey-service/repository"
+)
+
+// Scheduler publishes and closes surveys when their start and end dates come. Several
+// replicas may run a scheduler at the same time; the repository makes sure only one of them
+// applies the schedule at a time.
+type Scheduler struct {
+	repo     *repository.SurveyRepository
+	interval time.Duration
+	logger   *zap.Logger
+}
+
+// New creates a new scheduler that applies the schedule every interval
+func New(repo *repository.SurveyRepository, interval time.Duration, logger *zap.Logger) *Scheduler {
+	return &Scheduler{
+		repo:     repo,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run applies the schedule right away and then every interval until the context is canceled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.apply(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// apply applies the schedule once and logs the surveys it changed
+func (s *Scheduler) apply(ctx context.Context) {
+	result, err := s.repo.ApplySchedule(ctx, time.Now().UTC())
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error("Failed to apply survey schedule", zap.Error(err))
+		}
+		return
+	}
+
+	for _, id := range result.Published {
+		s.logger.Info("Published scheduled survey", zap.String("survey_id", id.String()))
+	}
+	for _, id := range result.Closed {
+		s.logger.Info("Closed scheduled survey", zap.String("survey_id", id.String()))
+	}
+}
@@ -0,0 +1,206 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/mailer"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/repository"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/token"
+	"github.com/google/uuid"
+)
+
+// Config configures the password reset and email verification flows
+type Config struct {
+	// AppURL is the URL of the frontend, which hosts the pages the emailed links point to
+	AppURL string
+	// ResetTokenTTL is how long a password reset token is valid
+	ResetTokenTTL time.Duration
+	// VerificationTokenTTL is how long an email verification token is valid
+	VerificationTokenTTL time.Duration
+}
+
+// Service sends password reset and email verification emails and redeems their tokens
+type Service struct {
+	users  *repository.UserRepository
+	tokens *repository.TokenRepository
+	auth   *token.Service
+	mailer mailer.Mailer
+	logger *logging.Logger
+	config Config
+}
+
+// NewService creates a new account service
+func NewService(users *repository.UserRepository, tokens *repository.TokenRepository, auth *token.Service, m mailer.Mailer, logger *logging.Logger, config Config) *Service {
+	return &Service{
+		users:  users,
+		tokens: tokens,
+		auth:   auth,
+		mailer: m,
+		logger: logger,
+		config: config,
+	}
+}
+
+// RequestPasswordReset emails a password reset link to the user with the given email. It
+// succeeds without sending anything when no user has the email, so that callers cannot find
+// out which emails are registered.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.users.GetUserByEmail(ctx, email)
+	if err != nil {
+		s.logger.Info("Password reset requested for unknown email")
+		return nil
+	}
+
+	secret, err := s.createToken(ctx, user, models.PurposePasswordReset, s.config.ResetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nWe received a request to reset your PollPulse password. Open the link below to choose a new one:\n\n%s\n\nThe link expires in %s. If you did not request a password reset, you can ignore this email.\n",
+		user.Username, s.link("/reset-password", secret), s.config.ResetTokenTTL,
+	)
+
+	return s.send(ctx, user.Email, "Reset your PollPulse password", body)
+}
+
+// ResetPassword sets a new password with a password reset token and logs the user out on all
+// devices. It returns the ID of the user whose password was reset.
+func (s *Service) ResetPassword(ctx context.Context, secret, newPassword string) (string, error) {
+	userToken, err := s.tokens.ConsumeUserToken(ctx, models.PurposePasswordReset, token.HashSecret(secret))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return "", errors.NewError(errors.ErrBadRequest, "invalid or expired token")
+		}
+		return "", err
+	}
+
+	hashedPassword, err := models.HashPassword(newPassword)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.users.UpdatePassword(ctx, userToken.UserID, hashedPassword); err != nil {
+		return "", err
+	}
+
+	return userToken.UserID, s.auth.LogoutEverywhere(ctx, userToken.UserID)
+}
+
+// SendVerification emails a link that verifies the current email of a user
+func (s *Service) SendVerification(ctx context.Context, user *models.User) error {
+	if user.EmailVerified() {
+		return errors.NewError(errors.ErrConflict, "email is already verified")
+	}
+
+	secret, err := s.createToken(ctx, user, models.PurposeEmailVerification, s.config.VerificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+		user.Username, s.link("/verify-email", secret), s.config.VerificationTokenTTL,
+	)
+
+	return s.send(ctx, user.Email, "Confirm your PollPulse email address", body)
+}
+
+// VerifyEmail verifies the email of a user with an email verification token. The token only
+// verifies the email it was sent to.
+func (s *Service) VerifyEmail(ctx context.Context, secret string) error {
+	userToken, err := s.tokens.ConsumeUserToken(ctx, models.PurposeEmailVerification, token.HashSecret(secret))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return errors.NewError(errors.ErrBadRequest, "invalid or expired token")
+		}
+		return err
+	}
+
+	if err := s.users.MarkEmailVerified(ctx, userToken.UserID, userToken.Email); err != nil {
+		if err == repository.ErrNotFound {
+			return errors.NewError(errors.ErrBadRequest, "email has changed since the token was sent")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// NotifyExistingAccount tells the owner of an email that someone tried to register with it.
+// Registration sends this instead of reporting that the email is taken, so that it does not
+// reveal which emails are registered.
+func (s *Service) NotifyExistingAccount(ctx context.Context, user *models.User) error {
+	body := fmt.Sprintf(
+		"Hi %s,\n\nSomeone tried to create a PollPulse account with this email address, which already belongs to your account. If that was you, log in instead or reset your password:\n\n%s\n\nIf it was not you, you can ignore this email.\n",
+		user.Username, s.page("/forgot-password"),
+	)
+
+	return s.send(ctx, user.Email, "Your PollPulse account", body)
+}
+
+// NotifyUsernameTaken tells someone who tried to register that the username they chose is
+// taken, without revealing it in the registration response
+func (s *Service) NotifyUsernameTaken(ctx context.Context, email, username string) error {
+	body := fmt.Sprintf(
+		"Hi,\n\nWe could not create your PollPulse account because the username %q is already taken. Please register again with a different username:\n\n%s\n",
+		username, s.page("/register"),
+	)
+
+	return s.send(ctx, email, "Your PollPulse registration", body)
+}
+
+// createToken stores a new token for a user and returns its secret
+func (s *Service) createToken(ctx context.Context, user *models.User, purpose string, ttl time.Duration) (string, error) {
+	secret, err := token.NewSecret()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	userToken := &models.UserToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: token.HashSecret(secret),
+		Email:     user.Email,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := s.tokens.CreateUserToken(ctx, userToken); err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// link returns the URL of a frontend page that receives a token
+func (s *Service) link(path, secret string) string {
+	return s.page(path) + "?token=" + url.QueryEscape(secret)
+}
+
+// page returns the URL of a frontend page
+func (s *Service) page(path string) string {
+	return strings.TrimSuffix(s.config.AppURL, "/") + path
+}
+
+// send sends a plain text email to a single recipient
+func (s *Service) send(ctx context.Context, to, subject, body string) error {
+	err := s.mailer.Send(ctx, &mailer.Message{
+		To:      []string{to},
+		Subject: subject,
+		Body:    body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
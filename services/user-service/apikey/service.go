@@ -0,0 +1,174 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/permissions"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/repository"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/token"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// keyPrefix starts every API key, so that keys are recognizable, for example by secret
+	// scanners
+	keyPrefix = "pp_"
+	// displayPrefixLength is the number of characters of a key that are stored to tell keys
+	// apart
+	displayPrefixLength = len(keyPrefix) + 8
+)
+
+// Service issues, lists and revokes the API keys of users and resolves keys to the claims of
+// their user. It implements middleware.APIKeyVerifier.
+type Service struct {
+	repo       *repository.APIKeyRepository
+	users      *repository.UserRepository
+	logger     *logging.Logger
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+}
+
+// NewService creates a new API key service. Keys expire after defaultTTL unless the user picks
+// an expiry, which may be at most maxTTL away.
+func NewService(repo *repository.APIKeyRepository, users *repository.UserRepository, logger *logging.Logger, defaultTTL, maxTTL time.Duration) *Service {
+	return &Service{
+		repo:       repo,
+		users:      users,
+		logger:     logger,
+		defaultTTL: defaultTTL,
+		maxTTL:     maxTTL,
+	}
+}
+
+// Create issues an API key for a user. The key itself is only returned here.
+func (s *Service) Create(ctx context.Context, userID string, req *models.CreateAPIKeyRequest) (*models.CreatedAPIKey, error) {
+	var scopes []string
+	for _, scope := range req.Scopes {
+		if !permissions.ValidScope(scope) {
+			return nil, errors.NewError(errors.ErrBadRequest, "unknown scope %q", scope)
+		}
+		if !contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(s.defaultTTL)
+	if req.ExpiresAt != nil {
+		expiresAt = req.ExpiresAt.UTC()
+	}
+	if !expiresAt.After(now) {
+		return nil, errors.NewError(errors.ErrBadRequest, "expiry must be in the future")
+	}
+	if expiresAt.After(now.Add(s.maxTTL)) {
+		return nil, errors.NewError(errors.ErrBadRequest, "expiry must be within %s", s.maxTTL)
+	}
+
+	secret, err := token.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	key := keyPrefix + secret
+
+	apiKey := &models.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    key[:displayPrefixLength],
+		KeyHash:   token.HashSecret(key),
+		Scopes:    pq.StringArray(scopes),
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+	if err := s.repo.CreateAPIKey(ctx, apiKey); err != nil {
+		return nil, err
+	}
+
+	return &models.CreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+// List lists the API keys of a user, including expired and revoked keys
+func (s *Service) List(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx, userID)
+}
+
+// Revoke revokes an API key of a user
+func (s *Service) Revoke(ctx context.Context, userID, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return errors.NewError(errors.ErrNotFound, "API key not found")
+	}
+
+	if err := s.repo.RevokeAPIKey(ctx, id, userID); err != nil {
+		if err == repository.ErrNotFound {
+			return errors.NewError(errors.ErrNotFound, "API key not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// VerifyAPIKey returns the claims of the user of an active API key. The permissions in the
+// claims are those the user currently has within the scopes of the key. It returns
+// middleware.ErrInvalidAPIKey for unknown, expired and revoked keys.
+func (s *Service) VerifyAPIKey(ctx context.Context, key string) (*middleware.UserClaims, error) {
+	if len(key) <= displayPrefixLength || key[:len(keyPrefix)] != keyPrefix {
+		return nil, middleware.ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.repo.GetAPIKeyByHash(ctx, token.HashSecret(key))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, middleware.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if !apiKey.Active(now) {
+		return nil, middleware.ErrInvalidAPIKey
+	}
+
+	user, err := s.users.GetUserByID(ctx, apiKey.UserID)
+	if err != nil {
+		if stderrors.Is(err, sql.ErrNoRows) {
+			return nil, middleware.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	if err := s.repo.TouchAPIKey(ctx, apiKey.ID, now); err != nil {
+		s.logger.Error("Failed to record API key use", "api_key_id", apiKey.ID, "error", err)
+	}
+
+	claims := middleware.NewUserClaims(user.ID, user.Username, user.Email, user.Roles, apiKey.ExpiresAt.Sub(now))
+	claims.APIKeyID = apiKey.ID
+	claims.Scopes = []string(apiKey.Scopes)
+	claims.Permissions = []string{}
+	for _, permission := range user.Permissions {
+		if contains(claims.Scopes, permission) {
+			claims.Permissions = append(claims.Permissions, permission)
+		}
+	}
+	claims.EmailVerified = user.EmailVerified()
+
+	return claims, nil
+}
+
+// contains reports whether a list of strings contains one
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
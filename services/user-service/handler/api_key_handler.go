@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateAPIKey issues an API key for the current user. The response holds the key, which is
+// not shown again.
+func (h *UserHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if !h.decodeValid(w, r, &req) {
+		return
+	}
+
+	key, err := h.apiKeys.Create(r.Context(), userClaims.UserID, &req)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to create API key")
+		return
+	}
+
+	// The key itself is never recorded
+	h.recorder.Record(r, audit.ActionAPIKeyCreate, "api_key", key.ID, nil, key.APIKey)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// ListAPIKeys lists the API keys of the current user
+func (h *UserHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	keys, err := h.apiKeys.List(r.Context(), userClaims.UserID)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to list API keys")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKey revokes an API key of the current user
+func (h *UserHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	keyID := chi.URLParam(r, "id")
+	if err := h.apiKeys.Revoke(r.Context(), userClaims.UserID, keyID); err != nil {
+		h.handleServiceError(w, err, "Failed to revoke API key")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionAPIKeyRevoke, "api_key", keyID, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyAPIKey resolves an API key to the claims of its user, so that other services can
+// accept API keys
+func (h *UserHandler) VerifyAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyAPIKeyRequest
+	if !h.decodeValid(w, r, &req) {
+		return
+	}
+
+	claims, err := h.apiKeys.VerifyAPIKey(r.Context(), req.Key)
+	if err != nil {
+		if stderrors.Is(err, middleware.ErrInvalidAPIKey) {
+			errors.HandleError(w, errors.ErrUnauthorized, "Invalid API key")
+			return
+		}
+		h.logger.Error("Failed to verify API key", "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claims)
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+)
+
+// ListAuditEvents lists the events of the audit log of all services, newest first. The query
+// filters by actor, action, target, service, request and time range, and the next_cursor of a
+// page continues with the next page.
+func (h *UserHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		ActorID:    query.Get("actor_id"),
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+		Service:    query.Get("service"),
+		RequestID:  query.Get("request_id"),
+		Cursor:     query.Get("cursor"),
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			errors.HandleError(w, errors.ErrBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	for name, value := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if s := query.Get(name); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				errors.HandleError(w, errors.ErrBadRequest, "Invalid "+name+" time, expected RFC 3339")
+				return
+			}
+			*value = t
+		}
+	}
+
+	page, err := h.audit.Query(r.Context(), filter)
+	if err != nil {
+		if stderrors.Is(err, audit.ErrInvalidCursor) {
+			errors.HandleError(w, errors.ErrBadRequest, "Invalid cursor")
+			return
+		}
+		h.logger.Error("Failed to query audit events", "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
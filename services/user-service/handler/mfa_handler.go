@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// LoginMFA completes a login with a TOTP or recovery code
+func (h *UserHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	pending, err := h.mfa.PendingUser(r.Context(), req.MFAToken)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to complete MFA login")
+		return
+	}
+
+	// Wrong codes count as failed logins, so that codes cannot be guessed
+	ip := commonhttp.ClientIP(r)
+	if h.rejectBlocked(w, r, pending.Username, ip) {
+		return
+	}
+
+	user, recoveryCodes, err := h.mfa.CompleteLogin(r.Context(), pending, req.MFAToken, req.Code, req.RecoveryCode)
+	if err != nil {
+		if stderrors.Is(err, errors.ErrUnauthorized) {
+			h.recordLoginFailure(r, pending.Username, ip, pending)
+		}
+		h.handleServiceError(w, err, "Failed to complete MFA login")
+		return
+	}
+	h.recordLoginSuccess(r, user.Username)
+
+	// Issue tokens
+	tokens, err := h.tokens.Issue(r.Context(), user)
+	if err != nil {
+		h.logger.Error("Failed to generate token", "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	response := models.MFALoginResponse{
+		LoginResponse: models.LoginResponse{
+			TokenPair: *tokens,
+			User:      *user,
+		},
+		RecoveryCodes: recoveryCodes,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// EnrollMFAForLogin starts the enrollment of a user who is required to use MFA during their
+// login
+func (h *UserHandler) EnrollMFAForLogin(w http.ResponseWriter, r *http.Request) {
+	var req models.MFAEnrollLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	enrollment, err := h.mfa.EnrollForLogin(r.Context(), req.MFAToken)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to enroll in MFA")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// EnrollMFA starts the enrollment of the current user
+func (h *UserHandler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.mfa.Enroll(r.Context(), user)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to enroll in MFA")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// ConfirmMFA enables MFA for the current user with a code of their authenticator app
+func (h *UserHandler) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	user, req, ok := h.decodeMFACodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.mfa.Confirm(r.Context(), user, req.Code)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to confirm MFA")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+// DisableMFA disables MFA for the current user
+func (h *UserHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	user, req, ok := h.decodeMFACodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.mfa.Disable(r.Context(), user, req.Code); err != nil {
+		h.handleServiceError(w, err, "Failed to disable MFA")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionUserMFADisable, "user", user.ID, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the current user
+func (h *UserHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, req, ok := h.decodeMFACodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.mfa.RegenerateRecoveryCodes(r.Context(), user, req.Code)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+// SetMFARequired sets whether a user has to use MFA
+func (h *UserHandler) SetMFARequired(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the URL
+	userID := chi.URLParam(r, "id")
+
+	var req models.MFARequiredRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.mfa.SetRequired(r.Context(), userID, req.Required); err != nil {
+		h.handleServiceError(w, err, "Failed to set MFA requirement")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionUserMFARequired, "user", userID, nil, req)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// currentUser loads the user of the request. If that fails it responds with an error and
+// returns false.
+func (h *UserHandler) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return nil, false
+	}
+
+	user, err := h.repo.GetUserByID(r.Context(), userClaims.UserID)
+	if err != nil {
+		errors.HandleError(w, errors.ErrNotFound, "User not found")
+		return nil, false
+	}
+
+	return user, true
+}
+
+// decodeMFACodeRequest loads the current user and decodes a request confirmed with a TOTP
+// code. If that fails it responds with an error and returns false.
+func (h *UserHandler) decodeMFACodeRequest(w http.ResponseWriter, r *http.Request) (*models.User, *models.MFACodeRequest, bool) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return nil, nil, false
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return nil, nil, false
+	}
+
+	return user, &req, true
+}
+
+// handleServiceError responds with the status of a known service error and logs any other
+// error
+func (h *UserHandler) handleServiceError(w http.ResponseWriter, err error, message string) {
+	for _, known := range []error{errors.ErrBadRequest, errors.ErrUnauthorized, errors.ErrForbidden, errors.ErrNotFound, errors.ErrConflict} {
+		if stderrors.Is(err, known) {
+			errors.HandleError(w, err, "")
+			return
+		}
+	}
+
+	h.logger.Error(message, "error", err)
+	errors.HandleError(w, errors.ErrInternalServer, "")
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListOIDCProviders lists the identity providers users can log in with
+func (h *UserHandler) ListOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.oidc.Providers())
+}
+
+// BeginOIDCLogin starts a login with an identity provider. The client sends the user to the
+// authorization URL and keeps the state to check the callback against.
+func (h *UserHandler) BeginOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	authorization, err := h.oidc.Begin(r.Context(), chi.URLParam(r, "provider"))
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to start identity provider login")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authorization)
+}
+
+// CompleteOIDCLogin completes a login with an identity provider. Users who enabled MFA are
+// asked for their second factor as with a password login.
+func (h *UserHandler) CompleteOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	var req models.OIDCCallbackRequest
+	if !h.decodeValid(w, r, &req) {
+		return
+	}
+
+	user, err := h.oidc.Complete(r.Context(), chi.URLParam(r, "provider"), req.Code, req.State)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to complete identity provider login")
+		return
+	}
+
+	if h.mfa.Required(user) {
+		h.respondMFAChallenge(w, r, user)
+		return
+	}
+
+	h.respondLogin(w, r, user)
+}
+
+// ListGroupMappings lists the roles granted to the members of identity provider groups,
+// optionally of a single provider
+func (h *UserHandler) ListGroupMappings(w http.ResponseWriter, r *http.Request) {
+	mappings, err := h.oidc.GroupMappings(r.Context(), r.URL.Query().Get("provider"))
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to list group mappings")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mappings)
+}
+
+// AddGroupMapping grants a role to the members of an identity provider group
+func (h *UserHandler) AddGroupMapping(w http.ResponseWriter, r *http.Request) {
+	var req models.OIDCGroupMappingRequest
+	if !h.decodeValid(w, r, &req) {
+		return
+	}
+
+	mapping, err := h.oidc.AddGroupMapping(r.Context(), &req)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to add group mapping")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionGroupMappingAdd, "group_mapping", mapping.ID, nil, mapping)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(mapping)
+}
+
+// RemoveGroupMapping stops granting a role to the members of an identity provider group
+func (h *UserHandler) RemoveGroupMapping(w http.ResponseWriter, r *http.Request) {
+	if err := h.oidc.RemoveGroupMapping(r.Context(), chi.URLParam(r, "id")); err != nil {
+		h.handleServiceError(w, err, "Failed to remove group mapping")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionGroupMappingRemove, "group_mapping", chi.URLParam(r, "id"), nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
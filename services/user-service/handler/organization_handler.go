@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateOrganization creates an organization owned by the current user
+func (h *UserHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	var req models.OrganizationRequest
+	if !h.decodeValid(w, r, &req) {
+		return
+	}
+
+	org, err := h.orgs.Create(r.Context(), userClaims.UserID, req.Name)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to create organization")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionOrgCreate, "organization", org.ID, nil, org)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(org)
+}
+
+// ListOrganizations lists the organizations of the current user
+func (h *UserHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	orgs, err := h.orgs.List(r.Context(), userClaims.UserID)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to list organizations")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orgs)
+}
+
+// GetOrganization gets an organization of the current user
+func (h *UserHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	org, err := h.orgs.Get(r.Context(), userClaims.UserID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to get organization")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(org)
+}
+
+// RenameOrganization changes the name of an organization
+func (h *UserHandler) RenameOrganization(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	var req models.OrganizationRequest
+	if !h.decodeValid(w, r, &req) {
+		return
+	}
+
+	org, err := h.orgs.Rename(r.Context(), userClaims.UserID, chi.URLParam(r, "id"), req.Name)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to rename organization")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionOrgRename, "organization", org.ID, nil, map[string]string{"name": org.Name})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(org)
+}
+
+// ListMembers lists the members of an organization
+func (h *UserHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	members, err := h.orgs.Members(r.Context(), userClaims.UserID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to list organization members")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// GetMember gets a member of an organization with the permissions their role grants. Other
+// services use it to resolve access to the surveys of the organization.
+func (h *UserHandler) GetMember(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	member, err := h.orgs.Member(r.Context(), userClaims.UserID, chi.URLParam(r, "id"), chi.URLParam(r, "userId"))
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to get organization member")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// AddMember adds a user to an organization
+func (h *UserHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	var req models.AddMemberRequest
+	if !h.decodeValid(w, r, &req) {
+		return
+	}
+
+	member, err := h.orgs.AddMember(r.Context(), userClaims.UserID, chi.URLParam(r, "id"), &req)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to add organization member")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionOrgMemberAdd, "organization", chi.URLParam(r, "id"), nil, member)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+// UpdateMember changes the role of a member of an organization
+func (h *UserHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	var req models.UpdateMemberRequest
+	if !h.decodeValid(w, r, &req) {
+		return
+	}
+
+	member, err := h.orgs.UpdateMember(r.Context(), userClaims.UserID, chi.URLParam(r, "id"), chi.URLParam(r, "userId"), req.Role)
+	if err != nil {
+		h.handleServiceError(w, err, "Failed to update organization member")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionOrgMemberUpdate, "organization", chi.URLParam(r, "id"), nil, member)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// RemoveMember removes a member from an organization
+func (h *UserHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	if err := h.orgs.RemoveMember(r.Context(), userClaims.UserID, chi.URLParam(r, "id"), chi.URLParam(r, "userId")); err != nil {
+		h.handleServiceError(w, err, "Failed to remove organization member")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionOrgMemberRemove, "organization", chi.URLParam(r, "id"), map[string]string{"user_id": chi.URLParam(r, "userId")}, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserProfile gets the public profile of a user, so that other users can be picked as
+// members or survey owners
+func (h *UserHandler) GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	user, err := h.repo.GetUserByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		errors.HandleError(w, errors.ErrNotFound, "User not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserProfile{
+		ID:       user.ID,
+		Username: user.Username,
+	})
+}
+
+// decodeValid decodes and validates a JSON request body. If that fails it responds with an
+// error and returns false.
+func (h *UserHandler) decodeValid(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return false
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return false
+	}
+
+	return true
+}
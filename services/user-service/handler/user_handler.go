@@ -2,55 +2,148 @@ package handler
 
 import (
 	"encoding/json"
+	stderrors "errors"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit"
 	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
 	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
 	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/permissions"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/account"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/apikey"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/lockout"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/mfa"
 	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/oidc"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/organization"
 	"github.com/VitaliySynytskyi/pollpulse/services/user-service/repository"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/token"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 // UserHandler handles HTTP requests for users
 type UserHandler struct {
-	repo      *repository.UserRepository
-	validate  *validator.Validate
-	logger    *logging.Logger
-	jwtSecret string
+	repo     *repository.UserRepository
+	tokens   *token.Service
+	accounts *account.Service
+	mfa      *mfa.Service
+	guard    *lockout.Guard
+	orgs     *organization.Service
+	apiKeys  *apikey.Service
+	oidc     *oidc.Service
+	audit    *audit.Store
+	recorder *audit.Recorder
+	validate *validator.Validate
+	logger   *logging.Logger
+	keys     middleware.KeySource
+	authOpts []middleware.AuthOption
 }
 
-// NewUserHandler creates a new user handler
-func NewUserHandler(repo *repository.UserRepository, logger *logging.Logger, jwtSecret string) *UserHandler {
+// NewUserHandler creates a new user handler. The auth options configure how the protected
+// routes authenticate requests besides tokens and API keys.
+func NewUserHandler(repo *repository.UserRepository, tokens *token.Service, accounts *account.Service, mfaService *mfa.Service, guard *lockout.Guard, orgs *organization.Service, apiKeys *apikey.Service, oidcService *oidc.Service, auditStore *audit.Store, recorder *audit.Recorder, logger *logging.Logger, keys middleware.KeySource, authOpts ...middleware.AuthOption) *UserHandler {
 	return &UserHandler{
-		repo:      repo,
-		validate:  validator.New(),
-		logger:    logger,
-		jwtSecret: jwtSecret,
+		repo:     repo,
+		tokens:   tokens,
+		accounts: accounts,
+		mfa:      mfaService,
+		guard:    guard,
+		orgs:     orgs,
+		apiKeys:  apiKeys,
+		oidc:     oidcService,
+		audit:    auditStore,
+		recorder: recorder,
+		validate: validator.New(),
+		logger:   logger,
+		keys:     keys,
+		authOpts: authOpts,
 	}
 }
 
+// errTooManyLoginAttempts is returned while logins are blocked after failed attempts
+var errTooManyLoginAttempts = stderrors.New("too many failed login attempts")
+
+// registrationMessage is the response to every valid registration request
+const registrationMessage = "Registration received. Check your email to continue."
+
 // RegisterRoutes registers the routes for the user handler
 func (h *UserHandler) RegisterRoutes(r chi.Router) {
 	r.Post("/register", h.RegisterUser)
 	r.Post("/login", h.Login)
+	r.Post("/login/mfa", h.LoginMFA)
+	r.Post("/login/mfa/enroll", h.EnrollMFAForLogin)
+	r.Post("/refresh", h.Refresh)
+	r.Post("/password/forgot", h.ForgotPassword)
+	r.Post("/password/reset", h.ResetPassword)
+	r.Post("/email/verify", h.VerifyEmail)
+
+	manageUsers := middleware.RequirePermission(permissions.UserManage)
+	manageRoles := middleware.RequirePermission(permissions.RoleManage)
+	// Assigning a role grants its permissions, so it takes both
+	assignRoles := middleware.RequirePermission(permissions.UserManage, permissions.RoleManage)
+
+	r.Post("/api-keys/verify", h.VerifyAPIKey)
+	r.Post("/tokens/revoked", h.CheckTokenRevocation)
+	r.Get("/oidc/providers", h.ListOIDCProviders)
+	r.Post("/oidc/providers/{provider}/authorize", h.BeginOIDCLogin)
+	r.Post("/oidc/providers/{provider}/callback", h.CompleteOIDCLogin)
 
 	// Protected routes
 	r.Group(func(r chi.Router) {
-		r.Use(middleware.Auth(h.jwtSecret))
-		r.Get("/users", h.ListUsers)
+		opts := append([]middleware.AuthOption{middleware.WithRevocationChecker(h.tokens), middleware.WithAPIKeyVerifier(h.apiKeys)}, h.authOpts...)
+		r.Use(middleware.Auth(h.keys, opts...))
+
+		// The account itself is only managed with access tokens, not with API keys
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireAccessToken)
+			r.Post("/logout", h.Logout)
+			r.Post("/logout/all", h.LogoutEverywhere)
+			r.Post("/email/verification", h.ResendVerification)
+			r.Post("/mfa/enroll", h.EnrollMFA)
+			r.Post("/mfa/confirm", h.ConfirmMFA)
+			r.Post("/mfa/disable", h.DisableMFA)
+			r.Post("/mfa/recovery-codes", h.RegenerateRecoveryCodes)
+			r.Put("/users/{id}", h.UpdateUser)
+			r.Put("/users/me/password", h.UpdatePassword)
+			r.Post("/api-keys", h.CreateAPIKey)
+			r.Get("/api-keys", h.ListAPIKeys)
+			r.Delete("/api-keys/{id}", h.RevokeAPIKey)
+		})
+
+		r.With(manageUsers).Get("/users", h.ListUsers)
 		r.Get("/users/{id}", h.GetUser)
-		r.Put("/users/{id}", h.UpdateUser)
-		r.Delete("/users/{id}", h.DeleteUser)
+		r.With(manageUsers).Delete("/users/{id}", h.DeleteUser)
 		r.Get("/users/me", h.GetCurrentUser)
-		r.Put("/users/me/password", h.UpdatePassword)
-		r.Post("/users/{id}/roles", h.AddRole)
-		r.Delete("/users/{id}/roles/{role}", h.RemoveRole)
-		r.Get("/roles", h.GetRoles)
-		r.Post("/roles", h.CreateRole)
+		r.With(assignRoles).Post("/users/{id}/roles", h.AddRole)
+		r.With(assignRoles).Delete("/users/{id}/roles/{role}", h.RemoveRole)
+		r.With(manageUsers).Put("/users/{id}/mfa", h.SetMFARequired)
+		r.With(manageUsers).Post("/users/{id}/unlock", h.UnlockUser)
+		r.With(manageUsers).Get("/users/{id}/lockout-events", h.ListLockoutEvents)
+		r.With(manageRoles).Get("/roles", h.GetRoles)
+		r.With(manageRoles).Post("/roles", h.CreateRole)
+		r.With(manageRoles).Put("/roles/{name}/permissions", h.SetRolePermissions)
+		r.With(manageRoles).Get("/permissions", h.GetPermissions)
+		r.With(manageRoles).Get("/oidc/group-mappings", h.ListGroupMappings)
+		r.With(assignRoles).Post("/oidc/group-mappings", h.AddGroupMapping)
+		r.With(assignRoles).Delete("/oidc/group-mappings/{id}", h.RemoveGroupMapping)
+		r.With(middleware.RequirePermission(permissions.AuditRead)).Get("/audit/events", h.ListAuditEvents)
+		r.Get("/users/{id}/profile", h.GetUserProfile)
+		r.Post("/organizations", h.CreateOrganization)
+		r.Get("/organizations", h.ListOrganizations)
+		r.Get("/organizations/{id}", h.GetOrganization)
+		r.Put("/organizations/{id}", h.RenameOrganization)
+		r.Get("/organizations/{id}/members", h.ListMembers)
+		r.Post("/organizations/{id}/members", h.AddMember)
+		r.Get("/organizations/{id}/members/{userId}", h.GetMember)
+		r.Put("/organizations/{id}/members/{userId}", h.UpdateMember)
+		r.Delete("/organizations/{id}/members/{userId}", h.RemoveMember)
 	})
 }
 
@@ -68,24 +161,31 @@ func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if user exists
-	existingUser, _ := h.repo.GetUserByUsername(r.Context(), req.Username)
-	if existingUser != nil {
-		errors.HandleError(w, errors.ErrBadRequest, "Username already exists")
+	// Hash the password first, so that the response takes as long whether or not the
+	// username or email is already registered
+	hashedPassword, err := models.HashPassword(req.Password)
+	if err != nil {
+		h.logger.Error("Failed to hash password", "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
 		return
 	}
 
+	// Tell the owner of an existing account by email instead of in the response
 	existingEmail, _ := h.repo.GetUserByEmail(r.Context(), req.Email)
 	if existingEmail != nil {
-		errors.HandleError(w, errors.ErrBadRequest, "Email already exists")
+		if err := h.accounts.NotifyExistingAccount(r.Context(), existingEmail); err != nil {
+			h.logger.Error("Failed to notify existing account", "user_id", existingEmail.ID, "error", err)
+		}
+		h.respondRegistration(w)
 		return
 	}
 
-	// Hash the password
-	hashedPassword, err := models.HashPassword(req.Password)
-	if err != nil {
-		h.logger.Error("Failed to hash password", "error", err)
-		errors.HandleError(w, errors.ErrInternalServer, "")
+	existingUser, _ := h.repo.GetUserByUsername(r.Context(), req.Username)
+	if existingUser != nil {
+		if err := h.accounts.NotifyUsernameTaken(r.Context(), req.Email, req.Username); err != nil {
+			h.logger.Error("Failed to notify about taken username", "error", err)
+		}
+		h.respondRegistration(w)
 		return
 	}
 
@@ -110,43 +210,137 @@ func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		// Continue anyway, user was created
 	}
 
-	// Add any additional roles
-	for _, role := range req.Roles {
-		if err := h.repo.AddRole(r.Context(), user.ID, role); err != nil {
-			h.logger.Error("Failed to add role", "role", role, "error", err)
-			// Continue anyway
-		}
+	// Ask the user to verify their email
+	if err := h.accounts.SendVerification(r.Context(), user); err != nil {
+		h.logger.Error("Failed to send verification email", "user_id", user.ID, "error", err)
+		// Continue anyway, the user can request another email
+	}
+
+	h.respondRegistration(w)
+}
+
+// respondRegistration responds to a registration without revealing whether an account was
+// created
+func (h *UserHandler) respondRegistration(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(models.RegistrationResponse{Message: registrationMessage})
+}
+
+// Login logs in a user
+func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	// Reject the login while the account or client IP is locked out
+	ip := commonhttp.ClientIP(r)
+	if h.rejectBlocked(w, r, req.Username, ip) {
+		return
+	}
+
+	// Get the user
+	user, err := h.repo.GetUserByUsername(r.Context(), req.Username)
+	if err != nil {
+		models.CheckPasswordTiming(req.Password)
+		h.recordLoginFailure(r, req.Username, ip, nil)
+		errors.HandleError(w, errors.ErrUnauthorized, "Invalid username or password")
+		return
+	}
+
+	// Check the password
+	if !models.CheckPasswordHash(req.Password, user.Password) {
+		h.recordLoginFailure(r, req.Username, ip, user)
+		errors.HandleError(w, errors.ErrUnauthorized, "Invalid username or password")
+		return
+	}
+
+	// Ask for the second factor instead of issuing tokens
+	if h.mfa.Required(user) {
+		h.respondMFAChallenge(w, r, user)
+		return
 	}
+	h.recordLoginSuccess(r, user.Username)
 
-	// Generate JWT token
-	token, err := middleware.GenerateJWT(user.ID, user.Username, user.Email, []string{"user"}, h.jwtSecret, 24*time.Hour)
+	h.respondLogin(w, r, user)
+}
+
+// respondMFAChallenge responds to a login that needs a second factor with the token that
+// completes it
+func (h *UserHandler) respondMFAChallenge(w http.ResponseWriter, r *http.Request, user *models.User) {
+	challenge, err := h.mfa.Challenge(r.Context(), user)
 	if err != nil {
-		h.logger.Error("Failed to generate token", "error", err)
+		h.logger.Error("Failed to start MFA login", "error", err)
 		errors.HandleError(w, errors.ErrInternalServer, "")
 		return
 	}
 
-	// Get user with roles
-	user, err = h.repo.GetUserByID(r.Context(), user.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(challenge)
+}
+
+// respondLogin issues tokens for a user who logged in and responds with them
+func (h *UserHandler) respondLogin(w http.ResponseWriter, r *http.Request, user *models.User) {
+	tokens, err := h.tokens.Issue(r.Context(), user)
 	if err != nil {
-		h.logger.Error("Failed to get user with roles", "error", err)
+		h.logger.Error("Failed to generate token", "error", err)
 		errors.HandleError(w, errors.ErrInternalServer, "")
 		return
 	}
 
 	response := models.LoginResponse{
-		Token: token,
-		User:  *user,
+		TokenPair: *tokens,
+		User:      *user,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
 }
 
-// Login logs in a user
-func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var req models.LoginRequest
+// rejectBlocked responds with 429 Too Many Requests and returns true while logins for a
+// username from a client IP are blocked
+func (h *UserHandler) rejectBlocked(w http.ResponseWriter, r *http.Request, username, ip string) bool {
+	remaining, err := h.guard.Check(r.Context(), username, ip)
+	if err != nil {
+		h.logger.Error("Failed to check login throttle", "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return true
+	}
+	if remaining == 0 {
+		return false
+	}
+
+	seconds := int((remaining + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	errors.WriteError(w, errTooManyLoginAttempts, http.StatusTooManyRequests, "Try again later")
+	return true
+}
+
+// recordLoginFailure counts a failed login. Failing to count it does not fail the request.
+func (h *UserHandler) recordLoginFailure(r *http.Request, username, ip string, user *models.User) {
+	if err := h.guard.RecordFailure(r.Context(), username, ip, user); err != nil {
+		h.logger.Error("Failed to record login failure", "error", err)
+	}
+}
+
+// recordLoginSuccess clears the failed logins of a user
+func (h *UserHandler) recordLoginSuccess(r *http.Request, username string) {
+	if err := h.guard.RecordSuccess(r.Context(), username); err != nil {
+		h.logger.Error("Failed to reset login throttle", "error", err)
+	}
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token
+func (h *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
 		return
@@ -158,44 +352,257 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the user
-	user, err := h.repo.GetUserByUsername(r.Context(), req.Username)
+	tokens, user, err := h.tokens.Refresh(r.Context(), req.RefreshToken)
 	if err != nil {
-		errors.HandleError(w, errors.ErrUnauthorized, "Invalid username or password")
+		if stderrors.Is(err, errors.ErrUnauthorized) {
+			errors.HandleError(w, errors.ErrUnauthorized, "Invalid refresh token")
+			return
+		}
+		h.logger.Error("Failed to refresh token", "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
 		return
 	}
 
-	// Check the password
-	if !models.CheckPasswordHash(req.Password, user.Password) {
-		errors.HandleError(w, errors.ErrUnauthorized, "Invalid username or password")
+	response := models.LoginResponse{
+		TokenPair: *tokens,
+		User:      *user,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Logout revokes the access token of the request and the refresh token of the same session
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	// The refresh token is optional, so an empty body is allowed
+	var req models.LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.tokens.Logout(r.Context(), userClaims, req.RefreshToken); err != nil {
+		h.logger.Error("Failed to log out", "user_id", userClaims.UserID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
 		return
 	}
 
-	// Generate JWT token
-	token, err := middleware.GenerateJWT(user.ID, user.Username, user.Email, user.Roles, h.jwtSecret, 24*time.Hour)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutEverywhere revokes every token of the current user on all devices
+func (h *UserHandler) LogoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
 	if err != nil {
-		h.logger.Error("Failed to generate token", "error", err)
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	if err := h.tokens.LogoutEverywhere(r.Context(), userClaims.UserID); err != nil {
+		h.logger.Error("Failed to log out everywhere", "user_id", userClaims.UserID, "error", err)
 		errors.HandleError(w, errors.ErrInternalServer, "")
 		return
 	}
 
-	response := models.LoginResponse{
-		Token: token,
-		User:  *user,
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CheckTokenRevocation reports whether an access token was revoked, so that other services
+// reject tokens after logout
+func (h *UserHandler) CheckTokenRevocation(w http.ResponseWriter, r *http.Request) {
+	var req models.RevocationCheckRequest
+	if !h.decodeValid(w, r, &req) {
+		return
+	}
+
+	claims := &middleware.UserClaims{UserID: req.UserID}
+	claims.ID = req.TokenID
+	if req.IssuedAt != 0 {
+		claims.IssuedAt = jwt.NewNumericDate(time.Unix(req.IssuedAt, 0))
+	}
+
+	revoked, err := h.tokens.IsRevoked(r.Context(), claims)
+	if err != nil {
+		h.logger.Error("Failed to check token revocation", "user_id", req.UserID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(models.RevocationCheckResponse{Revoked: revoked})
 }
 
-// ListUsers lists all users
-func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	// Check if user has admin role
-	if !middleware.CheckRole(r.Context(), "admin") {
-		errors.HandleError(w, errors.ErrForbidden, "")
+// ForgotPassword emails a password reset link. It responds the same whether or not the email
+// is registered.
+func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
 		return
 	}
 
+	if err := h.accounts.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		h.logger.Error("Failed to request password reset", "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password with a password reset token
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	userID, err := h.accounts.ResetPassword(r.Context(), req.Token, req.NewPassword)
+	if err != nil {
+		if stderrors.Is(err, errors.ErrBadRequest) {
+			errors.HandleError(w, errors.ErrBadRequest, "Invalid or expired token")
+			return
+		}
+		h.logger.Error("Failed to reset password", "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionUserPasswordReset, "user", userID, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail verifies an email with an email verification token
+func (h *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	if err := h.accounts.VerifyEmail(r.Context(), req.Token); err != nil {
+		if stderrors.Is(err, errors.ErrBadRequest) {
+			errors.HandleError(w, errors.ErrBadRequest, "Invalid or expired token")
+			return
+		}
+		h.logger.Error("Failed to verify email", "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification emails a new verification link to the current user
+func (h *UserHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userClaims, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	user, err := h.repo.GetUserByID(r.Context(), userClaims.UserID)
+	if err != nil {
+		errors.HandleError(w, errors.ErrNotFound, "User not found")
+		return
+	}
+
+	if err := h.accounts.SendVerification(r.Context(), user); err != nil {
+		if stderrors.Is(err, errors.ErrConflict) {
+			errors.HandleError(w, errors.ErrConflict, "Email is already verified")
+			return
+		}
+		h.logger.Error("Failed to send verification email", "user_id", user.ID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// UnlockUser clears the failed logins of a user who is locked out
+func (h *UserHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		errors.HandleError(w, errors.ErrUnauthorized, "")
+		return
+	}
+
+	// Get the user
+	user, err := h.repo.GetUserByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		errors.HandleError(w, errors.ErrNotFound, "User not found")
+		return
+	}
+
+	if err := h.guard.Unlock(r.Context(), user, currentUser.UserID); err != nil {
+		h.logger.Error("Failed to unlock user", "user_id", user.ID, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionUserUnlock, "user", user.ID, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListLockoutEvents lists the lockouts and unlocks of a user
+func (h *UserHandler) ListLockoutEvents(w http.ResponseWriter, r *http.Request) {
+	// Parse pagination parameters
+	limit := 50
+	offset := 0
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if limitVal, err := strconv.Atoi(limitStr); err == nil {
+			limit = limitVal
+		}
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if offsetVal, err := strconv.Atoi(offsetStr); err == nil {
+			offset = offsetVal
+		}
+	}
+
+	events, err := h.guard.ListEvents(r.Context(), chi.URLParam(r, "id"), limit, offset)
+	if err != nil {
+		h.logger.Error("Failed to list lockout events", "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// ListUsers lists all users
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	// Parse pagination parameters
 	limit := 10
 	offset := 0
@@ -242,8 +649,8 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	// Get the user ID from the URL
 	userID := chi.URLParam(r, "id")
 
-	// Check if user has admin role or is the same user
-	if !middleware.CheckRole(r.Context(), "admin") && currentUser.UserID != userID {
+	// Check if user can manage users or is the same user
+	if !middleware.HasPermission(r.Context(), permissions.UserManage) && currentUser.UserID != userID {
 		errors.HandleError(w, errors.ErrForbidden, "")
 		return
 	}
@@ -291,8 +698,8 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	// Get the user ID from the URL
 	userID := chi.URLParam(r, "id")
 
-	// Check if user has admin role or is the same user
-	if !middleware.CheckRole(r.Context(), "admin") && currentUser.UserID != userID {
+	// Check if user can manage users or is the same user
+	if !middleware.HasPermission(r.Context(), permissions.UserManage) && currentUser.UserID != userID {
 		errors.HandleError(w, errors.ErrForbidden, "")
 		return
 	}
@@ -343,6 +750,8 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update the user
+	before := user.ToResponse()
+	emailChanged := user.Email != req.Email
 	user.Username = req.Username
 	user.Email = req.Email
 	user.FirstName = req.FirstName
@@ -354,6 +763,15 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.recorder.Record(r, audit.ActionUserUpdate, "user", user.ID, before, user.ToResponse())
+
+	// A new email has to be verified again
+	if emailChanged {
+		if err := h.accounts.SendVerification(r.Context(), user); err != nil {
+			h.logger.Error("Failed to send verification email", "user_id", user.ID, "error", err)
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user.ToResponse())
 }
@@ -412,38 +830,34 @@ func (h *UserHandler) UpdatePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.recorder.Record(r, audit.ActionUserPasswordChange, "user", user.ID, nil, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // DeleteUser deletes a user
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
-	// Check if user has admin role
-	if !middleware.CheckRole(r.Context(), "admin") {
-		errors.HandleError(w, errors.ErrForbidden, "")
+	// Get the user, which the audit log keeps
+	user, err := h.repo.GetUserByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		errors.HandleError(w, errors.ErrNotFound, "User not found")
 		return
 	}
 
-	// Get the user ID from the URL
-	userID := chi.URLParam(r, "id")
-
 	// Delete the user
-	if err := h.repo.DeleteUser(r.Context(), userID); err != nil {
+	if err := h.repo.DeleteUser(r.Context(), user.ID); err != nil {
 		h.logger.Error("Failed to delete user", "error", err)
 		errors.HandleError(w, errors.ErrInternalServer, "")
 		return
 	}
 
+	h.recorder.Record(r, audit.ActionUserDelete, "user", user.ID, user.ToResponse(), nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // AddRole adds a role to a user
 func (h *UserHandler) AddRole(w http.ResponseWriter, r *http.Request) {
-	// Check if user has admin role
-	if !middleware.CheckRole(r.Context(), "admin") {
-		errors.HandleError(w, errors.ErrForbidden, "")
-		return
-	}
-
 	// Get the user ID from the URL
 	userID := chi.URLParam(r, "id")
 
@@ -469,17 +883,13 @@ func (h *UserHandler) AddRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.recorder.Record(r, audit.ActionUserRoleAdd, "user", userID, nil, map[string]string{"role": req.Role})
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // RemoveRole removes a role from a user
 func (h *UserHandler) RemoveRole(w http.ResponseWriter, r *http.Request) {
-	// Check if user has admin role
-	if !middleware.CheckRole(r.Context(), "admin") {
-		errors.HandleError(w, errors.ErrForbidden, "")
-		return
-	}
-
 	// Get the user ID and role from the URL
 	userID := chi.URLParam(r, "id")
 	role := chi.URLParam(r, "role")
@@ -490,17 +900,13 @@ func (h *UserHandler) RemoveRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.recorder.Record(r, audit.ActionUserRoleRemove, "user", userID, map[string]string{"role": role}, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // GetRoles gets all roles
 func (h *UserHandler) GetRoles(w http.ResponseWriter, r *http.Request) {
-	// Check if user has admin role
-	if !middleware.CheckRole(r.Context(), "admin") {
-		errors.HandleError(w, errors.ErrForbidden, "")
-		return
-	}
-
 	// Get all roles
 	roles, err := h.repo.GetRoles(r.Context())
 	if err != nil {
@@ -515,12 +921,6 @@ func (h *UserHandler) GetRoles(w http.ResponseWriter, r *http.Request) {
 
 // CreateRole creates a new role
 func (h *UserHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
-	// Check if user has admin role
-	if !middleware.CheckRole(r.Context(), "admin") {
-		errors.HandleError(w, errors.ErrForbidden, "")
-		return
-	}
-
 	// Parse the request body
 	var req struct {
 		Name        string `json:"name" validate:"required"`
@@ -550,7 +950,62 @@ func (h *UserHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.recorder.Record(r, audit.ActionRoleCreate, "role", role.ID, nil, role)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(role)
 }
+
+// GetPermissions gets all permissions
+func (h *UserHandler) GetPermissions(w http.ResponseWriter, r *http.Request) {
+	perms, err := h.repo.GetPermissions(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to get permissions", "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(perms)
+}
+
+// SetRolePermissions replaces the permissions of a role. Users get the new permissions with
+// their next access token.
+func (h *UserHandler) SetRolePermissions(w http.ResponseWriter, r *http.Request) {
+	roleName := chi.URLParam(r, "name")
+
+	var req models.RolePermissionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, "Invalid request body")
+		return
+	}
+
+	// Validate the request
+	if err := h.validate.Struct(req); err != nil {
+		errors.HandleError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	for _, permission := range req.Permissions {
+		if !permissions.Valid(permission) {
+			errors.HandleError(w, errors.ErrBadRequest, "Unknown permission: "+permission)
+			return
+		}
+	}
+
+	previous, err := h.repo.SetRolePermissions(r.Context(), roleName, req.Permissions)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			errors.HandleError(w, errors.ErrNotFound, "Role not found")
+			return
+		}
+		h.logger.Error("Failed to set role permissions", "role", roleName, "error", err)
+		errors.HandleError(w, errors.ErrInternalServer, "")
+		return
+	}
+
+	h.recorder.Record(r, audit.ActionRolePermissions, "role", roleName,
+		map[string][]string{"permissions": previous}, map[string][]string{"permissions": req.Permissions})
+
+	w.WriteHeader(http.StatusNoContent)
+}
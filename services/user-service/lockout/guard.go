@@ -0,0 +1,197 @@
+package lockout
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/repository"
+	"github.com/google/uuid"
+)
+
+// Policy decides how long logins are blocked after a number of failures. The first
+// FreeAttempts failures are not penalized. Each further failure blocks logins for BaseDelay,
+// doubling with every failure, until LockoutThreshold failures lock logins out for
+// LockoutDuration, again doubling with every failure. No block lasts longer than MaxLockout.
+type Policy struct {
+	FreeAttempts     int
+	LockoutThreshold int
+	BaseDelay        time.Duration
+	LockoutDuration  time.Duration
+	MaxLockout       time.Duration
+}
+
+// delay returns how long logins are blocked after the given number of failures and whether
+// that is a lockout rather than a backoff
+func (p Policy) delay(failures int) (time.Duration, bool) {
+	switch {
+	case failures >= p.LockoutThreshold:
+		return backoff(p.LockoutDuration, failures-p.LockoutThreshold, p.MaxLockout), true
+	case failures > p.FreeAttempts:
+		return backoff(p.BaseDelay, failures-p.FreeAttempts-1, p.MaxLockout), false
+	default:
+		return 0, false
+	}
+}
+
+// backoff doubles base the given number of times without exceeding max
+func backoff(base time.Duration, doublings int, max time.Duration) time.Duration {
+	delay := base
+	for i := 0; i < doublings && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// Config configures login throttling
+type Config struct {
+	// Account limits failed logins per username, whether or not the account exists
+	Account Policy
+	// IP limits failed logins per client IP across all usernames
+	IP Policy
+	// ResetAfter is how long after the last failure counting starts over
+	ResetAfter time.Duration
+}
+
+// Guard throttles failed logins per account and per client IP. Its counters are kept in
+// Postgres so that they apply across all replicas.
+type Guard struct {
+	repo   *repository.LockoutRepository
+	logger *logging.Logger
+	config Config
+}
+
+// NewGuard creates a new login guard
+func NewGuard(repo *repository.LockoutRepository, logger *logging.Logger, config Config) *Guard {
+	return &Guard{
+		repo:   repo,
+		logger: logger,
+		config: config,
+	}
+}
+
+// Check returns how long logins for a username from a client IP are still blocked, or zero
+// if they are allowed
+func (g *Guard) Check(ctx context.Context, username, ip string) (time.Duration, error) {
+	blockedUntil, err := g.repo.BlockedUntil(ctx, normalize(username), ip)
+	if err != nil {
+		return 0, err
+	}
+
+	if remaining := time.Until(blockedUntil); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+// RecordFailure counts a failed login for a username from a client IP and blocks further
+// logins as the policies require. The user is nil when no account has the username.
+func (g *Guard) RecordFailure(ctx context.Context, username, ip string, user *models.User) error {
+	var userID *string
+	if user != nil {
+		userID = &user.ID
+	}
+
+	if err := g.recordFailure(ctx, models.ThrottleScopeAccount, normalize(username), userID, g.config.Account); err != nil {
+		return err
+	}
+	return g.recordFailure(ctx, models.ThrottleScopeIP, ip, nil, g.config.IP)
+}
+
+// RecordSuccess clears the failed logins of a username after a successful login. The
+// counter of the client IP is kept, so that logging in to one account does not allow
+// guessing the passwords of others.
+func (g *Guard) RecordSuccess(ctx context.Context, username string) error {
+	_, err := g.repo.Reset(ctx, models.ThrottleScopeAccount, normalize(username))
+	return err
+}
+
+// Unlock clears the failed logins of an account on behalf of an admin
+func (g *Guard) Unlock(ctx context.Context, user *models.User, actorID string) error {
+	subject := normalize(user.Username)
+	if _, err := g.repo.Reset(ctx, models.ThrottleScopeAccount, subject); err != nil {
+		return err
+	}
+
+	g.logger.Info("Account unlocked", "user_id", user.ID, "actor_id", actorID)
+	return g.repo.CreateEvent(ctx, &models.LockoutEvent{
+		ID:        uuid.New().String(),
+		Scope:     models.ThrottleScopeAccount,
+		Subject:   subject,
+		UserID:    &user.ID,
+		Event:     models.LockoutEventUnlocked,
+		ActorID:   &actorID,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// ListEvents lists the lockout events of a user
+func (g *Guard) ListEvents(ctx context.Context, userID string, limit, offset int) ([]*models.LockoutEvent, error) {
+	return g.repo.ListEvents(ctx, userID, limit, offset)
+}
+
+// RunCleanup deletes stale counters every interval until the context is canceled
+func (g *Guard) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now().UTC()
+			deleted, err := g.repo.DeleteStale(ctx, now.Add(-g.config.ResetAfter), now)
+			if err != nil && ctx.Err() == nil {
+				g.logger.Error("Failed to delete stale login throttles", "error", err)
+			}
+			if deleted > 0 {
+				g.logger.Info("Deleted stale login throttles", "count", deleted)
+			}
+		}
+	}
+}
+
+// recordFailure counts a failure of one scope and blocks it as the policy requires
+func (g *Guard) recordFailure(ctx context.Context, scope, subject string, userID *string, policy Policy) error {
+	now := time.Now().UTC()
+	failures, err := g.repo.RecordFailure(ctx, scope, subject, now, now.Add(-g.config.ResetAfter))
+	if err != nil {
+		return err
+	}
+
+	delay, locked := policy.delay(failures)
+	if delay == 0 {
+		return nil
+	}
+
+	blockedUntil := now.Add(delay)
+	if err := g.repo.Block(ctx, scope, subject, blockedUntil); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+
+	g.logger.Warn("Login locked out", "scope", scope, "subject", subject, "failures", failures, "blocked_until", blockedUntil)
+	return g.repo.CreateEvent(ctx, &models.LockoutEvent{
+		ID:           uuid.New().String(),
+		Scope:        scope,
+		Subject:      subject,
+		UserID:       userID,
+		Event:        models.LockoutEventLocked,
+		Failures:     failures,
+		BlockedUntil: &blockedUntil,
+		CreatedAt:    now,
+	})
+}
+
+// normalize makes usernames that only differ in case share a counter
+func normalize(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
@@ -0,0 +1,83 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	policy := Policy{
+		FreeAttempts:     3,
+		LockoutThreshold: 6,
+		BaseDelay:        time.Second,
+		LockoutDuration:  15 * time.Minute,
+		MaxLockout:       time.Hour,
+	}
+
+	tests := []struct {
+		failures int
+		delay    time.Duration
+		locked   bool
+	}{
+		{0, 0, false},
+		{3, 0, false},
+		{4, time.Second, false},
+		{5, 2 * time.Second, false},
+		{6, 15 * time.Minute, true},
+		{7, 30 * time.Minute, true},
+		{8, time.Hour, true},
+		{9, time.Hour, true},
+		{1000, time.Hour, true},
+	}
+
+	for _, tt := range tests {
+		delay, locked := policy.delay(tt.failures)
+		if delay != tt.delay || locked != tt.locked {
+			t.Errorf("delay(%d) = %v, %v, want %v, %v", tt.failures, delay, locked, tt.delay, tt.locked)
+		}
+	}
+}
+
+func TestPolicyBackoffIsCapped(t *testing.T) {
+	// The backoff before the lockout is capped as well
+	policy := Policy{FreeAttempts: 0, LockoutThreshold: 100, BaseDelay: time.Minute, LockoutDuration: time.Hour, MaxLockout: 5 * time.Minute}
+
+	tests := []struct {
+		failures int
+		delay    time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 5 * time.Minute},
+		{99, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if delay, locked := policy.delay(tt.failures); delay != tt.delay || locked {
+			t.Errorf("delay(%d) = %v, %v, want %v, false", tt.failures, delay, locked, tt.delay)
+		}
+	}
+
+	// A lockout longer than the cap is cut to it
+	if delay, locked := policy.delay(100); delay != 5*time.Minute || !locked {
+		t.Errorf("delay(100) = %v, %v, want 5m, true", delay, locked)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		username string
+		want     string
+	}{
+		{"alice", "alice"},
+		{"Alice", "alice"},
+		{"  ALICE\t", "alice"},
+	}
+
+	for _, tt := range tests {
+		if got := normalize(tt.username); got != tt.want {
+			t.Errorf("normalize(%q) = %q, want %q", tt.username, got, tt.want)
+		}
+	}
+}
@@ -6,14 +6,28 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/audit"
 	"github.com/VitaliySynytskyi/pollpulse/pkg/common/config"
 	"github.com/VitaliySynytskyi/pollpulse/pkg/common/database"
+	commonhttp "github.com/VitaliySynytskyi/pollpulse/pkg/common/http"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/jwks"
 	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/mailer"
+	authmw "github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/account"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/apikey"
 	"github.com/VitaliySynytskyi/pollpulse/services/user-service/handler"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/lockout"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/mfa"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/migrations"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/oidc"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/organization"
 	"github.com/VitaliySynytskyi/pollpulse/services/user-service/repository"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/token"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -45,15 +59,143 @@ func main() {
 	}
 	defer database.Close(db)
 
-	// Create repository
+	// Run the migrate subcommand or apply pending migrations
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		logger.Fatal("Failed to load migrations", "error", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := database.RunMigrateCommand(context.Background(), migrator, os.Args[2:], os.Stdout); err != nil {
+			logger.Fatal("Failed to run migrations", "error", err)
+		}
+		return
+	}
+	if config.GetEnvBool("MIGRATE_ON_START", true) {
+		migrated, err := migrator.Up(context.Background())
+		if err != nil {
+			logger.Fatal("Failed to apply migrations", "error", err)
+		}
+		for _, migration := range migrated {
+			logger.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+		}
+	}
+
+	// Connect to the audit database, which the services share
+	auditDB, err := database.Connect(&database.Config{
+		Host:     config.GetEnv("AUDIT_DB_HOST", dbConfig.Host),
+		Port:     config.GetEnvInt("AUDIT_DB_PORT", dbConfig.Port),
+		User:     config.GetEnv("AUDIT_DB_USER", dbConfig.User),
+		Password: config.GetEnv("AUDIT_DB_PASSWORD", dbConfig.Password),
+		DBName:   config.GetEnv("AUDIT_DB_NAME", "pollpulse_audit"),
+		SSLMode:  config.GetEnv("AUDIT_DB_SSLMODE", dbConfig.SSLMode),
+	})
+	if err != nil {
+		logger.Fatal("Failed to connect to audit database", "error", err)
+	}
+	defer database.Close(auditDB)
+
+	auditStore := audit.NewStore(auditDB)
+	if config.GetEnvBool("MIGRATE_ON_START", true) {
+		if err := auditStore.Migrate(context.Background()); err != nil {
+			logger.Fatal("Failed to apply audit migrations", "error", err)
+		}
+	}
+	auditRecorder := audit.NewRecorder(auditStore, "user-service", func(err error) {
+		logger.Error("Failed to record audit event", "error", err)
+	})
+
+	// Create repositories
 	userRepo := repository.NewUserRepository(db)
+	keys, err := loadSigningKeys(logger)
+	if err != nil {
+		logger.Fatal("Failed to load signing keys", "error", err)
+	}
+	tokenRepo := repository.NewTokenRepository(db)
+	tokenService := token.NewService(
+		tokenRepo,
+		userRepo,
+		logger,
+		keys,
+		config.GetEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		config.GetEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+	)
+
+	accountService := account.NewService(userRepo, tokenRepo, tokenService, newMailer(logger), logger, account.Config{
+		AppURL:               config.GetEnv("APP_URL", "http://localhost:3000"),
+		ResetTokenTTL:        config.GetEnvDuration("PASSWORD_RESET_TOKEN_TTL", time.Hour),
+		VerificationTokenTTL: config.GetEnvDuration("EMAIL_VERIFICATION_TOKEN_TTL", 48*time.Hour),
+	})
+
+	mfaService := mfa.NewService(
+		repository.NewMFARepository(db),
+		userRepo,
+		tokenRepo,
+		config.GetEnv("MFA_ISSUER", "PollPulse"),
+		config.GetEnvDuration("MFA_LOGIN_TOKEN_TTL", 5*time.Minute),
+		config.GetEnvSlice("MFA_REQUIRED_ROLES", ",", nil),
+	)
+
+	guard := lockout.NewGuard(repository.NewLockoutRepository(db), logger, lockout.Config{
+		Account: lockout.Policy{
+			FreeAttempts:     config.GetEnvInt("LOGIN_FREE_ATTEMPTS", 3),
+			LockoutThreshold: config.GetEnvInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+			BaseDelay:        config.GetEnvDuration("LOGIN_BACKOFF_BASE", time.Second),
+			LockoutDuration:  config.GetEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+			MaxLockout:       config.GetEnvDuration("LOGIN_MAX_LOCKOUT", 24*time.Hour),
+		},
+		IP: lockout.Policy{
+			FreeAttempts:     config.GetEnvInt("LOGIN_IP_FREE_ATTEMPTS", 20),
+			LockoutThreshold: config.GetEnvInt("LOGIN_IP_LOCKOUT_THRESHOLD", 100),
+			BaseDelay:        config.GetEnvDuration("LOGIN_BACKOFF_BASE", time.Second),
+			LockoutDuration:  config.GetEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+			MaxLockout:       config.GetEnvDuration("LOGIN_MAX_LOCKOUT", 24*time.Hour),
+		},
+		ResetAfter: config.GetEnvDuration("LOGIN_FAILURE_RESET_AFTER", 24*time.Hour),
+	})
+
+	orgService := organization.NewService(repository.NewOrganizationRepository(db), userRepo)
+
+	apiKeyService := apikey.NewService(
+		repository.NewAPIKeyRepository(db),
+		userRepo,
+		logger,
+		config.GetEnvDuration("API_KEY_DEFAULT_TTL", 90*24*time.Hour),
+		config.GetEnvDuration("API_KEY_MAX_TTL", 365*24*time.Hour),
+	)
+
+	providers, err := loadOIDCProviders()
+	if err != nil {
+		logger.Fatal("Failed to configure identity providers", "error", err)
+	}
+	oidcService := oidc.NewService(
+		providers,
+		repository.NewIdentityRepository(db),
+		userRepo,
+		logger,
+		config.GetEnvDuration("OIDC_LOGIN_TTL", 10*time.Minute),
+	)
+
+	// Delete expired tokens, stale login counters and abandoned logins in the background
+	cleanupCtx, stopCleanup := context.WithCancel(context.Background())
+	defer stopCleanup()
+	cleanupInterval := config.GetEnvDuration("TOKEN_CLEANUP_INTERVAL", time.Hour)
+	go tokenService.RunCleanup(cleanupCtx, cleanupInterval)
+	go guard.RunCleanup(cleanupCtx, cleanupInterval)
+	go oidcService.RunCleanup(cleanupCtx, cleanupInterval)
+
+	// Only take the address of clients from the API gateway and the proxies it runs behind
+	trustedProxies, err := commonhttp.ParseTrustedProxies(config.GetEnvSlice("TRUSTED_PROXIES", ",", nil))
+	if err != nil {
+		logger.Fatal("Invalid trusted proxies", "error", err)
+	}
 
 	// Initialize router
 	r := chi.NewRouter()
 
 	// Middleware
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	// Take the address of clients from the API gateway
+	r.Use(commonhttp.GatewayIP(trustedProxies))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
@@ -61,7 +203,7 @@ func main() {
 	// CORS configuration
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
@@ -69,14 +211,18 @@ func main() {
 	}))
 
 	// Create handler
-	jwtSecret := config.GetEnv("JWT_SECRET", "dev_secret_key")
-	userHandler := handler.NewUserHandler(userRepo, logger, jwtSecret)
+	userHandler := handler.NewUserHandler(userRepo, tokenService, accountService, mfaService, guard, orgService, apiKeyService, oidcService, auditStore, auditRecorder, logger, keys,
+		authmw.WithGatewaySecret([]byte(config.GetEnv("GATEWAY_IDENTITY_SECRET", ""))),
+	)
 
 	// Register routes
 	r.Route("/api/v1", func(r chi.Router) {
 		userHandler.RegisterRoutes(r)
 	})
 
+	// Publish the public keys that verify tokens
+	r.Handle(jwks.Path, keys)
+
 	// Health check endpoint
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -127,3 +273,77 @@ func main() {
 
 	logger.Info("Server stopped")
 }
+
+// loadSigningKeys loads the keys that sign tokens from JWT_KEYS_DIR. Without a directory it
+// generates a key that only lives as long as the process, which is enough for development
+// with a single instance.
+func loadSigningKeys(logger *logging.Logger) (*jwks.KeySet, error) {
+	dir := config.GetEnv("JWT_KEYS_DIR", "")
+	if dir != "" {
+		return jwks.LoadKeySet(dir, config.GetEnv("JWT_KEY_ID", ""))
+	}
+
+	logger.Warn("JWT_KEYS_DIR is not set, signing tokens with a temporary key")
+	key, err := jwks.GenerateKey("dev-" + time.Now().UTC().Format("20060102150405"))
+	if err != nil {
+		return nil, err
+	}
+	return jwks.NewKeySet([]*jwks.Key{key}, key.ID)
+}
+
+// loadOIDCProviders configures the identity providers listed in OIDC_PROVIDERS. Each provider
+// is configured by the variables OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET,
+// _REDIRECT_URL, _DISPLAY_NAME, _SCOPES, _GROUPS_CLAIM, _AUTO_PROVISION, _LINK_BY_EMAIL and
+// _AUTHORIZATION_URL, where NAME is the upper case provider name with dashes replaced by
+// underscores.
+func loadOIDCProviders() ([]*oidc.Provider, error) {
+	var providers []*oidc.Provider
+	for _, name := range config.GetEnvSlice("OIDC_PROVIDERS", ",", nil) {
+		name = strings.TrimSpace(name)
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		provider, err := oidc.NewProvider(oidc.ProviderConfig{
+			Name:             name,
+			DisplayName:      config.GetEnv(prefix+"DISPLAY_NAME", ""),
+			Issuer:           config.GetEnv(prefix+"ISSUER", ""),
+			AuthorizationURL: config.GetEnv(prefix+"AUTHORIZATION_URL", ""),
+			ClientID:         config.GetEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret:     config.GetEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:      config.GetEnv(prefix+"REDIRECT_URL", ""),
+			Scopes:           config.GetEnvSlice(prefix+"SCOPES", " ", nil),
+			GroupsClaim:      config.GetEnv(prefix+"GROUPS_CLAIM", ""),
+			AutoProvision:    config.GetEnvBool(prefix+"AUTO_PROVISION", false),
+			LinkByEmail:      config.GetEnvBool(prefix+"LINK_BY_EMAIL", true),
+		})
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+// newMailer creates the mailer selected by MAILER: smtp sends emails, file writes them to
+// MAIL_DIR and log, the default, only logs them
+func newMailer(logger *logging.Logger) mailer.Mailer {
+	from := config.GetEnv("MAIL_FROM", "PollPulse <no-reply@pollpulse.local>")
+
+	switch mailerType := config.GetEnv("MAILER", "log"); mailerType {
+	case "smtp":
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     config.GetEnv("SMTP_HOST", "localhost"),
+			Port:     config.GetEnvInt("SMTP_PORT", 587),
+			Username: config.GetEnv("SMTP_USERNAME", ""),
+			Password: config.GetEnv("SMTP_PASSWORD", ""),
+			From:     from,
+		})
+	case "file":
+		return mailer.NewFileMailer(config.GetEnv("MAIL_DIR", "mail"), from)
+	case "log":
+		return mailer.NewLogMailer(logger)
+	default:
+		logger.Fatal("Unknown mailer", "mailer", mailerType)
+		return nil
+	}
+}
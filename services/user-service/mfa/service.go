@@ -0,0 +1,314 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/repository"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/token"
+	"github.com/google/uuid"
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes a user gets
+	recoveryCodeCount = 10
+	// recoveryCodeBytes is the number of random bytes in a recovery code
+	recoveryCodeBytes = 5
+)
+
+// Service enrolls users in TOTP two-factor authentication and verifies their second factor
+// during the login
+type Service struct {
+	repo     *repository.MFARepository
+	users    *repository.UserRepository
+	tokens   *repository.TokenRepository
+	issuer   string
+	loginTTL time.Duration
+	// requiredRoles are the roles whose users have to use MFA
+	requiredRoles []string
+}
+
+// NewService creates a new MFA service. Authenticator apps show the issuer next to the code,
+// and the token of a login waiting for the second factor is valid for loginTTL. Users with one
+// of the required roles have to use MFA, as do users an admin required it for.
+func NewService(repo *repository.MFARepository, users *repository.UserRepository, tokens *repository.TokenRepository, issuer string, loginTTL time.Duration, requiredRoles []string) *Service {
+	return &Service{
+		repo:          repo,
+		users:         users,
+		tokens:        tokens,
+		issuer:        issuer,
+		loginTTL:      loginTTL,
+		requiredRoles: requiredRoles,
+	}
+}
+
+// Required reports whether a user has to provide a second factor to log in
+func (s *Service) Required(user *models.User) bool {
+	return user.MFAEnabled() || s.mandatory(user)
+}
+
+// Enroll generates a new TOTP secret for a user. MFA is enabled once the user confirms a code.
+func (s *Service) Enroll(ctx context.Context, user *models.User) (*models.MFAEnrollment, error) {
+	if user.MFAEnabled() {
+		return nil, errors.NewError(errors.ErrConflict, "MFA is already enabled")
+	}
+
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetPendingSecret(ctx, user.ID, secret); err != nil {
+		if err == repository.ErrNotFound {
+			return nil, errors.NewError(errors.ErrConflict, "MFA is already enabled")
+		}
+		return nil, err
+	}
+
+	return &models.MFAEnrollment{
+		Secret: secret,
+		URI:    URI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm enables MFA for a user who enrolled, given a code of their authenticator app, and
+// returns their recovery codes
+func (s *Service) Confirm(ctx context.Context, user *models.User, code string) ([]string, error) {
+	if user.MFAEnabled() {
+		return nil, errors.NewError(errors.ErrConflict, "MFA is already enabled")
+	}
+	if user.MFASecret == nil {
+		return nil, errors.NewError(errors.ErrBadRequest, "MFA enrollment has not been started")
+	}
+
+	step, ok := Validate(*user.MFASecret, code, time.Now())
+	if !ok {
+		return nil, errors.NewError(errors.ErrBadRequest, "invalid code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Enable(ctx, user.ID, step, hashes); err != nil {
+		if err == repository.ErrNotFound {
+			return nil, errors.NewError(errors.ErrConflict, "MFA is already enabled")
+		}
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable disables MFA for a user, given a TOTP or recovery code. Users who are required to
+// use MFA cannot disable it.
+func (s *Service) Disable(ctx context.Context, user *models.User, code string) error {
+	if s.mandatory(user) {
+		return errors.NewError(errors.ErrForbidden, "MFA is required for this account")
+	}
+	if !user.MFAEnabled() {
+		return errors.NewError(errors.ErrBadRequest, "MFA is not enabled")
+	}
+
+	if err := s.verify(ctx, user, code, code); err != nil {
+		return err
+	}
+
+	return s.repo.Disable(ctx, user.ID)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user, given a TOTP code
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, user *models.User, code string) ([]string, error) {
+	if !user.MFAEnabled() {
+		return nil, errors.NewError(errors.ErrBadRequest, "MFA is not enabled")
+	}
+
+	if err := s.verify(ctx, user, code, ""); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// SetRequired sets whether a user has to use MFA
+func (s *Service) SetRequired(ctx context.Context, userID string, required bool) error {
+	if err := s.repo.SetRequired(ctx, userID, required); err != nil {
+		if err == repository.ErrNotFound {
+			return errors.NewError(errors.ErrNotFound, "user not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Challenge starts a login that waits for the second factor of a user whose password was
+// checked
+func (s *Service) Challenge(ctx context.Context, user *models.User) (*models.MFAChallenge, error) {
+	secret, err := token.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	userToken := &models.UserToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Purpose:   models.PurposeMFALogin,
+		TokenHash: token.HashSecret(secret),
+		Email:     user.Email,
+		ExpiresAt: now.Add(s.loginTTL),
+		CreatedAt: now,
+	}
+	if err := s.tokens.CreateUserToken(ctx, userToken); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAChallenge{
+		MFARequired:        true,
+		EnrollmentRequired: !user.MFAEnabled(),
+		MFAToken:           secret,
+		ExpiresAt:          userToken.ExpiresAt,
+	}, nil
+}
+
+// EnrollForLogin enrolls a user who is required to use MFA but has not enrolled yet, given the
+// token of their pending login
+func (s *Service) EnrollForLogin(ctx context.Context, mfaToken string) (*models.MFAEnrollment, error) {
+	user, err := s.PendingUser(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Enroll(ctx, user)
+}
+
+// CompleteLogin checks the second factor of the pending login of a user, as returned by
+// PendingUser, and returns the user who logged in. A user who enrolled during the login
+// confirms the enrollment with their first code and also gets their recovery codes.
+func (s *Service) CompleteLogin(ctx context.Context, user *models.User, mfaToken, code, recoveryCode string) (*models.User, []string, error) {
+	var (
+		recoveryCodes []string
+		err           error
+	)
+	if user.MFAEnabled() {
+		if err := s.verify(ctx, user, code, recoveryCode); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		recoveryCodes, err = s.Confirm(ctx, user, code)
+		if err != nil {
+			if stderrors.Is(err, errors.ErrBadRequest) {
+				return nil, nil, errors.NewError(errors.ErrUnauthorized, "invalid code")
+			}
+			return nil, nil, err
+		}
+	}
+
+	// The token is only used up once the second factor is correct, so a mistyped code can be
+	// retried while the token is valid and the login guard allows it
+	if _, err := s.tokens.ConsumeUserToken(ctx, models.PurposeMFALogin, token.HashSecret(mfaToken)); err != nil {
+		if err == repository.ErrNotFound {
+			return nil, nil, errors.NewError(errors.ErrUnauthorized, "invalid or expired MFA token")
+		}
+		return nil, nil, err
+	}
+
+	// Load the user again to include the enrollment
+	user, err = s.users.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, recoveryCodes, nil
+}
+
+// mandatory reports whether a user has to use MFA, because an admin required it or because of
+// their roles
+func (s *Service) mandatory(user *models.User) bool {
+	if user.MFARequired {
+		return true
+	}
+	for _, role := range user.Roles {
+		for _, required := range s.requiredRoles {
+			if role == required {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PendingUser returns the user of a pending login
+func (s *Service) PendingUser(ctx context.Context, mfaToken string) (*models.User, error) {
+	userToken, err := s.tokens.GetUserToken(ctx, models.PurposeMFALogin, token.HashSecret(mfaToken))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, errors.NewError(errors.ErrUnauthorized, "invalid or expired MFA token")
+		}
+		return nil, err
+	}
+
+	return s.users.GetUserByID(ctx, userToken.UserID)
+}
+
+// verify checks a TOTP code or, if given, a recovery code of a user with MFA enabled. Each
+// code is only accepted once.
+func (s *Service) verify(ctx context.Context, user *models.User, code, recoveryCode string) error {
+	if code != "" && user.MFASecret != nil {
+		if step, ok := Validate(*user.MFASecret, code, time.Now()); ok {
+			err := s.repo.UseStep(ctx, user.ID, step)
+			if err != repository.ErrNotFound {
+				return err
+			}
+		}
+	}
+
+	if recoveryCode != "" {
+		err := s.repo.UseRecoveryCode(ctx, user.ID, token.HashSecret(normalizeRecoveryCode(recoveryCode)))
+		if err != repository.ErrNotFound {
+			return err
+		}
+	}
+
+	return errors.NewError(errors.ErrUnauthorized, "invalid code")
+}
+
+// newRecoveryCodes generates recovery codes and returns them with their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(encoding.EncodeToString(buf))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = token.HashSecret(code)
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode removes the formatting of a recovery code as it was typed
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,108 @@
+package mfa
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the base32 encoding of the SHA-1 seed of the RFC 6238 test vectors,
+// "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfcVectors are the SHA-1 test vectors of RFC 6238 appendix B, truncated to six digits
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+	for _, v := range rfcVectors {
+		got, err := Code(rfcSecret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("Code(%d) returned error: %v", v.unix, err)
+		}
+		if got != v.code {
+			t.Errorf("Code(%d) = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestValidateAcceptsRFC6238Vectors(t *testing.T) {
+	for _, v := range rfcVectors {
+		at := time.Unix(v.unix, 0)
+		matched, ok := Validate(rfcSecret, v.code, at)
+		if !ok {
+			t.Errorf("Validate(%d, %s) rejected the code", v.unix, v.code)
+			continue
+		}
+		if matched != step(at) {
+			t.Errorf("Validate(%d, %s) matched step %d, want %d", v.unix, v.code, matched, step(at))
+		}
+	}
+}
+
+func TestValidateAllowsOneStepOfSkew(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	code, err := Code(rfcSecret, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, offset := range []time.Duration{-period, period} {
+		if _, ok := Validate(rfcSecret, code, at.Add(offset)); !ok {
+			t.Errorf("Validate rejected a code %v away", offset)
+		}
+	}
+	for _, offset := range []time.Duration{-2 * period, 2 * period} {
+		if _, ok := Validate(rfcSecret, code, at.Add(offset)); ok {
+			t.Errorf("Validate accepted a code %v away", offset)
+		}
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	at := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		input  string
+		want   bool
+	}{
+		{"spaces are ignored", rfcSecret, "287 082", true},
+		{"lowercase padded secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq====", "287082", true},
+		{"wrong code", rfcSecret, "287083", false},
+		{"too short", rfcSecret, "28708", false},
+		{"too long", rfcSecret, "2870821", false},
+		{"invalid secret", "not base32!", "287082", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := Validate(tt.secret, tt.input, at); ok != tt.want {
+				t.Errorf("Validate() = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateSecretRoundTrips(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		t.Fatalf("generated secret does not decode: %v", err)
+	}
+	if len(key) != secretBytes {
+		t.Errorf("generated secret has %d bytes, want %d", len(key), secretBytes)
+	}
+}
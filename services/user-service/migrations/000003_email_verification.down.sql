@@ -0,0 +1,8 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_user_tokens_expires_at;
+DROP INDEX IF EXISTS idx_user_tokens_user_id_purpose;
+
+-- Drop tables
+DROP TABLE IF EXISTS user_tokens;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,16 @@
+-- Add TOTP two-factor authentication to users. The secret is stored as soon as enrollment
+-- starts, but only used to log in once the enrollment was confirmed.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_enabled_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_last_step BIGINT;  -- Last accepted time step, so that a code cannot be replayed
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_required BOOLEAN NOT NULL DEFAULT FALSE;  -- Set by an admin
+
+-- Create mfa_recovery_codes table for single-use codes that replace a TOTP code
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,  -- SHA-256 of the code, the code itself is never stored
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE(user_id, code_hash)
+);
@@ -0,0 +1,7 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_login_lockout_events_user_id;
+DROP INDEX IF EXISTS idx_login_throttles_last_failure_at;
+
+-- Drop tables
+DROP TABLE IF EXISTS login_lockout_events;
+DROP TABLE IF EXISTS login_throttles;
@@ -0,0 +1,27 @@
+-- Create login_throttles table to count failed logins per account and per client IP. Rows are
+-- shared by all replicas of the service.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    scope VARCHAR(20) NOT NULL,  -- account, ip
+    subject VARCHAR(255) NOT NULL,  -- The username tried or the client IP
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    blocked_until TIMESTAMP WITH TIME ZONE,  -- Logins are rejected until then
+    PRIMARY KEY (scope, subject)
+);
+
+-- Create login_lockout_events table to record lockouts and unlocks
+CREATE TABLE IF NOT EXISTS login_lockout_events (
+    id UUID PRIMARY KEY,
+    scope VARCHAR(20) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,  -- The locked account, if it exists
+    event VARCHAR(20) NOT NULL,  -- locked, unlocked
+    failures INTEGER NOT NULL,
+    blocked_until TIMESTAMP WITH TIME ZONE,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,  -- The admin who unlocked the account
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_login_throttles_last_failure_at ON login_throttles(last_failure_at);
+CREATE INDEX IF NOT EXISTS idx_login_lockout_events_user_id ON login_lockout_events(user_id);
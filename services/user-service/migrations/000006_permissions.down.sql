@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_role_permissions_permission;
+
+-- Drop tables
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
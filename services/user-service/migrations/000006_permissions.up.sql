@@ -0,0 +1,54 @@
+-- Create permissions table
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(100) PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+-- Create role_permissions table (many-to-many relationship)
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission VARCHAR(100) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (role_id, permission)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_role_permissions_permission ON role_permissions(permission);
+
+-- Insert permissions
+INSERT INTO permissions (name, description)
+VALUES
+    ('survey:create', 'Create surveys'),
+    ('survey:publish', 'Publish surveys the user can manage'),
+    ('survey:manage_any', 'Manage the surveys of all users'),
+    ('results:read', 'Read the results and analytics of surveys'),
+    ('results:export', 'Export the results of surveys'),
+    ('user:manage', 'Manage users, their roles and their lockouts'),
+    ('role:manage', 'Manage roles and the permissions they grant')
+ON CONFLICT (name) DO NOTHING;
+
+-- Migrate the existing roles to permission sets. Admins can do everything, survey creators can
+-- create, publish, analyze and export surveys, and regular users keep creating, publishing and
+-- analyzing their own surveys as they could before.
+INSERT INTO role_permissions (role_id, permission, created_at)
+SELECT r.id, p.name, NOW()
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission, created_at)
+SELECT r.id, p.name, NOW()
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'survey_creator'
+    AND p.name IN ('survey:create', 'survey:publish', 'results:read', 'results:export')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission, created_at)
+SELECT r.id, p.name, NOW()
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'user'
+    AND p.name IN ('survey:create', 'survey:publish', 'results:read')
+ON CONFLICT DO NOTHING;
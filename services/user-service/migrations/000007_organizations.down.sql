@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_organization_members_user_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
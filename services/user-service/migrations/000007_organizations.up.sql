@@ -0,0 +1,22 @@
+-- Create organizations table for team workspaces that share surveys
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Create organization_members table with the role of each member
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL,  -- owner, admin, editor, viewer
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (organization_id, user_id),
+    CONSTRAINT chk_organization_members_role CHECK (role IN ('owner', 'admin', 'editor', 'viewer'))
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id);
@@ -0,0 +1,9 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_user_identities_user_id;
+DROP INDEX IF EXISTS idx_oidc_login_states_expires_at;
+
+-- Drop tables
+DROP TABLE IF EXISTS oidc_role_grants;
+DROP TABLE IF EXISTS oidc_group_mappings;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS oidc_login_states;
@@ -0,0 +1,46 @@
+-- Create oidc_login_states table for logins through an external identity provider that were
+-- started but not completed yet. Only the hash of the state is stored.
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+    state_hash VARCHAR(64) PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    nonce VARCHAR(100) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Create user_identities table linking users to their accounts at identity providers
+CREATE TABLE IF NOT EXISTS user_identities (
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_login_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (provider, subject),
+    UNIQUE (provider, user_id)
+);
+
+-- Create oidc_group_mappings table granting roles to the members of identity provider groups
+CREATE TABLE IF NOT EXISTS oidc_group_mappings (
+    id UUID PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    group_name VARCHAR(255) NOT NULL,
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (provider, group_name, role_id)
+);
+
+-- Create oidc_role_grants table with the roles users hold because of group mappings, so that
+-- they are revoked once the user leaves the group
+CREATE TABLE IF NOT EXISTS oidc_role_grants (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, provider, role_id)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_oidc_login_states_expires_at ON oidc_login_states(expires_at);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
@@ -0,0 +1,2 @@
+-- Remove the permission to read the audit log, and its grants with it
+DELETE FROM permissions WHERE name = 'audit:read';
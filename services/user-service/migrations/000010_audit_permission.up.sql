@@ -0,0 +1,11 @@
+-- Insert the permission to read the audit log
+INSERT INTO permissions (name, description)
+VALUES ('audit:read', 'Read the audit log')
+ON CONFLICT (name) DO NOTHING;
+
+-- Admins can read the audit log
+INSERT INTO role_permissions (role_id, permission, created_at)
+SELECT r.id, 'audit:read', NOW()
+FROM roles r
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
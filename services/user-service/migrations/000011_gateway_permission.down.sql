@@ -0,0 +1,2 @@
+-- Remove the permission to read the routing table of the API gateway, and its grants with it
+DELETE FROM permissions WHERE name = 'gateway:read';
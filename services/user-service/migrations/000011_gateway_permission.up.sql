@@ -0,0 +1,11 @@
+-- Insert the permission to read the routing table of the API gateway
+INSERT INTO permissions (name, description)
+VALUES ('gateway:read', 'Read the routing table of the API gateway')
+ON CONFLICT (name) DO NOTHING;
+
+-- Admins can read the routing table
+INSERT INTO role_permissions (role_id, permission, created_at)
+SELECT r.id, 'gateway:read', NOW()
+FROM roles r
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
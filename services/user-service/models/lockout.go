@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Scopes of login throttling
+const (
+	ThrottleScopeAccount = "account"
+	ThrottleScopeIP      = "ip"
+)
+
+// Lockout events
+const (
+	LockoutEventLocked   = "locked"
+	LockoutEventUnlocked = "unlocked"
+)
+
+// LockoutEvent records that an account or client IP was locked out after failed logins, or
+// that an admin unlocked an account
+type LockoutEvent struct {
+	ID           string     `json:"id" db:"id"`
+	Scope        string     `json:"scope" db:"scope"`
+	Subject      string     `json:"subject" db:"subject"`
+	UserID       *string    `json:"user_id,omitempty" db:"user_id"`
+	Event        string     `json:"event" db:"event"`
+	Failures     int        `json:"failures" db:"failures"`
+	BlockedUntil *time.Time `json:"blocked_until,omitempty" db:"blocked_until"`
+	ActorID      *string    `json:"actor_id,omitempty" db:"actor_id"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// MFAEnrollment is the TOTP secret of a user who starts enrolling, to be added to an
+// authenticator app
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFAChallenge is returned by the login instead of tokens when the user has to provide a
+// second factor. EnrollmentRequired is set when an admin requires MFA but the user has not
+// enrolled yet.
+type MFAChallenge struct {
+	MFARequired        bool      `json:"mfa_required"`
+	EnrollmentRequired bool      `json:"enrollment_required"`
+	MFAToken           string    `json:"mfa_token"`
+	ExpiresAt          time.Time `json:"expires_at"`
+}
+
+// MFALoginRequest represents the second step of the login. It carries either a TOTP code or
+// a recovery code.
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+// MFAEnrollLoginRequest represents the request to enroll during the login, when an admin
+// requires MFA
+type MFAEnrollLoginRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+// MFACodeRequest represents a request confirmed with a TOTP code
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MFARequiredRequest represents the request of an admin to require MFA for a user
+type MFARequiredRequest struct {
+	Required bool `json:"required"`
+}
+
+// RecoveryCodesResponse lists recovery codes, which are only shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFALoginResponse is the login response of the second step, with new recovery codes if the
+// user enrolled during the login
+type MFALoginResponse struct {
+	LoginResponse
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// OIDCProvider is an external identity provider users can log in with
+type OIDCProvider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// OIDCLoginState is a login through an identity provider that was started but not completed
+// yet. Only the hash of the state is stored.
+type OIDCLoginState struct {
+	StateHash    string    `db:"state_hash"`
+	Provider     string    `db:"provider"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// UserIdentity links a user to their account at an identity provider
+type UserIdentity struct {
+	Provider    string    `json:"provider" db:"provider"`
+	Subject     string    `json:"subject" db:"subject"`
+	UserID      string    `json:"user_id" db:"user_id"`
+	Email       string    `json:"email" db:"email"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at" db:"last_login_at"`
+}
+
+// OIDCGroupMapping grants a role to the members of a group at an identity provider
+type OIDCGroupMapping struct {
+	ID        string    `json:"id" db:"id"`
+	Provider  string    `json:"provider" db:"provider"`
+	Group     string    `json:"group" db:"group_name"`
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// OIDCAuthorization is where to send the user to log in with an identity provider. The client
+// keeps the state and only completes a login whose callback carries the same state.
+type OIDCAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
+
+// OIDCCallbackRequest represents the request to complete a login with the code and state the
+// identity provider redirected back with
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+// OIDCGroupMappingRequest represents the request to grant a role to the members of a group
+type OIDCGroupMappingRequest struct {
+	Provider string `json:"provider" validate:"required"`
+	Group    string `json:"group" validate:"required,max=255"`
+	Role     string `json:"role" validate:"required"`
+}
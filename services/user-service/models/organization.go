@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Organization is a team workspace whose members share surveys
+type Organization struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedBy *string   `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	Role      string    `json:"role,omitempty" db:"role"` // The role of the current user
+}
+
+// OrganizationMember is a user who belongs to an organization
+type OrganizationMember struct {
+	OrganizationID string    `json:"organization_id" db:"organization_id"`
+	UserID         string    `json:"user_id" db:"user_id"`
+	Username       string    `json:"username" db:"username"`
+	Email          string    `json:"email" db:"email"`
+	Role           string    `json:"role" db:"role"`
+	Permissions    []string  `json:"permissions" db:"-"` // Granted on the surveys of the organization
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// OrganizationRequest represents the request to create or rename an organization
+type OrganizationRequest struct {
+	Name string `json:"name" validate:"required,min=2,max=100"`
+}
+
+// AddMemberRequest represents the request to add a user to an organization, identified by
+// their ID or email
+type AddMemberRequest struct {
+	UserID string `json:"user_id" validate:"required_without=Email,omitempty,uuid"`
+	Email  string `json:"email" validate:"required_without=UserID,omitempty,email"`
+	Role   string `json:"role" validate:"required,oneof=owner admin editor viewer"`
+}
+
+// UpdateMemberRequest represents the request to change the role of a member
+type UpdateMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner admin editor viewer"`
+}
+
+// UserProfile is the public part of a user, visible to every authenticated user
+type UserProfile struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+// RefreshToken is a stored refresh token. Tokens issued for the same login form a family;
+// each refresh replaces the used token with the next one of its family.
+type RefreshToken struct {
+	ID            string     `db:"id"`
+	UserID        string     `db:"user_id"`
+	FamilyID      string     `db:"family_id"`
+	TokenHash     string     `db:"token_hash"`
+	AccessTokenID string     `db:"access_token_id"`
+	ReplacedBy    *string    `db:"replaced_by"`
+	RevokedAt     *time.Time `db:"revoked_at"`
+	ExpiresAt     time.Time  `db:"expires_at"`
+	CreatedAt     time.Time  `db:"created_at"`
+}
+
+// TokenPair is a short-lived access token together with the refresh token that renews it
+type TokenPair struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// RefreshRequest represents the request to refresh an access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// LogoutRequest represents the request to log out. Without a refresh token only the access
+// token of the request is revoked.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RevocationCheckRequest represents the request of a service to check whether an access token
+// was revoked
+type RevocationCheckRequest struct {
+	TokenID  string `json:"token_id"`
+	UserID   string `json:"user_id" validate:"required"`
+	IssuedAt int64  `json:"issued_at"`
+}
+
+// RevocationCheckResponse reports whether an access token was revoked
+type RevocationCheckResponse struct {
+	Revoked bool `json:"revoked"`
+}
+
+// Purposes of user tokens
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+	PurposeMFALogin          = "mfa_login"
+)
+
+// UserToken is a stored single-use token sent to a user by email, such as a password reset
+// or email verification token
+type UserToken struct {
+	ID        string     `db:"id"`
+	UserID    string     `db:"user_id"`
+	Purpose   string     `db:"purpose"`
+	TokenHash string     `db:"token_hash"`
+	Email     string     `db:"email"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// ForgotPasswordRequest represents the request to send a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents the request to set a new password with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
+}
+
+// VerifyEmailRequest represents the request to verify an email with a verification token
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
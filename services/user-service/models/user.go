@@ -17,6 +17,29 @@ type User struct {
 	Roles     []string  `json:"roles" db:"-"` // Handled separately
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// Permissions are granted by the roles, also handled separately
+	Permissions []string `json:"permissions" db:"-"`
+
+	// EmailVerifiedAt is when the user confirmed their current email, nil if they did not
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+
+	// MFASecret is the TOTP secret, set once the user started enrolling
+	MFASecret *string `json:"-" db:"mfa_secret"`
+	// MFAEnabledAt is when the user confirmed their TOTP enrollment, nil if they did not
+	MFAEnabledAt *time.Time `json:"mfa_enabled_at,omitempty" db:"mfa_enabled_at"`
+	// MFARequired is set by an admin to make the user enroll before they can log in
+	MFARequired bool `json:"mfa_required" db:"mfa_required"`
+}
+
+// EmailVerified reports whether the user confirmed their current email
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// MFAEnabled reports whether logging in requires a second factor
+func (u *User) MFAEnabled() bool {
+	return u.MFAEnabledAt != nil
 }
 
 // Role represents a role in the system
@@ -26,6 +49,18 @@ type Role struct {
 	Description string    `json:"description" db:"description"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	Permissions []string  `json:"permissions" db:"-"` // Handled separately
+}
+
+// Permission represents a permission that roles can grant
+type Permission struct {
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description" db:"description"`
+}
+
+// RolePermissionsRequest represents the request to replace the permissions of a role
+type RolePermissionsRequest struct {
+	Permissions []string `json:"permissions" validate:"required"`
 }
 
 // UserRole represents the many-to-many relationship between users and roles
@@ -37,12 +72,11 @@ type UserRole struct {
 
 // CreateUserRequest represents the request to create a new user
 type CreateUserRequest struct {
-	Username  string   `json:"username" validate:"required,min=3,max=30"`
-	Email     string   `json:"email" validate:"required,email"`
-	Password  string   `json:"password" validate:"required,min=8"`
-	FirstName string   `json:"first_name" validate:"required"`
-	LastName  string   `json:"last_name" validate:"required"`
-	Roles     []string `json:"roles" validate:"omitempty"`
+	Username  string `json:"username" validate:"required,min=3,max=30"`
+	Email     string `json:"email" validate:"required,email"`
+	Password  string `json:"password" validate:"required,min=8"`
+	FirstName string `json:"first_name" validate:"required"`
+	LastName  string `json:"last_name" validate:"required"`
 }
 
 // LoginRequest represents the login request
@@ -51,10 +85,16 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// RegistrationResponse represents the registration response. It is the same whether or not
+// the username or email is already registered.
+type RegistrationResponse struct {
+	Message string `json:"message"`
+}
+
 // LoginResponse represents the login response
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	TokenPair
+	User User `json:"user"`
 }
 
 // UserResponse represents the user response without sensitive data
@@ -67,8 +107,18 @@ type UserResponse struct {
 	Roles     []string  `json:"roles"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	EmailVerified bool `json:"email_verified"`
+	MFAEnabled    bool `json:"mfa_enabled"`
+	MFARequired   bool `json:"mfa_required"`
+
+	Permissions []string `json:"permissions"`
 }
 
+// dummyPasswordHash is compared against when a user does not exist, so that failing to log
+// in takes as long whether or not the username exists
+const dummyPasswordHash = "$2a$10$gk132.TTYhPqQO1X2b/nzeXFV3CiCEko9/BBu/bVSXCmM5aRNmfXO"
+
 // HashPassword creates a password hash
 func HashPassword(password string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -84,6 +134,11 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
+// CheckPasswordTiming spends as long as checking a password, for users that do not exist
+func CheckPasswordTiming(password string) {
+	CheckPasswordHash(password, dummyPasswordHash)
+}
+
 // ToResponse converts a User to a UserResponse
 func (u *User) ToResponse() UserResponse {
 	return UserResponse{
@@ -95,5 +150,10 @@ func (u *User) ToResponse() UserResponse {
 		Roles:     u.Roles,
 		CreatedAt: u.CreatedAt,
 		UpdatedAt: u.UpdatedAt,
+
+		Permissions:   u.Permissions,
+		EmailVerified: u.EmailVerified(),
+		MFAEnabled:    u.MFAEnabled(),
+		MFARequired:   u.MFARequired,
 	}
 } 
\ No newline at end of file
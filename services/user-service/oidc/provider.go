@@ -0,0 +1,322 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/jwks"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// requestTimeout bounds each request to a provider
+const requestTimeout = 10 * time.Second
+
+// providerName is the format of provider names, which appear in URLs and environment variables
+var providerName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ProviderConfig configures an OpenID Connect provider
+type ProviderConfig struct {
+	// Name identifies the provider in URLs and stored identities
+	Name string
+	// DisplayName is shown on the login page
+	DisplayName string
+	// Issuer is the issuer URL, from which the provider configuration is discovered
+	Issuer string
+	// AuthorizationURL overrides the discovered authorization endpoint, for a provider that
+	// browsers reach at another address than the service, such as a mock server in Docker
+	AuthorizationURL string
+	ClientID         string
+	// ClientSecret is empty for public clients, which only rely on PKCE
+	ClientSecret string
+	// RedirectURL is the page of the frontend that completes the login
+	RedirectURL string
+	Scopes      []string
+	// GroupsClaim is the ID token claim that lists the groups of the user. Without it group
+	// mappings are not applied.
+	GroupsClaim string
+	// AutoProvision creates users on their first login
+	AutoProvision bool
+	// LinkByEmail links an identity to the existing user with the same verified email
+	LinkByEmail bool
+}
+
+// Identity is the user an ID token was issued for
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	GivenName         string
+	FamilyName        string
+	Groups            []string
+}
+
+// metadata is the part of the discovered provider configuration that is used
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// tokenResponse is the response of the token endpoint
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Provider is an OpenID Connect provider users log in with through the authorization code
+// flow with PKCE. Its configuration is discovered on first use.
+type Provider struct {
+	config     ProviderConfig
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     *jwks.Client
+}
+
+// NewProvider creates a new provider from its configuration
+func NewProvider(config ProviderConfig) (*Provider, error) {
+	if !providerName.MatchString(config.Name) {
+		return nil, fmt.Errorf("invalid provider name %q", config.Name)
+	}
+	if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, fmt.Errorf("provider %s needs an issuer, client ID and redirect URL", config.Name)
+	}
+	if config.DisplayName == "" {
+		config.DisplayName = config.Name
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{
+		config:     config,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// Config returns the configuration of the provider
+func (p *Provider) Config() ProviderConfig {
+	return p.config
+}
+
+// AuthorizationURL returns the URL that sends the user to the provider to log in
+func (p *Provider) AuthorizationURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := meta.AuthorizationEndpoint
+	if p.config.AuthorizationURL != "" {
+		endpoint = p.config.AuthorizationURL
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+	return endpoint + separator + query.Encode(), nil
+}
+
+// Exchange exchanges an authorization code for the identity in its ID token, which must carry
+// the nonce of the login
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("%w: %s %s", ErrInvalidLogin, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("token response has no ID token")
+	}
+
+	return p.verifyIDToken(ctx, meta, token.IDToken, nonce)
+}
+
+// verifyIDToken validates the signature, issuer, audience, expiry and nonce of an ID token
+// and returns the identity it was issued for
+func (p *Provider) verifyIDToken(ctx context.Context, meta *metadata, rawToken, nonce string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("token has no key ID")
+		}
+		return p.keys.PublicKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLogin, err)
+	}
+
+	if exp, err := claims.GetExpirationTime(); err != nil || exp == nil {
+		return nil, fmt.Errorf("%w: ID token has no expiry", ErrInvalidLogin)
+	}
+	if stringClaim(claims, "nonce") != nonce {
+		return nil, fmt.Errorf("%w: ID token nonce does not match", ErrInvalidLogin)
+	}
+	// A token for several audiences must have been issued to this client
+	if aud, _ := claims.GetAudience(); len(aud) > 1 && stringClaim(claims, "azp") != p.config.ClientID {
+		return nil, fmt.Errorf("%w: ID token was issued to another client", ErrInvalidLogin)
+	}
+
+	identity := &Identity{
+		Subject:           stringClaim(claims, "sub"),
+		Email:             stringClaim(claims, "email"),
+		EmailVerified:     boolClaim(claims, "email_verified"),
+		PreferredUsername: stringClaim(claims, "preferred_username"),
+		GivenName:         stringClaim(claims, "given_name"),
+		FamilyName:        stringClaim(claims, "family_name"),
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: ID token has no subject", ErrInvalidLogin)
+	}
+	if p.config.GroupsClaim != "" {
+		identity.Groups = stringsClaim(claims, p.config.GroupsClaim)
+	}
+
+	return identity, nil
+}
+
+// discover fetches the provider configuration once and caches it
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	issuer := strings.TrimSuffix(p.config.Issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover provider %s: %w", p.config.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to discover provider %s: status %d", p.config.Name, resp.StatusCode)
+	}
+
+	var meta metadata
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("failed to decode provider configuration: %w", err)
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("provider %s reports issuer %q", p.config.Name, meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("provider %s configuration is incomplete", p.config.Name)
+	}
+
+	p.metadata = &meta
+	p.keys = jwks.NewClient(meta.JWKSURI, 0)
+	return p.metadata, nil
+}
+
+// stringClaim returns a string claim, or an empty string if the claim is missing
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// boolClaim returns a boolean claim. Some providers send booleans as strings.
+func boolClaim(claims jwt.MapClaims, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	default:
+		return false
+	}
+}
+
+// stringsClaim returns a claim that is a list of strings or a single string
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,329 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/jwks"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID    = "pollpulse"
+	testRedirectURL = "http://localhost:3000/login/callback"
+)
+
+// mockProvider is an OpenID Connect provider that serves its discovery document, key set and
+// a token endpoint. Codes are issued by authorize and can be exchanged once, with the code
+// verifier of their PKCE challenge.
+type mockProvider struct {
+	server *httptest.Server
+	keys   *jwks.KeySet
+
+	mu    sync.Mutex
+	codes map[string]mockCode
+	next  int
+}
+
+// mockCode is an authorization code with the challenge it was issued for and the claims of its
+// ID token
+type mockCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := jwks.GenerateKey("mock-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := jwks.NewKeySet([]*jwks.Key{key}, "mock-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockProvider{keys: keys, codes: make(map[string]mockCode)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.serveDiscovery)
+	mux.Handle("/jwks", keys)
+	mux.HandleFunc("/token", m.serveToken)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+// newProvider returns a provider configured for the mock
+func (m *mockProvider) newProvider(t *testing.T, configure func(config *ProviderConfig)) *Provider {
+	t.Helper()
+
+	config := ProviderConfig{
+		Name:        "mock",
+		Issuer:      m.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+	}
+	if configure != nil {
+		configure(&config)
+	}
+
+	provider, err := NewProvider(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func (m *mockProvider) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metadata{
+		Issuer:                m.server.URL,
+		AuthorizationEndpoint: m.server.URL + "/authorize",
+		TokenEndpoint:         m.server.URL + "/token",
+		JWKSURI:               m.server.URL + "/jwks",
+	})
+}
+
+func (m *mockProvider) serveToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "invalid_request")
+		return
+	}
+	if r.PostForm.Get("client_id") != testClientID || r.PostForm.Get("redirect_uri") != testRedirectURL {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	m.mu.Lock()
+	code, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	if !ok || challengeOf(r.PostForm.Get("code_verifier")) != code.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	idToken, err := m.keys.Sign(code.claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokenResponse{IDToken: idToken})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(tokenResponse{Error: code})
+}
+
+// authorize logs a user in at an authorization URL and returns the code the provider
+// redirects back with. The ID token of the code has the nonce of the URL and the given claims,
+// which override the defaults. Claims set to nil are left out.
+func (m *mockProvider) authorize(t *testing.T, authURL string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, m.server.URL+"/authorize?") {
+		t.Fatalf("authorization URL %s is not the endpoint of the provider", authURL)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization URL %s has no S256 code challenge", authURL)
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   m.server.URL,
+		"aud":   testClientID,
+		"sub":   "subject-1",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(idClaims, name)
+			continue
+		}
+		idClaims[name] = value
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.next++
+	code := fmt.Sprintf("code-%d", m.next)
+	m.codes[code] = mockCode{challenge: query.Get("code_challenge"), claims: idClaims}
+	return code
+}
+
+// challengeOf returns the S256 PKCE challenge of a code verifier
+func challengeOf(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// authorizationURL starts a login at the provider with a known code verifier
+func authorizationURL(t *testing.T, provider *Provider, nonce, verifier string) string {
+	t.Helper()
+
+	authURL, err := provider.AuthorizationURL(context.Background(), "state", nonce, challengeOf(verifier))
+	if err != nil {
+		t.Fatalf("AuthorizationURL returned error: %v", err)
+	}
+	return authURL
+}
+
+func TestAuthorizationURL(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := mock.newProvider(t, nil)
+
+	u, err := url.Parse(authorizationURL(t, provider, "nonce-1", "verifier-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "state",
+		"nonce":                 "nonce-1",
+		"code_challenge":        challengeOf("verifier-1"),
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestExchangeReturnsIdentity(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := mock.newProvider(t, func(config *ProviderConfig) { config.GroupsClaim = "groups" })
+
+	code := mock.authorize(t, authorizationURL(t, provider, "nonce-1", "verifier-1"), jwt.MapClaims{
+		"email":              "ada@example.com",
+		"email_verified":     "true",
+		"preferred_username": "ada",
+		"groups":             []string{"staff", "admins"},
+	})
+
+	identity, err := provider.Exchange(context.Background(), code, "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+	if identity.Subject != "subject-1" || identity.Email != "ada@example.com" || identity.PreferredUsername != "ada" {
+		t.Errorf("identity = %+v", identity)
+	}
+	if !identity.EmailVerified {
+		t.Error("email_verified sent as a string was not read")
+	}
+	if len(identity.Groups) != 2 || identity.Groups[0] != "staff" {
+		t.Errorf("groups = %v, want [staff admins]", identity.Groups)
+	}
+}
+
+func TestExchangeRejectsWrongCodeVerifier(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := mock.newProvider(t, nil)
+
+	code := mock.authorize(t, authorizationURL(t, provider, "nonce-1", "verifier-1"), nil)
+
+	_, err := provider.Exchange(context.Background(), code, "another-verifier", "nonce-1")
+	if !errors.Is(err, ErrInvalidLogin) {
+		t.Errorf("Exchange error = %v, want ErrInvalidLogin", err)
+	}
+}
+
+func TestExchangeRejectsInvalidIDTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		nonce  string
+		claims jwt.MapClaims
+	}{
+		{"nonce mismatch", "nonce-2", nil},
+		{"missing nonce", "nonce-1", jwt.MapClaims{"nonce": nil}},
+		{"other issuer", "nonce-1", jwt.MapClaims{"iss": "https://evil.example.com"}},
+		{"other audience", "nonce-1", jwt.MapClaims{"aud": "another-client"}},
+		{"issued to another client", "nonce-1", jwt.MapClaims{"aud": []string{testClientID, "another-client"}, "azp": "another-client"}},
+		{"expired", "nonce-1", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}},
+		{"no expiry", "nonce-1", jwt.MapClaims{"exp": nil}},
+		{"no subject", "nonce-1", jwt.MapClaims{"sub": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockProvider(t)
+			provider := mock.newProvider(t, nil)
+
+			code := mock.authorize(t, authorizationURL(t, provider, "nonce-1", "verifier-1"), tt.claims)
+
+			_, err := provider.Exchange(context.Background(), code, "verifier-1", tt.nonce)
+			if !errors.Is(err, ErrInvalidLogin) {
+				t.Errorf("Exchange error = %v, want ErrInvalidLogin", err)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsTokenSignedByAnotherKey(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := mock.newProvider(t, nil)
+	code := mock.authorize(t, authorizationURL(t, provider, "nonce-1", "verifier-1"), nil)
+
+	// The provider signs with a key it does not publish
+	key, err := jwks.GenerateKey("mock-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := jwks.NewKeySet([]*jwks.Key{key}, "mock-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := mock.keys
+	mock.keys = other
+	defer func() { mock.keys = published }()
+
+	_, err = provider.Exchange(context.Background(), code, "verifier-1", "nonce-1")
+	if !errors.Is(err, ErrInvalidLogin) {
+		t.Errorf("Exchange error = %v, want ErrInvalidLogin", err)
+	}
+}
+
+func TestDiscoverRejectsOtherIssuer(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := mock.newProvider(t, func(config *ProviderConfig) {
+		config.Issuer = mock.server.URL + "/tenant"
+	})
+
+	// The discovery document is served for any path but reports the issuer of the mock
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tenant/.well-known/openid-configuration", mock.serveDiscovery)
+	mock.server.Config.Handler = mux
+
+	if _, err := provider.AuthorizationURL(context.Background(), "state", "nonce", "challenge"); err == nil {
+		t.Error("AuthorizationURL accepted a configuration for another issuer")
+	}
+}
@@ -0,0 +1,416 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/repository"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/token"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidLogin is returned when a provider rejects a login or issues an invalid ID token
+var ErrInvalidLogin = stderrors.New("invalid login")
+
+// defaultRole is the role of provisioned users, as of registered users
+const defaultRole = "user"
+
+// usernameCharacters matches the characters that are dropped from provisioned usernames
+var usernameCharacters = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// identityStore keeps login states, linked identities and group mappings, as
+// repository.IdentityRepository does
+type identityStore interface {
+	CreateLoginState(ctx context.Context, state *models.OIDCLoginState) error
+	ConsumeLoginState(ctx context.Context, stateHash string) (*models.OIDCLoginState, error)
+	DeleteExpiredLoginStates(ctx context.Context, before time.Time) (int64, error)
+	GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	GetUserIdentity(ctx context.Context, provider, userID string) (*models.UserIdentity, error)
+	LinkIdentity(ctx context.Context, identity *models.UserIdentity) error
+	TouchIdentity(ctx context.Context, provider, subject, email string, loginAt time.Time) error
+	ListGroupMappings(ctx context.Context, provider string) ([]*models.OIDCGroupMapping, error)
+	CreateGroupMapping(ctx context.Context, mapping *models.OIDCGroupMapping) error
+	DeleteGroupMapping(ctx context.Context, id string) error
+	SyncGroupRoles(ctx context.Context, userID, provider string, groups []string) error
+}
+
+// userStore keeps the users that identities are linked to, as repository.UserRepository does
+type userStore interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	MarkEmailVerified(ctx context.Context, userID, email string) error
+	AddRole(ctx context.Context, userID, roleName string) error
+}
+
+// Service logs users in through external OpenID Connect providers. It links identities to
+// existing users by verified email or provisions new users, and keeps the roles that group
+// mappings grant in sync with the groups the provider reports.
+type Service struct {
+	providers map[string]*Provider
+	names     []string
+	repo      identityStore
+	users     userStore
+	logger    *logging.Logger
+	stateTTL  time.Duration
+}
+
+// NewService creates a new OIDC service for the given providers. A login must be completed
+// within stateTTL after it was started.
+func NewService(providers []*Provider, repo *repository.IdentityRepository, users *repository.UserRepository, logger *logging.Logger, stateTTL time.Duration) *Service {
+	s := &Service{
+		providers: make(map[string]*Provider, len(providers)),
+		repo:      repo,
+		users:     users,
+		logger:    logger,
+		stateTTL:  stateTTL,
+	}
+	for _, provider := range providers {
+		s.providers[provider.config.Name] = provider
+		s.names = append(s.names, provider.config.Name)
+	}
+
+	return s
+}
+
+// Providers lists the configured providers
+func (s *Service) Providers() []models.OIDCProvider {
+	providers := make([]models.OIDCProvider, 0, len(s.names))
+	for _, name := range s.names {
+		providers = append(providers, models.OIDCProvider{
+			Name:        name,
+			DisplayName: s.providers[name].config.DisplayName,
+		})
+	}
+	return providers
+}
+
+// Begin starts a login with a provider. The returned state must come back with the callback.
+func (s *Service) Begin(ctx context.Context, providerName string) (*models.OIDCAuthorization, error) {
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := token.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := token.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	// A secret is a valid PKCE code verifier
+	verifier, err := token.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	authURL, err := provider.AuthorizationURL(ctx, state, nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	err = s.repo.CreateLoginState(ctx, &models.OIDCLoginState{
+		StateHash:    token.HashSecret(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(s.stateTTL),
+		CreatedAt:    now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OIDCAuthorization{AuthorizationURL: authURL, State: state}, nil
+}
+
+// Complete completes a login with the code and state the provider redirected back with and
+// returns the user who logged in
+func (s *Service) Complete(ctx context.Context, providerName, code, state string) (*models.User, error) {
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	loginState, err := s.repo.ConsumeLoginState(ctx, token.HashSecret(state))
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, errors.NewError(errors.ErrUnauthorized, "login expired, start again")
+		}
+		return nil, err
+	}
+	if loginState.Provider != providerName {
+		return nil, errors.NewError(errors.ErrUnauthorized, "login was started with another provider")
+	}
+
+	identity, err := provider.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		if stderrors.Is(err, ErrInvalidLogin) {
+			s.logger.Warn("Identity provider login rejected", "provider", providerName, "error", err)
+			return nil, errors.NewError(errors.ErrUnauthorized, "login was rejected by the identity provider")
+		}
+		return nil, err
+	}
+
+	userID, err := s.resolveUser(ctx, provider, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if provider.config.GroupsClaim != "" {
+		if err := s.repo.SyncGroupRoles(ctx, userID, providerName, identity.Groups); err != nil {
+			return nil, err
+		}
+	}
+
+	// Load the user again so that they have the roles granted through their groups
+	return s.users.GetUserByID(ctx, userID)
+}
+
+// GroupMappings lists the group mappings of a provider, or of all providers if provider is
+// empty
+func (s *Service) GroupMappings(ctx context.Context, providerName string) ([]*models.OIDCGroupMapping, error) {
+	if providerName != "" {
+		if _, err := s.provider(providerName); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.repo.ListGroupMappings(ctx, providerName)
+}
+
+// AddGroupMapping grants a role to the members of a group at a provider, from their next login
+func (s *Service) AddGroupMapping(ctx context.Context, req *models.OIDCGroupMappingRequest) (*models.OIDCGroupMapping, error) {
+	provider, err := s.provider(req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if provider.config.GroupsClaim == "" {
+		return nil, errors.NewError(errors.ErrBadRequest, "provider %s has no groups claim configured", req.Provider)
+	}
+
+	mapping := &models.OIDCGroupMapping{
+		ID:        uuid.New().String(),
+		Provider:  req.Provider,
+		Group:     req.Group,
+		Role:      req.Role,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.repo.CreateGroupMapping(ctx, mapping); err != nil {
+		switch err {
+		case repository.ErrNotFound:
+			return nil, errors.NewError(errors.ErrBadRequest, "role %s does not exist", req.Role)
+		case repository.ErrMappingExists:
+			return nil, errors.NewError(errors.ErrConflict, "group is already mapped to the role")
+		default:
+			return nil, err
+		}
+	}
+
+	return mapping, nil
+}
+
+// RemoveGroupMapping deletes a group mapping. The roles it granted are revoked when their
+// users log in next.
+func (s *Service) RemoveGroupMapping(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return errors.NewError(errors.ErrNotFound, "group mapping not found")
+	}
+
+	if err := s.repo.DeleteGroupMapping(ctx, id); err != nil {
+		if err == repository.ErrNotFound {
+			return errors.NewError(errors.ErrNotFound, "group mapping not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RunCleanup deletes expired login states every interval until the context is canceled
+func (s *Service) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.repo.DeleteExpiredLoginStates(ctx, time.Now().UTC())
+			if err != nil && ctx.Err() == nil {
+				s.logger.Error("Failed to delete expired login states", "error", err)
+			}
+			if deleted > 0 {
+				s.logger.Info("Deleted expired login states", "count", deleted)
+			}
+		}
+	}
+}
+
+// resolveUser returns the ID of the user an identity is linked to. An identity that is not
+// linked yet is linked to the user with the same verified email, or to a new user, if the
+// provider allows it.
+func (s *Service) resolveUser(ctx context.Context, provider *Provider, identity *Identity) (string, error) {
+	config := provider.config
+	now := time.Now().UTC()
+
+	linked, err := s.repo.GetIdentity(ctx, config.Name, identity.Subject)
+	if err == nil {
+		if err := s.repo.TouchIdentity(ctx, config.Name, identity.Subject, identity.Email, now); err != nil {
+			return "", err
+		}
+		return linked.UserID, nil
+	}
+	if err != repository.ErrNotFound {
+		return "", err
+	}
+
+	// Only an email the provider verified identifies a user
+	if identity.Email == "" || !identity.EmailVerified {
+		return "", errors.NewError(errors.ErrForbidden, "the identity provider did not verify your email")
+	}
+
+	user, err := s.users.GetUserByEmail(ctx, identity.Email)
+	if err != nil && !stderrors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	switch {
+	case user != nil:
+		if !config.LinkByEmail {
+			return "", errors.NewError(errors.ErrForbidden, "no account is linked to this identity")
+		}
+		// Someone who registered with the email without confirming it must not get access
+		// to the account of its owner
+		if !user.EmailVerified() {
+			return "", errors.NewError(errors.ErrConflict, "verify the email of your account before logging in with %s", config.DisplayName)
+		}
+		if _, err := s.repo.GetUserIdentity(ctx, config.Name, user.ID); err == nil {
+			return "", errors.NewError(errors.ErrConflict, "your account is linked to another %s identity", config.DisplayName)
+		} else if err != repository.ErrNotFound {
+			return "", err
+		}
+	case config.AutoProvision:
+		user, err = s.provision(ctx, identity)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", errors.NewError(errors.ErrForbidden, "no account is linked to this identity")
+	}
+
+	err = s.repo.LinkIdentity(ctx, &models.UserIdentity{
+		Provider:    config.Name,
+		Subject:     identity.Subject,
+		UserID:      user.ID,
+		Email:       identity.Email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.logger.Info("Linked identity", "provider", config.Name, "user_id", user.ID)
+	return user.ID, nil
+}
+
+// provision creates a user for an identity. The user has no usable password until they reset
+// it, and their email is verified since the provider verified it.
+func (s *Service) provision(ctx context.Context, identity *Identity) (*models.User, error) {
+	username, err := s.availableUsername(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := token.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	passwordHash, err := models.HashPassword(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &models.User{
+		Username:  username,
+		Email:     identity.Email,
+		Password:  passwordHash,
+		FirstName: identity.GivenName,
+		LastName:  identity.FamilyName,
+	}
+	if err := s.users.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	if err := s.users.MarkEmailVerified(ctx, user.ID, user.Email); err != nil {
+		return nil, err
+	}
+	if err := s.users.AddRole(ctx, user.ID, defaultRole); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Provisioned user", "user_id", user.ID)
+	return user, nil
+}
+
+// availableUsername derives a username from the preferred username or email of an identity,
+// with a random suffix if it is taken
+func (s *Service) availableUsername(ctx context.Context, identity *Identity) (string, error) {
+	base := identity.PreferredUsername
+	if base == "" || strings.Contains(base, "@") {
+		base = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	base = usernameCharacters.ReplaceAllString(base, "")
+	if len(base) < 3 {
+		base = "user" + base
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	username := base
+	for attempt := 0; attempt < 5; attempt++ {
+		existing, err := s.users.GetUserByUsername(ctx, username)
+		if err != nil && !stderrors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+		if existing == nil {
+			return username, nil
+		}
+
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", fmt.Errorf("failed to generate username: %w", err)
+		}
+		username = base + "-" + hex.EncodeToString(suffix)
+	}
+
+	return "", fmt.Errorf("failed to find an available username for %s", base)
+}
+
+// provider returns the configured provider with the given name
+func (s *Service) provider(name string) (*Provider, error) {
+	provider, ok := s.providers[name]
+	if !ok {
+		return nil, errors.NewError(errors.ErrNotFound, "identity provider %s is not configured", name)
+	}
+	return provider, nil
+}
@@ -0,0 +1,421 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/repository"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// fakeIdentities keeps login states and linked identities in memory
+type fakeIdentities struct {
+	mu         sync.Mutex
+	states     map[string]*models.OIDCLoginState
+	identities map[string]*models.UserIdentity
+	groups     map[string][]string
+}
+
+func newFakeIdentities() *fakeIdentities {
+	return &fakeIdentities{
+		states:     make(map[string]*models.OIDCLoginState),
+		identities: make(map[string]*models.UserIdentity),
+		groups:     make(map[string][]string),
+	}
+}
+
+func (f *fakeIdentities) CreateLoginState(ctx context.Context, state *models.OIDCLoginState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.states[state.StateHash] = state
+	return nil
+}
+
+func (f *fakeIdentities) ConsumeLoginState(ctx context.Context, stateHash string) (*models.OIDCLoginState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state, ok := f.states[stateHash]
+	if !ok || !state.ExpiresAt.After(time.Now()) {
+		return nil, repository.ErrNotFound
+	}
+	delete(f.states, stateHash)
+	return state, nil
+}
+
+func (f *fakeIdentities) DeleteExpiredLoginStates(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeIdentities) GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	identity, ok := f.identities[provider+"/"+subject]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return identity, nil
+}
+
+func (f *fakeIdentities) GetUserIdentity(ctx context.Context, provider, userID string) (*models.UserIdentity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, identity := range f.identities {
+		if identity.Provider == provider && identity.UserID == userID {
+			return identity, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (f *fakeIdentities) LinkIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.identities[identity.Provider+"/"+identity.Subject] = identity
+	return nil
+}
+
+func (f *fakeIdentities) TouchIdentity(ctx context.Context, provider, subject, email string, loginAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if identity, ok := f.identities[provider+"/"+subject]; ok {
+		identity.Email = email
+		identity.LastLoginAt = loginAt
+	}
+	return nil
+}
+
+func (f *fakeIdentities) ListGroupMappings(ctx context.Context, provider string) ([]*models.OIDCGroupMapping, error) {
+	return nil, nil
+}
+
+func (f *fakeIdentities) CreateGroupMapping(ctx context.Context, mapping *models.OIDCGroupMapping) error {
+	return nil
+}
+
+func (f *fakeIdentities) DeleteGroupMapping(ctx context.Context, id string) error {
+	return nil
+}
+
+func (f *fakeIdentities) SyncGroupRoles(ctx context.Context, userID, provider string, groups []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.groups[userID] = groups
+	return nil
+}
+
+// fakeUsers keeps users in memory, returning sql.ErrNoRows for missing users like
+// repository.UserRepository
+type fakeUsers struct {
+	mu    sync.Mutex
+	users map[string]*models.User
+}
+
+func newFakeUsers(users ...*models.User) *fakeUsers {
+	f := &fakeUsers{users: make(map[string]*models.User)}
+	for _, user := range users {
+		f.users[user.ID] = user
+	}
+	return f
+}
+
+func (f *fakeUsers) CreateUser(ctx context.Context, user *models.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user.ID = uuid.New().String()
+	f.users[user.ID] = user
+	return nil
+}
+
+func (f *fakeUsers) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	return f.find(func(user *models.User) bool { return user.ID == id })
+}
+
+func (f *fakeUsers) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return f.find(func(user *models.User) bool { return user.Username == username })
+}
+
+func (f *fakeUsers) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return f.find(func(user *models.User) bool { return user.Email == email })
+}
+
+func (f *fakeUsers) MarkEmailVerified(ctx context.Context, userID, email string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	f.users[userID].EmailVerifiedAt = &now
+	return nil
+}
+
+func (f *fakeUsers) AddRole(ctx context.Context, userID, roleName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users[userID].Roles = append(f.users[userID].Roles, roleName)
+	return nil
+}
+
+func (f *fakeUsers) find(match func(user *models.User) bool) (*models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, user := range f.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found: %w", sql.ErrNoRows)
+}
+
+// testService is a service with a single mock provider and in-memory stores
+type testService struct {
+	*Service
+	mock       *mockProvider
+	identities *fakeIdentities
+	users      *fakeUsers
+}
+
+func newTestService(t *testing.T, configure func(config *ProviderConfig), users ...*models.User) *testService {
+	t.Helper()
+
+	mock := newMockProvider(t)
+	provider := mock.newProvider(t, configure)
+	identities := newFakeIdentities()
+	userStore := newFakeUsers(users...)
+
+	return &testService{
+		Service: &Service{
+			providers: map[string]*Provider{provider.config.Name: provider},
+			names:     []string{provider.config.Name},
+			repo:      identities,
+			users:     userStore,
+			logger:    logging.NewLogger(&logging.Config{Level: "error", ServiceName: "user-service-test"}),
+			stateTTL:  time.Minute,
+		},
+		mock:       mock,
+		identities: identities,
+		users:      userStore,
+	}
+}
+
+// login begins a login and returns its state with the code the provider issued for claims
+func (s *testService) login(t *testing.T, claims jwt.MapClaims) (state, code string) {
+	t.Helper()
+
+	authorization, err := s.Begin(context.Background(), "mock")
+	if err != nil {
+		t.Fatalf("Begin returned error: %v", err)
+	}
+	return authorization.State, s.mock.authorize(t, authorization.AuthorizationURL, claims)
+}
+
+// verifiedUser returns a user who verified their email
+func verifiedUser(email string) *models.User {
+	verifiedAt := time.Now()
+	return &models.User{ID: uuid.New().String(), Username: "existing", Email: email, EmailVerifiedAt: &verifiedAt}
+}
+
+func TestBeginStoresStateForPKCEAndNonce(t *testing.T) {
+	s := newTestService(t, nil)
+
+	authorization, err := s.Begin(context.Background(), "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(authorization.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("state") != authorization.State {
+		t.Errorf("authorization URL state = %q, want %q", query.Get("state"), authorization.State)
+	}
+
+	if len(s.identities.states) != 1 {
+		t.Fatalf("stored %d login states, want 1", len(s.identities.states))
+	}
+	for hash, state := range s.identities.states {
+		if hash == authorization.State {
+			t.Error("login state is stored in plain text")
+		}
+		if state.Nonce != query.Get("nonce") {
+			t.Errorf("stored nonce %q, want the nonce of the URL %q", state.Nonce, query.Get("nonce"))
+		}
+		if challengeOf(state.CodeVerifier) != query.Get("code_challenge") {
+			t.Error("code challenge of the URL is not the S256 hash of the stored verifier")
+		}
+	}
+}
+
+func TestCompleteConsumesState(t *testing.T) {
+	s := newTestService(t, nil)
+	s.identities.identities["mock/subject-1"] = &models.UserIdentity{Provider: "mock", Subject: "subject-1", UserID: "user-1"}
+	s.users.users["user-1"] = &models.User{ID: "user-1"}
+
+	state, code := s.login(t, nil)
+	user, err := s.Complete(context.Background(), "mock", code, state)
+	if err != nil {
+		t.Fatalf("Complete returned error: %v", err)
+	}
+	if user.ID != "user-1" {
+		t.Errorf("logged in user %s, want user-1", user.ID)
+	}
+
+	// The state completes a single login, even with a new code
+	_, code = s.login(t, nil)
+	_, err = s.Complete(context.Background(), "mock", code, state)
+	if !stderrors.Is(err, errors.ErrUnauthorized) {
+		t.Errorf("second Complete error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestCompleteRejectsUnknownOrExpiredState(t *testing.T) {
+	s := newTestService(t, nil)
+
+	_, code := s.login(t, nil)
+	if _, err := s.Complete(context.Background(), "mock", code, "unknown-state"); !stderrors.Is(err, errors.ErrUnauthorized) {
+		t.Errorf("unknown state: error = %v, want ErrUnauthorized", err)
+	}
+
+	state, code := s.login(t, nil)
+	for _, loginState := range s.identities.states {
+		loginState.ExpiresAt = time.Now().Add(-time.Second)
+	}
+	if _, err := s.Complete(context.Background(), "mock", code, state); !stderrors.Is(err, errors.ErrUnauthorized) {
+		t.Errorf("expired state: error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestCompleteRejectsStateOfAnotherProvider(t *testing.T) {
+	s := newTestService(t, nil)
+	other := s.mock.newProvider(t, func(config *ProviderConfig) { config.Name = "other" })
+	s.providers["other"] = other
+
+	state, code := s.login(t, nil)
+	if _, err := s.Complete(context.Background(), "other", code, state); !stderrors.Is(err, errors.ErrUnauthorized) {
+		t.Errorf("error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestCompleteRejectsNonceMismatch(t *testing.T) {
+	s := newTestService(t, nil)
+
+	state, code := s.login(t, jwt.MapClaims{"nonce": "nonce-of-another-login"})
+	if _, err := s.Complete(context.Background(), "mock", code, state); !stderrors.Is(err, errors.ErrUnauthorized) {
+		t.Errorf("error = %v, want ErrUnauthorized", err)
+	}
+	if len(s.identities.identities) != 0 {
+		t.Error("identity was linked despite the nonce mismatch")
+	}
+}
+
+func TestCompleteRejectsCodeOfAnotherLogin(t *testing.T) {
+	s := newTestService(t, nil)
+
+	// The code was issued for the PKCE challenge of the first login, so the verifier of the
+	// second does not redeem it
+	_, code := s.login(t, nil)
+	state, _ := s.login(t, nil)
+	if _, err := s.Complete(context.Background(), "mock", code, state); !stderrors.Is(err, errors.ErrUnauthorized) {
+		t.Errorf("error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestCompleteLinksByVerifiedEmail(t *testing.T) {
+	unverified := verifiedUser("ada@example.com")
+	unverified.EmailVerifiedAt = nil
+
+	tests := []struct {
+		name        string
+		linkByEmail bool
+		user        *models.User
+		claims      jwt.MapClaims
+		want        error
+	}{
+		{"verified email links", true, verifiedUser("ada@example.com"), jwt.MapClaims{"email_verified": true}, nil},
+		{"unverified identity email", true, verifiedUser("ada@example.com"), jwt.MapClaims{"email_verified": false}, errors.ErrForbidden},
+		{"missing email_verified", true, verifiedUser("ada@example.com"), jwt.MapClaims{}, errors.ErrForbidden},
+		{"unverified account email", true, unverified, jwt.MapClaims{"email_verified": true}, errors.ErrConflict},
+		{"linking disabled", false, verifiedUser("ada@example.com"), jwt.MapClaims{"email_verified": true}, errors.ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, func(config *ProviderConfig) { config.LinkByEmail = tt.linkByEmail }, tt.user)
+
+			tt.claims["email"] = "ada@example.com"
+			state, code := s.login(t, tt.claims)
+			user, err := s.Complete(context.Background(), "mock", code, state)
+
+			if tt.want != nil {
+				if !stderrors.Is(err, tt.want) {
+					t.Errorf("error = %v, want %v", err, tt.want)
+				}
+				if len(s.identities.identities) != 0 {
+					t.Error("identity was linked")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Complete returned error: %v", err)
+			}
+			if user.ID != tt.user.ID {
+				t.Errorf("logged in user %s, want %s", user.ID, tt.user.ID)
+			}
+			if linked := s.identities.identities["mock/subject-1"]; linked == nil || linked.UserID != tt.user.ID {
+				t.Errorf("linked identity = %+v, want one for user %s", linked, tt.user.ID)
+			}
+		})
+	}
+}
+
+func TestCompleteRejectsSecondIdentityForLinkedAccount(t *testing.T) {
+	user := verifiedUser("ada@example.com")
+	s := newTestService(t, func(config *ProviderConfig) { config.LinkByEmail = true }, user)
+	s.identities.identities["mock/subject-0"] = &models.UserIdentity{Provider: "mock", Subject: "subject-0", UserID: user.ID}
+
+	state, code := s.login(t, jwt.MapClaims{"email": user.Email, "email_verified": true})
+	if _, err := s.Complete(context.Background(), "mock", code, state); !stderrors.Is(err, errors.ErrConflict) {
+		t.Errorf("error = %v, want ErrConflict", err)
+	}
+}
+
+func TestCompleteProvisionsUser(t *testing.T) {
+	s := newTestService(t, func(config *ProviderConfig) {
+		config.AutoProvision = true
+		config.GroupsClaim = "groups"
+	})
+
+	state, code := s.login(t, jwt.MapClaims{
+		"email":              "grace@example.com",
+		"email_verified":     true,
+		"preferred_username": "grace hopper",
+		"given_name":         "Grace",
+		"groups":             []string{"staff"},
+	})
+	user, err := s.Complete(context.Background(), "mock", code, state)
+	if err != nil {
+		t.Fatalf("Complete returned error: %v", err)
+	}
+
+	if user.Username != "gracehopper" || user.FirstName != "Grace" {
+		t.Errorf("provisioned user = %+v", user)
+	}
+	if !user.EmailVerified() {
+		t.Error("provisioned user's email is not verified")
+	}
+	if len(user.Roles) != 1 || user.Roles[0] != defaultRole {
+		t.Errorf("roles = %v, want [%s]", user.Roles, defaultRole)
+	}
+	if groups := s.identities.groups[user.ID]; len(groups) != 1 || groups[0] != "staff" {
+		t.Errorf("synced groups = %v, want [staff]", groups)
+	}
+}
@@ -0,0 +1,228 @@
+package organization
+
+import (
+	"context"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/permissions"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/repository"
+	"github.com/google/uuid"
+)
+
+// Service manages organizations and their members. Owners and admins manage the members, but
+// only owners may grant or revoke the owner role, and every organization keeps an owner.
+type Service struct {
+	repo  *repository.OrganizationRepository
+	users *repository.UserRepository
+}
+
+// NewService creates a new organization service
+func NewService(repo *repository.OrganizationRepository, users *repository.UserRepository) *Service {
+	return &Service{
+		repo:  repo,
+		users: users,
+	}
+}
+
+// Create creates an organization owned by a user
+func (s *Service) Create(ctx context.Context, userID, name string) (*models.Organization, error) {
+	now := time.Now().UTC()
+	org := &models.Organization{
+		ID:        uuid.New().String(),
+		Name:      name,
+		CreatedBy: &userID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.CreateOrganization(ctx, org, userID); err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// List lists the organizations a user belongs to
+func (s *Service) List(ctx context.Context, userID string) ([]*models.Organization, error) {
+	return s.repo.ListOrganizations(ctx, userID)
+}
+
+// Get retrieves an organization the user belongs to
+func (s *Service) Get(ctx context.Context, userID, orgID string) (*models.Organization, error) {
+	org, err := s.repo.GetOrganization(ctx, orgID, userID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, errNotMember()
+		}
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// Rename changes the name of an organization on behalf of one of its owners or admins
+func (s *Service) Rename(ctx context.Context, actorID, orgID, name string) (*models.Organization, error) {
+	if _, err := s.authorize(ctx, actorID, orgID, permissions.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.RenameOrganization(ctx, orgID, name); err != nil {
+		return nil, err
+	}
+
+	return s.Get(ctx, actorID, orgID)
+}
+
+// Members lists the members of an organization the actor belongs to
+func (s *Service) Members(ctx context.Context, actorID, orgID string) ([]*models.OrganizationMember, error) {
+	if _, err := s.authorize(ctx, actorID, orgID, permissions.OrgRoleViewer); err != nil {
+		return nil, err
+	}
+
+	members, err := s.repo.ListMembers(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		member.Permissions = permissions.OrgRolePermissions(member.Role)
+	}
+
+	return members, nil
+}
+
+// Member retrieves a member of an organization the actor belongs to, with the permissions
+// their role grants on the surveys of the organization
+func (s *Service) Member(ctx context.Context, actorID, orgID, userID string) (*models.OrganizationMember, error) {
+	if _, err := s.authorize(ctx, actorID, orgID, permissions.OrgRoleViewer); err != nil {
+		return nil, err
+	}
+
+	return s.member(ctx, orgID, userID)
+}
+
+// AddMember adds a user to an organization on behalf of one of its owners or admins
+func (s *Service) AddMember(ctx context.Context, actorID, orgID string, req *models.AddMemberRequest) (*models.OrganizationMember, error) {
+	actorRole, err := s.authorize(ctx, actorID, orgID, permissions.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if req.Role == permissions.OrgRoleOwner && actorRole != permissions.OrgRoleOwner {
+		return nil, errors.NewError(errors.ErrForbidden, "only owners can add owners")
+	}
+
+	var user *models.User
+	if req.UserID != "" {
+		user, err = s.users.GetUserByID(ctx, req.UserID)
+	} else {
+		user, err = s.users.GetUserByEmail(ctx, req.Email)
+	}
+	if err != nil {
+		return nil, errors.NewError(errors.ErrNotFound, "user not found")
+	}
+
+	if err := s.repo.AddMember(ctx, orgID, user.ID, req.Role); err != nil {
+		if err == repository.ErrAlreadyMember {
+			return nil, errors.NewError(errors.ErrConflict, "user is already a member")
+		}
+		return nil, err
+	}
+
+	return s.member(ctx, orgID, user.ID)
+}
+
+// UpdateMember changes the role of a member on behalf of one of the owners or admins
+func (s *Service) UpdateMember(ctx context.Context, actorID, orgID, userID, role string) (*models.OrganizationMember, error) {
+	actorRole, err := s.authorize(ctx, actorID, orgID, permissions.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := s.member(ctx, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if (member.Role == permissions.OrgRoleOwner || role == permissions.OrgRoleOwner) && actorRole != permissions.OrgRoleOwner {
+		return nil, errors.NewError(errors.ErrForbidden, "only owners can grant or revoke the owner role")
+	}
+
+	if err := s.repo.UpdateMemberRole(ctx, orgID, userID, role); err != nil {
+		return nil, memberError(err)
+	}
+
+	return s.member(ctx, orgID, userID)
+}
+
+// RemoveMember removes a member on behalf of one of the owners or admins. Members may also
+// leave by removing themselves.
+func (s *Service) RemoveMember(ctx context.Context, actorID, orgID, userID string) error {
+	actorRole, err := s.authorize(ctx, actorID, orgID, permissions.OrgRoleViewer)
+	if err != nil {
+		return err
+	}
+
+	if actorID != userID {
+		if !permissions.OrgRoleAtLeast(actorRole, permissions.OrgRoleAdmin) {
+			return errors.NewError(errors.ErrForbidden, "only owners and admins can remove members")
+		}
+
+		member, err := s.member(ctx, orgID, userID)
+		if err != nil {
+			return err
+		}
+		if member.Role == permissions.OrgRoleOwner && actorRole != permissions.OrgRoleOwner {
+			return errors.NewError(errors.ErrForbidden, "only owners can remove owners")
+		}
+	}
+
+	return memberError(s.repo.RemoveMember(ctx, orgID, userID))
+}
+
+// authorize returns the role of the actor in an organization and checks that it is at least
+// the given role. Users who do not belong to the organization cannot tell it exists.
+func (s *Service) authorize(ctx context.Context, actorID, orgID, minimum string) (string, error) {
+	actor, err := s.repo.GetMember(ctx, orgID, actorID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return "", errNotMember()
+		}
+		return "", err
+	}
+
+	if !permissions.OrgRoleAtLeast(actor.Role, minimum) {
+		return "", errors.NewError(errors.ErrForbidden, "requires the %s role or higher", minimum)
+	}
+
+	return actor.Role, nil
+}
+
+// member retrieves a member with their permissions
+func (s *Service) member(ctx context.Context, orgID, userID string) (*models.OrganizationMember, error) {
+	member, err := s.repo.GetMember(ctx, orgID, userID)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil, errors.NewError(errors.ErrNotFound, "member not found")
+		}
+		return nil, err
+	}
+
+	member.Permissions = permissions.OrgRolePermissions(member.Role)
+	return member, nil
+}
+
+// memberError translates the errors of changing a member
+func memberError(err error) error {
+	switch err {
+	case repository.ErrNotFound:
+		return errors.NewError(errors.ErrNotFound, "member not found")
+	case repository.ErrLastOwner:
+		return errors.NewError(errors.ErrConflict, "organization must keep an owner")
+	default:
+		return err
+	}
+}
+
+// errNotMember is returned to users who do not belong to an organization
+func errNotMember() error {
+	return errors.NewError(errors.ErrNotFound, "organization not found")
+}
@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrMappingExists is returned when a group is already mapped to a role
+var ErrMappingExists = errors.New("group is already mapped to the role")
+
+// identityColumns are the columns selected for a user identity
+const identityColumns = `
+	provider, subject, user_id, email, created_at, last_login_at
+`
+
+// IdentityRepository handles database operations for logins through external identity
+// providers: pending login states, the identities linked to users and the roles granted to the
+// members of groups
+type IdentityRepository struct {
+	db *sqlx.DB
+}
+
+// NewIdentityRepository creates a new identity repository
+func NewIdentityRepository(db *sqlx.DB) *IdentityRepository {
+	return &IdentityRepository{
+		db: db,
+	}
+}
+
+// CreateLoginState stores the state of a login that was started
+func (r *IdentityRepository) CreateLoginState(ctx context.Context, state *models.OIDCLoginState) error {
+	query := `
+		INSERT INTO oidc_login_states (state_hash, provider, nonce, code_verifier, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, state.StateHash, state.Provider, state.Nonce, state.CodeVerifier, state.ExpiresAt, state.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create login state: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeLoginState deletes the unexpired login state with the given hash and returns it, so
+// that each state completes a single login. It returns ErrNotFound when there is no such state.
+func (r *IdentityRepository) ConsumeLoginState(ctx context.Context, stateHash string) (*models.OIDCLoginState, error) {
+	query := `
+		DELETE FROM oidc_login_states
+		WHERE state_hash = $1 AND expires_at > $2
+		RETURNING state_hash, provider, nonce, code_verifier, expires_at, created_at
+	`
+
+	var state models.OIDCLoginState
+	err := r.db.GetContext(ctx, &state, query, stateHash, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to consume login state: %w", err)
+	}
+
+	return &state, nil
+}
+
+// DeleteExpiredLoginStates deletes the login states that expired before the given time and
+// returns how many were deleted
+func (r *IdentityRepository) DeleteExpiredLoginStates(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM oidc_login_states WHERE expires_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired login states: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired login states: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// GetIdentity retrieves the identity with the given subject at a provider
+func (r *IdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE provider = $1 AND subject = $2`
+
+	var identity models.UserIdentity
+	err := r.db.GetContext(ctx, &identity, query, provider, subject)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	return &identity, nil
+}
+
+// GetUserIdentity retrieves the identity a user has linked at a provider
+func (r *IdentityRepository) GetUserIdentity(ctx context.Context, provider, userID string) (*models.UserIdentity, error) {
+	query := `SELECT ` + identityColumns + ` FROM user_identities WHERE provider = $1 AND user_id = $2`
+
+	var identity models.UserIdentity
+	err := r.db.GetContext(ctx, &identity, query, provider, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	return &identity, nil
+}
+
+// LinkIdentity links an identity to a user
+func (r *IdentityRepository) LinkIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (provider, subject, user_id, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, identity.Provider, identity.Subject, identity.UserID, identity.Email, identity.CreatedAt, identity.LastLoginAt)
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return nil
+}
+
+// TouchIdentity records a login with an identity and the email the provider reported
+func (r *IdentityRepository) TouchIdentity(ctx context.Context, provider, subject, email string, loginAt time.Time) error {
+	query := `
+		UPDATE user_identities
+		SET email = $1, last_login_at = $2
+		WHERE provider = $3 AND subject = $4
+	`
+
+	_, err := r.db.ExecContext(ctx, query, email, loginAt, provider, subject)
+	if err != nil {
+		return fmt.Errorf("failed to update identity: %w", err)
+	}
+
+	return nil
+}
+
+// ListGroupMappings lists the group mappings of a provider, or of all providers if provider is
+// empty
+func (r *IdentityRepository) ListGroupMappings(ctx context.Context, provider string) ([]*models.OIDCGroupMapping, error) {
+	query := `
+		SELECT m.id, m.provider, m.group_name, r.name AS role, m.created_at
+		FROM oidc_group_mappings m
+		JOIN roles r ON r.id = m.role_id
+		WHERE $1 = '' OR m.provider = $1
+		ORDER BY m.provider, m.group_name, r.name
+	`
+
+	mappings := []*models.OIDCGroupMapping{}
+	err := r.db.SelectContext(ctx, &mappings, query, provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group mappings: %w", err)
+	}
+
+	return mappings, nil
+}
+
+// CreateGroupMapping maps a group to a role. It returns ErrNotFound when the role does not
+// exist and ErrMappingExists when the group is already mapped to it.
+func (r *IdentityRepository) CreateGroupMapping(ctx context.Context, mapping *models.OIDCGroupMapping) error {
+	var roleID string
+	err := r.db.GetContext(ctx, &roleID, "SELECT id FROM roles WHERE name = $1", mapping.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get role: %w", err)
+	}
+
+	query := `
+		INSERT INTO oidc_group_mappings (id, provider, group_name, role_id, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, group_name, role_id) DO NOTHING
+	`
+
+	result, err := r.db.ExecContext(ctx, query, mapping.ID, mapping.Provider, mapping.Group, roleID, mapping.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create group mapping: %w", err)
+	}
+
+	if err := expectRow(result, "failed to create group mapping"); err != nil {
+		if err == ErrNotFound {
+			return ErrMappingExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+// DeleteGroupMapping deletes a group mapping. The roles it granted are revoked when their
+// users log in next.
+func (r *IdentityRepository) DeleteGroupMapping(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM oidc_group_mappings WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete group mapping: %w", err)
+	}
+
+	return expectRow(result, "failed to delete group mapping")
+}
+
+// SyncGroupRoles grants a user the roles mapped to the groups they are in at a provider and
+// revokes the roles it granted before through groups they left. Roles the user was given
+// otherwise are left alone.
+func (r *IdentityRepository) SyncGroupRoles(ctx context.Context, userID, provider string, groups []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now().UTC()
+
+	// Revoke the roles granted through groups the user left, or whose mapping was deleted
+	query := `
+		WITH revoked AS (
+			DELETE FROM oidc_role_grants g
+			WHERE g.user_id = $1 AND g.provider = $2 AND NOT EXISTS (
+				SELECT 1 FROM oidc_group_mappings m
+				WHERE m.provider = g.provider AND m.role_id = g.role_id AND m.group_name = ANY($3)
+			)
+			RETURNING g.role_id
+		)
+		DELETE FROM user_roles
+		WHERE user_id = $1 AND role_id IN (SELECT role_id FROM revoked) AND NOT EXISTS (
+			SELECT 1 FROM oidc_role_grants o
+			WHERE o.user_id = $1 AND o.role_id = user_roles.role_id AND o.provider <> $2
+		)
+	`
+	if _, err = tx.ExecContext(ctx, query, userID, provider, pq.Array(groups)); err != nil {
+		return fmt.Errorf("failed to revoke group roles: %w", err)
+	}
+
+	// Record the mapped roles the user does not hold otherwise
+	query = `
+		INSERT INTO oidc_role_grants (user_id, provider, role_id, created_at)
+		SELECT DISTINCT $1::uuid, m.provider, m.role_id, $4::timestamptz
+		FROM oidc_group_mappings m
+		WHERE m.provider = $2 AND m.group_name = ANY($3) AND NOT EXISTS (
+			SELECT 1 FROM user_roles ur WHERE ur.user_id = $1 AND ur.role_id = m.role_id
+		)
+		ON CONFLICT (user_id, provider, role_id) DO NOTHING
+	`
+	if _, err = tx.ExecContext(ctx, query, userID, provider, pq.Array(groups), now); err != nil {
+		return fmt.Errorf("failed to record group roles: %w", err)
+	}
+
+	// Grant the recorded roles, again if they were removed by hand
+	query = `
+		INSERT INTO user_roles (user_id, role_id, created_at)
+		SELECT user_id, role_id, $3
+		FROM oidc_role_grants
+		WHERE user_id = $1 AND provider = $2
+		ON CONFLICT (user_id, role_id) DO NOTHING
+	`
+	if _, err = tx.ExecContext(ctx, query, userID, provider, now); err != nil {
+		return fmt.Errorf("failed to grant group roles: %w", err)
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// LockoutRepository handles database operations for failed login counters and lockout events
+type LockoutRepository struct {
+	db *sqlx.DB
+}
+
+// NewLockoutRepository creates a new lockout repository
+func NewLockoutRepository(db *sqlx.DB) *LockoutRepository {
+	return &LockoutRepository{
+		db: db,
+	}
+}
+
+// BlockedUntil returns the time until which logins are blocked for an account or a client IP,
+// whichever is later. It returns the zero time when neither is blocked.
+func (r *LockoutRepository) BlockedUntil(ctx context.Context, account, ip string) (time.Time, error) {
+	query := `
+		SELECT MAX(blocked_until)
+		FROM login_throttles
+		WHERE (scope = $1 AND subject = $2) OR (scope = $3 AND subject = $4)
+	`
+
+	var blockedUntil sql.NullTime
+	err := r.db.GetContext(ctx, &blockedUntil, query, models.ThrottleScopeAccount, account, models.ThrottleScopeIP, ip)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get login throttle: %w", err)
+	}
+
+	return blockedUntil.Time, nil
+}
+
+// RecordFailure counts a failed login and returns the number of failures so far. Counting
+// starts over when the previous failure was before resetBefore. Concurrent failures are all
+// counted.
+func (r *LockoutRepository) RecordFailure(ctx context.Context, scope, subject string, now, resetBefore time.Time) (int, error) {
+	query := `
+		INSERT INTO login_throttles (scope, subject, failures, last_failure_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, subject) DO UPDATE
+		SET failures = CASE WHEN login_throttles.last_failure_at < $4 THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure_at = $3
+		RETURNING failures
+	`
+
+	var failures int
+	if err := r.db.GetContext(ctx, &failures, query, scope, subject, now, resetBefore); err != nil {
+		return 0, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return failures, nil
+}
+
+// Block blocks logins for an account or a client IP until the given time
+func (r *LockoutRepository) Block(ctx context.Context, scope, subject string, until time.Time) error {
+	query := `
+		UPDATE login_throttles
+		SET blocked_until = GREATEST(blocked_until, $1)
+		WHERE scope = $2 AND subject = $3
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, until, scope, subject); err != nil {
+		return fmt.Errorf("failed to block logins: %w", err)
+	}
+
+	return nil
+}
+
+// Reset clears the failed logins of an account or a client IP and unblocks it. It returns
+// whether there was anything to clear.
+func (r *LockoutRepository) Reset(ctx context.Context, scope, subject string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM login_throttles WHERE scope = $1 AND subject = $2", scope, subject)
+	if err != nil {
+		return false, fmt.Errorf("failed to reset login throttle: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to reset login throttle: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// DeleteStale deletes the counters whose last failure was before the given time and that
+// are no longer blocked, and returns how many were deleted
+func (r *LockoutRepository) DeleteStale(ctx context.Context, before, now time.Time) (int64, error) {
+	query := `
+		DELETE FROM login_throttles
+		WHERE last_failure_at < $1 AND (blocked_until IS NULL OR blocked_until < $2)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, before, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale login throttles: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale login throttles: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// CreateEvent records a lockout event
+func (r *LockoutRepository) CreateEvent(ctx context.Context, event *models.LockoutEvent) error {
+	query := `
+		INSERT INTO login_lockout_events (id, scope, subject, user_id, event, failures, blocked_until, actor_id, created_at)
+		VALUES (:id, :scope, :subject, :user_id, :event, :failures, :blocked_until, :actor_id, :created_at)
+	`
+
+	if _, err := r.db.NamedExecContext(ctx, query, event); err != nil {
+		return fmt.Errorf("failed to create lockout event: %w", err)
+	}
+
+	return nil
+}
+
+// ListEvents retrieves the lockout events of a user, most recent first
+func (r *LockoutRepository) ListEvents(ctx context.Context, userID string, limit, offset int) ([]*models.LockoutEvent, error) {
+	query := `
+		SELECT id, scope, subject, user_id, event, failures, blocked_until, actor_id, created_at
+		FROM login_lockout_events
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	events := []*models.LockoutEvent{}
+	if err := r.db.SelectContext(ctx, &events, query, userID, limit, offset); err != nil {
+		return nil, fmt.Errorf("failed to list lockout events: %w", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// MFARepository handles database operations for TOTP secrets and recovery codes
+type MFARepository struct {
+	db *sqlx.DB
+}
+
+// NewMFARepository creates a new MFA repository
+func NewMFARepository(db *sqlx.DB) *MFARepository {
+	return &MFARepository{
+		db: db,
+	}
+}
+
+// SetPendingSecret stores the TOTP secret of a user who starts enrolling. It returns
+// ErrNotFound when the user already enabled MFA.
+func (r *MFARepository) SetPendingSecret(ctx context.Context, userID, secret string) error {
+	query := `
+		UPDATE users
+		SET mfa_secret = $1, mfa_last_step = NULL, updated_at = $2
+		WHERE id = $3 AND mfa_enabled_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, secret, time.Now().UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set MFA secret: %w", err)
+	}
+
+	return expectRow(result, "failed to set MFA secret")
+}
+
+// Enable enables MFA for a user with a pending secret and replaces their recovery codes
+func (r *MFARepository) Enable(ctx context.Context, userID string, step int64, codeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now().UTC()
+	query := `
+		UPDATE users
+		SET mfa_enabled_at = $1, mfa_last_step = $2, updated_at = $1
+		WHERE id = $3 AND mfa_secret IS NOT NULL AND mfa_enabled_at IS NULL
+	`
+	result, err := tx.ExecContext(ctx, query, now, step, userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable MFA: %w", err)
+	}
+	if err = expectRow(result, "failed to enable MFA"); err != nil {
+		return err
+	}
+
+	if err = replaceRecoveryCodes(ctx, tx, userID, codeHashes, now); err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Disable removes the TOTP secret and recovery codes of a user
+func (r *MFARepository) Disable(ctx context.Context, userID string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE users
+		SET mfa_secret = NULL, mfa_enabled_at = NULL, mfa_last_step = NULL, updated_at = $1
+		WHERE id = $2
+	`
+	if _, err = tx.ExecContext(ctx, query, time.Now().UTC(), userID); err != nil {
+		return fmt.Errorf("failed to disable MFA: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SetRequired sets whether a user has to use MFA
+func (r *MFARepository) SetRequired(ctx context.Context, userID string, required bool) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET mfa_required = $1, updated_at = $2 WHERE id = $3", required, time.Now().UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set MFA requirement: %w", err)
+	}
+
+	return expectRow(result, "failed to set MFA requirement")
+}
+
+// UseStep records that a TOTP code of the given time step was used. It returns ErrNotFound
+// when a code of the same or a later step was already used, which means the code is replayed.
+func (r *MFARepository) UseStep(ctx context.Context, userID string, step int64) error {
+	query := `
+		UPDATE users
+		SET mfa_last_step = $1
+		WHERE id = $2 AND (mfa_last_step IS NULL OR mfa_last_step < $1)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, step, userID)
+	if err != nil {
+		return fmt.Errorf("failed to use TOTP code: %w", err)
+	}
+
+	return expectRow(result, "failed to use TOTP code")
+}
+
+// UseRecoveryCode marks an unused recovery code of a user as used. It returns ErrNotFound when
+// the user has no such code.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	return expectRow(result, "failed to use recovery code")
+}
+
+// ReplaceRecoveryCodes replaces the recovery codes of a user
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = replaceRecoveryCodes(ctx, tx, userID, codeHashes, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left
+func (r *MFARepository) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL", userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return count, nil
+}
+
+// replaceRecoveryCodes deletes the recovery codes of a user and inserts new ones
+func replaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userID string, codeHashes []string, now time.Time) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, codeHash := range codeHashes {
+		_, err := tx.ExecContext(ctx, "INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at) VALUES ($1, $2, $3, $4)", uuid.New().String(), userID, codeHash, now)
+		if err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// expectRow returns ErrNotFound when a statement affected no rows
+func expectRow(result sql.Result, message string) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", message, err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
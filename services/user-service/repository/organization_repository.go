@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/permissions"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrAlreadyMember is returned when a user who is already a member is added to an organization
+	ErrAlreadyMember = errors.New("user is already a member")
+	// ErrLastOwner is returned when a change would leave an organization without an owner
+	ErrLastOwner = errors.New("organization must keep an owner")
+)
+
+// memberColumns are the columns of a member joined with their user
+const memberColumns = "m.organization_id, m.user_id, u.username, u.email, m.role, m.created_at, m.updated_at"
+
+// OrganizationRepository handles database operations for organizations and their members
+type OrganizationRepository struct {
+	db *sqlx.DB
+}
+
+// NewOrganizationRepository creates a new organization repository
+func NewOrganizationRepository(db *sqlx.DB) *OrganizationRepository {
+	return &OrganizationRepository{
+		db: db,
+	}
+}
+
+// CreateOrganization creates an organization with the given user as its owner
+func (r *OrganizationRepository) CreateOrganization(ctx context.Context, org *models.Organization, ownerID string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO organizations (id, name, created_by, created_at, updated_at)
+		VALUES (:id, :name, :created_by, :created_at, :updated_at)
+	`
+	if _, err = tx.NamedExecContext(ctx, query, org); err != nil {
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	query = `
+		INSERT INTO organization_members (organization_id, user_id, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+	`
+	if _, err = tx.ExecContext(ctx, query, org.ID, ownerID, permissions.OrgRoleOwner, org.CreatedAt); err != nil {
+		return fmt.Errorf("failed to add organization owner: %w", err)
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	org.Role = permissions.OrgRoleOwner
+	return nil
+}
+
+// GetOrganization retrieves an organization with the role of a user in it. It returns
+// ErrNotFound when the user is not a member.
+func (r *OrganizationRepository) GetOrganization(ctx context.Context, id, userID string) (*models.Organization, error) {
+	query := `
+		SELECT o.id, o.name, o.created_by, o.created_at, o.updated_at, m.role
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE o.id = $1 AND m.user_id = $2
+	`
+
+	var org models.Organization
+	if err := r.db.GetContext(ctx, &org, query, id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return &org, nil
+}
+
+// ListOrganizations lists the organizations a user belongs to with their role in each
+func (r *OrganizationRepository) ListOrganizations(ctx context.Context, userID string) ([]*models.Organization, error) {
+	query := `
+		SELECT o.id, o.name, o.created_by, o.created_at, o.updated_at, m.role
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY o.name, o.id
+	`
+
+	orgs := []*models.Organization{}
+	if err := r.db.SelectContext(ctx, &orgs, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	return orgs, nil
+}
+
+// RenameOrganization changes the name of an organization
+func (r *OrganizationRepository) RenameOrganization(ctx context.Context, id, name string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE organizations SET name = $1, updated_at = $2 WHERE id = $3", name, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to rename organization: %w", err)
+	}
+
+	return expectRow(result, "failed to rename organization")
+}
+
+// GetMember retrieves a member of an organization. It returns ErrNotFound when the user is not
+// a member.
+func (r *OrganizationRepository) GetMember(ctx context.Context, orgID, userID string) (*models.OrganizationMember, error) {
+	query := `
+		SELECT ` + memberColumns + `
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1 AND m.user_id = $2
+	`
+
+	var member models.OrganizationMember
+	if err := r.db.GetContext(ctx, &member, query, orgID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization member: %w", err)
+	}
+
+	return &member, nil
+}
+
+// ListMembers lists the members of an organization, most privileged first
+func (r *OrganizationRepository) ListMembers(ctx context.Context, orgID string) ([]*models.OrganizationMember, error) {
+	query := `
+		SELECT ` + memberColumns + `
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 WHEN 'editor' THEN 2 ELSE 3 END, u.username
+	`
+
+	members := []*models.OrganizationMember{}
+	if err := r.db.SelectContext(ctx, &members, query, orgID); err != nil {
+		return nil, fmt.Errorf("failed to list organization members: %w", err)
+	}
+
+	return members, nil
+}
+
+// AddMember adds a user to an organization. It returns ErrAlreadyMember when the user already
+// belongs to it.
+func (r *OrganizationRepository) AddMember(ctx context.Context, orgID, userID, role string) error {
+	query := `
+		INSERT INTO organization_members (organization_id, user_id, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (organization_id, user_id) DO NOTHING
+	`
+
+	result, err := r.db.ExecContext(ctx, query, orgID, userID, role, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to add organization member: %w", err)
+	}
+
+	if err := expectRow(result, "failed to add organization member"); err != nil {
+		if err == ErrNotFound {
+			return ErrAlreadyMember
+		}
+		return err
+	}
+
+	return nil
+}
+
+// UpdateMemberRole changes the role of a member. It returns ErrLastOwner when that would leave
+// the organization without an owner.
+func (r *OrganizationRepository) UpdateMemberRole(ctx context.Context, orgID, userID, role string) error {
+	return r.changeMembers(ctx, orgID, func(tx *sqlx.Tx) (sql.Result, error) {
+		return tx.ExecContext(ctx, "UPDATE organization_members SET role = $1, updated_at = $2 WHERE organization_id = $3 AND user_id = $4", role, time.Now().UTC(), orgID, userID)
+	})
+}
+
+// RemoveMember removes a user from an organization. It returns ErrLastOwner when that would
+// leave the organization without an owner.
+func (r *OrganizationRepository) RemoveMember(ctx context.Context, orgID, userID string) error {
+	return r.changeMembers(ctx, orgID, func(tx *sqlx.Tx) (sql.Result, error) {
+		return tx.ExecContext(ctx, "DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2", orgID, userID)
+	})
+}
+
+// changeMembers runs a change to a single member and rolls it back when the organization is
+// left without an owner. Changes to the same organization are serialized by locking it, so
+// that two owners cannot demote each other at the same time.
+func (r *OrganizationRepository) changeMembers(ctx context.Context, orgID string, change func(tx *sqlx.Tx) (sql.Result, error)) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, "SELECT id FROM organizations WHERE id = $1 FOR UPDATE", orgID); err != nil {
+		return fmt.Errorf("failed to lock organization: %w", err)
+	}
+
+	result, err := change(tx)
+	if err != nil {
+		return fmt.Errorf("failed to change organization member: %w", err)
+	}
+	if err = expectRow(result, "failed to change organization member"); err != nil {
+		return err
+	}
+
+	var owners int
+	err = tx.GetContext(ctx, &owners, "SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = $2", orgID, permissions.OrgRoleOwner)
+	if err != nil {
+		return fmt.Errorf("failed to count organization owners: %w", err)
+	}
+	if owners == 0 {
+		err = ErrLastOwner
+		return err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
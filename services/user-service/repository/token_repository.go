@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrNotFound is returned when a token does not exist
+var ErrNotFound = errors.New("not found")
+
+// refreshTokenColumns are the columns selected for a refresh token
+const refreshTokenColumns = `
+	id, user_id, family_id, token_hash, access_token_id, replaced_by, revoked_at, expires_at, created_at
+`
+
+// userTokenColumns are the columns selected for a user token
+const userTokenColumns = `
+	id, user_id, purpose, token_hash, email, expires_at, used_at, created_at
+`
+
+// TokenRepository handles database operations for refresh tokens, revoked access tokens and
+// single-use tokens sent by email
+type TokenRepository struct {
+	db *sqlx.DB
+}
+
+// NewTokenRepository creates a new token repository
+func NewTokenRepository(db *sqlx.DB) *TokenRepository {
+	return &TokenRepository{
+		db: db,
+	}
+}
+
+// CreateRefreshToken stores a refresh token
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return insertRefreshToken(ctx, r.db, token)
+}
+
+// GetRefreshToken retrieves a refresh token by the hash of the token
+func (r *TokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token_hash = $1`
+
+	var token models.RefreshToken
+	err := r.db.GetContext(ctx, &token, query, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// RotateRefreshToken replaces the active refresh token with the given hash by the next token
+// of its family, which gets the user and family of the replaced token. Concurrent callers
+// never rotate the same token. It returns ErrNotFound when no active token has the hash.
+func (r *TokenRepository) RotateRefreshToken(ctx context.Context, tokenHash string, next *models.RefreshToken) (*models.RefreshToken, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE refresh_tokens
+		SET replaced_by = $1
+		WHERE token_hash = $2 AND replaced_by IS NULL AND revoked_at IS NULL AND expires_at > $3
+		RETURNING ` + refreshTokenColumns
+
+	var current models.RefreshToken
+	err = tx.GetContext(ctx, &current, query, next.ID, tokenHash, next.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrNotFound
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	next.UserID = current.UserID
+	next.FamilyID = current.FamilyID
+	if err = insertRefreshToken(ctx, tx, next); err != nil {
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &current, nil
+}
+
+// RevokeFamily revokes every refresh token of a family and the access tokens issued with
+// them that may not have expired yet, given the lifetime of access tokens
+func (r *TokenRepository) RevokeFamily(ctx context.Context, familyID string, accessTokenTTL time.Duration) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now().UTC()
+	_, err = tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL", now, familyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	query := `
+		INSERT INTO revoked_tokens (token_id, user_id, expires_at, revoked_at)
+		SELECT access_token_id, user_id, created_at + make_interval(secs => $1), $2
+		FROM refresh_tokens
+		WHERE family_id = $3 AND created_at + make_interval(secs => $1) > $2
+		ON CONFLICT (token_id) DO NOTHING
+	`
+	_, err = tx.ExecContext(ctx, query, accessTokenTTL.Seconds(), now, familyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAccessToken revokes a single access token until it expires
+func (r *TokenRepository) RevokeAccessToken(ctx context.Context, tokenID, userID string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (token_id, user_id, expires_at, revoked_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (token_id) DO NOTHING
+	`
+
+	_, err := r.db.ExecContext(ctx, query, tokenID, userID, expiresAt, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeUserTokens revokes every refresh token of a user and every access token issued to
+// them before now
+func (r *TokenRepository) RevokeUserTokens(ctx context.Context, userID string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Tokens carry their issue time in whole seconds, so the cutoff is the start of the next
+	// second to also revoke the tokens issued earlier within the second of the revocation.
+	// Tokens issued later within that second are revoked as well.
+	now := time.Now().UTC()
+	cutoff := now.Truncate(time.Second).Add(time.Second)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET tokens_revoked_before = $1 WHERE id = $2", cutoff, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL", now, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether an access token was revoked by its ID or by revoking
+// all tokens its user was issued before it
+func (r *TokenRepository) IsAccessTokenRevoked(ctx context.Context, tokenID, userID string, issuedAt time.Time) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE token_id = $1)
+			OR EXISTS (SELECT 1 FROM users WHERE id = $2 AND tokens_revoked_before > $3)
+	`
+
+	var revoked bool
+	err := r.db.GetContext(ctx, &revoked, query, tokenID, userID, issuedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// CreateUserToken stores a single-use token and invalidates the unused tokens of the same user
+// and purpose, so that only the most recently sent token works
+func (r *TokenRepository) CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, "UPDATE user_tokens SET used_at = $1 WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL", token.CreatedAt, token.UserID, token.Purpose)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate user tokens: %w", err)
+	}
+
+	query := `
+		INSERT INTO user_tokens (id, user_id, purpose, token_hash, email, expires_at, created_at)
+		VALUES (:id, :user_id, :purpose, :token_hash, :email, :expires_at, :created_at)
+	`
+	if _, err = sqlx.NamedExecContext(ctx, tx, query, token); err != nil {
+		return fmt.Errorf("failed to create user token: %w", err)
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserToken retrieves the unused, unexpired token with the given purpose and hash without
+// using it. It returns ErrNotFound when no such token exists.
+func (r *TokenRepository) GetUserToken(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error) {
+	query := `
+		SELECT ` + userTokenColumns + `
+		FROM user_tokens
+		WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > $3
+	`
+
+	var token models.UserToken
+	err := r.db.GetContext(ctx, &token, query, purpose, tokenHash, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get user token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// ConsumeUserToken marks the unused, unexpired token with the given purpose and hash as used
+// and returns it. Concurrent callers never consume the same token. It returns ErrNotFound
+// when no such token exists.
+func (r *TokenRepository) ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error) {
+	query := `
+		UPDATE user_tokens
+		SET used_at = $1
+		WHERE purpose = $2 AND token_hash = $3 AND used_at IS NULL AND expires_at > $1
+		RETURNING ` + userTokenColumns
+
+	var token models.UserToken
+	err := r.db.GetContext(ctx, &token, query, time.Now().UTC(), purpose, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to consume user token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// DeleteExpiredTokens deletes refresh tokens, revoked access tokens and user tokens that
+// expired before the given time and returns how many were deleted
+func (r *TokenRepository) DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	for _, table := range []string{"refresh_tokens", "revoked_tokens", "user_tokens"} {
+		result, err := r.db.ExecContext(ctx, "DELETE FROM "+table+" WHERE expires_at <= $1", before)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete expired %s: %w", table, err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete expired %s: %w", table, err)
+		}
+		deleted += rows
+	}
+
+	return deleted, nil
+}
+
+// insertRefreshToken inserts a refresh token with the given executor
+func insertRefreshToken(ctx context.Context, db sqlx.ExtContext, token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, access_token_id, expires_at, created_at)
+		VALUES (:id, :user_id, :family_id, :token_hash, :access_token_id, :expires_at, :created_at)
+	`
+
+	_, err := sqlx.NamedExecContext(ctx, db, query, token)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
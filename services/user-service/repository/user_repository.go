@@ -62,7 +62,8 @@ func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) erro
 // GetUserByID retrieves a user by ID
 func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, first_name, last_name, created_at, updated_at
+		SELECT id, username, email, password_hash, first_name, last_name, created_at, updated_at, email_verified_at,
+			mfa_secret, mfa_enabled_at, mfa_required
 		FROM users
 		WHERE id = $1
 	`
@@ -76,12 +77,10 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.Us
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	// Get user roles
-	roles, err := r.GetUserRoles(ctx, id)
-	if err != nil {
+	// Get user roles and permissions
+	if err := r.loadAccess(ctx, &user); err != nil {
 		return nil, err
 	}
-	user.Roles = roles
 
 	return &user, nil
 }
@@ -89,7 +88,8 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.Us
 // GetUserByUsername retrieves a user by username
 func (r *UserRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, first_name, last_name, created_at, updated_at
+		SELECT id, username, email, password_hash, first_name, last_name, created_at, updated_at, email_verified_at,
+			mfa_secret, mfa_enabled_at, mfa_required
 		FROM users
 		WHERE username = $1
 	`
@@ -103,12 +103,10 @@ func (r *UserRepository) GetUserByUsername(ctx context.Context, username string)
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	// Get user roles
-	roles, err := r.GetUserRoles(ctx, user.ID)
-	if err != nil {
+	// Get user roles and permissions
+	if err := r.loadAccess(ctx, &user); err != nil {
 		return nil, err
 	}
-	user.Roles = roles
 
 	return &user, nil
 }
@@ -116,7 +114,8 @@ func (r *UserRepository) GetUserByUsername(ctx context.Context, username string)
 // GetUserByEmail retrieves a user by email
 func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, first_name, last_name, created_at, updated_at
+		SELECT id, username, email, password_hash, first_name, last_name, created_at, updated_at, email_verified_at,
+			mfa_secret, mfa_enabled_at, mfa_required
 		FROM users
 		WHERE email = $1
 	`
@@ -130,12 +129,10 @@ func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*mod
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	// Get user roles
-	roles, err := r.GetUserRoles(ctx, user.ID)
-	if err != nil {
+	// Get user roles and permissions
+	if err := r.loadAccess(ctx, &user); err != nil {
 		return nil, err
 	}
-	user.Roles = roles
 
 	return &user, nil
 }
@@ -143,7 +140,8 @@ func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*mod
 // ListUsers retrieves a list of users with pagination
 func (r *UserRepository) ListUsers(ctx context.Context, limit, offset int) ([]*models.User, error) {
 	query := `
-		SELECT id, username, email, first_name, last_name, created_at, updated_at
+		SELECT id, username, email, first_name, last_name, created_at, updated_at, email_verified_at,
+			mfa_enabled_at, mfa_required
 		FROM users
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
@@ -155,30 +153,31 @@ func (r *UserRepository) ListUsers(ctx context.Context, limit, offset int) ([]*m
 		return nil, fmt.Errorf("failed to list users: %w", err)
 	}
 
-	// Get roles for each user
+	// Get roles and permissions for each user
 	for _, user := range users {
-		roles, err := r.GetUserRoles(ctx, user.ID)
-		if err != nil {
+		if err := r.loadAccess(ctx, user); err != nil {
 			return nil, err
 		}
-		user.Roles = roles
 	}
 
 	return users, nil
 }
 
-// UpdateUser updates a user in the database
+// UpdateUser updates a user in the database. Changing the email clears its verification.
 func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) error {
 	user.UpdatedAt = time.Now().UTC()
 
 	query := `
 		UPDATE users
-		SET username = $1, email = $2, first_name = $3, last_name = $4, updated_at = $5
+		SET username = $1, email = $2, first_name = $3, last_name = $4, updated_at = $5,
+			email_verified_at = CASE WHEN email = $2 THEN email_verified_at END
 		WHERE id = $6
+		RETURNING email_verified_at
 	`
 
-	_, err := r.db.ExecContext(
+	err := r.db.GetContext(
 		ctx,
+		&user.EmailVerifiedAt,
 		query,
 		user.Username,
 		user.Email,
@@ -195,6 +194,31 @@ func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) erro
 	return nil
 }
 
+// MarkEmailVerified marks the email of a user as verified, provided it is still the given
+// email. It returns ErrNotFound when the user changed their email in the meantime.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, userID, email string) error {
+	query := `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, $1)
+		WHERE id = $2 AND email = $3
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UTC(), userID, email)
+	if err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
 // UpdatePassword updates a user's password
 func (r *UserRepository) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
 	query := `
@@ -323,6 +347,42 @@ func (r *UserRepository) GetUserRoles(ctx context.Context, userID string) ([]str
 	return roles, nil
 }
 
+// GetUserPermissions gets the permissions granted by the roles of a user
+func (r *UserRepository) GetUserPermissions(ctx context.Context, userID string) ([]string, error) {
+	query := `
+		SELECT DISTINCT rp.permission
+		FROM role_permissions rp
+		JOIN user_roles ur ON rp.role_id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY rp.permission
+	`
+
+	permissions := []string{}
+	err := r.db.SelectContext(ctx, &permissions, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user permissions: %w", err)
+	}
+
+	return permissions, nil
+}
+
+// loadAccess sets the roles and permissions of a user
+func (r *UserRepository) loadAccess(ctx context.Context, user *models.User) error {
+	roles, err := r.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	user.Roles = roles
+
+	permissions, err := r.GetUserPermissions(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	user.Permissions = permissions
+
+	return nil
+}
+
 // CreateRole creates a new role in the database
 func (r *UserRepository) CreateRole(ctx context.Context, role *models.Role) error {
 	if role.ID == "" {
@@ -368,5 +428,88 @@ func (r *UserRepository) GetRoles(ctx context.Context) ([]*models.Role, error) {
 		return nil, fmt.Errorf("failed to get roles: %w", err)
 	}
 
+	// Get the permissions of all roles
+	var grants []struct {
+		RoleID     string `db:"role_id"`
+		Permission string `db:"permission"`
+	}
+	err = r.db.SelectContext(ctx, &grants, "SELECT role_id, permission FROM role_permissions ORDER BY permission")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+
+	byID := make(map[string]*models.Role, len(roles))
+	for _, role := range roles {
+		role.Permissions = []string{}
+		byID[role.ID] = role
+	}
+	for _, grant := range grants {
+		if role, ok := byID[grant.RoleID]; ok {
+			role.Permissions = append(role.Permissions, grant.Permission)
+		}
+	}
+
 	return roles, nil
 }
+
+// GetPermissions gets all permissions
+func (r *UserRepository) GetPermissions(ctx context.Context) ([]*models.Permission, error) {
+	var permissions []*models.Permission
+	err := r.db.SelectContext(ctx, &permissions, "SELECT name, description FROM permissions ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+
+	return permissions, nil
+}
+
+// SetRolePermissions replaces the permissions of a role and returns the permissions it replaced.
+// It returns ErrNotFound when the role does not exist.
+func (r *UserRepository) SetRolePermissions(ctx context.Context, roleName string, permissions []string) ([]string, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rollback in case of error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var roleID string
+	err = tx.GetContext(ctx, &roleID, "SELECT id FROM roles WHERE name = $1", roleName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrNotFound
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get role ID: %w", err)
+	}
+
+	previous := []string{}
+	err = tx.SelectContext(ctx, &previous, "SELECT permission FROM role_permissions WHERE role_id = $1 ORDER BY permission", roleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role_id = $1", roleID); err != nil {
+		return nil, fmt.Errorf("failed to delete role permissions: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for _, permission := range permissions {
+		_, err = tx.ExecContext(ctx, "INSERT INTO role_permissions (role_id, permission, created_at) VALUES ($1, $2, $3)", roleID, permission, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add permission to role: %w", err)
+		}
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return previous, nil
+}
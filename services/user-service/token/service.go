@@ -0,0 +1,235 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/errors"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/jwks"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/logging"
+	"github.com/VitaliySynytskyi/pollpulse/pkg/common/middleware"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/models"
+	"github.com/VitaliySynytskyi/pollpulse/services/user-service/repository"
+	"github.com/google/uuid"
+)
+
+// secretBytes is the number of random bytes in a refresh token or other secret token
+const secretBytes = 32
+
+// Service issues access tokens together with rotating refresh tokens and revokes them.
+// It implements middleware.RevocationChecker.
+type Service struct {
+	repo       *repository.TokenRepository
+	users      *repository.UserRepository
+	logger     *logging.Logger
+	keys       *jwks.KeySet
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// NewService creates a new token service. Access tokens are valid for accessTTL and refresh
+// tokens for refreshTTL.
+func NewService(repo *repository.TokenRepository, users *repository.UserRepository, logger *logging.Logger, keys *jwks.KeySet, accessTTL, refreshTTL time.Duration) *Service {
+	return &Service{
+		repo:       repo,
+		users:      users,
+		logger:     logger,
+		keys:       keys,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// Issue issues tokens for a user who just logged in, starting a new refresh token family
+func (s *Service) Issue(ctx context.Context, user *models.User) (*models.TokenPair, error) {
+	refresh, secret, err := s.newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	refresh.UserID = user.ID
+	refresh.FamilyID = uuid.New().String()
+
+	claims := s.newClaims(user, refresh)
+	if err := s.repo.CreateRefreshToken(ctx, refresh); err != nil {
+		return nil, err
+	}
+
+	return s.pair(claims, secret)
+}
+
+// Refresh exchanges a refresh token for new tokens. Using a refresh token that was already
+// exchanged revokes its whole family, since either its owner or an attacker holds a stolen
+// copy.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, *models.User, error) {
+	next, secret, err := s.newRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokenHash := HashSecret(refreshToken)
+	if _, err := s.repo.RotateRefreshToken(ctx, tokenHash, next); err != nil {
+		if err != repository.ErrNotFound {
+			return nil, nil, err
+		}
+		if err := s.detectReuse(ctx, tokenHash); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, errors.NewError(errors.ErrUnauthorized, "invalid refresh token")
+	}
+
+	// Load the user again so that the new access token has their current roles
+	user, err := s.users.GetUserByID(ctx, next.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pair, err := s.pair(s.newClaims(user, next), secret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pair, user, nil
+}
+
+// Logout revokes the access token of the request and, if given, the refresh token family of
+// the same session
+func (s *Service) Logout(ctx context.Context, claims *middleware.UserClaims, refreshToken string) error {
+	if refreshToken != "" {
+		token, err := s.repo.GetRefreshToken(ctx, HashSecret(refreshToken))
+		if err != nil && err != repository.ErrNotFound {
+			return err
+		}
+		if err == nil && token.UserID == claims.UserID {
+			if err := s.repo.RevokeFamily(ctx, token.FamilyID, s.accessTTL); err != nil {
+				return err
+			}
+		}
+	}
+
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	return s.repo.RevokeAccessToken(ctx, claims.ID, claims.UserID, claims.ExpiresAt.Time)
+}
+
+// LogoutEverywhere revokes every token issued to a user so far
+func (s *Service) LogoutEverywhere(ctx context.Context, userID string) error {
+	return s.repo.RevokeUserTokens(ctx, userID)
+}
+
+// IsRevoked reports whether an access token was revoked
+func (s *Service) IsRevoked(ctx context.Context, claims *middleware.UserClaims) (bool, error) {
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	return s.repo.IsAccessTokenRevoked(ctx, claims.ID, claims.UserID, issuedAt)
+}
+
+// RunCleanup deletes expired tokens every interval until the context is canceled
+func (s *Service) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.repo.DeleteExpiredTokens(ctx, time.Now().UTC())
+			if err != nil && ctx.Err() == nil {
+				s.logger.Error("Failed to delete expired tokens", "error", err)
+			}
+			if deleted > 0 {
+				s.logger.Info("Deleted expired tokens", "count", deleted)
+			}
+		}
+	}
+}
+
+// detectReuse revokes the family of a refresh token that was already exchanged
+func (s *Service) detectReuse(ctx context.Context, tokenHash string) error {
+	token, err := s.repo.GetRefreshToken(ctx, tokenHash)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	if token.ReplacedBy == nil || token.RevokedAt != nil {
+		return nil
+	}
+
+	s.logger.Warn("Refresh token reused, revoking its family", "user_id", token.UserID, "family_id", token.FamilyID)
+	if err := s.repo.RevokeFamily(ctx, token.FamilyID, s.accessTTL); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// newRefreshToken creates a refresh token and returns it together with the secret handed
+// to the client, of which only the hash is stored
+func (s *Service) newRefreshToken() (*models.RefreshToken, string, error) {
+	secret, err := NewSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	token := &models.RefreshToken{
+		ID:            uuid.New().String(),
+		TokenHash:     HashSecret(secret),
+		AccessTokenID: uuid.New().String(),
+		ExpiresAt:     now.Add(s.refreshTTL),
+		CreatedAt:     now,
+	}
+
+	return token, secret, nil
+}
+
+// newClaims creates the claims of the access token issued together with a refresh token
+func (s *Service) newClaims(user *models.User, refresh *models.RefreshToken) *middleware.UserClaims {
+	claims := middleware.NewUserClaims(user.ID, user.Username, user.Email, user.Roles, s.accessTTL)
+	claims.ID = refresh.AccessTokenID
+	claims.Permissions = user.Permissions
+	claims.EmailVerified = user.EmailVerified()
+	return claims
+}
+
+// pair signs the access token and pairs it with the refresh token secret
+func (s *Service) pair(claims *middleware.UserClaims, refreshToken string) (*models.TokenPair, error) {
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresAt:    claims.ExpiresAt.Time,
+	}, nil
+}
+
+// NewSecret generates a random, URL safe token secret
+func NewSecret() (string, error) {
+	buf := make([]byte, secretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashSecret returns the hex encoded SHA-256 hash of a token secret, which is stored instead
+// of the secret itself
+func HashSecret(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}